@@ -0,0 +1,50 @@
+package main
+
+import (
+	"crypto/subtle"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/s3nkyh/arcticeroute/api"
+)
+
+// adminAuth пропускает запрос с токеном ADMIN_TOKEN в заголовке
+// "Authorization: Bearer <token>". Без ADMIN_TOKEN админка отключена.
+func adminAuth(c *gin.Context) {
+	token := os.Getenv("ADMIN_TOKEN")
+	if token == "" {
+		c.AbortWithStatusJSON(403, gin.H{"error": "admin endpoints are disabled: ADMIN_TOKEN is not set"})
+		return
+	}
+	got := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		c.AbortWithStatusJSON(401, gin.H{"error": "invalid admin token"})
+		return
+	}
+	c.Next()
+}
+
+func getSubscription(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"config":      aisSubscription.Config(),
+		"api_key_set": aisSubscription.HasAPIKey(),
+	})
+}
+
+func updateSubscription(c *gin.Context) {
+	var cfg api.SubscriptionConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		c.JSON(400, gin.H{"error": "invalid subscription: " + err.Error()})
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := aisSubscription.Update(cfg); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	getSubscription(c)
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/s3nkyh/arcticeroute/api"
+	"github.com/s3nkyh/arcticeroute/models"
+	"github.com/s3nkyh/arcticeroute/service"
+)
+
+// runIngest передает позиции из источника AIS в хранилище.
+// Живой поток переподключается при обрыве, запись воспроизводится один раз.
+func runIngest() {
+	for {
+		src, err := api.OpenSource(aisSubscription)
+		if err == nil {
+			err = api.Ingest(src, api.IngestHandler{
+				Position: func(ship models.Ship) bool {
+					ship.Name = vesselRegistry.DisplayName(ship.MMSI, ship.Name)
+					shipStore.Update(ship)
+					return true
+				},
+				Static: func(static models.ShipStatic) bool {
+					shipStore.UpdateStatic(static)
+					vesselRegistry.Observe(static)
+					return true
+				},
+			})
+			src.Close()
+		}
+
+		if api.ReplayConfigured() {
+			if err != nil {
+				log.Println("AIS replay error:", err)
+			}
+			log.Println("AIS replay finished")
+			return
+		}
+
+		if errors.Is(err, api.ErrNoAPIKey) {
+			return
+		}
+		log.Println("AIS stream error:", err)
+		time.Sleep(10 * time.Second)
+	}
+}
+
+// estimateTime возвращает момент оценки из параметра at (RFC3339) или часы хранилища
+func estimateTime(c *gin.Context) (time.Time, bool) {
+	v := c.Query("at")
+	if v == "" {
+		return shipStore.Now(), true
+	}
+	at, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid at: " + err.Error()})
+		return time.Time{}, false
+	}
+	return at, true
+}
+
+func getShips(c *gin.Context) {
+	at, ok := estimateTime(c)
+	if !ok {
+		return
+	}
+
+	estimates := shipStore.Estimates(at)
+	if id := c.Query("fleet"); id != "" {
+		members, found := fleetStore.Members(id)
+		if !found {
+			c.JSON(404, gin.H{"error": "fleet not found"})
+			return
+		}
+		filtered := make([]models.ShipEstimate, 0, len(members))
+		for _, estimate := range estimates {
+			if members[estimate.MMSI] {
+				filtered = append(filtered, estimate)
+			}
+		}
+		estimates = filtered
+	}
+	bbox, ok := queryBBox(c, nil)
+	if !ok {
+		return
+	}
+	if bbox != nil {
+		filtered := make([]models.ShipEstimate, 0, len(estimates))
+		for _, estimate := range estimates {
+			if bbox.Contains(estimate.PredictedLatitude, estimate.PredictedLongitude) {
+				filtered = append(filtered, estimate)
+			}
+		}
+		estimates = filtered
+	}
+	if query := c.Query("flag"); query != "" {
+		flag, found := service.LookupFlag(query)
+		if !found {
+			c.JSON(400, gin.H{"error": "unknown flag"})
+			return
+		}
+		// Флаг определен по MID при приеме позиции
+		filtered := make([]models.ShipEstimate, 0, len(estimates))
+		for _, estimate := range estimates {
+			if estimate.Flag == flag.ISO {
+				filtered = append(filtered, estimate)
+			}
+		}
+		estimates = filtered
+	}
+	c.JSON(200, estimates)
+}
+
+// shipDetail - позиция судна с действующими ледовыми предупреждениями
+type shipDetail struct {
+	models.ShipEstimate
+	IceWarnings []service.IceWarning `json:"ice_warnings"`
+}
+
+func getShip(c *gin.Context) {
+	mmsi, err := strconv.ParseInt(c.Param("mmsi"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid mmsi"})
+		return
+	}
+	at, ok := estimateTime(c)
+	if !ok {
+		return
+	}
+
+	estimate, found := shipStore.Estimate(int32(mmsi), at)
+	if !found {
+		c.JSON(404, gin.H{"error": "ship not found"})
+		return
+	}
+	c.JSON(200, shipDetail{
+		ShipEstimate: estimate,
+		IceWarnings:  iceHazards.Warnings(int32(mmsi)),
+	})
+}
+
+func getAlerts(c *gin.Context) {
+	c.JSON(200, collisionMonitor.Alerts())
+}
+
+func getMMSI(c *gin.Context) {
+	info, err := service.ParseMMSI(c.Param("mmsi"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, info)
+}
+
+func getAnomalies(c *gin.Context) {
+	filter := service.AnomalyFilter{
+		Type:        c.Query("type"),
+		MinSeverity: c.Query("severity"),
+	}
+	if v := c.Query("mmsi"); v != "" {
+		mmsi, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "invalid mmsi"})
+			return
+		}
+		filter.MMSI = int32(mmsi)
+	}
+	c.JSON(200, anomalyDetector.Anomalies(filter))
+}
+
+// voyageFilter читает фильтр рейсов из параметров запроса
+func voyageFilter(c *gin.Context) (service.VoyageFilter, bool) {
+	filter := service.VoyageFilter{
+		Port:   c.Query("port"),
+		Status: c.Query("status"),
+	}
+	if v := c.Query("mmsi"); v != "" {
+		mmsi, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "invalid mmsi"})
+			return filter, false
+		}
+		filter.MMSI = int32(mmsi)
+	}
+	return filter, true
+}
+
+func getPortCalls(c *gin.Context) {
+	filter, ok := voyageFilter(c)
+	if !ok {
+		return
+	}
+	c.JSON(200, voyageTracker.PortCalls(filter))
+}
+
+func getVoyages(c *gin.Context) {
+	filter, ok := voyageFilter(c)
+	if !ok {
+		return
+	}
+	c.JSON(200, voyageTracker.Voyages(filter))
+}
+
+// streamLive отдает события живого канала как Server-Sent Events.
+// Параметр fleet оставляет только события судов этого флота.
+func streamLive(c *gin.Context) {
+	fleet := c.Query("fleet")
+	if fleet != "" {
+		if _, found := fleetStore.Get(fleet); !found {
+			c.JSON(404, gin.H{"error": "fleet not found"})
+			return
+		}
+	}
+
+	events, unsubscribe := liveHub.Subscribe(64)
+	defer unsubscribe()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			// Состав флота проверяется для каждого события: правки флота действуют сразу
+			if fleet != "" {
+				member, found := fleetStore.Includes(fleet, event.MMSIs())
+				if !found {
+					return false
+				}
+				if !member {
+					return true
+				}
+			}
+			c.SSEvent(event.Type, event.Data)
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
@@ -2,67 +2,272 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	aisstream "github.com/aisstream/ais-message-models/golang/aisStream"
 	"github.com/gorilla/websocket"
 	"github.com/s3nkyh/arcticeroute/models"
 )
 
-func Get10Ships() []models.Ship {
-	url := "wss://stream.aisstream.io/v0/stream"
-	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+const aisStreamURL = "wss://stream.aisstream.io/v0/stream"
+
+// RawMessage - сырое сообщение потока AIS с временем получения
+type RawMessage struct {
+	ReceivedAt time.Time       `json:"received_at"` // Время получения сообщения
+	Data       json.RawMessage `json:"message"`     // Сообщение в исходном виде
+}
+
+// MessageSource - источник сырых сообщений AIS (живой поток или запись).
+// Next возвращает io.EOF, когда сообщения закончились.
+type MessageSource interface {
+	Next() (RawMessage, error)
+	Close() error
+}
+
+// LiveSource - живой поток aisstream.io
+type LiveSource struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex // Подписка может меняться во время чтения
+	onClose func()
+}
+
+// DialLive подключается к aisstream.io и отправляет подписку
+func DialLive(sub aisstream.SubscriptionMessage) (*LiveSource, error) {
+	ws, _, err := websocket.DefaultDialer.Dial(aisStreamURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("Connected to WebSocket server")
+
+	live := &LiveSource{ws: ws}
+	if err := live.Resubscribe(sub); err != nil {
+		ws.Close()
+		return nil, err
+	}
+	return live, nil
+}
+
+// Resubscribe отправляет новую подписку в открытое соединение
+func (s *LiveSource) Resubscribe(sub aisstream.SubscriptionMessage) error {
+	subMsgBytes, err := json.Marshal(sub)
+	if err != nil {
+		return err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.ws.WriteMessage(websocket.TextMessage, subMsgBytes)
+}
+
+// Next читает следующее сообщение из WebSocket
+func (s *LiveSource) Next() (RawMessage, error) {
+	_, message, err := s.ws.ReadMessage()
 	if err != nil {
-		log.Fatalln(err)
+		return RawMessage{}, err
+	}
+	return RawMessage{ReceivedAt: time.Now().UTC(), Data: message}, nil
+}
+
+// Close закрывает соединение
+func (s *LiveSource) Close() error {
+	if s.onClose != nil {
+		s.onClose()
+	}
+	return s.ws.Close()
+}
+
+// ReplayConfigured сообщает, что вместо живого потока будет воспроизводиться запись
+func ReplayConfigured() bool {
+	return os.Getenv("AIS_REPLAY_FILE") != ""
+}
+
+// OpenSource открывает источник AIS согласно окружению:
+// AIS_REPLAY_FILE - воспроизвести запись вместо живого потока,
+// AIS_REPLAY_SPEED - скорость воспроизведения (1 - реальное время, 0 - без пауз),
+// AIS_RECORD_FILE - дописывать все полученные сообщения в файл.
+// Сообщения отбираются фильтрами подписки sub, в том числе при воспроизведении.
+func OpenSource(sub *Subscription) (MessageSource, error) {
+	var src MessageSource
+
+	if ReplayConfigured() {
+		path := os.Getenv("AIS_REPLAY_FILE")
+		speed := 1.0
+		if v := os.Getenv("AIS_REPLAY_SPEED"); v != "" {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid AIS_REPLAY_SPEED %q: %w", v, err)
+			}
+			speed = parsed
+		}
+		replay, err := OpenReplay(path, speed)
+		if err != nil {
+			return nil, err
+		}
+		src = replay
 	} else {
-		log.Println("Connected to WebSocket server")
+		live, err := sub.dial()
+		if err != nil {
+			return nil, err
+		}
+		src = live
 	}
-	defer ws.Close()
 
-	subMsg := aisstream.SubscriptionMessage{
-		APIKey:        "f4f956742f6bddcdc52ccd035a66057aa80e4f3e",
-		BoundingBoxes: [][][]float64{{{63.7, 33.0}, {90.0, 180.0}}},	// Только русская арктика
-		// BoundingBoxes: [][][]float64{{{63.7, 0.0}, {90.0, 180.0}}},		// +  Скандинавия
+	if path := os.Getenv("AIS_RECORD_FILE"); path != "" {
+		recorder, err := NewRecorder(src, path)
+		if err != nil {
+			src.Close()
+			return nil, err
+		}
+		src = recorder
 	}
 
-	subMsgBytes, _ := json.Marshal(subMsg)
-	if err := ws.WriteMessage(websocket.TextMessage, subMsgBytes); err != nil {
-		log.Fatalln(err)
+	return &filteredSource{src: src, sub: sub}, nil
+}
+
+// DecodePosition разбирает сообщение и возвращает позицию судна,
+// если это отчет о местоположении
+func DecodePosition(msg RawMessage) (models.Ship, bool, error) {
+	var packet aisstream.AisStreamMessage
+	if err := json.Unmarshal(msg.Data, &packet); err != nil {
+		return models.Ship{}, false, err
 	}
+	ship, ok := positionFromPacket(packet, msg.ReceivedAt)
+	return ship, ok, nil
+}
+
+// positionFromPacket извлекает позицию из отчета о местоположении
+func positionFromPacket(packet aisstream.AisStreamMessage, receivedAt time.Time) (models.Ship, bool) {
+	if packet.MessageType != aisstream.POSITION_REPORT || packet.Message.PositionReport == nil {
+		return models.Ship{}, false
+	}
+
+	shipName := "Unknown"
+	if name, ok := packet.MetaData["ShipName"].(string); ok && cleanAISText(name) != "" {
+		shipName = cleanAISText(name)
+	}
+
+	report := packet.Message.PositionReport
+	return models.Ship{
+		MMSI:      report.UserID,
+		Name:      shipName,
+		Latitude:  report.Latitude,
+		Longitude: report.Longitude,
+		SOG:       report.Sog,
+		COG:       report.Cog,
+		ROT:       decodeROT(report.RateOfTurn),
+		Heading:   report.TrueHeading,
+		NavStatus: report.NavigationalStatus,
+		Timestamp: receivedAt,
+	}, true
+}
+
+// decodeROT переводит закодированную скорость поворота AIS в градусы в минуту.
+// Значения -128 (нет данных) и ±127 (поворот без индикатора) дают 0.
+func decodeROT(raw int32) float64 {
+	if raw <= -127 || raw >= 127 {
+		return 0
+	}
+	rot := float64(raw) / 4.733
+	if raw < 0 {
+		return -rot * rot
+	}
+	return rot * rot
+}
+
+// DecodeStatic разбирает сообщение и возвращает статические данные судна,
+// если это сообщение типа 5 (ShipStaticData) или 24 (StaticDataReport)
+func DecodeStatic(msg RawMessage) (models.ShipStatic, bool, error) {
+	var packet aisstream.AisStreamMessage
+	if err := json.Unmarshal(msg.Data, &packet); err != nil {
+		return models.ShipStatic{}, false, err
+	}
+	static, ok := staticFromPacket(packet, msg.ReceivedAt)
+	return static, ok, nil
+}
 
-	ships := make([]models.Ship, 0, 10)
+// staticFromPacket извлекает статические данные из сообщений типа 5 и 24
+func staticFromPacket(packet aisstream.AisStreamMessage, receivedAt time.Time) (models.ShipStatic, bool) {
+	switch {
+	case packet.MessageType == aisstream.SHIP_STATIC_DATA && packet.Message.ShipStaticData != nil:
+		data := packet.Message.ShipStaticData
+		return models.ShipStatic{
+			MMSI:        data.UserID,
+			IMO:         data.ImoNumber,
+			CallSign:    cleanAISText(data.CallSign),
+			Name:        cleanAISText(data.Name),
+			ShipType:    data.Type,
+			Length:      float64(data.Dimension.A + data.Dimension.B),
+			Beam:        float64(data.Dimension.C + data.Dimension.D),
+			Draught:     data.MaximumStaticDraught,
+			Destination: cleanAISText(data.Destination),
+			Timestamp:   receivedAt,
+		}, true
 
-	for len(ships) < 2 {
-		_, message, err := ws.ReadMessage()
+	case packet.MessageType == aisstream.STATIC_DATA_REPORT && packet.Message.StaticDataReport != nil:
+		report := packet.Message.StaticDataReport
+		static := models.ShipStatic{MMSI: report.UserID, Timestamp: receivedAt}
+		if report.ReportA.Valid {
+			static.Name = cleanAISText(report.ReportA.Name)
+		}
+		if report.ReportB.Valid {
+			static.ShipType = report.ReportB.ShipType
+			static.CallSign = cleanAISText(report.ReportB.CallSign)
+			static.Length = float64(report.ReportB.Dimension.A + report.ReportB.Dimension.B)
+			static.Beam = float64(report.ReportB.Dimension.C + report.ReportB.Dimension.D)
+		}
+		return static, true
+	}
+
+	return models.ShipStatic{}, false
+}
+
+// cleanAISText убирает заполнители '@' и пробелы из текстовых полей AIS
+func cleanAISText(s string) string {
+	return strings.TrimSpace(strings.TrimRight(s, "@ "))
+}
+
+// IngestHandler - обработчики разобранных сообщений; nil-обработчики пропускаются.
+// Возврат false останавливает прием.
+type IngestHandler struct {
+	Position func(models.Ship) bool
+	Static   func(models.ShipStatic) bool
+}
+
+// Ingest читает сообщения из источника и передает их обработчикам,
+// пока те возвращают true. Конец записи не считается ошибкой.
+func Ingest(src MessageSource, h IngestHandler) error {
+	for {
+		msg, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
 		if err != nil {
-			log.Println("Read error:", err)
-			continue
+			return err
 		}
 
 		var packet aisstream.AisStreamMessage
-		err = json.Unmarshal(message, &packet)
-		if err != nil {
+		if err := json.Unmarshal(msg.Data, &packet); err != nil {
 			log.Println("Unmarshal error:", err)
 			continue
 		}
 
-		if packet.MessageType == aisstream.POSITION_REPORT {
-			shipName := "Unknown"
-			if name, ok := packet.MetaData["ShipName"]; ok && name != "" {
-				shipName = name.(string)
+		if h.Position != nil {
+			if ship, ok := positionFromPacket(packet, msg.ReceivedAt); ok && ship.HasPosition() && !h.Position(ship) {
+				return nil
 			}
-			ship := models.Ship{
-				MMSI:      packet.Message.PositionReport.UserID,
-				Name:      shipName,
-				Latitude:  packet.Message.PositionReport.Latitude,
-				Longitude: packet.Message.PositionReport.Longitude,
+		}
+		if h.Static != nil {
+			if static, ok := staticFromPacket(packet, msg.ReceivedAt); ok && !h.Static(static) {
+				return nil
 			}
-
-			ships = append(ships, ship)
-			fmt.Printf("Собрано кораблей: %d/2\n", len(ships))
 		}
 	}
-
-	return ships
 }
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/paulmach/orb"
+	"github.com/s3nkyh/arcticeroute/models"
+	"github.com/s3nkyh/arcticeroute/osm"
+	"github.com/s3nkyh/arcticeroute/overpass"
+)
+
+// coastlineQuery - линии берега с геометрией в области {{bbox}}
+const coastlineQuery = `
+	[out:json][timeout:180];
+	way["natural"="coastline"]({{bbox}});
+	out geom;
+	`
+
+// Размер части области, которой береговая линия запрашивается из Overpass, градусы:
+// вся область морских путей одним запросом не укладывается в таймаут сервера
+const (
+	coastlineTileLat = 5
+	coastlineTileLon = 10
+)
+
+// GetCoastlines загружает линии берега (natural=coastline) в области bbox.
+// Из Overpass область запрашивается частями; линия, попавшая в несколько частей, берется один раз.
+// Направление линий сохраняется: по правилам OSM суша слева.
+func GetCoastlines(ctx context.Context, src OSMSource, bbox models.BBox) ([]orb.LineString, error) {
+	tiles := []models.BBox{bbox}
+	for _, part := range bbox.Split() {
+		if !src.local(part, osm.LayerCoastlines) {
+			tiles = bbox.Tiles(coastlineTileLat, coastlineTileLon)
+			break
+		}
+	}
+
+	seen := make(map[int64]bool)
+	var elements []overpass.Element
+	for _, tile := range tiles {
+		found, err := src.elements(ctx, coastlineQuery, tile, osm.LayerCoastlines)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range found {
+			if !seen[e.ID] {
+				seen[e.ID] = true
+				elements = append(elements, e)
+			}
+		}
+	}
+	return coastlineWays(elements), nil
+}
+
+// coastlineWays выбирает линии берега из элементов Overpass
+func coastlineWays(elements []overpass.Element) []orb.LineString {
+	var lines []orb.LineString
+	for _, e := range elements {
+		if e.Type == "way" && e.Tags["natural"] == "coastline" && len(e.Geometry) >= 2 {
+			lines = append(lines, overpass.LineString(e.Geometry))
+		}
+	}
+	return lines
+}
+
+// ReadCoastlines читает линии берега из локальной выгрузки OSM:
+// PBF (.pbf), XML (.osm) или сохраненного ответа Overpass в JSON (.json)
+func ReadCoastlines(path string) ([]orb.LineString, error) {
+	if strings.HasSuffix(strings.ToLower(path), ".pbf") {
+		layers, err := osm.ReadPBF(path, osm.LayerCoastlines)
+		if err != nil {
+			return nil, err
+		}
+		return coastlineWays(layers[osm.LayerCoastlines]), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		var resp overpass.Response
+		if err := json.NewDecoder(f).Decode(&resp); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return coastlineWays(resp.Elements), nil
+	case ".osm", ".xml":
+		lines, err := readOSMCoastlines(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return lines, nil
+	default:
+		return nil, fmt.Errorf("%s: unsupported extract format", path)
+	}
+}
+
+// osmXMLNode и osmXMLWay - элементы OSM XML, нужные для линий берега
+type osmXMLNode struct {
+	ID  int64   `xml:"id,attr"`
+	Lat float64 `xml:"lat,attr"`
+	Lon float64 `xml:"lon,attr"`
+}
+
+type osmXMLWay struct {
+	ID    int64 `xml:"id,attr"`
+	Nodes []struct {
+		Ref int64 `xml:"ref,attr"`
+	} `xml:"nd"`
+	Tags []struct {
+		K string `xml:"k,attr"`
+		V string `xml:"v,attr"`
+	} `xml:"tag"`
+}
+
+// readOSMCoastlines потоково читает OSM XML: запоминает координаты узлов
+// и собирает из них линии с natural=coastline
+func readOSMCoastlines(r io.Reader) ([]orb.LineString, error) {
+	dec := xml.NewDecoder(r)
+	nodes := make(map[int64]orb.Point)
+	var lines []orb.LineString
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "node":
+			var n osmXMLNode
+			if err := dec.DecodeElement(&n, &start); err != nil {
+				return nil, err
+			}
+			nodes[n.ID] = orb.Point{n.Lon, n.Lat}
+		case "way":
+			var w osmXMLWay
+			if err := dec.DecodeElement(&w, &start); err != nil {
+				return nil, err
+			}
+			coastline := false
+			for _, t := range w.Tags {
+				if t.K == "natural" && t.V == "coastline" {
+					coastline = true
+				}
+			}
+			if !coastline {
+				continue
+			}
+			ls := make(orb.LineString, 0, len(w.Nodes))
+			for _, nd := range w.Nodes {
+				if p, ok := nodes[nd.Ref]; ok {
+					ls = append(ls, p)
+				}
+			}
+			if len(ls) >= 2 {
+				lines = append(lines, ls)
+			}
+		}
+	}
+}
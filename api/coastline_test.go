@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/s3nkyh/arcticeroute/models"
+	"github.com/s3nkyh/arcticeroute/overpass"
+)
+
+func TestGetCoastlinesTiles(t *testing.T) {
+	// Сервер на каждый запрос отдает одну длинную линию, общую для всех частей, и одну свою
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		fmt.Fprintf(w, `{"elements":[
+			{"type":"way","id":1,"tags":{"natural":"coastline"},"geometry":[{"lat":70,"lon":30},{"lat":70,"lon":80}]},
+			{"type":"way","id":%d,"tags":{"natural":"coastline"},"geometry":[{"lat":69,"lon":31},{"lat":69,"lon":32}]}
+		]}`, 100+n)
+	}))
+	defer srv.Close()
+
+	cfg := overpass.DefaultConfig()
+	cfg.Endpoint = srv.URL
+	cfg.MinInterval = 0
+	src := OSMSource{Client: overpass.NewClient(cfg)}
+
+	tests := []struct {
+		bbox  string
+		tiles int
+	}{
+		{"68,30,72,40", 1},
+		{"64,28,81,85", 24},
+	}
+	for _, tt := range tests {
+		t.Run(tt.bbox, func(t *testing.T) {
+			requests.Store(0)
+			lines, err := GetCoastlines(context.Background(), src, models.MustParseBBox(tt.bbox))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := requests.Load(); n != int64(tt.tiles) {
+				t.Errorf("%d requests, want %d", n, tt.tiles)
+			}
+			if len(lines) != tt.tiles+1 {
+				t.Errorf("got %d lines, want %d", len(lines), tt.tiles+1)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+
+	"github.com/s3nkyh/arcticeroute/models"
+	"github.com/s3nkyh/arcticeroute/osm"
+	"github.com/s3nkyh/arcticeroute/overpass"
+)
+
+// harbourQuery - гавани и порты в области {{bbox}}; отбор совпадает со слоем harbours хранилища
+const harbourQuery = `
+	[out:json][timeout:90];
+	(
+	  nwr["harbour"]["harbour"!="no"]({{bbox}});
+	  nwr["seamark:type"~"^(harbour|small_craft_facility)$"]({{bbox}});
+	  nwr["landuse"="port"]({{bbox}});
+	  nwr["industrial"="port"]({{bbox}});
+	);
+	out center tags;
+	`
+
+// GetHarbours загружает гавани в области bbox
+func GetHarbours(ctx context.Context, src OSMSource, bbox models.BBox) ([]models.Harbour, error) {
+	elements, err := src.elements(ctx, harbourQuery, bbox, osm.LayerHarbours)
+	if err != nil {
+		return nil, err
+	}
+	harbours := make([]models.Harbour, 0, len(elements))
+	for _, e := range elements {
+		if h, ok := parseHarbour(e); ok {
+			harbours = append(harbours, h)
+		}
+	}
+	return harbours, nil
+}
+
+// parseHarbour разбирает название и вид гавани. Вид берется из категории seamark,
+// значения harbour (кроме yes), а для портовых территорий - port.
+func parseHarbour(e overpass.Element) (models.Harbour, bool) {
+	lat, lon, ok := e.Position()
+	if !ok {
+		return models.Harbour{}, false
+	}
+	h := models.Harbour{ID: e.ID, OSMType: e.Type, Name: e.Tags["name"], Lat: lat, Lon: lon}
+	if h.Name == "" {
+		h.Name = e.Tags["seamark:name"]
+	}
+
+	typ := e.Tags["seamark:type"]
+	switch {
+	case e.Tags["seamark:"+typ+":category"] != "":
+		h.Category = e.Tags["seamark:"+typ+":category"]
+	case e.Tags["harbour"] != "" && e.Tags["harbour"] != "yes":
+		h.Category = e.Tags["harbour"]
+	case e.Tags["landuse"] == "port" || e.Tags["industrial"] == "port":
+		h.Category = "port"
+	default:
+		h.Category = typ
+	}
+	return h, true
+}
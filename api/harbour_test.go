@@ -0,0 +1,108 @@
+package api
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/s3nkyh/arcticeroute/models"
+	"github.com/s3nkyh/arcticeroute/osm"
+	"github.com/s3nkyh/arcticeroute/overpass"
+)
+
+// testStore - хранилище со слоем гаваней из выгрузки, покрывающей Печорское море
+func testStore(t *testing.T) *osm.Store {
+	dir := t.TempDir()
+	meta := osm.Meta{
+		Source: "pechora.osm.pbf",
+		Counts: map[string]int{osm.LayerHarbours: 1},
+		Bounds: &overpass.Bounds{MinLat: 67, MinLon: 45, MaxLat: 71, MaxLon: 62},
+	}
+	harbours := []overpass.Element{{Type: "node", ID: 11, Lat: 67.65, Lon: 49.0, Tags: map[string]string{"harbour": "yes", "name": "Индига"}}}
+
+	data, _ := json.Marshal(meta)
+	if err := os.WriteFile(filepath.Join(dir, "meta.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(dir, osm.LayerHarbours+".json.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(f)
+	json.NewEncoder(zw).Encode(harbours)
+	zw.Close()
+	f.Close()
+
+	store, err := osm.OpenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestGetHarboursSource(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"elements":[{"type":"way","id":7,"center":{"lat":69.06,"lon":33.2},"tags":{"landuse":"port","name":"Murmansk"}}]}`))
+	}))
+	defer srv.Close()
+	cfg := overpass.DefaultConfig()
+	cfg.Endpoint = srv.URL
+	cfg.MinInterval = 0
+	store := testStore(t)
+
+	tests := []struct {
+		name     string
+		src      OSMSource
+		bbox     string
+		want     string
+		requests int32
+	}{
+		{"inside the extract", OSMSource{Store: store, Client: overpass.NewClient(cfg)}, "67,48,68,50", "Индига", 0},
+		{"outside the extract falls back to Overpass", OSMSource{Store: store, Client: overpass.NewClient(cfg)}, "68,30,70,35", "Murmansk", 1},
+		{"outside the extract offline", OSMSource{Store: store}, "68,30,70,35", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			harbours, err := GetHarbours(context.Background(), tt.src, models.MustParseBBox(tt.bbox))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := requests.Load(); n != tt.requests {
+				t.Errorf("%d Overpass requests, want %d", n, tt.requests)
+			}
+			if tt.want == "" && len(harbours) != 0 || tt.want != "" && (len(harbours) != 1 || harbours[0].Name != tt.want) {
+				t.Errorf("harbours = %+v, want %q", harbours, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHarbour(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     map[string]string
+		category string
+	}{
+		{"seamark category", map[string]string{"seamark:type": "harbour", "seamark:harbour:category": "fishing"}, "fishing"},
+		{"harbour value", map[string]string{"harbour": "marina"}, "marina"},
+		{"port area", map[string]string{"harbour": "yes", "landuse": "port"}, "port"},
+		{"small craft facility", map[string]string{"seamark:type": "small_craft_facility"}, "small_craft_facility"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, ok := parseHarbour(overpass.Element{Type: "node", ID: 1, Lat: 69, Lon: 33, Tags: tt.tags})
+			if !ok || h.Category != tt.category {
+				t.Errorf("parseHarbour = %+v, %v; want category %q", h, ok, tt.category)
+			}
+		})
+	}
+}
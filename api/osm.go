@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+	"github.com/s3nkyh/arcticeroute/osm"
+	"github.com/s3nkyh/arcticeroute/overpass"
+)
+
+// ErrNoOSMSource - слой не импортирован в локальное хранилище, а Overpass отключен
+var ErrNoOSMSource = errors.New("no OSM source")
+
+// OSMSource - источник объектов OSM: локальное хранилище, импортированное из .osm.pbf,
+// и Overpass API как необязательный онлайн-источник слоев, которых нет в хранилище
+type OSMSource struct {
+	Store  *osm.Store       // nil - без локального хранилища
+	Client *overpass.Client // nil - работа без сети
+}
+
+// elements возвращает элементы слоев в области bbox: из хранилища, если все слои импортированы
+// и область лежит в границах выгрузки, иначе запросом query к Overpass.
+// Область через антимеридиан запрашивается двумя частями.
+func (s OSMSource) elements(ctx context.Context, query string, bbox models.BBox, layers ...string) ([]overpass.Element, error) {
+	if !s.stored(layers...) && s.Client == nil {
+		return nil, fmt.Errorf("%w: %s not imported and Overpass is disabled", ErrNoOSMSource, strings.Join(layers, ", "))
+	}
+
+	// Элемент может входить в несколько слоев и частей области: маяк - и в знаки, и в маяки
+	seen := make(map[string]bool)
+	var elements []overpass.Element
+	add := func(found []overpass.Element) {
+		for _, e := range found {
+			key := e.Type + "/" + strconv.FormatInt(e.ID, 10)
+			if !seen[key] {
+				seen[key] = true
+				elements = append(elements, e)
+			}
+		}
+	}
+	for _, part := range bbox.Split() {
+		if s.local(part, layers...) {
+			for _, layer := range layers {
+				add(s.Store.Query(layer, part.Bound()))
+			}
+			continue
+		}
+		resp, err := s.Client.Query(ctx, query, part)
+		if err != nil {
+			return nil, err
+		}
+		add(resp.Elements)
+	}
+	return elements, nil
+}
+
+// stored сообщает, что все слои есть в локальном хранилище
+func (s OSMSource) stored(layers ...string) bool {
+	if s.Store == nil {
+		return false
+	}
+	for _, layer := range layers {
+		if !s.Store.Has(layer) {
+			return false
+		}
+	}
+	return true
+}
+
+// local сообщает, что область part, не пересекающую антимеридиан, можно прочитать из хранилища:
+// все слои импортированы и область лежит в границах выгрузки. Без Overpass хранилище - единственный источник.
+func (s OSMSource) local(part models.BBox, layers ...string) bool {
+	return s.stored(layers...) && (s.Client == nil || s.Store.Covers(part.Bound()))
+}
+
+// Origin описывает, откуда берется слой: "overpass" или импортированная выгрузка с временем импорта
+func (s OSMSource) Origin(layer string) string {
+	if s.stored(layer) {
+		meta := s.Store.Meta()
+		return "pbf:" + meta.Source + "@" + meta.ImportedAt.Format(time.RFC3339)
+	}
+	return "overpass"
+}
@@ -1,84 +1,55 @@
 package api
 
 import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"net/http"
-	"net/url"
+	"context"
 
 	"github.com/s3nkyh/arcticeroute/models"
+	"github.com/s3nkyh/arcticeroute/osm"
+	"github.com/s3nkyh/arcticeroute/overpass"
 )
 
-func GetGlaciers(bbox string) ([]models.Glacier, error) {
-	query := fmt.Sprintf(`
-	[out:json][timeout:25];
+// glacierQuery - ледники с полной геометрией в области {{bbox}}
+const glacierQuery = `
+	[out:json][timeout:60];
 	(
-	  node["natural"="glacier"](%s);
-	  way["natural"="glacier"](%s);
-	  relation["natural"="glacier"](%s);
+	  node["natural"="glacier"]({{bbox}});
+	  way["natural"="glacier"]({{bbox}});
+	  relation["natural"="glacier"]({{bbox}});
 	);
-	out center;
-	`, bbox, bbox, bbox)
+	out geom;
+	`
 
-	encodedQuery := url.QueryEscape(query)
-	apiURL := fmt.Sprintf("https://overpass-api.de/api/interpreter?data=%s", encodedQuery)
-
-	resp, err := http.Get(apiURL)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
+// GetGlaciers загружает ледники в области bbox
+func GetGlaciers(ctx context.Context, src OSMSource, bbox models.BBox) ([]models.Glacier, error) {
+	elements, err := src.elements(ctx, glacierQuery, bbox, osm.LayerGlaciers)
 	if err != nil {
 		return nil, err
 	}
-
-	var result map[string]interface{}
-	json.Unmarshal(body, &result)
-
-	return parseGlaciers(result), nil
+	return parseGlaciers(elements), nil
 }
 
-func parseGlaciers(data map[string]interface{}) []models.Glacier {
+func parseGlaciers(elements []overpass.Element) []models.Glacier {
 	var glaciers []models.Glacier
 
-	elements, ok := data["elements"].([]interface{})
-	if !ok {
-		return glaciers
-	}
-
-	for _, element := range elements {
-		elem := element.(map[string]interface{})
-
+	for _, elem := range elements {
 		glacier := models.Glacier{
-			ID:   int64(elem["id"].(float64)),
-			Type: elem["type"].(string),
+			ID:   elem.ID,
+			Type: elem.Type,
+			Name: "Unnamed Glacier",
 		}
-
-		if tags, ok := elem["tags"].(map[string]interface{}); ok {
-			if name, ok := tags["name"].(string); ok {
-				glacier.Name = name
-			} else {
-				glacier.Name = "Unnamed Glacier"
-			}
-		} else {
-			glacier.Name = "Unnamed Glacier"
+		if name := elem.Tags["name"]; name != "" {
+			glacier.Name = name
 		}
+		glacier.Tidewater = elem.Tags["glacier:type"] == "tidewater" || elem.Tags["tidewater"] == "yes"
 
-		if center, ok := elem["center"].(map[string]interface{}); ok {
-			if lat, ok := center["lat"].(float64); ok {
-				glacier.Latitude = lat
-			}
-			if lon, ok := center["lon"].(float64); ok {
-				glacier.Longitude = lon
-			}
-		} else if lat, ok := elem["lat"].(float64); ok {
-			glacier.Latitude = lat
-			if lon, ok := elem["lon"].(float64); ok {
-				glacier.Longitude = lon
+		if elem.Type == "node" {
+			glacier.Latitude, glacier.Longitude = elem.Lat, elem.Lon
+		} else {
+			mp := elem.Polygons()
+			if len(mp) == 0 {
+				continue
 			}
+			glacier.SetGeometry(mp)
 		}
 
 		if glacier.Latitude != 0 && glacier.Longitude != 0 {
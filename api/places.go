@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/s3nkyh/arcticeroute/models"
+	"github.com/s3nkyh/arcticeroute/osm"
+	"github.com/s3nkyh/arcticeroute/overpass"
+)
+
+// placeQuery - именованные места и природные объекты (мысы, заливы, проливы) в области {{bbox}}
+const placeQuery = `
+	[out:json][timeout:90];
+	(
+	  nwr["place"]["name"]({{bbox}});
+	  nwr["natural"~"^(cape|peninsula|bay|strait)$"]["name"]({{bbox}});
+	);
+	out center tags;
+	`
+
+// placeNameTags - теги с другими названиями места
+var placeNameTags = []string{"name:en", "name:ru", "int_name", "official_name", "alt_name", "old_name", "loc_name"}
+
+// GetPlaces загружает именованные места OSM в области bbox
+func GetPlaces(ctx context.Context, src OSMSource, bbox models.BBox) ([]models.Place, error) {
+	elements, err := src.elements(ctx, placeQuery, bbox, osm.LayerPlaces)
+	if err != nil {
+		return nil, err
+	}
+	places := make([]models.Place, 0, len(elements))
+	for _, e := range elements {
+		if p, ok := parsePlace(e); ok {
+			places = append(places, p)
+		}
+	}
+	return places, nil
+}
+
+// parsePlace разбирает название и вид места; вид - значение place или natural
+func parsePlace(e overpass.Element) (models.Place, bool) {
+	lat, lon, ok := e.Position()
+	name := e.Tags["name"]
+	if !ok || name == "" {
+		return models.Place{}, false
+	}
+	p := models.Place{
+		ID:     fmt.Sprintf("osm/%s/%d", e.Type, e.ID),
+		Name:   name,
+		Kind:   e.Tags["place"],
+		Source: "osm",
+		Lat:    lat,
+		Lon:    lon,
+	}
+	if p.Kind == "" {
+		p.Kind = e.Tags["natural"]
+	}
+	for _, tag := range placeNameTags {
+		for _, alt := range splitListBy(e.Tags[tag], ";") {
+			if alt != name && !slices.Contains(p.AltNames, alt) {
+				p.AltNames = append(p.AltNames, alt)
+			}
+		}
+	}
+	return p, true
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxRecordLine - максимальная длина строки записи (сообщения AIS невелики)
+const maxRecordLine = 1 << 20
+
+// ErrSourceClosed возвращается из Next после Close
+var ErrSourceClosed = errors.New("ais source closed")
+
+// ==============================
+// ЗАПИСЬ ПОТОКА
+// ==============================
+
+// Recorder - источник-обертка, дописывающий каждое сообщение в JSONL-файл
+type Recorder struct {
+	src  MessageSource
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewRecorder открывает файл записи на дозапись и оборачивает источник
+func NewRecorder(src MessageSource, path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{src: src, file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// Next читает сообщение из исходного источника и записывает его
+func (r *Recorder) Next() (RawMessage, error) {
+	msg, err := r.src.Next()
+	if err != nil {
+		return msg, err
+	}
+
+	// Запись не должна останавливать прием
+	if !json.Valid(msg.Data) {
+		log.Println("Record skipped: message is not valid JSON")
+	} else if err := r.enc.Encode(msg); err != nil {
+		log.Println("Record error:", err)
+	}
+
+	return msg, nil
+}
+
+// Close закрывает источник и файл записи
+func (r *Recorder) Close() error {
+	srcErr := r.src.Close()
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	return srcErr
+}
+
+// ==============================
+// ВОСПРОИЗВЕДЕНИЕ ЗАПИСИ
+// ==============================
+
+// ReplaySource - источник, воспроизводящий JSONL-запись с сохранением
+// интервалов между сообщениями. Speed = 1 - реальное время, N - в N раз
+// быстрее, 0 - без пауз. Время получения сообщений остается исходным,
+// поэтому результат воспроизведения детерминирован.
+type ReplaySource struct {
+	file    *os.File
+	scanner *bufio.Scanner
+	speed   float64
+
+	firstRecorded time.Time // Время первого сообщения в записи
+	startedAt     time.Time // Момент выдачи первого сообщения
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// OpenReplay открывает запись для воспроизведения
+func OpenReplay(path string, speed float64) (*ReplaySource, error) {
+	if speed < 0 {
+		return nil, errors.New("replay speed must not be negative")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordLine)
+
+	return &ReplaySource{
+		file:    file,
+		scanner: scanner,
+		speed:   speed,
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// Next возвращает следующее сообщение записи, выдерживая паузу
+func (r *ReplaySource) Next() (RawMessage, error) {
+	for {
+		select {
+		case <-r.done:
+			return RawMessage{}, ErrSourceClosed
+		default:
+		}
+
+		if !r.scanner.Scan() {
+			if err := r.scanner.Err(); err != nil {
+				select {
+				case <-r.done:
+					return RawMessage{}, ErrSourceClosed
+				default:
+				}
+				return RawMessage{}, err
+			}
+			return RawMessage{}, io.EOF
+		}
+
+		line := r.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg RawMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			log.Println("Replay unmarshal error:", err)
+			continue
+		}
+
+		if err := r.wait(msg.ReceivedAt); err != nil {
+			return RawMessage{}, err
+		}
+		return msg, nil
+	}
+}
+
+// wait выдерживает паузу до момента выдачи сообщения
+func (r *ReplaySource) wait(recordedAt time.Time) error {
+	if r.firstRecorded.IsZero() {
+		r.firstRecorded = recordedAt
+		r.startedAt = time.Now()
+		return nil
+	}
+	if r.speed == 0 {
+		return nil
+	}
+
+	offset := time.Duration(float64(recordedAt.Sub(r.firstRecorded)) / r.speed)
+	delay := time.Until(r.startedAt.Add(offset))
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-r.done:
+		return ErrSourceClosed
+	}
+}
+
+// Close прерывает воспроизведение и закрывает файл
+func (r *ReplaySource) Close() error {
+	var err error
+	r.closeOnce.Do(func() {
+		close(r.done)
+		err = r.file.Close()
+	})
+	return err
+}
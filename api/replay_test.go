@@ -0,0 +1,206 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// replayFixture - запись с пустой и поврежденной строками и позицией вне диапазона
+const replayFixture = "testdata/replay.jsonl"
+
+// sliceSource - источник из заранее заданных сообщений
+type sliceSource struct {
+	msgs   []RawMessage
+	closed bool
+}
+
+func (s *sliceSource) Next() (RawMessage, error) {
+	if len(s.msgs) == 0 {
+		return RawMessage{}, io.EOF
+	}
+	msg := s.msgs[0]
+	s.msgs = s.msgs[1:]
+	return msg, nil
+}
+
+func (s *sliceSource) Close() error {
+	s.closed = true
+	return nil
+}
+
+// readAll вычитывает источник до конца
+func readAll(t *testing.T, src MessageSource) []RawMessage {
+	t.Helper()
+	var msgs []RawMessage
+	for {
+		msg, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			return msgs
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+func TestReplaySkipsBrokenLines(t *testing.T) {
+	src, err := OpenReplay(replayFixture, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	msgs := readAll(t, src)
+	base := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	if len(msgs) != 4 {
+		t.Fatalf("got %d messages, want 4", len(msgs))
+	}
+	for i, msg := range msgs {
+		if want := base.Add(time.Duration(i) * time.Second); !msg.ReceivedAt.Equal(want) {
+			t.Errorf("message %d received at %v, want recorded time %v", i, msg.ReceivedAt, want)
+		}
+	}
+	if _, err := src.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Next after end = %v, want io.EOF", err)
+	}
+}
+
+func TestReplayIngestIsDeterministic(t *testing.T) {
+	run := func() ([]models.Ship, []models.ShipStatic) {
+		src, err := OpenReplay(replayFixture, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer src.Close()
+
+		var ships []models.Ship
+		var statics []models.ShipStatic
+		err = Ingest(src, IngestHandler{
+			Position: func(s models.Ship) bool { ships = append(ships, s); return true },
+			Static:   func(s models.ShipStatic) bool { statics = append(statics, s); return true },
+		})
+		if err != nil {
+			t.Fatalf("Ingest: %v", err)
+		}
+		return ships, statics
+	}
+
+	ships, statics := run()
+	// Позиция вне диапазона координат отбрасывается
+	if len(ships) != 2 || ships[0].MMSI != 273000101 || ships[1].MMSI != 273000102 {
+		t.Fatalf("positions = %+v, want 273000101 and 273000102", ships)
+	}
+	if ships[0].Name != "TUG ONE" || ships[1].Name != "Unknown" {
+		t.Errorf("names = %q, %q", ships[0].Name, ships[1].Name)
+	}
+	if len(statics) != 1 || statics[0].Name != "TUG ONE" || statics[0].Draught != 5 {
+		t.Fatalf("statics = %+v", statics)
+	}
+
+	again, againStatics := run()
+	if !reflect.DeepEqual(ships, again) || !reflect.DeepEqual(statics, againStatics) {
+		t.Error("second replay produced different results")
+	}
+}
+
+func TestReplaySpeed(t *testing.T) {
+	tests := []struct {
+		name  string
+		speed float64
+		min   time.Duration
+		max   time.Duration
+	}{
+		{"no pauses", 0, 0, 100 * time.Millisecond},
+		{"30x", 30, 80 * time.Millisecond, 1 * time.Second},
+		{"10x", 10, 250 * time.Millisecond, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := OpenReplay(replayFixture, tt.speed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer src.Close()
+
+			// Между первым и последним сообщением записи 3 секунды
+			start := time.Now()
+			readAll(t, src)
+			if elapsed := time.Since(start); elapsed < tt.min || elapsed > tt.max {
+				t.Errorf("replay took %v, want between %v and %v", elapsed, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestReplayCloseInterruptsWait(t *testing.T) {
+	src, err := OpenReplay(replayFixture, 0.001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Next(); err != nil {
+		t.Fatal(err)
+	}
+
+	time.AfterFunc(50*time.Millisecond, func() { src.Close() })
+	start := time.Now()
+	if _, err := src.Next(); !errors.Is(err, ErrSourceClosed) {
+		t.Fatalf("Next = %v, want ErrSourceClosed", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Close took %v to interrupt the pause", elapsed)
+	}
+	if _, err := src.Next(); !errors.Is(err, ErrSourceClosed) {
+		t.Errorf("Next after Close = %v, want ErrSourceClosed", err)
+	}
+}
+
+func TestOpenReplayRejectsNegativeSpeed(t *testing.T) {
+	if _, err := OpenReplay(replayFixture, -1); err == nil {
+		t.Error("OpenReplay accepted negative speed")
+	}
+}
+
+func TestRecorderRoundTrip(t *testing.T) {
+	at := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	msgs := []RawMessage{
+		{ReceivedAt: at, Data: []byte(`{"MessageType":"PositionReport"}`)},
+		{ReceivedAt: at.Add(time.Second), Data: []byte(`{broken`)},
+		{ReceivedAt: at.Add(2 * time.Second), Data: []byte(`{"MessageType":"ShipStaticData"}`)},
+	}
+	path := filepath.Join(t.TempDir(), "record.jsonl")
+
+	inner := &sliceSource{msgs: msgs}
+	rec, err := NewRecorder(inner, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Поврежденное сообщение передается дальше, но не записывается
+	if got := readAll(t, rec); len(got) != len(msgs) {
+		t.Fatalf("recorder passed %d messages, want %d", len(got), len(msgs))
+	}
+	if err := rec.Close(); err != nil || !inner.closed {
+		t.Fatalf("Close = %v, source closed = %v", err, inner.closed)
+	}
+
+	src, err := OpenReplay(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	got := readAll(t, src)
+	if len(got) != 2 {
+		t.Fatalf("replayed %d messages, want 2", len(got))
+	}
+	for i, want := range []RawMessage{msgs[0], msgs[2]} {
+		if !got[i].ReceivedAt.Equal(want.ReceivedAt) || string(got[i].Data) != string(want.Data) {
+			t.Errorf("message %d = %s at %v, want %s at %v", i, got[i].Data, got[i].ReceivedAt, want.Data, want.ReceivedAt)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/s3nkyh/arcticeroute/models"
+	"github.com/s3nkyh/arcticeroute/osm"
+	"github.com/s3nkyh/arcticeroute/overpass"
+)
+
+// seamarkQuery - навигационные знаки и маяки в области {{bbox}}; у линий и контуров - центр
+const seamarkQuery = `
+	[out:json][timeout:90];
+	(
+	  nwr["seamark:type"]({{bbox}});
+	  nwr["man_made"="lighthouse"]({{bbox}});
+	);
+	out center tags;
+	`
+
+// GetSeamarks загружает навигационные знаки в области bbox
+func GetSeamarks(ctx context.Context, src OSMSource, bbox models.BBox) ([]models.Seamark, error) {
+	elements, err := src.elements(ctx, seamarkQuery, bbox, osm.LayerSeamarks, osm.LayerLighthouses)
+	if err != nil {
+		return nil, err
+	}
+	seamarks := make([]models.Seamark, 0, len(elements))
+	for _, e := range elements {
+		if s, ok := parseSeamark(e); ok {
+			seamarks = append(seamarks, s)
+		}
+	}
+	return seamarks, nil
+}
+
+// parseSeamark разбирает теги seamark:* элемента. Маяк без тегов seamark считается light_major.
+func parseSeamark(e overpass.Element) (models.Seamark, bool) {
+	lat, lon, ok := e.Position()
+	if !ok {
+		return models.Seamark{}, false
+	}
+	typ := e.Tags["seamark:type"]
+	if typ == "" && e.Tags["man_made"] == "lighthouse" {
+		typ = "light_major"
+	}
+	if typ == "" {
+		return models.Seamark{}, false
+	}
+
+	s := models.Seamark{
+		ID:      e.ID,
+		OSMType: e.Type,
+		Type:    typ,
+		Name:    e.Tags["seamark:name"],
+		Lat:     lat,
+		Lon:     lon,
+	}
+	if s.Name == "" {
+		s.Name = e.Tags["name"]
+	}
+	prefix := "seamark:" + typ + ":"
+	s.Category = e.Tags[prefix+"category"]
+	s.Colours = splitListBy(e.Tags[prefix+"colour"], ";")
+	s.ColourPattern = e.Tags[prefix+"colour_pattern"]
+	s.Shape = e.Tags[prefix+"shape"]
+	s.Lights = parseLights(e.Tags)
+	return s, true
+}
+
+// parseLights собирает огни: seamark:light:* или секторные seamark:light:<n>:*
+func parseLights(tags map[string]string) []models.Light {
+	var lights []models.Light
+	if l, ok := parseLight(tags, "seamark:light:"); ok {
+		lights = append(lights, l)
+	}
+	for n := 1; ; n++ {
+		l, ok := parseLight(tags, "seamark:light:"+strconv.Itoa(n)+":")
+		if !ok {
+			break
+		}
+		lights = append(lights, l)
+	}
+	return lights
+}
+
+func parseLight(tags map[string]string, prefix string) (models.Light, bool) {
+	l := models.Light{
+		Character: tags[prefix+"character"],
+		Group:     tags[prefix+"group"],
+		Colours:   splitListBy(tags[prefix+"colour"], ";"),
+		Period:    leadingFloat(tags[prefix+"period"]),
+		Height:    leadingFloat(tags[prefix+"height"]),
+		Range:     leadingFloat(tags[prefix+"range"]),
+	}
+	if l.Character == "" && len(l.Colours) == 0 {
+		return models.Light{}, false
+	}
+	if v, ok := tags[prefix+"sector_start"]; ok {
+		f := leadingFloat(v)
+		l.SectorStart = &f
+	}
+	if v, ok := tags[prefix+"sector_end"]; ok {
+		f := leadingFloat(v)
+		l.SectorEnd = &f
+	}
+	l.Description = l.Describe()
+	return l, true
+}
+
+// leadingFloat читает число в начале значения тега ("10", "10 s", "12.5")
+func leadingFloat(s string) float64 {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return 0
+	}
+	f, _ := strconv.ParseFloat(fields[0], 64)
+	return f
+}
@@ -0,0 +1,330 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	aisstream "github.com/aisstream/ais-message-models/golang/aisStream"
+)
+
+// ==============================
+// ПОДПИСКА AIS
+// ==============================
+
+// maxFilterMMSI - ограничение aisstream.io на размер списка MMSI
+const maxFilterMMSI = 50
+
+// ErrNoAPIKey - ключ aisstream.io не задан
+var ErrNoAPIKey = errors.New("AISSTREAM_API_KEY is not set")
+
+// SubscriptionConfig - фильтры подписки на поток AIS.
+// Пустые MMSI и MessageTypes означают "все".
+type SubscriptionConfig struct {
+	BoundingBoxes [][][]float64 `json:"bounding_boxes"`          // [[[lat1, lon1], [lat2, lon2]], ...]
+	MMSI          []string      `json:"mmsi,omitempty"`          // Разрешенные MMSI, до 50
+	MessageTypes  []string      `json:"message_types,omitempty"` // Типы сообщений aisstream, например PositionReport
+}
+
+// DefaultSubscriptionConfig - российская Арктика, все суда и сообщения
+func DefaultSubscriptionConfig() SubscriptionConfig {
+	return SubscriptionConfig{
+		BoundingBoxes: [][][]float64{{{63.7, 33.0}, {90.0, 180.0}}},
+	}
+}
+
+// Validate проверяет рамки, MMSI и типы сообщений
+func (cfg SubscriptionConfig) Validate() error {
+	if len(cfg.BoundingBoxes) == 0 {
+		return errors.New("at least one bounding box is required")
+	}
+	for i, box := range cfg.BoundingBoxes {
+		if len(box) != 2 || len(box[0]) != 2 || len(box[1]) != 2 {
+			return fmt.Errorf("bounding box %d must be [[lat, lon], [lat, lon]]", i)
+		}
+		for _, corner := range box {
+			if corner[0] < -90 || corner[0] > 90 || corner[1] < -180 || corner[1] > 180 {
+				return fmt.Errorf("bounding box %d is out of range", i)
+			}
+		}
+	}
+
+	if len(cfg.MMSI) > maxFilterMMSI {
+		return fmt.Errorf("at most %d mmsi are allowed", maxFilterMMSI)
+	}
+	for _, mmsi := range cfg.MMSI {
+		if _, err := strconv.ParseUint(mmsi, 10, 32); err != nil || len(mmsi) != 9 {
+			return fmt.Errorf("invalid mmsi %q", mmsi)
+		}
+	}
+
+	for _, t := range cfg.MessageTypes {
+		if !aisstream.AisMessageTypes(t).IsValid() {
+			return fmt.Errorf("unknown message type %q", t)
+		}
+	}
+	return nil
+}
+
+// message строит сообщение подписки aisstream.io
+func (cfg SubscriptionConfig) message(apiKey string) aisstream.SubscriptionMessage {
+	msg := aisstream.SubscriptionMessage{
+		APIKey:          apiKey,
+		BoundingBoxes:   cfg.BoundingBoxes,
+		FiltersShipMMSI: cfg.MMSI,
+	}
+	for _, t := range cfg.MessageTypes {
+		msg.FilterMessageTypes = append(msg.FilterMessageTypes, aisstream.AisMessageTypes(t))
+	}
+	return msg
+}
+
+// allows повторяет фильтры aisstream.io на нашей стороне: запись их не знает,
+// а в живом потоке после смены подписки еще приходят сообщения по старой
+func (cfg SubscriptionConfig) allows(messageType string, mmsi int32, lat, lon float64) bool {
+	if len(cfg.MessageTypes) > 0 && !slices.Contains(cfg.MessageTypes, messageType) {
+		return false
+	}
+	if len(cfg.MMSI) > 0 && !slices.Contains(cfg.MMSI, fmt.Sprintf("%09d", mmsi)) {
+		return false
+	}
+	if lat == 0 && lon == 0 {
+		return true // Позиции нет - по рамкам не отсекаем
+	}
+	for _, box := range cfg.BoundingBoxes {
+		minLat, maxLat := min(box[0][0], box[1][0]), max(box[0][0], box[1][0])
+		minLon, maxLon := min(box[0][1], box[1][1]), max(box[0][1], box[1][1])
+		if lat >= minLat && lat <= maxLat && lon >= minLon && lon <= maxLon {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscription - текущая подписка AIS. Изменение отправляется в открытое
+// соединение без переподключения, так что потребители потока не теряются.
+type Subscription struct {
+	mu     sync.RWMutex
+	apiKey string
+	cfg    SubscriptionConfig
+	live   *LiveSource
+}
+
+// NewSubscription создает подписку с заданными фильтрами
+func NewSubscription(apiKey string, cfg SubscriptionConfig) (*Subscription, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Subscription{apiKey: apiKey, cfg: cfg}, nil
+}
+
+// LoadSubscription читает подписку из окружения:
+// AISSTREAM_API_KEY - ключ aisstream.io,
+// AIS_SUBSCRIPTION_FILE - JSON с полями SubscriptionConfig (и необязательным api_key),
+// AIS_BBOXES - рамки "lat1,lon1,lat2,lon2;...",
+// AIS_MMSI - разрешенные MMSI через запятую,
+// AIS_MESSAGE_TYPES - типы сообщений через запятую.
+// Переменные окружения имеют приоритет над файлом.
+func LoadSubscription() (*Subscription, error) {
+	cfg := DefaultSubscriptionConfig()
+	apiKey := ""
+
+	if path := os.Getenv("AIS_SUBSCRIPTION_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var file struct {
+			SubscriptionConfig
+			APIKey string `json:"api_key"`
+		}
+		file.SubscriptionConfig = cfg
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", path, err)
+		}
+		cfg, apiKey = file.SubscriptionConfig, file.APIKey
+	}
+
+	if v := os.Getenv("AISSTREAM_API_KEY"); v != "" {
+		apiKey = v
+	}
+	if v := os.Getenv("AIS_BBOXES"); v != "" {
+		boxes, err := parseBoundingBoxes(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AIS_BBOXES: %w", err)
+		}
+		cfg.BoundingBoxes = boxes
+	}
+	if v := os.Getenv("AIS_MMSI"); v != "" {
+		cfg.MMSI = splitList(v)
+	}
+	if v := os.Getenv("AIS_MESSAGE_TYPES"); v != "" {
+		cfg.MessageTypes = splitList(v)
+	}
+
+	return NewSubscription(apiKey, cfg)
+}
+
+// parseBoundingBoxes разбирает рамки "lat1,lon1,lat2,lon2;..."
+func parseBoundingBoxes(s string) ([][][]float64, error) {
+	var boxes [][][]float64
+	for _, part := range splitListBy(s, ";") {
+		fields := splitList(part)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("box %q must have 4 numbers", part)
+		}
+		var v [4]float64
+		for i, f := range fields {
+			parsed, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return nil, fmt.Errorf("box %q: %w", part, err)
+			}
+			v[i] = parsed
+		}
+		boxes = append(boxes, [][]float64{{v[0], v[1]}, {v[2], v[3]}})
+	}
+	return boxes, nil
+}
+
+func splitList(s string) []string {
+	return splitListBy(s, ",")
+}
+
+func splitListBy(s, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(s, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Config возвращает текущие фильтры
+func (s *Subscription) Config() SubscriptionConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+// HasAPIKey сообщает, задан ли ключ aisstream.io
+func (s *Subscription) HasAPIKey() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.apiKey != ""
+}
+
+// Update меняет фильтры и переподписывает открытое соединение
+func (s *Subscription) Update(cfg SubscriptionConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.cfg = cfg
+	live := s.live
+	msg := cfg.message(s.apiKey)
+	s.mu.Unlock()
+
+	if live != nil {
+		if err := live.Resubscribe(msg); err != nil {
+			return fmt.Errorf("resubscribe: %w", err)
+		}
+		log.Println("AIS subscription updated")
+	}
+	return nil
+}
+
+// dial открывает живой поток с текущими фильтрами и запоминает его для переподписки
+func (s *Subscription) dial() (*LiveSource, error) {
+	s.mu.RLock()
+	apiKey, msg := s.apiKey, s.cfg.message(s.apiKey)
+	s.mu.RUnlock()
+
+	if apiKey == "" {
+		return nil, ErrNoAPIKey
+	}
+	live, err := DialLive(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.live = live
+	s.mu.Unlock()
+	live.onClose = func() {
+		s.mu.Lock()
+		if s.live == live {
+			s.live = nil
+		}
+		s.mu.Unlock()
+	}
+	return live, nil
+}
+
+// filterEnvelope - поля сообщения aisstream, нужные для фильтрации.
+// Тело сообщения лежит под ключом с именем его типа.
+type filterEnvelope struct {
+	MessageType string `json:"MessageType"`
+	MetaData    struct {
+		MMSI      int32   `json:"MMSI"`
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+	} `json:"MetaData"`
+	Message map[string]struct {
+		UserID    int32   `json:"UserID"`
+		Latitude  float64 `json:"Latitude"`
+		Longitude float64 `json:"Longitude"`
+	} `json:"Message"`
+}
+
+// fields возвращает MMSI и позицию из тела сообщения, иначе из метаданных
+func (e *filterEnvelope) fields() (int32, float64, float64) {
+	mmsi, lat, lon := e.MetaData.MMSI, e.MetaData.Latitude, e.MetaData.Longitude
+	if body, ok := e.Message[e.MessageType]; ok {
+		if body.UserID != 0 {
+			mmsi = body.UserID
+		}
+		if body.Latitude != 0 || body.Longitude != 0 {
+			lat, lon = body.Latitude, body.Longitude
+		}
+	}
+	return mmsi, lat, lon
+}
+
+// filteredSource пропускает только сообщения, подходящие под текущую подписку
+type filteredSource struct {
+	src MessageSource
+	sub *Subscription
+}
+
+// Next возвращает следующее подходящее сообщение.
+// Неразбираемые сообщения пропускаются дальше - их отбросит Ingest.
+func (f *filteredSource) Next() (RawMessage, error) {
+	for {
+		msg, err := f.src.Next()
+		if err != nil {
+			return msg, err
+		}
+
+		var env filterEnvelope
+		if err := json.Unmarshal(msg.Data, &env); err != nil {
+			return msg, nil
+		}
+		mmsi, lat, lon := env.fields()
+		if f.sub.Config().allows(env.MessageType, mmsi, lat, lon) {
+			return msg, nil
+		}
+	}
+}
+
+// Close закрывает исходный источник
+func (f *filteredSource) Close() error {
+	return f.src.Close()
+}
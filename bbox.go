@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/s3nkyh/arcticeroute/api"
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// maxBBoxArea - наибольшая площадь области в запросах к Overpass, км²; задается BBOX_MAX_AREA_KM2
+var maxBBoxArea = 20e6
+
+// envBBox читает область "south,west,north,east" из переменной окружения key; пустая - def
+func envBBox(key string, def models.BBox) (models.BBox, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	b, err := models.ParseBBox(v)
+	if err != nil {
+		return models.BBox{}, fmt.Errorf("%s: %w", key, err)
+	}
+	return b, nil
+}
+
+// queryBBox читает область из параметра bbox ("south,west,north,east").
+// Без параметра возвращает def; nil - область не ограничена. При ошибке отвечает 400.
+func queryBBox(c *gin.Context, def *models.BBox) (*models.BBox, bool) {
+	v := c.Query("bbox")
+	if v == "" {
+		return def, true
+	}
+	b, err := models.ParseBBox(v)
+	if err != nil {
+		bboxError(c, err)
+		return nil, false
+	}
+	return &b, true
+}
+
+// queryOverpassBBox читает область, как queryBBox, и ограничивает ее площадь maxBBoxArea:
+// область уходит запросом в Overpass, фильтрам в памяти ограничение не нужно
+func queryOverpassBBox(c *gin.Context, def *models.BBox) (*models.BBox, bool) {
+	bbox, ok := queryBBox(c, def)
+	if !ok || bbox == nil || bbox == def {
+		return bbox, ok
+	}
+	if err := bbox.CheckArea(maxBBoxArea); err != nil {
+		bboxError(c, err)
+		return nil, false
+	}
+	return bbox, true
+}
+
+// bboxError отвечает 400 с причиной, по которой отклонена область
+func bboxError(c *gin.Context, err error) {
+	var e *models.BBoxError
+	if errors.As(err, &e) {
+		c.JSON(400, gin.H{"error": err.Error(), "param": "bbox", "reason": e.Reason, "detail": e.Detail})
+		return
+	}
+	c.JSON(400, gin.H{"error": err.Error(), "param": "bbox"})
+}
+
+// osmError переводит ошибку загрузки объектов OSM в ответ
+func osmError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidBBox):
+		bboxError(c, err)
+	case errors.Is(err, api.ErrNoOSMSource):
+		c.JSON(503, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
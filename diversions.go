@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/s3nkyh/arcticeroute/models"
+	"github.com/s3nkyh/arcticeroute/service"
+)
+
+// queryFloat читает необязательное число из параметра запроса; NaN и бесконечность - ошибка,
+// чтобы они не проходили дальнейшие проверки диапазона
+func queryFloat(c *gin.Context, key string) (float64, bool, error) {
+	v := c.Query(key)
+	if v == "" {
+		return 0, false, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		err = fmt.Errorf("%s %q is not a finite number", key, v)
+	}
+	return f, true, err
+}
+
+// getDiversions подбирает порт или место убежища для судна (?mmsi=), позиции (?lat=&lon=)
+// или места, заданного названием (?place=).
+// Осадка, ледовый класс и скорость берутся из реестра судов и уточняются
+// параметрами draught, ice_class, speed; rank=distance ищет и ранжирует кратчайшие
+// по длине пути вместо самых быстрых.
+func getDiversions(c *gin.Context) {
+	at, ok := estimateTime(c)
+	if !ok {
+		return
+	}
+	req := service.DiversionRequest{At: at, RankBy: c.Query("rank")}
+	var place *service.PlaceMatch
+
+	if v := c.Query("mmsi"); v != "" {
+		mmsi, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "invalid mmsi"})
+			return
+		}
+		estimate, found := shipStore.Estimate(int32(mmsi), at)
+		if !found {
+			c.JSON(404, gin.H{"error": "ship not found"})
+			return
+		}
+		req.From = models.Point{Name: estimate.Name, Lat: estimate.PredictedLatitude, Lon: estimate.PredictedLongitude}
+		if profile, found := vesselRegistry.Profile(int32(mmsi)); found {
+			req.Profile = profile
+		} else {
+			req.Profile = models.VesselProfile{MMSI: int32(mmsi), Name: estimate.Name, ShipType: estimate.ShipType}
+		}
+	} else if req.From, place, ok = queryPosition(c); !ok {
+		return
+	}
+
+	draught, has, err := queryFloat(c, "draught")
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid draught"})
+		return
+	}
+	if has {
+		req.Profile.Draught = draught
+	}
+	if v := c.Query("ice_class"); v != "" {
+		if service.IceClassRank(v) == 0 && v != "none" {
+			c.JSON(400, gin.H{"error": "unknown ice class: " + v})
+			return
+		}
+		req.Profile.IceClass = v
+	}
+	if req.Speed, _, err = queryFloat(c, "speed"); err != nil {
+		c.JSON(400, gin.H{"error": "invalid speed"})
+		return
+	}
+
+	plan, err := diversionPlanner.Plan(req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidDiversion) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	plan.Place = place
+	c.JSON(200, plan)
+}
+
+func getHavens(c *gin.Context) {
+	c.JSON(200, diversionPlanner.Havens())
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestQueryFloat(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    float64
+		has     bool
+		wantErr bool
+	}{
+		{"", 0, false, false},
+		{"speed=12.5", 12.5, true, false},
+		{"speed=-3", -3, true, false},
+		{"speed=abc", 0, true, true},
+		{"speed=NaN", 0, true, true},
+		{"speed=nan", 0, true, true},
+		{"speed=Inf", 0, true, true},
+		{"speed=-Infinity", 0, true, true},
+		{"speed=1e400", 0, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/?"+tt.query, nil)
+			got, has, err := queryFloat(c, "speed")
+			if has != tt.has || (err != nil) != tt.wantErr {
+				t.Fatalf("queryFloat = %g, %v, %v; want has %v, error %v", got, has, err, tt.has, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("queryFloat = %g, want %g", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/s3nkyh/arcticeroute/models"
+	"github.com/s3nkyh/arcticeroute/service"
+)
+
+// maxNotSeenHours - наибольший порог "давно не видели", ч
+const maxNotSeenHours = 24 * 365
+
+// notSeenAfter читает порог "давно не видели" из параметра not_seen_hours (по умолчанию 6 ч)
+func notSeenAfter(c *gin.Context) (time.Duration, bool) {
+	hours, has, err := queryFloat(c, "not_seen_hours")
+	if !has {
+		hours = 6
+	}
+	if err != nil || hours <= 0 || hours > maxNotSeenHours {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("not_seen_hours must be between 0 and %d", maxNotSeenHours)})
+		return 0, false
+	}
+	return time.Duration(hours * float64(time.Hour)), true
+}
+
+// fleetError переводит ошибку хранилища флотов в ответ
+func fleetError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrFleetNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidFleet):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
+
+func getFleets(c *gin.Context) {
+	notSeen, ok := notSeenAfter(c)
+	if !ok {
+		return
+	}
+
+	now := shipStore.Now()
+	fleets := fleetStore.List()
+	result := make([]gin.H, 0, len(fleets))
+	for _, f := range fleets {
+		result = append(result, gin.H{
+			"id":          f.ID,
+			"name":        f.Name,
+			"description": f.Description,
+			"vessels":     f.Vessels,
+			"summary":     fleetStore.Status(f, shipStore, now, notSeen).Summary,
+		})
+	}
+	c.JSON(200, result)
+}
+
+func getFleet(c *gin.Context) {
+	notSeen, ok := notSeenAfter(c)
+	if !ok {
+		return
+	}
+	f, found := fleetStore.Get(c.Param("id"))
+	if !found {
+		c.JSON(404, gin.H{"error": "fleet not found"})
+		return
+	}
+	c.JSON(200, fleetStore.Status(f, shipStore, shipStore.Now(), notSeen))
+}
+
+func createFleet(c *gin.Context) {
+	var f models.Fleet
+	if err := c.ShouldBindJSON(&f); err != nil {
+		c.JSON(400, gin.H{"error": "invalid fleet: " + err.Error()})
+		return
+	}
+	created, err := fleetStore.Create(f)
+	if err != nil {
+		fleetError(c, err)
+		return
+	}
+	c.JSON(201, created)
+}
+
+func updateFleet(c *gin.Context) {
+	var f models.Fleet
+	if err := c.ShouldBindJSON(&f); err != nil {
+		c.JSON(400, gin.H{"error": "invalid fleet: " + err.Error()})
+		return
+	}
+	updated, err := fleetStore.Update(c.Param("id"), f)
+	if err != nil {
+		fleetError(c, err)
+		return
+	}
+	c.JSON(200, updated)
+}
+
+func deleteFleet(c *gin.Context) {
+	if err := fleetStore.Delete(c.Param("id")); err != nil {
+		fleetError(c, err)
+		return
+	}
+	c.Status(204)
+}
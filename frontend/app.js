@@ -111,67 +111,42 @@ function addPointsToMap(points) {
 }
 
 function addGlaciersToMap(glaciers) {
-    if (!glaciers || !Array.isArray(glaciers)) {
+    if (!glaciers || glaciers.type !== 'FeatureCollection') {
         console.error('❌ Invalid glaciers data:', glaciers);
         return;
     }
 
-    let validGlaciers = 0;
-    let invalidGlaciers = 0;
-
-    glaciers.forEach((glacier, index) => {
-        if (index < 5) {
-            console.log(`🔍 Glacier ${index}:`, glacier);
-        }
-
-        const lat = glacier.latitude || glacier.Latitude || glacier.lat;
-        const lon = glacier.longitude || glacier.Longitude || glacier.lon;
-        const name = glacier.name || glacier.Name || `Glacier ${glacier.id || glacier.ID}`;
-        const type = glacier.type || glacier.Type || 'Unknown';
-        const id = glacier.id || glacier.ID;
-
-        if (!isValidCoordinate(lat, lon)) {
-            invalidGlaciers++;
-            if (invalidGlaciers <= 5) {
-                console.warn(`❌ Invalid glacier coordinates: ${name} (${lat}, ${lon})`);
-            }
-            return;
-        }
-
-        try {
-            const marker = L.marker([lat, lon], { icon: glacierIcon })
-                .addTo(map)
-                .bindPopup(`
-                    <div class="glacier-popup">
-                        <h3>${name}</h3>
-                        <div class="popup-info">
-                            <strong>Type:</strong> ${type}<br>
-                            <strong>Position:</strong> ${lat.toFixed(4)}, ${lon.toFixed(4)}<br>
-                            <strong>ID:</strong> ${id || 'N/A'}
-                        </div>
-                    </div>
-                `);
-
-            marker.on('click', () => {
-                document.getElementById('selectedInfo').innerHTML = `
-                    <h4>🧊 ${name}</h4>
-                    <p><strong>Type:</strong> ${type}</p>
-                    <p><strong>ID:</strong> ${id || 'N/A'}</p>
-                    <p><strong>Latitude:</strong> ${lat.toFixed(6)}</p>
-                    <p><strong>Longitude:</strong> ${lon.toFixed(6)}</p>
-                `;
+    const layer = L.geoJSON(glaciers, {
+        style: feature => ({
+            color: feature.properties.tidewater ? '#d9480f' : '#1c7ed6',
+            weight: 1,
+            fillOpacity: 0.3
+        }),
+        pointToLayer: (feature, latlng) => L.marker(latlng, { icon: glacierIcon }),
+        onEachFeature: (feature, featureLayer) => {
+            const props = feature.properties;
+            const [lon, lat] = props.center;
+            const details = `
+                <strong>Type:</strong> ${props.osm_type}${props.tidewater ? ' (tidewater)' : ''}<br>
+                <strong>Area:</strong> ${(props.area / 1e6).toFixed(1)} km²<br>
+                <strong>Perimeter:</strong> ${(props.perimeter / 1000).toFixed(1)} km<br>
+                <strong>Center:</strong> ${lat.toFixed(4)}, ${lon.toFixed(4)}<br>
+                <strong>ID:</strong> ${props.osm_id}
+            `;
+            featureLayer.bindPopup(`
+                <div class="glacier-popup">
+                    <h3>${props.name}</h3>
+                    <div class="popup-info">${details}</div>
+                </div>
+            `);
+            featureLayer.on('click', () => {
+                document.getElementById('selectedInfo').innerHTML = `<h4>🧊 ${props.name}</h4><p>${details}</p>`;
             });
-
-            glacierMarkers.push(marker);
-            validGlaciers++;
-
-        } catch (error) {
-            console.error(`❌ Error adding glacier ${name}:`, error);
-            invalidGlaciers++;
         }
-    });
+    }).addTo(map);
 
-    console.log(`📊 Glaciers summary: ${validGlaciers} valid, ${invalidGlaciers} invalid`);
+    glacierMarkers.push(layer);
+    console.log(`📊 Glaciers summary: ${glaciers.features.length} features`);
 }
 
 function isValidCoordinate(lat, lon) {
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/s3nkyh/arcticeroute/service"
+)
+
+// storeError переводит ошибку сохраняемых коллекций (геозоны, вебхуки) в ответ
+func storeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrGeofenceNotFound), errors.Is(err, service.ErrWebhookNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidGeofence), errors.Is(err, service.ErrInvalidWebhook):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
+
+func getGeofences(c *gin.Context) {
+	c.JSON(200, geofenceMonitor.List())
+}
+
+func getGeofence(c *gin.Context) {
+	fence, inside, found := geofenceMonitor.Get(c.Param("id"))
+	if !found {
+		c.JSON(404, gin.H{"error": "geofence not found"})
+		return
+	}
+	c.JSON(200, gin.H{"geofence": fence, "inside": inside})
+}
+
+func createGeofence(c *gin.Context) {
+	var fence service.Geofence
+	if err := c.ShouldBindJSON(&fence); err != nil {
+		c.JSON(400, gin.H{"error": "invalid geofence: " + err.Error()})
+		return
+	}
+	created, err := geofenceMonitor.Create(fence)
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	c.JSON(201, created)
+}
+
+func updateGeofence(c *gin.Context) {
+	var fence service.Geofence
+	if err := c.ShouldBindJSON(&fence); err != nil {
+		c.JSON(400, gin.H{"error": "invalid geofence: " + err.Error()})
+		return
+	}
+	updated, err := geofenceMonitor.Update(c.Param("id"), fence)
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	c.JSON(200, updated)
+}
+
+func deleteGeofence(c *gin.Context) {
+	if err := geofenceMonitor.Delete(c.Param("id")); err != nil {
+		storeError(c, err)
+		return
+	}
+	c.Status(204)
+}
+
+func getGeofenceEvents(c *gin.Context) {
+	filter := service.GeofenceEventFilter{
+		GeofenceID: c.Query("geofence"),
+		Type:       c.Query("type"),
+	}
+	if v := c.Query("mmsi"); v != "" {
+		mmsi, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "invalid mmsi"})
+			return
+		}
+		filter.MMSI = int32(mmsi)
+	}
+	if v := c.Query("since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "invalid since: " + err.Error()})
+			return
+		}
+		filter.Since = since
+	}
+	c.JSON(200, geofenceMonitor.Events(filter))
+}
+
+func getWebhooks(c *gin.Context) {
+	c.JSON(200, webhooks.List())
+}
+
+func createWebhook(c *gin.Context) {
+	var hook service.Webhook
+	if err := c.ShouldBindJSON(&hook); err != nil {
+		c.JSON(400, gin.H{"error": "invalid webhook: " + err.Error()})
+		return
+	}
+	created, err := webhooks.Create(hook)
+	if err != nil {
+		storeError(c, err)
+		return
+	}
+	c.JSON(201, created)
+}
+
+func deleteWebhook(c *gin.Context) {
+	if err := webhooks.Delete(c.Param("id")); err != nil {
+		storeError(c, err)
+		return
+	}
+	c.Status(204)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/s3nkyh/arcticeroute/api"
+	"github.com/s3nkyh/arcticeroute/models"
+	"github.com/s3nkyh/arcticeroute/service"
+)
+
+// glacierBBox - область ледников по умолчанию: российская Арктика
+var glacierBBox = models.MustParseBBox("65,30,90,180")
+
+// glacierHazardRadius - радиус охвата ледника вокруг его центральной точки, м
+const glacierHazardRadius = 5000
+
+// refreshGlacierHazards загружает ледники из OSM как ледовые опасности и обновляет их раз в сутки
+func refreshGlacierHazards() {
+	for {
+		if err := updateGlacierHazards(); err != nil {
+			log.Println("Glacier hazards error:", err)
+			if errors.Is(err, models.ErrInvalidBBox) {
+				return
+			}
+			time.Sleep(time.Hour)
+			continue
+		}
+		time.Sleep(24 * time.Hour)
+	}
+}
+
+// updateGlacierHazards заменяет ледовые опасности от ледников.
+// Область задается GLACIER_BBOX (south,west,north,east), по умолчанию российская Арктика.
+func updateGlacierHazards() error {
+	bbox, err := envBBox("GLACIER_BBOX", glacierBBox)
+	if err != nil {
+		return err
+	}
+	glaciers, err := api.GetGlaciers(context.Background(), osmSource, bbox)
+	if err != nil {
+		return err
+	}
+	hazards := service.GlacierHazards(glaciers, glacierHazardRadius)
+	iceHazards.SetHazards("osm", hazards)
+	log.Printf("Glacier hazards loaded: %d from %d glaciers", len(hazards), len(glaciers))
+	return nil
+}
+
+func getHazards(c *gin.Context) {
+	at, ok := estimateTime(c)
+	if !ok {
+		return
+	}
+	bbox, ok := queryBBox(c, nil)
+	if !ok {
+		return
+	}
+	hazards := iceHazards.Hazards(at)
+	if bbox != nil {
+		filtered := make([]service.IceHazard, 0, len(hazards))
+		for _, h := range hazards {
+			// Опасность попадает в область, если ее круг задевает область, а не только центр
+			if bbox.Distance(h.Point.Lat, h.Point.Lon) <= h.Radius {
+				filtered = append(filtered, h)
+			}
+		}
+		hazards = filtered
+	}
+	c.JSON(200, hazards)
+}
+
+func getHazardWarnings(c *gin.Context) {
+	c.JSON(200, iceHazards.AllWarnings())
+}
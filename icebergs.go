@@ -0,0 +1,442 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/s3nkyh/arcticeroute/models"
+	"github.com/s3nkyh/arcticeroute/service"
+)
+
+var (
+	icebergStore *service.IcebergStore
+	driftModel   *service.DriftModel
+)
+
+// loadIcebergs открывает хранилище наблюдений айсбергов, загружает поля ветра и течения
+// для прогноза дрейфа и передает наблюдения монитору ледовых опасностей.
+// Срок действия наблюдения задается ICEBERG_TTL_HOURS, снос ветром - DRIFT_WIND_FACTOR.
+func loadIcebergs() error {
+	cfg := service.DefaultIcebergConfig()
+	cfg.TTL = time.Duration(envFloat("ICEBERG_TTL_HOURS", cfg.TTL.Hours()) * float64(time.Hour))
+	store, err := service.NewIcebergStore(filepath.Join(dataDir(), "icebergs.json"), cfg)
+	if err != nil {
+		return err
+	}
+
+	driftCfg := service.DefaultDriftConfig()
+	driftCfg.WindFactor = envFloat("DRIFT_WIND_FACTOR", driftCfg.WindFactor)
+	driftModel = service.NewDriftModel(driftCfg)
+	for _, kind := range []string{service.GridWind, service.GridCurrent} {
+		if err := loadFlowGrid(kind); err != nil {
+			return err
+		}
+	}
+
+	store.UseClock(shipStore.Now)
+	store.UseDrift(driftModel)
+	store.OnChange(func(list []models.IcebergObservation) {
+		iceHazards.SetHazards("icebergs", store.Hazards(list))
+	})
+	icebergStore = store
+	updateIcebergHazards()
+	return nil
+}
+
+// loadFlowGrid загружает поле kind из WIND_GRID_FILE / CURRENT_GRID_FILE
+// или из поля, ранее загруженного через API; без файла поле не задано
+func loadFlowGrid(kind string) error {
+	path := os.Getenv(strings.ToUpper(kind) + "_GRID_FILE")
+	if path == "" {
+		path = gridPath(kind)
+		if _, err := os.Stat(path); err != nil {
+			return nil
+		}
+	}
+	grid, err := service.ReadFlowGrid(path)
+	if err != nil {
+		return err
+	}
+	log.Printf("Drift %s grid loaded from %s", kind, path)
+	return driftModel.SetGrid(kind, grid)
+}
+
+// gridPath - файл, в котором хранится поле, загруженное через API
+func gridPath(kind string) string {
+	return filepath.Join(dataDir(), "grids", kind+".csv")
+}
+
+// updateIcebergHazards пересчитывает опасные зоны айсбергов, например после смены полей дрейфа
+func updateIcebergHazards() {
+	iceHazards.SetHazards("icebergs", icebergStore.Hazards(icebergStore.List(time.Time{}, true)))
+}
+
+// watchIcebergFiles импортирует файлы ледовых служб (CSV, GeoJSON) из каталога ICEBERG_DIR,
+// проверяя его раз в ICEBERG_POLL_MIN минут (по умолчанию 10). Измененный файл
+// импортируется заново; наблюдения из него не дублируются.
+func watchIcebergFiles() {
+	dir := os.Getenv("ICEBERG_DIR")
+	if dir == "" {
+		return
+	}
+	interval := time.Duration(envFloat("ICEBERG_POLL_MIN", 10) * float64(time.Minute))
+	seen := make(map[string]time.Time)
+	for {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			log.Println("Iceberg files error:", err)
+		}
+		for _, e := range entries {
+			ext := strings.ToLower(filepath.Ext(e.Name()))
+			if e.IsDir() || (ext != ".csv" && ext != ".geojson" && ext != ".json") {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			if modified, ok := seen[path]; ok && modified.Equal(info.ModTime()) {
+				continue
+			}
+			seen[path] = info.ModTime()
+
+			list, err := service.ReadIcebergs(path)
+			if err == nil {
+				_, err = icebergStore.Import(list)
+			}
+			if err != nil {
+				log.Printf("Iceberg file %s: %v", path, err)
+				continue
+			}
+			log.Printf("Iceberg observations imported from %s: %d", path, len(list))
+		}
+		time.Sleep(interval)
+	}
+}
+
+// icebergError переводит ошибку хранилища айсбергов в ответ
+func icebergError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrIcebergNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidIceberg):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
+
+// getIcebergs возвращает наблюдения айсбергов, действующие в момент ?at (все с ?all=true),
+// в области ?bbox; ?format=geojson - как FeatureCollection
+func getIcebergs(c *gin.Context) {
+	at, ok := estimateTime(c)
+	if !ok {
+		return
+	}
+	bbox, ok := queryBBox(c, nil)
+	if !ok {
+		return
+	}
+	list := icebergStore.List(at, c.Query("all") == "true")
+	if bbox != nil {
+		filtered := make([]models.IcebergObservation, 0, len(list))
+		for _, o := range list {
+			if bbox.Contains(o.Lat, o.Lon) {
+				filtered = append(filtered, o)
+			}
+		}
+		list = filtered
+	}
+
+	if c.Query("format") == "geojson" {
+		fc := geojson.NewFeatureCollection()
+		for _, o := range list {
+			f := o.Feature()
+			f.Properties["radius"] = icebergStore.Radius(o.Size)
+			fc.Append(f)
+		}
+		c.JSON(200, fc)
+		return
+	}
+	c.JSON(200, list)
+}
+
+func getIceberg(c *gin.Context) {
+	o, found := icebergStore.Get(c.Param("id"))
+	if !found {
+		c.JSON(404, gin.H{"error": "iceberg not found"})
+		return
+	}
+	c.JSON(200, o)
+}
+
+// icebergReport - донесение судна об айсберге
+type icebergReport struct {
+	Lat        *float64   `json:"lat"`
+	Lon        *float64   `json:"lon"`
+	Size       string     `json:"size"`
+	Length     float64    `json:"length"`
+	ObservedAt *time.Time `json:"observed_at"`
+	MMSI       int32      `json:"mmsi"`
+	Reporter   string     `json:"reporter"`
+	Note       string     `json:"note"`
+}
+
+// reportIceberg принимает донесение судна. Без observed_at берется текущее время,
+// без lat и lon - позиция судна mmsi на момент наблюдения.
+func reportIceberg(c *gin.Context) {
+	var r icebergReport
+	if err := c.ShouldBindJSON(&r); err != nil {
+		c.JSON(400, gin.H{"error": "invalid report: " + err.Error()})
+		return
+	}
+	o := models.IcebergObservation{
+		Size:     r.Size,
+		Length:   r.Length,
+		MMSI:     r.MMSI,
+		Reporter: r.Reporter,
+		Note:     r.Note,
+	}
+	o.ObservedAt = shipStore.Now()
+	if r.ObservedAt != nil {
+		o.ObservedAt = *r.ObservedAt
+	}
+	switch {
+	case r.Lat != nil && r.Lon != nil:
+		o.Lat, o.Lon = *r.Lat, *r.Lon
+	case r.MMSI != 0:
+		est, found := shipStore.Estimate(r.MMSI, o.ObservedAt)
+		if !found {
+			c.JSON(404, gin.H{"error": "ship not found"})
+			return
+		}
+		o.Lat, o.Lon = est.PredictedLatitude, est.PredictedLongitude
+	default:
+		c.JSON(400, gin.H{"error": "lat and lon or mmsi are required"})
+		return
+	}
+
+	created, err := icebergStore.Report(o)
+	if err != nil {
+		icebergError(c, err)
+		return
+	}
+	c.JSON(201, created)
+}
+
+// importIcebergs добавляет наблюдения ледовой службы из тела запроса: CSV (text/csv или
+// ?format=csv) или GeoJSON FeatureCollection. Источник задается ?source= (по умолчанию upload).
+func importIcebergs(c *gin.Context) {
+	format := c.Query("format")
+	if format == "" {
+		format = "geojson"
+		if strings.Contains(c.ContentType(), "csv") {
+			format = "csv"
+		}
+	}
+	source := c.DefaultQuery("source", "upload")
+
+	var list []models.IcebergObservation
+	var err error
+	switch format {
+	case "csv":
+		list, err = service.ParseIcebergsCSV(c.Request.Body, source)
+	case "geojson":
+		list, err = service.ParseIcebergsGeoJSON(c.Request.Body, source)
+	default:
+		c.JSON(400, gin.H{"error": "format must be csv or geojson"})
+		return
+	}
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	n, err := icebergStore.Import(list)
+	if err != nil {
+		icebergError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"imported": n})
+}
+
+func deleteIceberg(c *gin.Context) {
+	if err := icebergStore.Delete(c.Param("id")); err != nil {
+		icebergError(c, err)
+		return
+	}
+	c.Status(204)
+}
+
+// maxForecastHours - наибольшая длительность прогноза дрейфа
+const maxForecastHours = 240
+
+// forecastTimes читает параметры прогноза: ?hours (по умолчанию 24) и ?step в минутах
+// (по умолчанию 60, не больше всего прогноза) от момента from. При ошибке отвечает 400.
+func forecastTimes(c *gin.Context, from time.Time) ([]time.Time, bool) {
+	hours, has, err := queryFloat(c, "hours")
+	if !has {
+		hours = 24
+	}
+	if err != nil || hours < 0 || hours > maxForecastHours {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("hours must be between 0 and %d", maxForecastHours)})
+		return nil, false
+	}
+	step, has, err := queryFloat(c, "step")
+	if !has {
+		step = 60
+	}
+	if err != nil || step < 10 || step > maxForecastHours*60 {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("step must be between 10 and %d minutes", maxForecastHours*60)})
+		return nil, false
+	}
+	return service.ForecastTimes(from, hours, time.Duration(step*float64(time.Minute))), true
+}
+
+// getIcebergForecasts прогнозирует дрейф айсбергов, действующих в момент ?at, на ?hours вперед:
+// положения и эллипсы неопределенности через ?step минут. ?bbox отбирает айсберги
+// по положению на момент at; ?format=geojson - траектории и эллипсы как FeatureCollection.
+func getIcebergForecasts(c *gin.Context) {
+	at, ok := estimateTime(c)
+	if !ok {
+		return
+	}
+	bbox, ok := queryBBox(c, nil)
+	if !ok {
+		return
+	}
+	times, ok := forecastTimes(c, at)
+	if !ok {
+		return
+	}
+	forecasts := icebergStore.Forecast(icebergStore.List(at, false), times)
+	if bbox != nil {
+		filtered := make([]service.IcebergForecast, 0, len(forecasts))
+		for _, f := range forecasts {
+			if len(f.Fixes) > 0 && bbox.Contains(f.Fixes[0].Point.Lat, f.Fixes[0].Point.Lon) {
+				filtered = append(filtered, f)
+			}
+		}
+		forecasts = filtered
+	}
+	writeForecasts(c, forecasts)
+}
+
+// getIcebergForecast прогнозирует дрейф одного айсберга от ?at (не раньше наблюдения)
+func getIcebergForecast(c *gin.Context) {
+	o, found := icebergStore.Get(c.Param("id"))
+	if !found {
+		c.JSON(404, gin.H{"error": "iceberg not found"})
+		return
+	}
+	at, ok := estimateTime(c)
+	if !ok {
+		return
+	}
+	if at.Before(o.ObservedAt) {
+		at = o.ObservedAt
+	}
+	times, ok := forecastTimes(c, at)
+	if !ok {
+		return
+	}
+	forecasts := icebergStore.Forecast([]models.IcebergObservation{o}, times)
+	if c.Query("format") == "geojson" {
+		writeForecasts(c, forecasts)
+		return
+	}
+	c.JSON(200, forecasts[0])
+}
+
+// writeForecasts отвечает прогнозами; с ?format=geojson - траекторией (LineString)
+// и эллипсом неопределенности (Polygon) на каждый момент прогноза
+func writeForecasts(c *gin.Context, forecasts []service.IcebergForecast) {
+	if c.Query("format") != "geojson" {
+		c.JSON(200, forecasts)
+		return
+	}
+	fc := geojson.NewFeatureCollection()
+	for _, f := range forecasts {
+		if len(f.Fixes) == 0 {
+			continue
+		}
+		track := make(orb.LineString, 0, len(f.Fixes))
+		for _, fix := range f.Fixes {
+			track = append(track, orb.Point{fix.Point.Lon, fix.Point.Lat})
+		}
+		line := geojson.NewFeature(track)
+		line.ID = f.Iceberg.ID
+		line.Properties["kind"] = "track"
+		line.Properties["iceberg"] = f.Iceberg.ID
+		line.Properties["size"] = f.Iceberg.Size
+		line.Properties["radius"] = f.Radius
+		fc.Append(line)
+
+		for _, fix := range f.Fixes {
+			ellipse := geojson.NewFeature(fix.Ellipse.Polygon(fix.Point))
+			ellipse.Properties["kind"] = "ellipse"
+			ellipse.Properties["iceberg"] = f.Iceberg.ID
+			ellipse.Properties["at"] = fix.At
+			ellipse.Properties["lat"] = fix.Point.Lat
+			ellipse.Properties["lon"] = fix.Point.Lon
+			ellipse.Properties["major"] = fix.Ellipse.Major
+			ellipse.Properties["minor"] = fix.Ellipse.Minor
+			ellipse.Properties["orientation"] = fix.Ellipse.Orientation
+			ellipse.Properties["speed"] = fix.Speed
+			ellipse.Properties["heading"] = fix.Heading
+			ellipse.Properties["no_data"] = fix.NoData
+			fc.Append(ellipse)
+		}
+	}
+	c.JSON(200, fc)
+}
+
+// getFlowGrids описывает загруженные поля ветра и течения
+func getFlowGrids(c *gin.Context) {
+	c.JSON(200, driftModel.Grids())
+}
+
+// updateFlowGrid заменяет поле ветра или течения (:kind) полем из CSV в теле запроса
+// и пересчитывает траектории айсбергов. Поле сохраняется и загружается при перезапуске.
+func updateFlowGrid(c *gin.Context) {
+	kind := c.Param("kind")
+	if kind != service.GridWind && kind != service.GridCurrent {
+		c.JSON(404, gin.H{"error": "grid kind must be wind or current"})
+		return
+	}
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	grid, err := service.ParseFlowGridCSV(bytes.NewReader(data))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	path := gridPath(kind)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if err := driftModel.SetGrid(kind, grid); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	updateIcebergHazards()
+	c.JSON(200, grid.Info(kind))
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestForecastTimesQuery(t *testing.T) {
+	from := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		query string
+		count int // Число моментов; -1 - ответ 400
+	}{
+		{"", 25},
+		{"hours=6&step=30", 13},
+		{"hours=240&step=14400", 2},
+		{"step=5", -1},
+		{"step=14401", -1},
+		{"step=1e12", -1},
+		{"step=NaN", -1},
+		{"step=Inf", -1},
+		{"hours=NaN", -1},
+		{"hours=-1", -1},
+		{"hours=241", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/?"+tt.query, nil)
+			times, ok := forecastTimes(c, from)
+			if tt.count < 0 {
+				if ok || w.Code != 400 {
+					t.Fatalf("got %d times and status %d, want 400", len(times), w.Code)
+				}
+				return
+			}
+			if !ok || len(times) != tt.count {
+				t.Errorf("got %d times (ok %v), want %d", len(times), ok, tt.count)
+			}
+		})
+	}
+}
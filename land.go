@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/s3nkyh/arcticeroute/api"
+	"github.com/s3nkyh/arcticeroute/osm"
+	"github.com/s3nkyh/arcticeroute/service"
+)
+
+// landReload - запрос на перестройку суши, например после импорта выгрузки OSM
+var landReload = make(chan struct{}, 1)
+
+// reloadLand просит цикл runLand перестроить сушу; повторные запросы до начала перестройки сливаются
+func reloadLand() {
+	select {
+	case landReload <- struct{}{}:
+	default:
+	}
+}
+
+// runLand - единственный цикл построения суши: строит ее при запуске и по reloadLand,
+// а при недоступности Overpass повторяет попытку раз в час
+func runLand() {
+	for {
+		var retry <-chan time.Time
+		if !loadLand() {
+			retry = time.After(time.Hour)
+		}
+		select {
+		case <-landReload:
+		case <-retry:
+		}
+	}
+}
+
+// loadLand заполняет детектор суши полигонами из береговой линии OSM.
+// Область задается COASTLINE_BBOX (south,west,north,east), по умолчанию область морских путей.
+// Линии берега берутся из импортированной выгрузки OSM_PBF или Overpass; COASTLINE_FILE - отдельный
+// файл с береговой линией, LAND_SIMPLIFY_M - допуск упрощения, LAND_MIN_AREA_M2 - площадь
+// самого мелкого сохраняемого острова.
+// Результат хранится в DATA_DIR/land.json и перестраивается раз в LAND_CACHE_DAYS дней.
+// Возвращает false, если линии берега не загрузились из Overpass и попытку стоит повторить.
+func loadLand() bool {
+	bbox, err := envBBox("COASTLINE_BBOX", service.SeawayBBox)
+	if err == nil && bbox.CrossesAntimeridian() {
+		err = fmt.Errorf("COASTLINE_BBOX %s crosses the antimeridian", bbox)
+	}
+	if err != nil {
+		log.Println("Land polygons disabled:", err)
+		return true
+	}
+	region := bbox.Bound()
+	file := os.Getenv("COASTLINE_FILE")
+	source := file
+	if source == "" {
+		source = osmSource.Origin(osm.LayerCoastlines)
+	}
+	cfg := service.DefaultLandConfig()
+	cfg.Tolerance = envFloat("LAND_SIMPLIFY_M", cfg.Tolerance)
+	cfg.MinArea = envFloat("LAND_MIN_AREA_M2", cfg.MinArea)
+	ttl := time.Duration(envFloat("LAND_CACHE_DAYS", 30) * float64(24*time.Hour))
+	path := filepath.Join(dataDir(), "land.json")
+
+	cache, err := service.LoadLandCache(path)
+	if err != nil {
+		log.Println("Land cache error:", err)
+	}
+	if cache != nil && cache.Matches(bbox.String(), source, cfg) {
+		landDetector.SetLandPolygons(cache.Polygons())
+		log.Printf("Land polygons loaded from cache: %d", landDetector.PolygonCount())
+		if time.Since(cache.CreatedAt) < ttl {
+			return true
+		}
+	}
+
+	var lines []orb.LineString
+	if file == "" {
+		lines, err = api.GetCoastlines(context.Background(), osmSource, bbox)
+	} else {
+		lines, err = api.ReadCoastlines(file)
+	}
+	if err == nil && len(lines) == 0 {
+		err = fmt.Errorf("no coastline ways in %s", bbox)
+	}
+	if err != nil {
+		log.Println("Coastline error:", err)
+		return source != "overpass" || errors.Is(err, api.ErrNoOSMSource)
+	}
+
+	polygons := service.BuildLandPolygons(lines, region, cfg)
+	landDetector.SetLandPolygons(polygons)
+	log.Printf("Land polygons built: %d from %d coastline ways", len(polygons), len(lines))
+	if err := service.SaveLandCache(path, service.NewLandCache(bbox.String(), source, cfg, polygons)); err != nil {
+		log.Println("Land cache error:", err)
+	}
+	return true
+}
@@ -2,21 +2,198 @@ package main
 
 import (
 	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/paulmach/orb/geojson"
 	"github.com/s3nkyh/arcticeroute/api"
+	"github.com/s3nkyh/arcticeroute/models"
+	"github.com/s3nkyh/arcticeroute/osm"
+	"github.com/s3nkyh/arcticeroute/overpass"
 	"github.com/s3nkyh/arcticeroute/service"
 )
 
+var (
+	shipStore        *service.ShipStore
+	liveHub          *service.LiveHub
+	collisionMonitor *service.CollisionMonitor
+	landDetector     *service.LandDetector
+	anomalyDetector  *service.AnomalyDetector
+	voyageTracker    *service.VoyageTracker
+	trafficDensity   *service.TrafficDensity
+	aisSubscription  *api.Subscription
+	fleetStore       *service.FleetStore
+	routeMonitor     *service.RouteMonitor
+	geofenceMonitor  *service.GeofenceMonitor
+	webhooks         *service.WebhookDispatcher
+	iceHazards       *service.IceHazardMonitor
+	vesselRegistry   *service.VesselRegistry
+	diversionPlanner *service.DiversionPlanner
+	sarAssist        *service.SARAssist
+	osmSource        api.OSMSource
+	fairway          *service.Fairway
+	portRegistry     *service.PortRegistry
+	geocoder         *service.Geocoder
+)
+
 func main() {
+	shipStore = service.NewShipStore()
+	liveHub = service.NewLiveHub()
+	if api.ReplayConfigured() {
+		shipStore.UseDataClock()
+	}
+	drCfg := service.DefaultDeadReckoningConfig()
+	drCfg.StaleAfter = time.Duration(envFloat("SHIP_STALE_MIN", drCfg.StaleAfter.Minutes()) * float64(time.Minute))
+	shipStore.SetDeadReckoning(drCfg)
+	shipStore.OnUpdate(func(ship models.Ship) {
+		liveHub.Publish("position", ship)
+	})
+
+	collisionCfg := service.DefaultCollisionConfig()
+	collisionCfg.CPALimit = envFloat("CPA_ALERT_NM", collisionCfg.CPALimit/1852) * 1852
+	collisionCfg.TCPALimit = time.Duration(envFloat("TCPA_ALERT_MIN", collisionCfg.TCPALimit.Minutes()) * float64(time.Minute))
+	collisionMonitor = service.NewCollisionMonitor(shipStore, liveHub, collisionCfg)
+
+	landDetector = service.NewLandDetector(60.0, 90.0, -180.0, 180.0)
+
+	var err error
+	anomalyCfg := service.DefaultAnomalyConfig()
+	anomalyCfg.GapWarning = time.Duration(envFloat("AIS_GAP_WARN_MIN", anomalyCfg.GapWarning.Minutes()) * float64(time.Minute))
+	anomalyCfg.GapCritical = time.Duration(envFloat("AIS_GAP_CRIT_MIN", anomalyCfg.GapCritical.Minutes()) * float64(time.Minute))
+	anomalyDetector, err = service.NewAnomalyDetector(shipStore, landDetector, liveHub, filepath.Join(dataDir(), "anomalies.json"), anomalyCfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	portRegistry, err = loadPorts()
+	if err != nil {
+		log.Fatal(err)
+	}
+	geocoder = service.NewGeocoder()
+	geocoder.SetPlaces(service.PlaceSourcePorts, service.PlacesFromPorts(portRegistry.List()))
+	gazetteer, err := loadGazetteer()
+	if err != nil {
+		log.Fatal(err)
+	}
+	geocoder.SetPlaces(service.PlaceSourceGazetteer, gazetteer)
+
+	portZones := service.PortZonesFromPorts(portRegistry.List(), service.PortZoneRadius, service.PortAnchorageRadius)
+	voyageTracker = service.NewVoyageTracker(shipStore, portZones, service.DefaultVoyageConfig())
+
+	trafficDensity = service.NewTrafficDensity(service.DefaultTrafficConfig())
+	shipStore.OnUpdate(trafficDensity.Add)
+
+	fleets, err := service.NewFleetStore(filepath.Join(dataDir(), "fleets.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fleetStore = fleets
+
+	routeCfg := service.DefaultRouteMonitorConfig()
+	routeCfg.Corridor = envFloat("ROUTE_CORRIDOR_NM", routeCfg.Corridor/1852) * 1852
+	routeMonitor, err = service.NewRouteMonitor(shipStore, liveHub, filepath.Join(dataDir(), "routes.json"), routeCfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	geofenceMonitor, err = service.NewGeofenceMonitor(shipStore, liveHub, filepath.Join(dataDir(), "geofences.json"), filepath.Join(dataDir(), "geofence_events.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	webhooks, err = service.NewWebhookDispatcher(liveHub, filepath.Join(dataDir(), "webhooks.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	maxBBoxArea = envFloat("BBOX_MAX_AREA_KM2", maxBBoxArea)
+
+	// OVERPASS_URL=off - работа без сети, только с импортированной выгрузкой OSM_PBF
+	if endpoint := os.Getenv("OVERPASS_URL"); endpoint != "off" {
+		overpassCfg := overpass.DefaultConfig()
+		if endpoint != "" {
+			overpassCfg.Endpoint = endpoint
+		}
+		overpassCfg.Timeout = time.Duration(envFloat("OVERPASS_TIMEOUT_SEC", overpassCfg.Timeout.Seconds()) * float64(time.Second))
+		overpassCfg.CacheTTL = time.Duration(envFloat("OVERPASS_CACHE_TTL_HOURS", overpassCfg.CacheTTL.Hours()) * float64(time.Hour))
+		overpassCfg.CacheSize = int64(envFloat("OVERPASS_CACHE_MAX_MB", float64(overpassCfg.CacheSize>>20))) << 20
+		overpassCfg.CacheDir = filepath.Join(dataDir(), "overpass")
+		osmSource.Client = overpass.NewClient(overpassCfg)
+	}
+	osmSource.Store, err = osm.OpenStore(filepath.Join(dataDir(), "osm"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	iceCfg := service.DefaultIceHazardConfig()
+	iceCfg.WarnRange = envFloat("ICE_WARN_NM", iceCfg.WarnRange/1852) * 1852
+	iceHazards = service.NewIceHazardMonitor(shipStore, liveHub, iceCfg)
+	if err := loadIcebergs(); err != nil {
+		log.Fatal(err)
+	}
+	go watchIcebergFiles()
+	// FAIRWAY_SNAP=on - подходы к портам проводятся по буям фарватера из OSM
+	if os.Getenv("FAIRWAY_SNAP") == "on" {
+		fairway = service.NewFairway(service.DefaultFairwayConfig())
+	}
+	go func() {
+		importOSMExtract()
+		go refreshGlacierHazards()
+		if fairway != nil {
+			go refreshFairway()
+		}
+		go refreshPlaces()
+		runLand()
+	}()
+
+	vesselRegistry, err = service.NewVesselRegistry(filepath.Join(dataDir(), "vessels.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	havens := service.HavensFromPorts(portRegistry.List())
+	arcticRouter := service.NewArcticRouter(havens, landDetector)
+	diversionPlanner = service.NewDiversionPlanner(arcticRouter, havens, iceHazards, service.DefaultDiversionConfig())
+	landDetector.OnChange(diversionPlanner.Rebuild)
+	if fairway != nil {
+		diversionPlanner.UseFairway(fairway)
+	}
+	// TRAFFIC_WEIGHT > 0 - ребра сети вне наезженных трасс дорожают до 1 + TRAFFIC_WEIGHT раз
+	if strength := envFloat("TRAFFIC_WEIGHT", 0); strength > 0 {
+		diversionPlanner.UseTrafficWeights(trafficDensity, os.Getenv("TRAFFIC_WEIGHT_WINDOW"), strength)
+		go refreshTrafficWeights()
+	}
+	if history := os.Getenv("TRAFFIC_HISTORY"); history != "" {
+		go loadTrafficHistory(strings.Split(history, ","))
+	}
+	sarAssist = service.NewSARAssist(shipStore, vesselRegistry, diversionPlanner, service.DefaultSARConfig())
+	portRegistry.OnChange(func(ports []models.Port) {
+		diversionPlanner.SetHavens(service.HavensFromPorts(ports))
+		voyageTracker.SetPorts(service.PortZonesFromPorts(ports, service.PortZoneRadius, service.PortAnchorageRadius))
+		geocoder.SetPlaces(service.PlaceSourcePorts, service.PlacesFromPorts(ports))
+	})
+
+	sub, err := api.LoadSubscription()
+	if err != nil {
+		log.Fatal("AIS subscription: ", err)
+	}
+	aisSubscription = sub
+	if !api.ReplayConfigured() && !aisSubscription.HasAPIKey() {
+		log.Println("AISSTREAM_API_KEY is not set, live AIS stream disabled")
+	}
+
+	go runIngest()
+
 	r := gin.Default()
 
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
@@ -26,10 +203,76 @@ func main() {
 	{
 		apiGroup.GET("/points", getPoints)
 		apiGroup.GET("/ships", getShips)
+		apiGroup.GET("/ships/:mmsi", getShip)
+		apiGroup.GET("/ships/:mmsi/route", getShipRoute)
+		apiGroup.PUT("/ships/:mmsi/route", assignShipRoute)
+		apiGroup.DELETE("/ships/:mmsi/route", unassignShipRoute)
+		apiGroup.GET("/routes", getRouteProgress)
+		apiGroup.GET("/routes/deviations", getRouteDeviations)
+		apiGroup.GET("/mmsi/:mmsi", getMMSI)
 		apiGroup.GET("/glaciers", getGlaciers)
+		apiGroup.GET("/seamarks", getSeamarks)
+		apiGroup.GET("/harbours", getHarbours)
+		apiGroup.GET("/alerts", getAlerts)
+		apiGroup.GET("/anomalies", getAnomalies)
+		apiGroup.GET("/portcalls", getPortCalls)
+		apiGroup.GET("/voyages", getVoyages)
+		apiGroup.GET("/heatmap", getHeatmap)
+		apiGroup.GET("/heatmap/windows", getHeatmapWindows)
+		apiGroup.GET("/fleets", getFleets)
+		apiGroup.POST("/fleets", createFleet)
+		apiGroup.GET("/fleets/:id", getFleet)
+		apiGroup.PUT("/fleets/:id", updateFleet)
+		apiGroup.DELETE("/fleets/:id", deleteFleet)
+		apiGroup.GET("/geofences", getGeofences)
+		apiGroup.POST("/geofences", createGeofence)
+		apiGroup.GET("/geofences/events", getGeofenceEvents)
+		apiGroup.GET("/geofences/:id", getGeofence)
+		apiGroup.PUT("/geofences/:id", updateGeofence)
+		apiGroup.DELETE("/geofences/:id", deleteGeofence)
+		apiGroup.GET("/vessels", getVessels)
+		apiGroup.GET("/vessels/:mmsi", getVessel)
+		apiGroup.PUT("/vessels/:mmsi/overrides", updateVesselOverrides)
+		apiGroup.GET("/ports", getPorts)
+		apiGroup.GET("/ports/nearest", getNearestPorts)
+		apiGroup.GET("/ports/:id", getPort)
+		apiGroup.GET("/search", getSearch)
+		apiGroup.GET("/havens", getHavens)
+		apiGroup.GET("/diversions", getDiversions)
+		apiGroup.GET("/sar", getSARCandidates)
+		apiGroup.GET("/hazards", getHazards)
+		apiGroup.GET("/hazards/warnings", getHazardWarnings)
+		apiGroup.GET("/icebergs", getIcebergs)
+		apiGroup.POST("/icebergs", reportIceberg)
+		apiGroup.GET("/icebergs/forecast", getIcebergForecasts)
+		apiGroup.GET("/icebergs/:id", getIceberg)
+		apiGroup.GET("/icebergs/:id/forecast", getIcebergForecast)
+		apiGroup.GET("/live", streamLive)
 		apiGroup.GET("/health", healthCheck)
 	}
 
+	adminGroup := r.Group("/api/admin", adminAuth)
+	{
+		adminGroup.GET("/subscription", getSubscription)
+		adminGroup.PUT("/subscription", updateSubscription)
+		adminGroup.GET("/webhooks", getWebhooks)
+		adminGroup.POST("/webhooks", createWebhook)
+		adminGroup.DELETE("/webhooks/:id", deleteWebhook)
+		// Порты задают места убежища для уводов и поиска и спасения
+		adminGroup.POST("/ports", createPort)
+		adminGroup.POST("/ports/import", importPorts)
+		adminGroup.PUT("/ports/:id", updatePort)
+		adminGroup.DELETE("/ports/:id", deletePort)
+		// Донесения судов (POST /api/icebergs) открыты, импорт и удаление - только для администратора
+		adminGroup.POST("/icebergs/import", importIcebergs)
+		adminGroup.DELETE("/icebergs/:id", deleteIceberg)
+		adminGroup.GET("/osm", getOSMStore)
+		adminGroup.POST("/osm/import", importOSM)
+		adminGroup.GET("/osm/import", getOSMImport)
+		adminGroup.GET("/grids", getFlowGrids)
+		adminGroup.PUT("/grids/:kind", updateFlowGrid)
+	}
+
 	r.Static("/css", "./frontend")
 	r.Static("/js", "./frontend")
 	r.Static("/assets", "./frontend")
@@ -49,23 +292,24 @@ func main() {
 }
 
 func getPoints(c *gin.Context) {
-	points := service.GetPoints()
-	c.JSON(200, points)
-}
-
-func getShips(c *gin.Context) {
-	ships := api.Get10Ships()
-	c.JSON(200, ships)
+	c.JSON(200, portRegistry.Points())
 }
 
 func getGlaciers(c *gin.Context) {
-	bbox := c.DefaultQuery("bbox", "65,30,90,180")
-	glaciers, err := api.GetGlaciers(bbox)
+	bbox, ok := queryOverpassBBox(c, &glacierBBox)
+	if !ok {
+		return
+	}
+	glaciers, err := api.GetGlaciers(c.Request.Context(), osmSource, *bbox)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		osmError(c, err)
 		return
 	}
-	c.JSON(200, glaciers)
+	fc := geojson.NewFeatureCollection()
+	for _, g := range glaciers {
+		fc.Append(g.Feature())
+	}
+	c.JSON(200, fc)
 }
 
 func healthCheck(c *gin.Context) {
@@ -75,3 +319,25 @@ func healthCheck(c *gin.Context) {
 		"framework": "Gin",
 	})
 }
+
+// envFloat читает число из переменной окружения
+func envFloat(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using %v", key, v, def)
+		return def
+	}
+	return f
+}
+
+// dataDir - каталог сохраняемых данных (DATA_DIR, по умолчанию ./data)
+func dataDir() string {
+	if dir := os.Getenv("DATA_DIR"); dir != "" {
+		return dir
+	}
+	return "data"
+}
@@ -0,0 +1,244 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/paulmach/orb"
+)
+
+// ErrInvalidBBox - область задана неверно
+var ErrInvalidBBox = errors.New("invalid bbox")
+
+// Причины, по которым область отклонена
+const (
+	BBoxFormat = "format" // Не четыре числа через запятую
+	BBoxRange  = "range"  // Широта вне [-90, 90] или долгота вне [-180, 180]
+	BBoxOrder  = "order"  // Юг севернее севера, запад восточнее востока или пустая область
+	BBoxArea   = "area"   // Область больше допустимой
+)
+
+// BBoxError - подробности ошибки разбора области; errors.Is(err, ErrInvalidBBox) выполняется
+type BBoxError struct {
+	Value  string `json:"value"`
+	Reason string `json:"reason"` // format, range, order или area
+	Detail string `json:"detail"`
+}
+
+func (e *BBoxError) Error() string {
+	return fmt.Sprintf("%v %q: %s", ErrInvalidBBox, e.Value, e.Detail)
+}
+
+func (e *BBoxError) Unwrap() error {
+	return ErrInvalidBBox
+}
+
+// earthRadiusKm - средний радиус Земли для расчета площади области
+const earthRadiusKm = 6371.0
+
+// BBox - прямоугольная область в градусах. West > East означает,
+// что область пересекает антимеридиан; в запросе такая область задается
+// восточной границей больше 180 (например, 60,170,75,190).
+type BBox struct {
+	South float64 `json:"south"`
+	West  float64 `json:"west"`
+	North float64 `json:"north"`
+	East  float64 `json:"east"`
+}
+
+// ParseBBox разбирает область "south,west,north,east" и проверяет порядок и диапазоны координат.
+// Запад восточнее востока - ошибка порядка; пересечение антимеридиана задается явно
+// восточной границей в (180, 360), которая приводится к [-180, 180].
+func ParseBBox(s string) (BBox, error) {
+	fail := func(reason, detail string, args ...interface{}) (BBox, error) {
+		return BBox{}, &BBoxError{Value: s, Reason: reason, Detail: fmt.Sprintf(detail, args...)}
+	}
+
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return fail(BBoxFormat, "want four numbers south,west,north,east")
+	}
+	var v [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return fail(BBoxFormat, "%q is not a number", strings.TrimSpace(p))
+		}
+		v[i] = f
+	}
+
+	b := BBox{South: v[0], West: v[1], North: v[2], East: v[3]}
+	for _, lat := range []float64{b.South, b.North} {
+		if lat < -90 || lat > 90 {
+			return fail(BBoxRange, "latitude %g out of [-90, 90]; order is south,west,north,east", lat)
+		}
+	}
+	if b.West < -180 || b.West > 180 {
+		return fail(BBoxRange, "longitude %g out of [-180, 180]", b.West)
+	}
+	if b.East < -180 || b.East > 360 {
+		return fail(BBoxRange, "longitude %g out of [-180, 360]; east above 180 crosses the antimeridian", b.East)
+	}
+	if b.South >= b.North {
+		return fail(BBoxOrder, "south %g must be less than north %g", b.South, b.North)
+	}
+	if b.West == b.East {
+		return fail(BBoxOrder, "west and east are equal")
+	}
+	if b.West > b.East {
+		return fail(BBoxOrder, "west %g must be less than east %g; to cross the antimeridian give east above 180 (e.g. %g)",
+			b.West, b.East, b.East+360)
+	}
+	if b.East > 180 {
+		if b.East-360 >= b.West {
+			return fail(BBoxOrder, "area from %g to %g spans more than 360°", b.West, b.East)
+		}
+		b.East -= 360
+	}
+	return b, nil
+}
+
+// MustParseBBox разбирает область, заданную в коде; паникует при ошибке
+func MustParseBBox(s string) BBox {
+	b, err := ParseBBox(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// String возвращает область в виде "south,west,north,east", который разбирает ParseBBox;
+// у области через антимеридиан восточная граница больше 180
+func (b BBox) String() string {
+	east := b.East
+	if b.CrossesAntimeridian() {
+		east += 360
+	}
+	values := []float64{b.South, b.West, b.North, east}
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+// CrossesAntimeridian сообщает, что область пересекает меридиан 180°
+func (b BBox) CrossesAntimeridian() bool {
+	return b.West > b.East
+}
+
+// Width возвращает ширину области по долготе, градусы
+func (b BBox) Width() float64 {
+	if b.CrossesAntimeridian() {
+		return b.East - b.West + 360
+	}
+	return b.East - b.West
+}
+
+// Area возвращает площадь области на сфере, км²
+func (b BBox) Area() float64 {
+	rad := math.Pi / 180
+	return earthRadiusKm * earthRadiusKm * b.Width() * rad * (math.Sin(b.North*rad) - math.Sin(b.South*rad))
+}
+
+// CheckArea проверяет, что площадь области не больше maxArea км² (0 - без ограничения)
+func (b BBox) CheckArea(maxArea float64) error {
+	if maxArea > 0 && b.Area() > maxArea {
+		return &BBoxError{Value: b.String(), Reason: BBoxArea,
+			Detail: fmt.Sprintf("area %.0f km² exceeds %.0f km²", b.Area(), maxArea)}
+	}
+	return nil
+}
+
+// Split делит область, пересекающую антимеридиан, на две; иначе возвращает ее саму
+func (b BBox) Split() []BBox {
+	if !b.CrossesAntimeridian() {
+		return []BBox{b}
+	}
+	return []BBox{
+		{South: b.South, West: b.West, North: b.North, East: 180},
+		{South: b.South, West: -180, North: b.North, East: b.East},
+	}
+}
+
+// Tiles делит область на части не больше latStep x lonStep градусов, не пересекающие антимеридиан
+func (b BBox) Tiles(latStep, lonStep float64) []BBox {
+	var tiles []BBox
+	for _, part := range b.Split() {
+		for south := part.South; south < part.North; south += latStep {
+			north := math.Min(south+latStep, part.North)
+			for west := part.West; west < part.East; west += lonStep {
+				tiles = append(tiles, BBox{South: south, West: west, North: north, East: math.Min(west+lonStep, part.East)})
+			}
+		}
+	}
+	return tiles
+}
+
+// Bound возвращает границы orb ([lon, lat]) области, не пересекающей антимеридиан
+func (b BBox) Bound() orb.Bound {
+	return orb.Bound{Min: orb.Point{b.West, b.South}, Max: orb.Point{b.East, b.North}}
+}
+
+// Contains сообщает, что точка лежит в области
+func (b BBox) Contains(lat, lon float64) bool {
+	if lat < b.South || lat > b.North {
+		return false
+	}
+	if b.CrossesAntimeridian() {
+		return lon >= b.West || lon <= b.East
+	}
+	return lon >= b.West && lon <= b.East
+}
+
+// Distance возвращает расстояние от точки до ближайшей точки области, м; 0 - точка в области
+func (b BBox) Distance(lat, lon float64) float64 {
+	if b.Contains(lat, lon) {
+		return 0
+	}
+	// Ближайшая граница по долготе; внутри полосы долгот - сама долгота точки
+	edge := lon
+	if !b.Contains(math.Max(b.South, math.Min(b.North, lat)), lon) {
+		edge = b.West
+		if math.Abs(lonDelta(lon, b.East)) < math.Abs(lonDelta(lon, b.West)) {
+			edge = b.East
+		}
+	}
+	// Ближайшая к точке широта на меридиане границы, ограниченная областью
+	rad := math.Pi / 180
+	near := lat
+	if d := lonDelta(lon, edge) * rad; d != 0 {
+		if math.Cos(d) <= 0 {
+			near = math.Copysign(90, lat)
+		} else {
+			near = math.Atan(math.Tan(lat*rad)/math.Cos(d)) / rad
+		}
+	}
+	near = math.Max(b.South, math.Min(b.North, near))
+	return haversine(lat, lon, near, edge)
+}
+
+// lonDelta возвращает разность долгот b - a, приведенную к [-180, 180]
+func lonDelta(a, b float64) float64 {
+	d := math.Mod(b-a, 360)
+	switch {
+	case d > 180:
+		d -= 360
+	case d < -180:
+		d += 360
+	}
+	return d
+}
+
+// haversine возвращает расстояние между точками по дуге большого круга, м
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * 1000 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
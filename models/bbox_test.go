@@ -0,0 +1,205 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParseBBox(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   BBox
+		reason string // Пусто - область корректна
+	}{
+		{"68,30,72,60", BBox{South: 68, West: 30, North: 72, East: 60}, ""},
+		{" 68.5 , 30 ,72, 60.25 ", BBox{South: 68.5, West: 30, North: 72, East: 60.25}, ""},
+		{"-90,-180,90,180", BBox{South: -90, West: -180, North: 90, East: 180}, ""},
+		{"60,170,75,190", BBox{South: 60, West: 170, North: 75, East: -170}, ""},
+		{"60,-180,75,180", BBox{South: 60, West: -180, North: 75, East: 180}, ""},
+		{"68,30,72", BBox{}, BBoxFormat},
+		{"68,30,72,60,1", BBox{}, BBoxFormat},
+		{"", BBox{}, BBoxFormat},
+		{"68,x,72,60", BBox{}, BBoxFormat},
+		{"68,NaN,72,60", BBox{}, BBoxFormat},
+		{"68,30,Inf,60", BBox{}, BBoxFormat},
+		{"30,68,60,400", BBox{}, BBoxRange},
+		{"-91,30,72,60", BBox{}, BBoxRange},
+		{"30,68,95,72", BBox{}, BBoxRange},
+		{"72,30,68,60", BBox{}, BBoxOrder},
+		{"68,30,68,60", BBox{}, BBoxOrder},
+		{"68,30,72,30", BBox{}, BBoxOrder},
+		{"70,40,75,30", BBox{}, BBoxOrder},
+		{"60,170,75,-170", BBox{}, BBoxOrder},
+		{"60,10,75,370", BBox{}, BBoxRange},
+		{"60,-180,75,200", BBox{}, BBoxOrder},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseBBox(tt.in)
+			if tt.reason == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got != tt.want {
+					t.Errorf("got %+v, want %+v", got, tt.want)
+				}
+				return
+			}
+
+			var bboxErr *BBoxError
+			if !errors.As(err, &bboxErr) || !errors.Is(err, ErrInvalidBBox) {
+				t.Fatalf("error %v is not a BBoxError", err)
+			}
+			if bboxErr.Reason != tt.reason {
+				t.Errorf("reason %q, want %q (%s)", bboxErr.Reason, tt.reason, bboxErr.Detail)
+			}
+		})
+	}
+}
+
+func TestBBoxString(t *testing.T) {
+	for _, s := range []string{"68,30,72,60", "68.5,-30.25,72,60", "60,170,75,190"} {
+		if got := MustParseBBox(s).String(); got != s {
+			t.Errorf("String() = %q, want %q", got, s)
+		}
+	}
+}
+
+func TestBBoxSplit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []BBox
+	}{
+		{"68,30,72,60", []BBox{{South: 68, West: 30, North: 72, East: 60}}},
+		{"60,170,75,190", []BBox{
+			{South: 60, West: 170, North: 75, East: 180},
+			{South: 60, West: -180, North: 75, East: -170},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			b := MustParseBBox(tt.in)
+			got := b.Split()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Split() = %+v, want %+v", got, tt.want)
+			}
+			width := 0.0
+			for _, part := range got {
+				if part.CrossesAntimeridian() {
+					t.Errorf("part %+v still crosses the antimeridian", part)
+				}
+				width += part.Width()
+			}
+			if width != b.Width() {
+				t.Errorf("parts span %g°, want %g°", width, b.Width())
+			}
+		})
+	}
+}
+
+func TestBBoxTiles(t *testing.T) {
+	tests := []struct {
+		in    string
+		count int
+	}{
+		{"68,30,72,40", 1},
+		{"64,28,81,85", 4 * 6},
+		{"60,170,75,190", 3 * 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			b := MustParseBBox(tt.in)
+			tiles := b.Tiles(5, 10)
+			if len(tiles) != tt.count {
+				t.Fatalf("got %d tiles %+v, want %d", len(tiles), tiles, tt.count)
+			}
+			area := 0.0
+			for _, tile := range tiles {
+				if tile.CrossesAntimeridian() || tile.North-tile.South > 5 || tile.Width() > 10 {
+					t.Errorf("tile %+v is out of shape", tile)
+				}
+				if !b.Contains(tile.South, tile.West) || !b.Contains(tile.North, tile.East) {
+					t.Errorf("tile %+v leaves the area", tile)
+				}
+				area += tile.Area()
+			}
+			if math.Abs(area-b.Area()) > 1 {
+				t.Errorf("tiles cover %.0f km², want %.0f km²", area, b.Area())
+			}
+		})
+	}
+}
+
+func TestBBoxContains(t *testing.T) {
+	tests := []struct {
+		bbox     string
+		lat, lon float64
+		want     bool
+	}{
+		{"68,30,72,60", 70, 45, true},
+		{"68,30,72,60", 68, 30, true},
+		{"68,30,72,60", 73, 45, false},
+		{"68,30,72,60", 70, 61, false},
+		{"60,170,75,190", 70, 175, true},
+		{"60,170,75,190", 70, -175, true},
+		{"60,170,75,190", 70, 180, true},
+		{"60,170,75,190", 70, 0, false},
+		{"60,170,75,190", 59, 175, false},
+	}
+	for _, tt := range tests {
+		if got := MustParseBBox(tt.bbox).Contains(tt.lat, tt.lon); got != tt.want {
+			t.Errorf("%s contains (%g, %g) = %v, want %v", tt.bbox, tt.lat, tt.lon, got, tt.want)
+		}
+	}
+}
+
+func TestBBoxCheckArea(t *testing.T) {
+	b := MustParseBBox("68,30,72,60")
+	area := b.Area()
+	if area < 480000 || area > 530000 {
+		t.Fatalf("area %.0f km², want about 507 000 km²", area)
+	}
+
+	tests := []struct {
+		limit float64
+		ok    bool
+	}{
+		{0, true},
+		{area + 1, true},
+		{area - 1, false},
+	}
+	for _, tt := range tests {
+		err := b.CheckArea(tt.limit)
+		var bboxErr *BBoxError
+		if tt.ok != (err == nil) || (err != nil && (!errors.As(err, &bboxErr) || bboxErr.Reason != BBoxArea)) {
+			t.Errorf("CheckArea(%.0f) = %v", tt.limit, err)
+		}
+	}
+}
+
+func TestBBoxDistance(t *testing.T) {
+	tests := []struct {
+		name     string
+		bbox     string
+		lat, lon float64
+		want     float64 // м
+	}{
+		{"inside", "68,30,72,60", 70, 45, 0},
+		{"north of the box", "68,30,72,60", 73, 45, 111195},
+		{"south of the box", "68,30,72,60", 67.5, 45, 55597},
+		{"west of the box on the equator", "-1,30,1,60", 0, 29, 111195},
+		{"beyond the corner", "68,30,72,60", 67, 29, 118000},
+		{"across the antimeridian", "60,170,75,190", 70, -169, 38030},
+		{"nearer edge across the antimeridian", "60,-175,75,175", 70, 178, 114090},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MustParseBBox(tt.bbox).Distance(tt.lat, tt.lon)
+			if math.Abs(got-tt.want) > tt.want*0.01+1 {
+				t.Errorf("Distance(%g, %g) = %.0f m, want %.0f m", tt.lat, tt.lon, got, tt.want)
+			}
+		})
+	}
+}
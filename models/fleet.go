@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// FleetVessel - судно в составе флота
+type FleetVessel struct {
+	MMSI  int32  `json:"mmsi"`
+	Label string `json:"label,omitempty"` // Пометка, например "charter"
+}
+
+// Fleet - именованная группа судов
+type Fleet struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Vessels     []FleetVessel `json:"vessels"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
@@ -1,9 +1,56 @@
 package models
 
+import (
+	"strconv"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/planar"
+)
+
 type Glacier struct {
 	ID        int64   `json:"id"`
 	Name      string  `json:"name"`
-	Latitude  float64 `json:"lat"`
+	Latitude  float64 `json:"lat"` // Центр контура; для точечного ледника - сама точка
 	Longitude float64 `json:"lon"`
 	Type      string  `json:"type"`
+	Tidewater bool    `json:"tidewater,omitempty"` // Ледник выходит к морю
+	Area      float64 `json:"area,omitempty"`      // Площадь, м²
+	Perimeter float64 `json:"perimeter,omitempty"` // Длина границы вместе с внутренними контурами, м
+
+	Geometry orb.MultiPolygon `json:"-"` // Контур ледника; пусто - ледник отмечен точкой
+}
+
+// SetGeometry задает контур ледника и пересчитывает центр, площадь и периметр
+func (g *Glacier) SetGeometry(mp orb.MultiPolygon) {
+	g.Geometry = mp
+	g.Area = geo.Area(mp)
+	g.Perimeter = geo.Length(mp)
+	if center, area := planar.CentroidArea(mp); area != 0 {
+		g.Longitude, g.Latitude = center[0], center[1]
+	}
+}
+
+// Feature возвращает ледник как объект GeoJSON: полигон, мультиполигон или точку
+func (g Glacier) Feature() *geojson.Feature {
+	var geometry orb.Geometry = orb.Point{g.Longitude, g.Latitude}
+	switch len(g.Geometry) {
+	case 0:
+	case 1:
+		geometry = g.Geometry[0]
+	default:
+		geometry = g.Geometry
+	}
+
+	f := geojson.NewFeature(geometry)
+	f.ID = g.Type + "/" + strconv.FormatInt(g.ID, 10)
+	f.Properties["osm_id"] = g.ID
+	f.Properties["osm_type"] = g.Type
+	f.Properties["name"] = g.Name
+	f.Properties["tidewater"] = g.Tidewater
+	f.Properties["area"] = g.Area
+	f.Properties["perimeter"] = g.Perimeter
+	f.Properties["center"] = []float64{g.Longitude, g.Latitude}
+	return f
 }
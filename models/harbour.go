@@ -0,0 +1,28 @@
+package models
+
+import (
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// Harbour - гавань, порт или стоянка маломерных судов из OSM
+type Harbour struct {
+	ID       int64   `json:"id"`
+	OSMType  string  `json:"osm_type"`
+	Name     string  `json:"name,omitempty"`
+	Category string  `json:"category,omitempty"` // Вид гавани: port, fishing, marina, small_craft_facility...
+	Lat      float64 `json:"lat"`                // Для контура - его центр
+	Lon      float64 `json:"lon"`
+}
+
+// Feature возвращает гавань как точку GeoJSON
+func (h Harbour) Feature() *geojson.Feature {
+	f := geojson.NewFeature(orb.Point{h.Lon, h.Lat})
+	f.Properties["osm_id"] = h.ID
+	f.Properties["osm_type"] = h.OSMType
+	f.Properties["name"] = h.Name
+	if h.Category != "" {
+		f.Properties["category"] = h.Category
+	}
+	return f
+}
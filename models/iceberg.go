@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// IcebergObservation - наблюдение айсберга ледовой службой или судном
+type IcebergObservation struct {
+	ID         string    `json:"id"`
+	ObservedAt time.Time `json:"observed_at"`
+	Lat        float64   `json:"lat"`
+	Lon        float64   `json:"lon"`
+	Size       string    `json:"size"`             // Класс размера: growler, bergy_bit, small, medium, large, very_large, unknown
+	Length     float64   `json:"length,omitempty"` // Длина над водой, м, если измерена
+	Source     string    `json:"source"`           // Ледовая служба, файл или ship для донесений с судов
+	MMSI       int32     `json:"mmsi,omitempty"`   // Судно, передавшее донесение
+	Reporter   string    `json:"reporter,omitempty"`
+	Note       string    `json:"note,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"` // После этого момента наблюдение не учитывается
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Point возвращает место наблюдения
+func (o IcebergObservation) Point() Point {
+	return Point{Name: o.ID, Lat: o.Lat, Lon: o.Lon}
+}
+
+// Active сообщает, что наблюдение действует в момент at
+func (o IcebergObservation) Active(at time.Time) bool {
+	return !o.ObservedAt.After(at) && o.ExpiresAt.After(at)
+}
+
+// Feature возвращает наблюдение как точку GeoJSON
+func (o IcebergObservation) Feature() *geojson.Feature {
+	f := geojson.NewFeature(orb.Point{o.Lon, o.Lat})
+	f.ID = o.ID
+	f.Properties["size"] = o.Size
+	f.Properties["observed_at"] = o.ObservedAt
+	f.Properties["expires_at"] = o.ExpiresAt
+	f.Properties["source"] = o.Source
+	if o.Length > 0 {
+		f.Properties["length"] = o.Length
+	}
+	if o.MMSI != 0 {
+		f.Properties["mmsi"] = o.MMSI
+	}
+	if o.Reporter != "" {
+		f.Properties["reporter"] = o.Reporter
+	}
+	if o.Note != "" {
+		f.Properties["note"] = o.Note
+	}
+	return f
+}
@@ -0,0 +1,17 @@
+package models
+
+// Place - именованное место для поиска по названию: порт, населенный пункт, мыс, пролив
+type Place struct {
+	ID       string   `json:"id"` // <источник>/<id>: ports/murmansk, osm/node/123, gazetteer/cape_chelyuskin
+	Name     string   `json:"name"`
+	AltNames []string `json:"alt_names,omitempty"` // Другие названия и написания
+	Kind     string   `json:"kind"`                // port, refuge, town, cape, strait, island, bay...
+	Source   string   `json:"source"`              // ports, gazetteer или osm
+	Lat      float64  `json:"lat"`
+	Lon      float64  `json:"lon"`
+}
+
+// Point возвращает положение места
+func (p Place) Point() Point {
+	return Point{Name: p.Name, Lat: p.Lat, Lon: p.Lon}
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Port - запись реестра портов: порт или место убежища
+type Port struct {
+	ID        string    `json:"id"`
+	LOCODE    string    `json:"locode,omitempty"` // UN/LOCODE, например "RU MMK"
+	Name      string    `json:"name"`
+	AltNames  []string  `json:"alt_names,omitempty"` // Другие названия и написания: Мурманск
+	Country   string    `json:"country,omitempty"`   // Код страны ISO 3166-1
+	Kind      string    `json:"kind"`                // port или refuge
+	Lat       float64   `json:"lat"`
+	Lon       float64   `json:"lon"`
+	MaxDraft  float64   `json:"max_draft,omitempty"`  // Наибольшая допустимая осадка, м; 0 - без ограничений
+	Berths    int       `json:"berths,omitempty"`     // Число причалов
+	Services  []string  `json:"services,omitempty"`   // repair, bunkering, medical, tugs...
+	IceClass  string    `json:"ice_class,omitempty"`  // Минимальный ледовый класс в ледовый сезон
+	IceMonths []int     `json:"ice_months,omitempty"` // Месяцы ледового сезона (1-12); пусто - круглый год
+	Approach  []Point   `json:"approach,omitempty"`   // Подходные точки от моря к порту по порядку
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Point возвращает положение порта
+func (p Port) Point() Point {
+	return Point{Name: p.Name, Lat: p.Lat, Lon: p.Lon}
+}
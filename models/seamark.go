@@ -0,0 +1,96 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// Seamark - навигационный знак или объект морской карты, размеченный в OSM тегами seamark:*
+type Seamark struct {
+	ID            int64    `json:"id"`
+	OSMType       string   `json:"osm_type"`
+	Type          string   `json:"type"` // seamark:type: light_major, buoy_lateral, harbour...
+	Name          string   `json:"name,omitempty"`
+	Lat           float64  `json:"lat"`
+	Lon           float64  `json:"lon"`
+	Category      string   `json:"category,omitempty"` // Категория знака или гавани: port, starboard, north, fishing...
+	Colours       []string `json:"colours,omitempty"`
+	ColourPattern string   `json:"colour_pattern,omitempty"` // horizontal, vertical...
+	Shape         string   `json:"shape,omitempty"`          // can, conical, spar, pillar...
+	Lights        []Light  `json:"lights,omitempty"`         // У секторного огня - по одному на сектор
+}
+
+// Light - характеристика огня
+type Light struct {
+	Character   string   `json:"character"`       // Fl, LFl, Oc, Iso, Q, F...
+	Group       string   `json:"group,omitempty"` // Число проблесков в группе: 2, 2+1
+	Colours     []string `json:"colours,omitempty"`
+	Period      float64  `json:"period,omitempty"` // Период, с
+	Height      float64  `json:"height,omitempty"` // Высота огня, м
+	Range       float64  `json:"range,omitempty"`  // Дальность видимости, мили
+	SectorStart *float64 `json:"sector_start,omitempty"`
+	SectorEnd   *float64 `json:"sector_end,omitempty"`
+	Description string   `json:"description"` // Обозначение как на карте: Fl(2) WR 10s 15m 12M
+}
+
+// lightColours - сокращения цветов огней на картах
+var lightColours = map[string]string{
+	"white": "W", "red": "R", "green": "G", "yellow": "Y", "blue": "Bu",
+	"orange": "Or", "violet": "Vi", "amber": "Am",
+}
+
+// Describe составляет обозначение огня по правилам морских карт
+func (l Light) Describe() string {
+	var b strings.Builder
+	b.WriteString(l.Character)
+	if l.Group != "" {
+		b.WriteString("(" + l.Group + ")")
+	}
+	if len(l.Colours) > 0 {
+		b.WriteByte(' ')
+		for _, c := range l.Colours {
+			if abbr, ok := lightColours[c]; ok {
+				b.WriteString(abbr)
+			} else {
+				b.WriteString(c)
+			}
+		}
+	}
+	for _, v := range []struct {
+		value float64
+		unit  string
+	}{{l.Period, "s"}, {l.Height, "m"}, {l.Range, "M"}} {
+		if v.value > 0 {
+			b.WriteString(" " + strconv.FormatFloat(v.value, 'f', -1, 64) + v.unit)
+		}
+	}
+	return b.String()
+}
+
+// Feature возвращает знак как точку GeoJSON
+func (s Seamark) Feature() *geojson.Feature {
+	f := geojson.NewFeature(orb.Point{s.Lon, s.Lat})
+	f.Properties["osm_id"] = s.ID
+	f.Properties["osm_type"] = s.OSMType
+	f.Properties["type"] = s.Type
+	f.Properties["name"] = s.Name
+	if s.Category != "" {
+		f.Properties["category"] = s.Category
+	}
+	if len(s.Colours) > 0 {
+		f.Properties["colours"] = s.Colours
+	}
+	if s.ColourPattern != "" {
+		f.Properties["colour_pattern"] = s.ColourPattern
+	}
+	if s.Shape != "" {
+		f.Properties["shape"] = s.Shape
+	}
+	if len(s.Lights) > 0 {
+		f.Properties["lights"] = s.Lights
+	}
+	return f
+}
@@ -1,8 +1,54 @@
 package models
 
+import "time"
+
 type Ship struct {
-	MMSI      int32   `json:"mmsi"`
-	Name      string  `json:"name"`
-	Latitude  float64 `json:"latitude"`
-	Longitude float64 `json:"longitude"`
+	MMSI      int32     `json:"mmsi"`
+	Name      string    `json:"name"`
+	Flag      string    `json:"flag,omitempty"`      // Флаг по MID (ISO 3166-1 alpha-2)
+	ShipType  int32     `json:"ship_type,omitempty"` // Тип судна AIS из статических данных
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	SOG       float64   `json:"sog"`        // Скорость над грунтом, узлы
+	COG       float64   `json:"cog"`        // Курс над грунтом, градусы
+	ROT       float64   `json:"rot"`        // Скорость поворота, градусы в минуту
+	Heading   int32     `json:"heading"`    // Истинный курс, 511 - нет данных
+	NavStatus int32     `json:"nav_status"` // Навигационный статус AIS
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// HasPosition сообщает, передана ли позиция (91 и 181 - "нет данных")
+func (s Ship) HasPosition() bool {
+	return s.Latitude >= -90 && s.Latitude <= 90 && s.Longitude >= -180 && s.Longitude <= 180
+}
+
+// HasMotion сообщает, переданы ли скорость и курс (102.3 и 360 - "нет данных")
+func (s Ship) HasMotion() bool {
+	return s.SOG >= 0 && s.SOG < 102.3 && s.COG >= 0 && s.COG < 360
+}
+
+// ShipEstimate - положение судна на момент At, досчитанное от последнего сообщения
+type ShipEstimate struct {
+	Ship
+	At                 time.Time `json:"at"`                  // Момент, на который дана оценка
+	Age                float64   `json:"age"`                 // Возраст последнего сообщения, с
+	Estimated          bool      `json:"estimated"`           // Позиция получена счислением
+	Stale              bool      `json:"stale"`               // Сообщение старше допустимого
+	PredictedLatitude  float64   `json:"predicted_latitude"`  // Досчитанная широта
+	PredictedLongitude float64   `json:"predicted_longitude"` // Досчитанная долгота
+	Uncertainty        float64   `json:"uncertainty"`         // Радиус неопределенности, м
+}
+
+// ShipStatic - статические данные судна из сообщений AIS 5 и 24
+type ShipStatic struct {
+	MMSI        int32     `json:"mmsi"`
+	IMO         int32     `json:"imo,omitempty"`
+	CallSign    string    `json:"call_sign,omitempty"`
+	Name        string    `json:"name,omitempty"`
+	ShipType    int32     `json:"ship_type,omitempty"` // Тип судна AIS (0-99)
+	Length      float64   `json:"length,omitempty"`    // Длина по размерам A+B, м
+	Beam        float64   `json:"beam,omitempty"`      // Ширина по размерам C+D, м
+	Draught     float64   `json:"draught,omitempty"`   // Максимальная осадка, м
+	Destination string    `json:"destination,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
 }
@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+// NameChange - период, когда судно передавало это название
+type NameChange struct {
+	Name string     `json:"name"`
+	From time.Time  `json:"from"`
+	To   *time.Time `json:"to,omitempty"` // Пусто - текущее название
+}
+
+// VesselOverrides - данные, введенные оператором вручную.
+// Пустые поля не переопределяют данные AIS.
+type VesselOverrides struct {
+	Name     string  `json:"name,omitempty"`
+	IMO      int32   `json:"imo,omitempty"`
+	IceClass string  `json:"ice_class,omitempty"` // Ледовый класс, например Arc7 или PC3
+	Owner    string  `json:"owner,omitempty"`
+	Operator string  `json:"operator,omitempty"`
+	Draught  float64 `json:"draught,omitempty"` // Фактическая осадка, м
+	Length   float64 `json:"length,omitempty"`  // м
+	Beam     float64 `json:"beam,omitempty"`    // м
+	Notes    string  `json:"notes,omitempty"`
+
+	Capabilities []string `json:"capabilities,omitempty"` // Возможности для спасательных операций: helideck, hospital, towing...
+}
+
+// Vessel - запись реестра судов: накопленные статические данные AIS и ручные правки
+type Vessel struct {
+	MMSI        int32           `json:"mmsi"`
+	AIS         ShipStatic      `json:"ais"`          // Последние известные данные AIS
+	NameHistory []NameChange    `json:"name_history"` // Названия по времени, старые первыми
+	Overrides   VesselOverrides `json:"overrides"`
+	FirstSeen   time.Time       `json:"first_seen"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// VesselProfile - итоговые характеристики судна: ручные правки поверх данных AIS
+type VesselProfile struct {
+	MMSI     int32   `json:"mmsi"`
+	IMO      int32   `json:"imo,omitempty"`
+	Name     string  `json:"name,omitempty"`
+	CallSign string  `json:"call_sign,omitempty"`
+	ShipType int32   `json:"ship_type,omitempty"`
+	Length   float64 `json:"length,omitempty"`
+	Beam     float64 `json:"beam,omitempty"`
+	Draught  float64 `json:"draught,omitempty"` // м
+	IceClass string  `json:"ice_class,omitempty"`
+	Owner    string  `json:"owner,omitempty"`
+	Operator string  `json:"operator,omitempty"`
+
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// Profile сводит данные AIS и ручные правки
+func (v *Vessel) Profile() VesselProfile {
+	p := VesselProfile{
+		MMSI:     v.MMSI,
+		IMO:      v.AIS.IMO,
+		Name:     v.AIS.Name,
+		CallSign: v.AIS.CallSign,
+		ShipType: v.AIS.ShipType,
+		Length:   v.AIS.Length,
+		Beam:     v.AIS.Beam,
+		Draught:  v.AIS.Draught,
+		IceClass: v.Overrides.IceClass,
+		Owner:    v.Overrides.Owner,
+		Operator: v.Overrides.Operator,
+
+		Capabilities: v.Overrides.Capabilities,
+	}
+	o := v.Overrides
+	if o.Name != "" {
+		p.Name = o.Name
+	}
+	if o.IMO != 0 {
+		p.IMO = o.IMO
+	}
+	if o.Draught != 0 {
+		p.Draught = o.Draught
+	}
+	if o.Length != 0 {
+		p.Length = o.Length
+	}
+	if o.Beam != 0 {
+		p.Beam = o.Beam
+	}
+	return p
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/s3nkyh/arcticeroute/osm"
+)
+
+// Состояния импорта выгрузки OSM
+const (
+	importIdle    = "idle"
+	importRunning = "running"
+	importDone    = "done"
+	importFailed  = "failed"
+)
+
+// osmImportStatus - состояние последнего импорта выгрузки OSM
+type osmImportStatus struct {
+	State      string     `json:"state"` // idle, running, done, failed
+	Path       string     `json:"path,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Meta       *osm.Meta  `json:"meta,omitempty"`
+}
+
+// osmImport - импорт выгрузки выполняется одним фоновым заданием
+var osmImport = struct {
+	mu     sync.Mutex
+	status osmImportStatus
+}{status: osmImportStatus{State: importIdle}}
+
+// beginOSMImport занимает задание импорта; false - уже идет другой импорт
+func beginOSMImport(path string) (osmImportStatus, bool) {
+	osmImport.mu.Lock()
+	defer osmImport.mu.Unlock()
+	if osmImport.status.State == importRunning {
+		return osmImport.status, false
+	}
+	now := time.Now().UTC()
+	osmImport.status = osmImportStatus{State: importRunning, Path: path, StartedAt: &now}
+	return osmImport.status, true
+}
+
+// endOSMImport записывает результат импорта и освобождает задание
+func endOSMImport(meta osm.Meta, err error) {
+	osmImport.mu.Lock()
+	defer osmImport.mu.Unlock()
+	now := time.Now().UTC()
+	osmImport.status.FinishedAt = &now
+	if err != nil {
+		osmImport.status.State = importFailed
+		osmImport.status.Error = err.Error()
+		return
+	}
+	osmImport.status.State = importDone
+	osmImport.status.Meta = &meta
+}
+
+// importOSMExtract импортирует выгрузку OSM_PBF в локальное хранилище,
+// если ее еще не импортировали или файл изменился после импорта
+func importOSMExtract() {
+	path := os.Getenv("OSM_PBF")
+	if path == "" || !osmSource.Store.Stale(path) {
+		return
+	}
+	if _, ok := beginOSMImport(path); !ok {
+		return
+	}
+	log.Printf("Importing OSM extract %s", path)
+	meta, err := osmSource.Store.Import(path)
+	endOSMImport(meta, err)
+	if err != nil {
+		log.Println("OSM import error:", err)
+		return
+	}
+	log.Printf("OSM extract imported: %v", meta.Counts)
+}
+
+func getOSMStore(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"store":    osmSource.Store.Meta(),
+		"overpass": osmSource.Client != nil,
+	})
+}
+
+// getOSMImport возвращает состояние последнего импорта выгрузки
+func getOSMImport(c *gin.Context) {
+	osmImport.mu.Lock()
+	status := osmImport.status
+	osmImport.mu.Unlock()
+	c.JSON(200, status)
+}
+
+// importOSM запускает в фоне импорт выгрузки .osm.pbf, лежащей на сервере, после которого
+// обновляются ледники, буи, места и суша. Пока идет импорт, новый не запускается (409).
+func importOSM(c *gin.Context) {
+	var req struct {
+		Path string `json:"path" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "invalid import request: " + err.Error()})
+		return
+	}
+	if _, err := os.Stat(req.Path); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	status, ok := beginOSMImport(req.Path)
+	if !ok {
+		c.JSON(409, gin.H{"error": "OSM import already running", "import": status})
+		return
+	}
+
+	go func() {
+		log.Printf("Importing OSM extract %s", req.Path)
+		meta, err := osmSource.Store.Import(req.Path)
+		if err != nil {
+			log.Println("OSM import error:", err)
+			endOSMImport(meta, err)
+			return
+		}
+		log.Printf("OSM extract imported: %v", meta.Counts)
+		if err := updateGlacierHazards(); err != nil {
+			log.Println("Glacier hazards error:", err)
+		}
+		if fairway != nil {
+			if err := updateFairway(); err != nil {
+				log.Println("Fairway marks error:", err)
+			}
+		}
+		if err := updatePlaces(); err != nil {
+			log.Println("OSM places error:", err)
+		}
+		reloadLand()
+		endOSMImport(meta, nil)
+	}()
+	c.JSON(202, status)
+}
@@ -0,0 +1,217 @@
+package osm
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/s3nkyh/arcticeroute/overpass"
+)
+
+// ==============================
+// ИМПОРТ АРКТИЧЕСКИХ ОБЪЕКТОВ
+// ==============================
+
+// Слои локального хранилища
+const (
+	LayerGlaciers    = "glaciers"
+	LayerCoastlines  = "coastlines"
+	LayerHarbours    = "harbours"
+	LayerSeamarks    = "seamarks"
+	LayerLighthouses = "lighthouses"
+	LayerPlaces      = "places"
+)
+
+// Layers - все слои, которые извлекаются из выгрузки
+var Layers = []string{LayerGlaciers, LayerCoastlines, LayerHarbours, LayerSeamarks, LayerLighthouses, LayerPlaces}
+
+// placeFeatures - именованные природные объекты, которые попадают в слой мест наравне с place=*
+var placeFeatures = map[string]bool{"cape": true, "peninsula": true, "bay": true, "strait": true}
+
+// harbourSeamarks - типы навигационных знаков, обозначающие гавани
+var harbourSeamarks = map[string]bool{"harbour": true, "small_craft_facility": true}
+
+// layersOf возвращает слои, к которым относится элемент типа kind (node, way, relation) с тегами
+func layersOf(kind string, tags map[string]string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	var layers []string
+	if tags["natural"] == "glacier" {
+		layers = append(layers, LayerGlaciers)
+	}
+	if kind == "way" && tags["natural"] == "coastline" {
+		layers = append(layers, LayerCoastlines)
+	}
+	if h, ok := tags["harbour"]; (ok && h != "no") || harbourSeamarks[tags["seamark:type"]] ||
+		tags["landuse"] == "port" || tags["industrial"] == "port" {
+		layers = append(layers, LayerHarbours)
+	}
+	if kind != "relation" && tags["seamark:type"] != "" {
+		layers = append(layers, LayerSeamarks)
+	}
+	if kind != "relation" && tags["man_made"] == "lighthouse" {
+		layers = append(layers, LayerLighthouses)
+	}
+	if tags["name"] != "" && (tags["place"] != "" || placeFeatures[tags["natural"]]) {
+		layers = append(layers, LayerPlaces)
+	}
+	return layers
+}
+
+// ReadPBF извлекает из выгрузки .osm.pbf объекты указанных слоев (по умолчанию всех).
+// Элементы возвращаются в виде ответа Overpass с полной геометрией (out geom), линии - без ссылок на узлы.
+// Файл читается трижды: отношения, затем их линии и линии слоев, затем нужные узлы.
+func ReadPBF(path string, layers ...string) (map[string][]overpass.Element, error) {
+	if len(layers) == 0 {
+		layers = Layers
+	}
+	wanted := make(map[string]bool, len(layers))
+	for _, l := range layers {
+		wanted[l] = true
+	}
+	match := func(kind string, tags map[string]string) []string {
+		var out []string
+		for _, l := range layersOf(kind, tags) {
+			if wanted[l] {
+				out = append(out, l)
+			}
+		}
+		return out
+	}
+
+	var relations []Relation
+	needWays := make(map[int64]bool)
+	err := scanFile(path, Handler{Relation: func(r Relation) {
+		if len(match("relation", r.Tags)) == 0 {
+			return
+		}
+		relations = append(relations, r)
+		for _, m := range r.Members {
+			if m.Type == "way" {
+				needWays[m.Ref] = true
+			}
+		}
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	ways := make(map[int64]Way)
+	needNodes := make(map[int64]bool)
+	err = scanFile(path, Handler{Way: func(w Way) {
+		if !needWays[w.ID] && len(match("way", w.Tags)) == 0 {
+			return
+		}
+		ways[w.ID] = w
+		for _, ref := range w.Nodes {
+			needNodes[ref] = true
+		}
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]overpass.Element, len(layers))
+	for _, l := range layers {
+		result[l] = []overpass.Element{}
+	}
+	coords := make(map[int64]overpass.LatLon, len(needNodes))
+	err = scanFile(path, Handler{Node: func(n Node) {
+		if needNodes[n.ID] {
+			coords[n.ID] = overpass.LatLon{Lat: n.Lat, Lon: n.Lon}
+		}
+		for _, l := range match("node", n.Tags) {
+			result[l] = append(result[l], overpass.Element{Type: "node", ID: n.ID, Lat: n.Lat, Lon: n.Lon, Tags: n.Tags})
+		}
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	geometry := func(refs []int64) []overpass.LatLon {
+		geom := make([]overpass.LatLon, 0, len(refs))
+		for _, ref := range refs {
+			if p, ok := coords[ref]; ok {
+				geom = append(geom, p)
+			}
+		}
+		return geom
+	}
+
+	for _, w := range ways {
+		layers := match("way", w.Tags)
+		if len(layers) == 0 {
+			continue
+		}
+		e := overpass.Element{Type: "way", ID: w.ID, Geometry: geometry(w.Nodes), Tags: w.Tags}
+		if len(e.Geometry) < 2 {
+			continue
+		}
+		e.Bounds = bounds(e.Geometry)
+		for _, l := range layers {
+			result[l] = append(result[l], e)
+		}
+	}
+
+	for _, r := range relations {
+		e := overpass.Element{Type: "relation", ID: r.ID, Tags: r.Tags}
+		var all []overpass.LatLon
+		for _, m := range r.Members {
+			member := overpass.Member{Type: m.Type, Ref: m.Ref, Role: m.Role}
+			switch m.Type {
+			case "node":
+				if p, ok := coords[m.Ref]; ok {
+					member.Lat, member.Lon = p.Lat, p.Lon
+					all = append(all, p)
+				}
+			case "way":
+				if w, ok := ways[m.Ref]; ok {
+					member.Geometry = geometry(w.Nodes)
+					all = append(all, member.Geometry...)
+				}
+			}
+			e.Members = append(e.Members, member)
+		}
+		if len(all) == 0 {
+			continue
+		}
+		e.Bounds = bounds(all)
+		for _, l := range match("relation", r.Tags) {
+			result[l] = append(result[l], e)
+		}
+	}
+
+	for _, elements := range result {
+		sort.Slice(elements, func(i, j int) bool {
+			if elements[i].Type != elements[j].Type {
+				return elements[i].Type < elements[j].Type
+			}
+			return elements[i].ID < elements[j].ID
+		})
+	}
+	return result, nil
+}
+
+// scanFile читает файл от начала с заданными обработчиками
+func scanFile(path string, h Handler) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := Scan(f, h); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// bounds - границы набора точек
+func bounds(points []overpass.LatLon) *overpass.Bounds {
+	b := &overpass.Bounds{MinLat: points[0].Lat, MaxLat: points[0].Lat, MinLon: points[0].Lon, MaxLon: points[0].Lon}
+	for _, p := range points[1:] {
+		b.MinLat, b.MaxLat = min(b.MinLat, p.Lat), max(b.MaxLat, p.Lat)
+		b.MinLon, b.MaxLon = min(b.MinLon, p.Lon), max(b.MaxLon, p.Lon)
+	}
+	return b
+}
@@ -0,0 +1,499 @@
+package osm
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ==============================
+// ЧТЕНИЕ OSM PBF
+// ==============================
+
+// Размеры блоков по спецификации формата
+const (
+	maxHeaderSize = 64 << 10
+	maxBlobSize   = 32 << 20
+)
+
+// ErrFormat - файл не является корректным OSM PBF или использует неподдерживаемые возможности
+var ErrFormat = errors.New("invalid osm pbf")
+
+// Node - узел OSM
+type Node struct {
+	ID       int64
+	Lat, Lon float64
+	Tags     map[string]string
+}
+
+// Way - линия OSM: ссылки на узлы без координат
+type Way struct {
+	ID    int64
+	Nodes []int64
+	Tags  map[string]string
+}
+
+// Relation - отношение OSM
+type Relation struct {
+	ID      int64
+	Members []RelationMember
+	Tags    map[string]string
+}
+
+// RelationMember - участник отношения
+type RelationMember struct {
+	Type string // node, way, relation
+	Ref  int64
+	Role string
+}
+
+// Handler - обработчики элементов; элементы без обработчика не декодируются
+type Handler struct {
+	Node     func(Node)
+	Way      func(Way)
+	Relation func(Relation)
+}
+
+// Scan читает файл .osm.pbf и передает элементы обработчикам в порядке файла.
+// Поддерживаются блоки без сжатия и со сжатием zlib.
+func Scan(r io.Reader, h Handler) error {
+	br := bufio.NewReaderSize(r, 1<<20)
+	var sizeBuf [4]byte
+	for {
+		if _, err := io.ReadFull(br, sizeBuf[:]); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		size := binary.BigEndian.Uint32(sizeBuf[:])
+		if size > maxHeaderSize {
+			return fmt.Errorf("%w: blob header of %d bytes", ErrFormat, size)
+		}
+		header := make([]byte, size)
+		if _, err := io.ReadFull(br, header); err != nil {
+			return err
+		}
+		kind, dataSize, err := parseBlobHeader(header)
+		if err != nil {
+			return err
+		}
+		if dataSize > maxBlobSize {
+			return fmt.Errorf("%w: blob of %d bytes", ErrFormat, dataSize)
+		}
+		blob := make([]byte, dataSize)
+		if _, err := io.ReadFull(br, blob); err != nil {
+			return err
+		}
+
+		switch kind {
+		case "OSMHeader":
+			data, err := decodeBlob(blob)
+			if err != nil {
+				return err
+			}
+			if err := checkHeader(data); err != nil {
+				return err
+			}
+		case "OSMData":
+			if h.Node == nil && h.Way == nil && h.Relation == nil {
+				continue
+			}
+			data, err := decodeBlob(blob)
+			if err != nil {
+				return err
+			}
+			if err := h.block(data); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// parseBlobHeader возвращает тип блока и размер его данных
+func parseBlobHeader(b []byte) (kind string, size int, err error) {
+	err = fields(b, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error {
+		switch num {
+		case 1:
+			kind = string(v)
+		case 3:
+			size = int(x)
+		}
+		return nil
+	})
+	return kind, size, err
+}
+
+// decodeBlob распаковывает данные блока
+func decodeBlob(b []byte) ([]byte, error) {
+	var raw, zdata []byte
+	var rawSize int
+	compression := ""
+	err := fields(b, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error {
+		switch num {
+		case 1:
+			raw = v
+		case 2:
+			rawSize = int(x)
+		case 3:
+			zdata = v
+		case 4:
+			compression = "lzma"
+		case 6:
+			compression = "lz4"
+		case 7:
+			compression = "zstd"
+		}
+		return nil
+	})
+	switch {
+	case err != nil:
+		return nil, err
+	case raw != nil:
+		return raw, nil
+	case zdata != nil:
+		if rawSize > maxBlobSize {
+			return nil, fmt.Errorf("%w: blob of %d bytes", ErrFormat, rawSize)
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(zdata))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrFormat, err)
+		}
+		defer zr.Close()
+		out := bytes.NewBuffer(make([]byte, 0, rawSize))
+		if _, err := io.Copy(out, io.LimitReader(zr, maxBlobSize)); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrFormat, err)
+		}
+		return out.Bytes(), nil
+	case compression != "":
+		return nil, fmt.Errorf("%w: %s compression is not supported", ErrFormat, compression)
+	default:
+		return nil, fmt.Errorf("%w: empty blob", ErrFormat)
+	}
+}
+
+// checkHeader проверяет, что обязательные возможности файла поддерживаются
+func checkHeader(b []byte) error {
+	return fields(b, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error {
+		if num != 4 {
+			return nil
+		}
+		switch feature := string(v); feature {
+		case "OsmSchema-V0.6", "DenseNodes":
+			return nil
+		default:
+			return fmt.Errorf("%w: required feature %q is not supported", ErrFormat, feature)
+		}
+	})
+}
+
+// block - контекст блока данных: таблица строк и параметры координат
+type block struct {
+	strings     []string
+	granularity int64
+	latOffset   int64
+	lonOffset   int64
+}
+
+// coord переводит координату блока в градусы
+func (b *block) coord(offset, v int64) float64 {
+	return float64(offset+b.granularity*v) / 1e9
+}
+
+// str возвращает строку из таблицы блока
+func (b *block) str(i uint64) (string, error) {
+	if i >= uint64(len(b.strings)) {
+		return "", fmt.Errorf("%w: string index %d out of range", ErrFormat, i)
+	}
+	return b.strings[i], nil
+}
+
+// tags собирает теги по индексам ключей и значений
+func (b *block) tags(keys, vals []uint64) (map[string]string, error) {
+	if len(keys) != len(vals) {
+		return nil, fmt.Errorf("%w: %d keys for %d values", ErrFormat, len(keys), len(vals))
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string, len(keys))
+	for i := range keys {
+		k, err := b.str(keys[i])
+		if err != nil {
+			return nil, err
+		}
+		v, err := b.str(vals[i])
+		if err != nil {
+			return nil, err
+		}
+		tags[k] = v
+	}
+	return tags, nil
+}
+
+// block разбирает блок данных и вызывает обработчики
+func (h Handler) block(data []byte) error {
+	b := &block{granularity: 100}
+	var groups [][]byte
+	err := fields(data, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error {
+		switch num {
+		case 1:
+			return fields(v, func(num protowire.Number, typ protowire.Type, s []byte, x uint64) error {
+				if num == 1 {
+					b.strings = append(b.strings, string(s))
+				}
+				return nil
+			})
+		case 2:
+			groups = append(groups, v)
+		case 17:
+			b.granularity = int64(x)
+		case 19:
+			b.latOffset = int64(x)
+		case 20:
+			b.lonOffset = int64(x)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, g := range groups {
+		err := fields(g, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error {
+			switch {
+			case num == 1 && h.Node != nil:
+				return h.node(b, v)
+			case num == 2 && h.Node != nil:
+				return h.denseNodes(b, v)
+			case num == 3 && h.Way != nil:
+				return h.way(b, v)
+			case num == 4 && h.Relation != nil:
+				return h.relation(b, v)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h Handler) node(b *block, data []byte) error {
+	var n Node
+	var keys, vals []uint64
+	var lat, lon int64
+	err := fields(data, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) (err error) {
+		switch num {
+		case 1:
+			n.ID = protowire.DecodeZigZag(x)
+		case 2:
+			keys, err = appendVarints(keys, typ, v, x)
+		case 3:
+			vals, err = appendVarints(vals, typ, v, x)
+		case 8:
+			lat = protowire.DecodeZigZag(x)
+		case 9:
+			lon = protowire.DecodeZigZag(x)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if n.Tags, err = b.tags(keys, vals); err != nil {
+		return err
+	}
+	n.Lat, n.Lon = b.coord(b.latOffset, lat), b.coord(b.lonOffset, lon)
+	h.Node(n)
+	return nil
+}
+
+func (h Handler) denseNodes(b *block, data []byte) error {
+	var ids, lats, lons, keysVals []uint64
+	err := fields(data, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) (err error) {
+		switch num {
+		case 1:
+			ids, err = appendVarints(ids, typ, v, x)
+		case 8:
+			lats, err = appendVarints(lats, typ, v, x)
+		case 9:
+			lons, err = appendVarints(lons, typ, v, x)
+		case 10:
+			keysVals, err = appendVarints(keysVals, typ, v, x)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(lats) != len(ids) || len(lons) != len(ids) {
+		return fmt.Errorf("%w: dense nodes of unequal length", ErrFormat)
+	}
+
+	var id, lat, lon int64
+	kv := 0
+	for i := range ids {
+		id += protowire.DecodeZigZag(ids[i])
+		lat += protowire.DecodeZigZag(lats[i])
+		lon += protowire.DecodeZigZag(lons[i])
+		n := Node{ID: id, Lat: b.coord(b.latOffset, lat), Lon: b.coord(b.lonOffset, lon)}
+
+		// Теги всех узлов идут подряд парами ключ-значение, узлы разделены нулем
+		var keys, vals []uint64
+		for kv < len(keysVals) && keysVals[kv] != 0 {
+			if kv+1 >= len(keysVals) {
+				return fmt.Errorf("%w: dense node key without value", ErrFormat)
+			}
+			keys, vals = append(keys, keysVals[kv]), append(vals, keysVals[kv+1])
+			kv += 2
+		}
+		kv++
+		if n.Tags, err = b.tags(keys, vals); err != nil {
+			return err
+		}
+		h.Node(n)
+	}
+	return nil
+}
+
+func (h Handler) way(b *block, data []byte) error {
+	var w Way
+	var keys, vals, refs []uint64
+	err := fields(data, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) (err error) {
+		switch num {
+		case 1:
+			w.ID = int64(x)
+		case 2:
+			keys, err = appendVarints(keys, typ, v, x)
+		case 3:
+			vals, err = appendVarints(vals, typ, v, x)
+		case 8:
+			refs, err = appendVarints(refs, typ, v, x)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if w.Tags, err = b.tags(keys, vals); err != nil {
+		return err
+	}
+	w.Nodes = make([]int64, len(refs))
+	var ref int64
+	for i, d := range refs {
+		ref += protowire.DecodeZigZag(d)
+		w.Nodes[i] = ref
+	}
+	h.Way(w)
+	return nil
+}
+
+// memberTypes - типы участников отношения по номеру перечисления MemberType
+var memberTypes = []string{"node", "way", "relation"}
+
+func (h Handler) relation(b *block, data []byte) error {
+	var r Relation
+	var keys, vals, roles, memids, types []uint64
+	err := fields(data, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) (err error) {
+		switch num {
+		case 1:
+			r.ID = int64(x)
+		case 2:
+			keys, err = appendVarints(keys, typ, v, x)
+		case 3:
+			vals, err = appendVarints(vals, typ, v, x)
+		case 8:
+			roles, err = appendVarints(roles, typ, v, x)
+		case 9:
+			memids, err = appendVarints(memids, typ, v, x)
+		case 10:
+			types, err = appendVarints(types, typ, v, x)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if r.Tags, err = b.tags(keys, vals); err != nil {
+		return err
+	}
+	if len(roles) != len(memids) || len(types) != len(memids) {
+		return fmt.Errorf("%w: relation %d members of unequal length", ErrFormat, r.ID)
+	}
+
+	var ref int64
+	r.Members = make([]RelationMember, len(memids))
+	for i := range memids {
+		ref += protowire.DecodeZigZag(memids[i])
+		if types[i] >= uint64(len(memberTypes)) {
+			return fmt.Errorf("%w: relation %d member type %d", ErrFormat, r.ID, types[i])
+		}
+		role, err := b.str(roles[i])
+		if err != nil {
+			return err
+		}
+		r.Members[i] = RelationMember{Type: memberTypes[types[i]], Ref: ref, Role: role}
+	}
+	h.Relation(r)
+	return nil
+}
+
+// fields перебирает поля сообщения protobuf: v - значение поля с длиной, x - числовое значение
+func fields(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("%w: %v", ErrFormat, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		var v []byte
+		var x uint64
+		switch typ {
+		case protowire.VarintType:
+			x, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		case protowire.Fixed32Type:
+			var u uint32
+			u, n = protowire.ConsumeFixed32(b)
+			x = uint64(u)
+		case protowire.Fixed64Type:
+			x, n = protowire.ConsumeFixed64(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return fmt.Errorf("%w: %v", ErrFormat, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		if err := fn(num, typ, v, x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendVarints добавляет значения повторяемого поля: упакованного или одиночного
+func appendVarints(dst []uint64, typ protowire.Type, v []byte, x uint64) ([]uint64, error) {
+	if typ != protowire.BytesType {
+		return append(dst, x), nil
+	}
+	for len(v) > 0 {
+		u, n := protowire.ConsumeVarint(v)
+		if n < 0 {
+			return nil, fmt.Errorf("%w: %v", ErrFormat, protowire.ParseError(n))
+		}
+		dst = append(dst, u)
+		v = v[n:]
+	}
+	return dst, nil
+}
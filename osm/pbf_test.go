@@ -0,0 +1,238 @@
+package osm
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ==============================
+// СБОРКА ТЕСТОВЫХ ФАЙЛОВ
+// ==============================
+
+// msg собирает сообщение protobuf из готовых полей
+func msg(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func varint(num protowire.Number, x uint64) []byte {
+	b := protowire.AppendTag(nil, num, protowire.VarintType)
+	return protowire.AppendVarint(b, x)
+}
+
+func bytesField(num protowire.Number, v []byte) []byte {
+	b := protowire.AppendTag(nil, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// packed кодирует упакованное повторяемое поле
+func packed(num protowire.Number, xs ...uint64) []byte {
+	var v []byte
+	for _, x := range xs {
+		v = protowire.AppendVarint(v, x)
+	}
+	return bytesField(num, v)
+}
+
+// packedDelta кодирует упакованное поле sint64 с дельта-кодированием
+func packedDelta(num protowire.Number, xs ...int64) []byte {
+	var prev int64
+	deltas := make([]uint64, len(xs))
+	for i, x := range xs {
+		deltas[i] = protowire.EncodeZigZag(x - prev)
+		prev = x
+	}
+	return packed(num, deltas...)
+}
+
+// rawBlob - блок без сжатия
+func rawBlob(data []byte) []byte {
+	return bytesField(1, data)
+}
+
+// zlibBlob - блок со сжатием zlib
+func zlibBlob(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return msg(varint(2, uint64(len(data))), bytesField(3, buf.Bytes()))
+}
+
+// fileBlock - блок файла: длина заголовка, заголовок и данные
+func fileBlock(kind string, blob []byte) []byte {
+	header := msg(bytesField(1, []byte(kind)), varint(3, uint64(len(blob))))
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(header)))
+	return msg(size[:], header, blob)
+}
+
+// osmHeader - заголовок файла с обязательными возможностями
+func osmHeader(features ...string) []byte {
+	var parts [][]byte
+	for _, f := range features {
+		parts = append(parts, bytesField(4, []byte(f)))
+	}
+	return rawBlob(msg(parts...))
+}
+
+// stringTable - таблица строк блока; нулевая строка по соглашению пустая
+func stringTable(strs ...string) []byte {
+	parts := [][]byte{bytesField(1, nil)}
+	for _, s := range strs {
+		parts = append(parts, bytesField(1, []byte(s)))
+	}
+	return bytesField(1, msg(parts...))
+}
+
+// group - группа примитивов блока
+func group(fields ...[]byte) []byte {
+	return bytesField(2, msg(fields...))
+}
+
+// Таблица строк тестового блока: индексы начинаются с 1
+var testStrings = []string{"natural", "coastline", "harbour", "yes", "name", "Индига", "outer"}
+
+// testBlock - блок с плотными узлами, линией и отношением
+func testBlock(extra ...[]byte) []byte {
+	dense := msg(
+		packedDelta(1, 10, 11, 15),
+		packedDelta(8, 695000000, 695100000, -10),
+		packedDelta(9, 480000000, 481000000, 1799999990),
+		// Теги: у первого узла нет, у второго harbour=yes и name=Индига, у третьего нет
+		packed(10, 0, 3, 4, 5, 6, 0, 0),
+	)
+	way := msg(varint(1, 100), packed(2, 1), packed(3, 2), packedDelta(8, 10, 11, 15, 10))
+	rel := msg(varint(1, 200), packed(8, 7, 7), packedDelta(9, 100, 300), packed(10, 1, 1))
+	return msg(append([][]byte{stringTable(testStrings...), group(bytesField(2, dense), bytesField(3, way), bytesField(4, rel))}, extra...)...)
+}
+
+// ==============================
+// ТЕСТЫ
+// ==============================
+
+// scanResult - все элементы, прочитанные из файла
+type scanResult struct {
+	nodes     []Node
+	ways      []Way
+	relations []Relation
+}
+
+func scanBytes(data []byte) (scanResult, error) {
+	var res scanResult
+	err := Scan(bytes.NewReader(data), Handler{
+		Node:     func(n Node) { res.nodes = append(res.nodes, n) },
+		Way:      func(w Way) { res.ways = append(res.ways, w) },
+		Relation: func(r Relation) { res.relations = append(res.relations, r) },
+	})
+	return res, err
+}
+
+func TestScan(t *testing.T) {
+	header := fileBlock("OSMHeader", osmHeader("OsmSchema-V0.6", "DenseNodes"))
+	wantWays := []Way{{ID: 100, Nodes: []int64{10, 11, 15, 10}, Tags: map[string]string{"natural": "coastline"}}}
+	wantRelations := []Relation{{ID: 200, Tags: nil, Members: []RelationMember{
+		{Type: "way", Ref: 100, Role: "outer"},
+		{Type: "way", Ref: 300, Role: "outer"},
+	}}}
+
+	tests := []struct {
+		name    string
+		file    []byte
+		granule int64 // Шаг координат блока, нанградусы
+		offset  float64
+	}{
+		{"raw blob", msg(header, fileBlock("OSMData", rawBlob(testBlock()))), 100, 0},
+		{"zlib blob", msg(header, fileBlock("OSMData", zlibBlob(testBlock()))), 100, 0},
+		{"granularity and offsets", msg(header, fileBlock("OSMData", rawBlob(testBlock(
+			varint(17, 1000), varint(19, 500000000), varint(20, 250000000),
+		)))), 1000, 0.5},
+		{"unknown blocks are skipped", msg(header, fileBlock("OSMIndex", rawBlob([]byte{0xff})), fileBlock("OSMData", rawBlob(testBlock()))), 100, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := scanBytes(tt.file)
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+
+			g := float64(tt.granule) / 1e9
+			lonOffset := tt.offset / 2
+			wantNodes := []Node{
+				{ID: 10, Lat: tt.offset + 695000000*g, Lon: lonOffset + 480000000*g},
+				{ID: 11, Lat: tt.offset + 695100000*g, Lon: lonOffset + 481000000*g, Tags: map[string]string{"harbour": "yes", "name": "Индига"}},
+				{ID: 15, Lat: tt.offset - 10*g, Lon: lonOffset + 1799999990*g},
+			}
+			if len(res.nodes) != len(wantNodes) {
+				t.Fatalf("got %d nodes, want %d", len(res.nodes), len(wantNodes))
+			}
+			for i, n := range res.nodes {
+				want := wantNodes[i]
+				if n.ID != want.ID || math.Abs(n.Lat-want.Lat) > 1e-9 || math.Abs(n.Lon-want.Lon) > 1e-9 || !reflect.DeepEqual(n.Tags, want.Tags) {
+					t.Errorf("node %d = %+v, want %+v", i, n, want)
+				}
+			}
+			if !reflect.DeepEqual(res.ways, wantWays) {
+				t.Errorf("ways = %+v, want %+v", res.ways, wantWays)
+			}
+			if !reflect.DeepEqual(res.relations, wantRelations) {
+				t.Errorf("relations = %+v, want %+v", res.relations, wantRelations)
+			}
+		})
+	}
+}
+
+func TestScanSkipsUnhandledElements(t *testing.T) {
+	file := msg(fileBlock("OSMHeader", osmHeader()), fileBlock("OSMData", rawBlob(testBlock())))
+	ways := 0
+	if err := Scan(bytes.NewReader(file), Handler{Way: func(Way) { ways++ }}); err != nil {
+		t.Fatal(err)
+	}
+	if ways != 1 {
+		t.Errorf("got %d ways, want 1", ways)
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	header := fileBlock("OSMHeader", osmHeader("OsmSchema-V0.6"))
+	data := func(fields ...[]byte) []byte {
+		return msg(header, fileBlock("OSMData", rawBlob(msg(fields...))))
+	}
+	truncated := fileBlock("OSMData", rawBlob(testBlock()))
+
+	tests := []struct {
+		name string
+		file []byte
+		want error
+	}{
+		{"unsupported feature", fileBlock("OSMHeader", osmHeader("OsmSchema-V0.6", "HistoricalInformation")), ErrFormat},
+		{"lzma compression", msg(header, fileBlock("OSMData", bytesField(4, []byte{1}))), ErrFormat},
+		{"empty blob", msg(header, fileBlock("OSMData", nil)), ErrFormat},
+		{"corrupt zlib", msg(header, fileBlock("OSMData", msg(varint(2, 10), bytesField(3, []byte("not zlib"))))), ErrFormat},
+		{"string index out of range", data(stringTable(testStrings...), group(bytesField(3, msg(varint(1, 1), packed(2, 99), packed(3, 1))))), ErrFormat},
+		{"keys without values", data(stringTable(testStrings...), group(bytesField(3, msg(varint(1, 1), packed(2, 1, 2), packed(3, 1))))), ErrFormat},
+		{"dense nodes of unequal length", data(group(bytesField(2, msg(packedDelta(1, 1, 2), packedDelta(8, 1), packedDelta(9, 1, 2))))), ErrFormat},
+		{"dense key without value", data(stringTable(testStrings...), group(bytesField(2, msg(packedDelta(1, 1), packedDelta(8, 1), packedDelta(9, 1), packed(10, 1))))), ErrFormat},
+		{"relation member type", data(stringTable(testStrings...), group(bytesField(4, msg(varint(1, 1), packed(8, 7), packedDelta(9, 1), packed(10, 5))))), ErrFormat},
+		{"truncated varint", data([]byte{0x08, 0xff}), ErrFormat},
+		{"truncated blob", msg(header, truncated[:len(truncated)-3]), io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := scanBytes(tt.file)
+			if err == nil {
+				t.Fatal("Scan succeeded")
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package osm
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/s3nkyh/arcticeroute/overpass"
+)
+
+// ==============================
+// ЛОКАЛЬНОЕ ПРОСТРАНСТВЕННОЕ ХРАНИЛИЩЕ
+// ==============================
+
+// maxCells - элементы, занимающие больше ячеек сетки, проверяются при каждом запросе
+const maxCells = 4096
+
+// Meta - сведения о последнем импорте
+type Meta struct {
+	Source     string         `json:"source"`      // Путь к выгрузке .osm.pbf
+	SourceTime time.Time      `json:"source_time"` // Время изменения файла выгрузки
+	ImportedAt time.Time      `json:"imported_at"`
+	Counts     map[string]int `json:"counts"` // Число объектов по слоям
+	// Bounds - границы импортированных объектов; вне их хранилище ничего не знает об области
+	Bounds *overpass.Bounds `json:"bounds,omitempty"`
+}
+
+// Store - объекты OSM по слоям с индексом по сетке 1°×1°.
+// Каждый слой хранится в файле <dir>/<layer>.json.gz в формате элементов Overpass.
+type Store struct {
+	dir string
+
+	mu     sync.RWMutex
+	layers map[string]*layer
+	meta   Meta
+}
+
+// layer - элементы слоя и их размещение по ячейкам сетки
+type layer struct {
+	elements []overpass.Element
+	bounds   []orb.Bound
+	cells    map[cell][]int
+	wide     []int // Элементы слишком большие для сетки
+}
+
+// cell - ячейка сетки 1°×1°
+type cell struct{ lat, lon int }
+
+// OpenStore открывает хранилище в каталоге dir и загружает импортированные слои
+func OpenStore(dir string) (*Store, error) {
+	s := &Store{dir: dir, layers: make(map[string]*layer)}
+	if err := readJSON(filepath.Join(dir, "meta.json"), false, &s.meta); err != nil {
+		return nil, fmt.Errorf("osm store: %w", err)
+	}
+	for _, name := range Layers {
+		if _, ok := s.meta.Counts[name]; !ok {
+			continue
+		}
+		var elements []overpass.Element
+		if err := readJSON(s.layerPath(name), true, &elements); err != nil {
+			return nil, fmt.Errorf("osm store: %w", err)
+		}
+		s.layers[name] = newLayer(elements)
+	}
+	return s, nil
+}
+
+// Import читает выгрузку .osm.pbf, сохраняет все слои и заменяет ими содержимое хранилища
+func (s *Store) Import(path string) (Meta, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Meta{}, err
+	}
+	data, err := ReadPBF(path)
+	if err != nil {
+		return Meta{}, err
+	}
+
+	meta := Meta{Source: path, SourceTime: info.ModTime().UTC(), ImportedAt: time.Now().UTC(), Counts: make(map[string]int)}
+	layers := make(map[string]*layer, len(data))
+	var extent orb.Bound
+	for name, elements := range data {
+		if err := writeJSON(s.layerPath(name), true, elements); err != nil {
+			return Meta{}, fmt.Errorf("osm store: %w", err)
+		}
+		l := newLayer(elements)
+		for _, b := range l.bounds {
+			if meta.Bounds == nil {
+				meta.Bounds, extent = &overpass.Bounds{}, b
+			}
+			extent = extent.Union(b)
+		}
+		layers[name] = l
+		meta.Counts[name] = len(elements)
+	}
+	if meta.Bounds != nil {
+		*meta.Bounds = overpass.Bounds{MinLat: extent.Min.Lat(), MinLon: extent.Min.Lon(), MaxLat: extent.Max.Lat(), MaxLon: extent.Max.Lon()}
+	}
+	if err := writeJSON(filepath.Join(s.dir, "meta.json"), false, meta); err != nil {
+		return Meta{}, fmt.Errorf("osm store: %w", err)
+	}
+
+	s.mu.Lock()
+	s.layers, s.meta = layers, meta
+	s.mu.Unlock()
+	return meta, nil
+}
+
+// Stale сообщает, что выгрузку path еще не импортировали, она изменилась после импорта,
+// при импорте еще не было какого-то из слоев Layers или не сохранялись границы
+func (s *Store) Stale(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	meta := s.Meta()
+	if meta.Source != path || !info.ModTime().UTC().Equal(meta.SourceTime) || meta.Bounds == nil {
+		return true
+	}
+	for _, name := range Layers {
+		if _, ok := meta.Counts[name]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Meta возвращает сведения о последнем импорте
+func (s *Store) Meta() Meta {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.meta
+}
+
+// Has сообщает, что слой импортирован
+func (s *Store) Has(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.layers[name]
+	return ok
+}
+
+// Covers сообщает, что область bound ([lon, lat]) лежит в границах импортированной выгрузки.
+// Импорт без сохраненных границ считается покрывающим любую область.
+func (s *Store) Covers(bound orb.Bound) bool {
+	s.mu.RLock()
+	b := s.meta.Bounds
+	s.mu.RUnlock()
+	if b == nil {
+		return true
+	}
+	return bound.Min.Lat() >= b.MinLat && bound.Max.Lat() <= b.MaxLat &&
+		bound.Min.Lon() >= b.MinLon && bound.Max.Lon() <= b.MaxLon
+}
+
+// Query возвращает элементы слоя, границы которых пересекаются с bound ([lon, lat])
+func (s *Store) Query(name string, bound orb.Bound) []overpass.Element {
+	s.mu.RLock()
+	l := s.layers[name]
+	s.mu.RUnlock()
+	if l == nil {
+		return nil
+	}
+
+	seen := make(map[int]bool)
+	var result []overpass.Element
+	check := func(i int) {
+		if !seen[i] && l.bounds[i].Intersects(bound) {
+			seen[i] = true
+			result = append(result, l.elements[i])
+		}
+	}
+	if cellCount(bound) > maxCells {
+		for i := range l.elements {
+			check(i)
+		}
+		return result
+	}
+	forCells(bound, func(c cell) {
+		for _, i := range l.cells[c] {
+			check(i)
+		}
+	})
+	for _, i := range l.wide {
+		check(i)
+	}
+	return result
+}
+
+func (s *Store) layerPath(name string) string {
+	return filepath.Join(s.dir, name+".json.gz")
+}
+
+// newLayer строит индекс слоя
+func newLayer(elements []overpass.Element) *layer {
+	l := &layer{elements: elements, bounds: make([]orb.Bound, len(elements)), cells: make(map[cell][]int)}
+	for i, e := range elements {
+		l.bounds[i] = elementBound(e)
+		if cellCount(l.bounds[i]) > maxCells {
+			l.wide = append(l.wide, i)
+			continue
+		}
+		forCells(l.bounds[i], func(c cell) {
+			l.cells[c] = append(l.cells[c], i)
+		})
+	}
+	return l
+}
+
+// elementBound - границы элемента: точка узла или bounds линии и отношения
+func elementBound(e overpass.Element) orb.Bound {
+	if e.Bounds != nil {
+		return orb.Bound{
+			Min: orb.Point{e.Bounds.MinLon, e.Bounds.MinLat},
+			Max: orb.Point{e.Bounds.MaxLon, e.Bounds.MaxLat},
+		}
+	}
+	return orb.Point{e.Lon, e.Lat}.Bound()
+}
+
+func cellRange(b orb.Bound) (minLat, minLon, maxLat, maxLon int) {
+	return int(math.Floor(b.Min[1])), int(math.Floor(b.Min[0])), int(math.Floor(b.Max[1])), int(math.Floor(b.Max[0]))
+}
+
+func cellCount(b orb.Bound) int {
+	minLat, minLon, maxLat, maxLon := cellRange(b)
+	return (maxLat - minLat + 1) * (maxLon - minLon + 1)
+}
+
+// forCells перебирает ячейки сетки, которые задевает bound
+func forCells(b orb.Bound, fn func(cell)) {
+	minLat, minLon, maxLat, maxLon := cellRange(b)
+	for lat := minLat; lat <= maxLat; lat++ {
+		for lon := minLon; lon <= maxLon; lon++ {
+			fn(cell{lat, lon})
+		}
+	}
+}
+
+// readJSON читает JSON-файл (при compressed - сжатый gzip). Отсутствующий файл не считается ошибкой.
+func readJSON(path string, compressed bool, v interface{}) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if !compressed {
+		return json.NewDecoder(f).Decode(v)
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	defer zr.Close()
+	return json.NewDecoder(zr).Decode(v)
+}
+
+// writeJSON записывает v во временный файл и заменяет им path
+func writeJSON(path string, compressed bool, v interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if compressed {
+		zw := gzip.NewWriter(tmp)
+		err = json.NewEncoder(zw).Encode(v)
+		if cerr := zw.Close(); err == nil {
+			err = cerr
+		}
+	} else {
+		enc := json.NewEncoder(tmp)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(v)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,63 @@
+package osm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+func TestStoreImport(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "extract.osm.pbf")
+	file := msg(fileBlock("OSMHeader", osmHeader("OsmSchema-V0.6", "DenseNodes")), fileBlock("OSMData", rawBlob(testBlock())))
+	if err := os.WriteFile(path, file, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := OpenStore(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !store.Stale(path) {
+		t.Fatal("new extract is not stale")
+	}
+	if _, err := store.Import(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Линия хранится с геометрией, но без ссылок на узлы
+	coast := store.Query(LayerCoastlines, orb.Bound{Min: orb.Point{-180, -90}, Max: orb.Point{180, 90}})
+	if len(coast) != 1 || len(coast[0].Geometry) != 4 || coast[0].Nodes != nil {
+		t.Fatalf("coastlines = %+v", coast)
+	}
+	if harbours := store.Query(LayerHarbours, orb.Bound{Min: orb.Point{48, 69}, Max: orb.Point{49, 70}}); len(harbours) != 1 || harbours[0].ID != 11 {
+		t.Errorf("harbours = %+v", harbours)
+	}
+
+	// Границы импорта переживают повторное открытие хранилища
+	reopened, err := OpenStore(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Stale(path) {
+		t.Error("imported extract is stale after reopening")
+	}
+	tests := []struct {
+		name  string
+		bound orb.Bound
+		want  bool
+	}{
+		{"inside the extract", orb.Bound{Min: orb.Point{50, 10}, Max: orb.Point{60, 60}}, true},
+		{"whole extract", orb.Bound{Min: orb.Point{48, -0.000001}, Max: orb.Point{179.999999, 69.51}}, true},
+		{"north of the extract", orb.Bound{Min: orb.Point{50, 60}, Max: orb.Point{60, 75}}, false},
+		{"west of the extract", orb.Bound{Min: orb.Point{30, 60}, Max: orb.Point{40, 65}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reopened.Covers(tt.bound); got != tt.want {
+				t.Errorf("Covers(%v) = %v, want %v (bounds %+v)", tt.bound, got, tt.want, reopened.Meta().Bounds)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package overpass
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ==============================
+// КЭШ ОТВЕТОВ НА ДИСКЕ
+// ==============================
+
+// tmpMaxAge - временные файлы старше этого остались от прерванной записи
+const tmpMaxAge = time.Hour
+
+// cache - ответы сервера в файлах <ключ>.json; срок годности отсчитывается от времени записи.
+// Ответы старше maxAge и самые старые сверх maxSize байт удаляются после каждой записи.
+type cache struct {
+	dir     string
+	ttl     time.Duration
+	maxAge  time.Duration // 0 - без ограничения
+	maxSize int64         // 0 - без ограничения
+}
+
+// get читает ответ; fresh - ответ моложе срока годности
+func (c *cache) get(key string) (body []byte, fresh, ok bool) {
+	path := filepath.Join(c.dir, key+".json")
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, false, false
+	}
+	body, err = os.ReadFile(path)
+	if err != nil {
+		return nil, false, false
+	}
+	return body, time.Since(info.ModTime()) < c.ttl, true
+}
+
+// put записывает ответ через временный файл, чтобы не оставить его наполовину записанным
+func (c *cache) put(key string, body []byte) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(c.dir, key+".json")); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := c.prune(); err != nil {
+		log.Println("Overpass cache prune error:", err)
+	}
+	return nil
+}
+
+// remove удаляет ответ, например поврежденный
+func (c *cache) remove(key string) {
+	if err := os.Remove(filepath.Join(c.dir, key+".json")); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Println("Overpass cache remove error:", err)
+	}
+}
+
+// prune удаляет ответы старше maxAge, брошенные временные файлы и самые старые
+// ответы, пока объем кэша больше maxSize
+func (c *cache) prune() error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+
+	type file struct {
+		path string
+		size int64
+		mod  time.Time
+	}
+	var files []file
+	var total int64
+	now := time.Now()
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // Файл удален параллельной очисткой
+		}
+		path := filepath.Join(c.dir, e.Name())
+		age := now.Sub(info.ModTime())
+		switch {
+		case strings.HasSuffix(e.Name(), ".tmp"):
+			if age > tmpMaxAge {
+				os.Remove(path)
+			}
+		case !strings.HasSuffix(e.Name(), ".json"):
+		case c.maxAge > 0 && age > c.maxAge:
+			os.Remove(path)
+		default:
+			files = append(files, file{path: path, size: info.Size(), mod: info.ModTime()})
+			total += info.Size()
+		}
+	}
+
+	if c.maxSize <= 0 || total <= c.maxSize {
+		return nil
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].mod.Before(files[j].mod) })
+	for _, f := range files {
+		if total <= c.maxSize {
+			break
+		}
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		total -= f.size
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+package overpass
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// ==============================
+// КЛИЕНТ OVERPASS API
+// ==============================
+
+// DefaultEndpoint - публичный сервер Overpass API
+const DefaultEndpoint = "https://overpass-api.de/api/interpreter"
+
+// BBoxPlaceholder в тексте запроса заменяется на область "south,west,north,east"
+const BBoxPlaceholder = "{{bbox}}"
+
+// ErrRemark - сервер вернул ответ с ошибкой выполнения запроса в поле remark
+var ErrRemark = errors.New("overpass runtime error")
+
+// Config - настройки клиента
+type Config struct {
+	Endpoint    string        // Адрес интерпретатора
+	Timeout     time.Duration // Таймаут одного HTTP-запроса
+	Retries     int           // Повторов при ответах 429 и 504 и сетевых ошибках
+	Backoff     time.Duration // Пауза перед первым повтором, далее удваивается
+	MinInterval time.Duration // Наименьший промежуток между запросами к серверу
+	CacheDir    string        // Каталог кэша ответов; пусто - без кэша
+	CacheTTL    time.Duration // Срок годности ответа в кэше
+	CacheMaxAge time.Duration // Более старые ответы удаляются (устаревшие служат запасом при сбоях сервера)
+	CacheSize   int64         // Наибольший объем кэша, байт; сверх него удаляются самые старые ответы
+}
+
+// DefaultConfig - публичный сервер, 3 повтора, не чаще раза в 2 секунды,
+// кэш на сутки с запасом устаревших ответов до 30 дней и 512 МБ
+func DefaultConfig() Config {
+	return Config{
+		Endpoint:    DefaultEndpoint,
+		Timeout:     90 * time.Second,
+		Retries:     3,
+		Backoff:     5 * time.Second,
+		MinInterval: 2 * time.Second,
+		CacheTTL:    24 * time.Hour,
+		CacheMaxAge: 30 * 24 * time.Hour,
+		CacheSize:   512 << 20,
+	}
+}
+
+// Client - клиент Overpass API с повторами, ограничением частоты и кэшем на диске
+type Client struct {
+	cfg   Config
+	http  *http.Client
+	cache *cache
+
+	slot chan struct{} // Запросы к серверу идут по одному; занятый слот - идет запрос
+	last time.Time     // Время последнего запроса; меняется только владельцем слота
+
+	mu      sync.Mutex
+	flights map[string]*flight // Выполняемые запросы по ключу кэша
+}
+
+// flight - запрос к серверу, результат которого ждут все одинаковые запросы
+type flight struct {
+	done    chan struct{}
+	body    []byte
+	err     error
+	waiters int                // Сколько запросов еще ждут результата; под Client.mu
+	cancel  context.CancelFunc // Отменяет запрос, когда ждать больше некому
+}
+
+// NewClient создает клиент; незаданные поля берутся из DefaultConfig
+func NewClient(cfg Config) *Client {
+	def := DefaultConfig()
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = def.Endpoint
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = def.Timeout
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = def.Backoff
+	}
+	if cfg.Retries < 0 {
+		cfg.Retries = 0
+	}
+
+	if cfg.CacheMaxAge < cfg.CacheTTL {
+		cfg.CacheMaxAge = cfg.CacheTTL
+	}
+
+	c := &Client{
+		cfg:     cfg,
+		http:    &http.Client{Timeout: cfg.Timeout},
+		slot:    make(chan struct{}, 1),
+		flights: make(map[string]*flight),
+	}
+	if cfg.CacheDir != "" && cfg.CacheTTL > 0 {
+		c.cache = &cache{dir: cfg.CacheDir, ttl: cfg.CacheTTL, maxAge: cfg.CacheMaxAge, maxSize: cfg.CacheSize}
+	}
+	return c
+}
+
+// Query выполняет запрос в области bbox. Вхождения {{bbox}} в тексте запроса заменяются
+// на координаты области; ответ кэшируется по запросу и области. Область, пересекающая
+// антимеридиан, не поддерживается Overpass и должна быть разделена (BBox.Split).
+// Если сервер недоступен, возвращается устаревший ответ из кэша, когда он есть.
+func (c *Client) Query(ctx context.Context, query string, bbox models.BBox) (*Response, error) {
+	if bbox.CrossesAntimeridian() {
+		return nil, fmt.Errorf("%w %s: split at the antimeridian", models.ErrInvalidBBox, bbox)
+	}
+	query = strings.ReplaceAll(query, BBoxPlaceholder, bbox.String())
+	key := cacheKey(query, bbox.String())
+
+	if resp, ok := c.cached(key); ok {
+		return resp, nil
+	}
+
+	resp, err := c.shared(ctx, query, key)
+	if err == nil {
+		return resp, nil
+	}
+
+	if c.cache != nil && ctx.Err() == nil {
+		if body, _, ok := c.cache.get(key); ok {
+			if resp, derr := decode(body); derr == nil {
+				log.Printf("Overpass request failed, using stale cache: %v", err)
+				return resp, nil
+			}
+		}
+	}
+	return nil, err
+}
+
+// cached возвращает свежий ответ из кэша. Поврежденный файл удаляется,
+// чтобы запрос ушел на сервер.
+func (c *Client) cached(key string) (*Response, bool) {
+	if c.cache == nil {
+		return nil, false
+	}
+	body, fresh, ok := c.cache.get(key)
+	if !ok || !fresh {
+		return nil, false
+	}
+	resp, err := decode(body)
+	if err != nil {
+		log.Printf("Overpass cache entry %s is corrupt, refetching: %v", key, err)
+		c.cache.remove(key)
+		return nil, false
+	}
+	return resp, true
+}
+
+// shared выполняет запрос к серверу или присоединяется к уже идущему запросу
+// с тем же ключом. Ожидание прерывается отменой ctx; сам запрос отменяется,
+// только когда его больше никто не ждет.
+func (c *Client) shared(ctx context.Context, query, key string) (*Response, error) {
+	c.mu.Lock()
+	f, ok := c.flights[key]
+	if !ok {
+		fctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		c.flights[key] = f
+		go c.run(fctx, f, query, key)
+	}
+	f.waiters++
+	c.mu.Unlock()
+
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		c.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			f.cancel()
+		}
+		c.mu.Unlock()
+		return nil, ctx.Err()
+	}
+
+	if f.err != nil {
+		return nil, f.err
+	}
+	return decode(f.body)
+}
+
+// run выполняет запрос flight и записывает ответ в кэш
+func (c *Client) run(ctx context.Context, f *flight, query, key string) {
+	defer func() {
+		c.mu.Lock()
+		delete(c.flights, key)
+		c.mu.Unlock()
+		f.cancel()
+		close(f.done)
+	}()
+
+	// Ответ мог появиться в кэше, пока проверяли очередь запросов
+	if c.cache != nil {
+		if body, fresh, ok := c.cache.get(key); ok && fresh {
+			if _, err := decode(body); err == nil {
+				f.body = body
+				return
+			}
+		}
+	}
+
+	body, err := c.fetch(ctx, query)
+	if err == nil {
+		_, err = decode(body)
+	}
+	if err != nil {
+		f.err = err
+		return
+	}
+	f.body = body
+	if c.cache != nil {
+		if err := c.cache.put(key, body); err != nil {
+			log.Println("Overpass cache write error:", err)
+		}
+	}
+}
+
+// fetch отправляет запрос с повторами на ответы 429 и 504 и сетевые ошибки
+func (c *Client) fetch(ctx context.Context, query string) ([]byte, error) {
+	var lastErr error
+	for attempt := 0; attempt <= c.cfg.Retries; attempt++ {
+		if attempt > 0 {
+			backoff := c.cfg.Backoff << (attempt - 1)
+			var retryErr *retryableError
+			if errors.As(lastErr, &retryErr) && retryErr.after > backoff {
+				backoff = retryErr.after
+			}
+			if err := sleep(ctx, backoff); err != nil {
+				return nil, err
+			}
+		}
+
+		body, err := c.send(ctx, query)
+		if err == nil {
+			return body, nil
+		}
+		lastErr = err
+
+		var retryErr *retryableError
+		if ctx.Err() != nil || !errors.As(err, &retryErr) {
+			return nil, err
+		}
+	}
+	return nil, lastErr
+}
+
+// send занимает очередь к серверу, выдерживает MinInterval с прошлого запроса и отправляет запрос
+func (c *Client) send(ctx context.Context, query string) ([]byte, error) {
+	select {
+	case c.slot <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-c.slot }()
+
+	if err := sleep(ctx, c.cfg.MinInterval-time.Since(c.last)); err != nil {
+		return nil, err
+	}
+	c.last = time.Now()
+	return c.post(ctx, query)
+}
+
+// sleep ждет d или отмены ctx
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryableError - ошибка, после которой запрос стоит повторить
+type retryableError struct {
+	err   error
+	after time.Duration // Пауза из заголовка Retry-After
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// post выполняет один запрос к серверу
+func (c *Client) post(ctx context.Context, query string) ([]byte, error) {
+	form := url.Values{"data": {query}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, &retryableError{err: fmt.Errorf("overpass: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &retryableError{err: fmt.Errorf("overpass: read response: %w", err)}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return body, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusGatewayTimeout:
+		after := time.Duration(0)
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			after = time.Duration(s) * time.Second
+		}
+		return nil, &retryableError{err: fmt.Errorf("overpass: status %d", resp.StatusCode), after: after}
+	default:
+		return nil, fmt.Errorf("overpass: status %d", resp.StatusCode)
+	}
+}
+
+// decode разбирает ответ и проверяет ошибку выполнения в remark
+func decode(body []byte) (*Response, error) {
+	var resp Response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("overpass: decode response: %w", err)
+	}
+	if strings.Contains(resp.Remark, "runtime error") {
+		return nil, fmt.Errorf("%w: %s", ErrRemark, resp.Remark)
+	}
+	return &resp, nil
+}
+
+// cacheKey - ключ кэша по тексту запроса и области
+func cacheKey(query, bbox string) string {
+	sum := sha256.Sum256([]byte(bbox + "\n" + query))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,230 @@
+package overpass
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+const testBody = `{"elements":[{"type":"node","id":1,"lat":69,"lon":33}]}`
+
+var testBBox = models.BBox{South: 68, West: 30, North: 72, East: 60}
+
+// testServer отвечает testBody после release; requests считает запросы
+func testServer(t *testing.T, release <-chan struct{}, status *atomic.Int32) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if release != nil {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if status != nil && status.Load() != 0 {
+			w.WriteHeader(int(status.Load()))
+			return
+		}
+		w.Write([]byte(testBody))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func testClient(endpoint, dir string) *Client {
+	cfg := DefaultConfig()
+	cfg.Endpoint = endpoint
+	cfg.Retries = 0
+	cfg.MinInterval = 0
+	cfg.CacheDir = dir
+	return NewClient(cfg)
+}
+
+func TestQuerySharesIdenticalRequests(t *testing.T) {
+	release := make(chan struct{})
+	srv, requests := testServer(t, release, nil)
+	c := testClient(srv.URL, t.TempDir())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.Query(context.Background(), "node({{bbox}});out;", testBBox)
+			if err == nil && len(resp.Elements) != 1 {
+				err = errors.New("unexpected response")
+			}
+			errs <- err
+		}()
+	}
+	for requests.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d requests to the server, want 1", n)
+	}
+}
+
+func TestQueryWaitHonoursContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	srv, requests := testServer(t, release, nil)
+	c := testClient(srv.URL, "")
+
+	// Первый запрос занимает сервер, запрос с другим текстом ждет очереди
+	go c.Query(context.Background(), "way({{bbox}});out;", testBBox)
+	for requests.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"joined flight", "way({{bbox}});out;"},
+		{"queued for the server", "node({{bbox}});out;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			_, err := c.Query(ctx, tt.query, testBBox)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("err = %v, want deadline exceeded", err)
+			}
+			if waited := time.Since(start); waited > time.Second {
+				t.Errorf("returned after %v", waited)
+			}
+		})
+	}
+}
+
+func TestQueryCache(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		age      time.Duration
+		status   int32 // Ответ сервера; 0 - testBody
+		requests int32
+		wantErr  bool
+	}{
+		{name: "fresh entry", content: testBody, requests: 0},
+		{name: "corrupt fresh entry is refetched", content: `{"elements":[`, requests: 1},
+		{name: "stale entry is refetched", content: testBody, age: 48 * time.Hour, requests: 1},
+		{name: "stale entry when the server fails", content: testBody, age: 48 * time.Hour, status: http.StatusBadGateway, requests: 1},
+		{name: "corrupt stale entry when the server fails", content: `{`, age: 48 * time.Hour, status: http.StatusBadGateway, requests: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var status atomic.Int32
+			status.Store(tt.status)
+			srv, requests := testServer(t, nil, &status)
+			dir := t.TempDir()
+			c := testClient(srv.URL, dir)
+
+			query := "node({{bbox}});out;"
+			path := filepath.Join(dir, cacheKey("node("+testBBox.String()+");out;", testBBox.String())+".json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			mod := time.Now().Add(-tt.age)
+			os.Chtimes(path, mod, mod)
+
+			resp, err := c.Query(context.Background(), query, testBBox)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+			} else if err != nil || len(resp.Elements) != 1 {
+				t.Fatalf("resp %+v, err %v", resp, err)
+			}
+			if n := requests.Load(); n != tt.requests {
+				t.Errorf("%d requests to the server, want %d", n, tt.requests)
+			}
+			if tt.status == 0 {
+				body, err := os.ReadFile(path)
+				if err != nil || string(body) != testBody {
+					t.Errorf("cache holds %q (%v), want the server response", body, err)
+				}
+			}
+		})
+	}
+}
+
+func TestCachePrune(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		maxAge  time.Duration
+		maxSize int64
+		files   map[string]time.Duration // Имя -> возраст; размер каждого 100 байт
+		keep    []string
+	}{
+		{
+			name:   "older than max age",
+			maxAge: 24 * time.Hour,
+			files:  map[string]time.Duration{"a.json": 48 * time.Hour, "b.json": time.Hour},
+			keep:   []string{"b.json"},
+		},
+		{
+			name:    "oldest beyond max size",
+			maxSize: 250,
+			files:   map[string]time.Duration{"a.json": 3 * time.Hour, "b.json": 2 * time.Hour, "c.json": time.Hour},
+			keep:    []string{"b.json", "c.json"},
+		},
+		{
+			name:  "abandoned temporary files",
+			files: map[string]time.Duration{"a.json": 48 * time.Hour, "a.1.tmp": 2 * time.Hour, "b.2.tmp": time.Minute},
+			keep:  []string{"a.json", "b.2.tmp"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &cache{dir: t.TempDir(), ttl: time.Hour, maxAge: tt.maxAge, maxSize: tt.maxSize}
+			for name, age := range tt.files {
+				path := filepath.Join(c.dir, name)
+				if err := os.WriteFile(path, make([]byte, 100), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				os.Chtimes(path, now.Add(-age), now.Add(-age))
+			}
+			if err := c.prune(); err != nil {
+				t.Fatal(err)
+			}
+			entries, _ := os.ReadDir(c.dir)
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Name())
+			}
+			if len(got) != len(tt.keep) {
+				t.Fatalf("kept %v, want %v", got, tt.keep)
+			}
+			for i := range got {
+				if got[i] != tt.keep[i] {
+					t.Errorf("kept %v, want %v", got, tt.keep)
+				}
+			}
+		})
+	}
+}
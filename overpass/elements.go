@@ -0,0 +1,212 @@
+package overpass
+
+import (
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+// ==============================
+// ЭЛЕМЕНТЫ OSM
+// ==============================
+
+// Response - ответ Overpass API в формате JSON ([out:json])
+type Response struct {
+	Version   float64 `json:"version"`
+	Generator string  `json:"generator"`
+	OSM3S     struct {
+		TimestampOSMBase string `json:"timestamp_osm_base"`
+	} `json:"osm3s"`
+	Elements []Element `json:"elements"`
+	Remark   string    `json:"remark,omitempty"`
+}
+
+// Element - узел, линия или отношение OSM.
+// Center заполняется при out center, Geometry и геометрия участников - при out geom.
+type Element struct {
+	Type     string            `json:"type"` // node, way, relation
+	ID       int64             `json:"id"`
+	Lat      float64           `json:"lat"`
+	Lon      float64           `json:"lon"`
+	Center   *LatLon           `json:"center,omitempty"`
+	Bounds   *Bounds           `json:"bounds,omitempty"`
+	Nodes    []int64           `json:"nodes,omitempty"`
+	Geometry []LatLon          `json:"geometry,omitempty"`
+	Members  []Member          `json:"members,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"`
+}
+
+// Member - участник отношения
+type Member struct {
+	Type     string   `json:"type"`
+	Ref      int64    `json:"ref"`
+	Role     string   `json:"role"`
+	Lat      float64  `json:"lat,omitempty"`
+	Lon      float64  `json:"lon,omitempty"`
+	Geometry []LatLon `json:"geometry,omitempty"`
+}
+
+// LatLon - точка геометрии
+type LatLon struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// Bounds - границы элемента
+type Bounds struct {
+	MinLat float64 `json:"minlat"`
+	MinLon float64 `json:"minlon"`
+	MaxLat float64 `json:"maxlat"`
+	MaxLon float64 `json:"maxlon"`
+}
+
+// Position возвращает координаты элемента: точку узла или центр линии и отношения
+// (из out center или, если его нет, середину границ)
+func (e Element) Position() (lat, lon float64, ok bool) {
+	switch {
+	case e.Type == "node":
+		return e.Lat, e.Lon, true
+	case e.Center != nil:
+		return e.Center.Lat, e.Center.Lon, true
+	case e.Bounds != nil:
+		return (e.Bounds.MinLat + e.Bounds.MaxLat) / 2, (e.Bounds.MinLon + e.Bounds.MaxLon) / 2, true
+	default:
+		return 0, 0, false
+	}
+}
+
+// LineString переводит геометрию в линию orb ([lon, lat])
+func LineString(geometry []LatLon) orb.LineString {
+	ls := make(orb.LineString, 0, len(geometry))
+	for _, p := range geometry {
+		ls = append(ls, orb.Point{p.Lon, p.Lat})
+	}
+	return ls
+}
+
+// Polygons собирает площадную геометрию линии или отношения-мультиполигона (out geom).
+// Для узлов и элементов без геометрии возвращает пустой мультиполигон.
+func (e Element) Polygons() orb.MultiPolygon {
+	switch e.Type {
+	case "way":
+		rings := AssembleRings([]orb.LineString{LineString(e.Geometry)})
+		if len(rings) == 0 {
+			return nil
+		}
+		return orb.MultiPolygon{orientPolygon(orb.Polygon{rings[0]})}
+	case "relation":
+		return assembleMultipolygon(e.Members)
+	default:
+		return nil
+	}
+}
+
+// assembleMultipolygon собирает мультиполигон отношения из линий-участников:
+// внешние контуры становятся полигонами, внутренние попадают в содержащий их внешний
+func assembleMultipolygon(members []Member) orb.MultiPolygon {
+	var outerWays, innerWays []orb.LineString
+	for _, m := range members {
+		if m.Type != "way" || len(m.Geometry) < 2 {
+			continue
+		}
+		if m.Role == "inner" {
+			innerWays = append(innerWays, LineString(m.Geometry))
+		} else {
+			outerWays = append(outerWays, LineString(m.Geometry))
+		}
+	}
+
+	var mp orb.MultiPolygon
+	for _, outer := range AssembleRings(outerWays) {
+		mp = append(mp, orb.Polygon{outer})
+	}
+	for _, inner := range AssembleRings(innerWays) {
+		for i := range mp {
+			if planar.RingContains(mp[i][0], inner[0]) {
+				mp[i] = append(mp[i], inner)
+				break
+			}
+		}
+	}
+	for i := range mp {
+		mp[i] = orientPolygon(mp[i])
+	}
+	return mp
+}
+
+// Stitch сшивает линии по совпадающим концам. Возвращает получившиеся линии:
+// замкнутые (первая точка равна последней) и оставшиеся открытыми.
+func Stitch(ways []orb.LineString) []orb.LineString {
+	open := make([]orb.LineString, 0, len(ways))
+	for _, w := range ways {
+		if len(w) >= 2 {
+			open = append(open, w)
+		}
+	}
+
+	var result []orb.LineString
+	for len(open) > 0 {
+		current := append(orb.LineString(nil), open[0]...)
+		open = open[1:]
+
+		for !current[0].Equal(current[len(current)-1]) {
+			joined := false
+			for i, w := range open {
+				first, last := current[0], current[len(current)-1]
+				switch {
+				case last.Equal(w[0]):
+					current = append(current, w[1:]...)
+				case last.Equal(w[len(w)-1]):
+					current = append(current, reversed(w)[1:]...)
+				case first.Equal(w[len(w)-1]):
+					current = append(append(orb.LineString(nil), w...), current[1:]...)
+				case first.Equal(w[0]):
+					current = append(reversed(w), current[1:]...)
+				default:
+					continue
+				}
+				open = append(open[:i], open[i+1:]...)
+				joined = true
+				break
+			}
+			if !joined {
+				break
+			}
+		}
+		result = append(result, current)
+	}
+	return result
+}
+
+// AssembleRings сшивает линии в замкнутые кольца.
+// Кольцо, которое не удалось замкнуть (неполные данные), замыкается прямым отрезком.
+func AssembleRings(ways []orb.LineString) []orb.Ring {
+	var rings []orb.Ring
+	for _, ls := range Stitch(ways) {
+		if !ls[0].Equal(ls[len(ls)-1]) {
+			ls = append(ls, ls[0])
+		}
+		if len(ls) >= 4 {
+			rings = append(rings, orb.Ring(ls))
+		}
+	}
+	return rings
+}
+
+// reversed возвращает линию в обратном порядке
+func reversed(ls orb.LineString) orb.LineString {
+	r := make(orb.LineString, len(ls))
+	for i, p := range ls {
+		r[len(ls)-1-i] = p
+	}
+	return r
+}
+
+// orientPolygon задает обход по RFC 7946: внешний контур против часовой стрелки, внутренние - по ней
+func orientPolygon(p orb.Polygon) orb.Polygon {
+	for i, ring := range p {
+		if (i == 0) != (ring.Orientation() == orb.CCW) {
+			ring.Reverse()
+		}
+	}
+	return p
+}
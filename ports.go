@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/s3nkyh/arcticeroute/models"
+	"github.com/s3nkyh/arcticeroute/service"
+)
+
+// loadPorts открывает реестр портов; пустой реестр заполняется из PORTS_FILE
+// (CSV или GeoJSON) или встроенным списком портов западной Арктики
+func loadPorts() (*service.PortRegistry, error) {
+	registry, err := service.NewPortRegistry(filepath.Join(dataDir(), "ports.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(registry.List()) > 0 {
+		return registry, nil
+	}
+
+	ports := service.DefaultPorts()
+	if path := os.Getenv("PORTS_FILE"); path != "" {
+		if ports, err = service.ReadPorts(path); err != nil {
+			return nil, err
+		}
+	}
+	n, err := registry.Import(ports)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Port registry seeded with %d ports", n)
+	return registry, nil
+}
+
+// portError переводит ошибку реестра портов в ответ
+func portError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrPortNotFound):
+		c.JSON(404, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidPort):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
+
+// queryLimit читает необязательный параметр limit
+func queryLimit(c *gin.Context) (int, bool) {
+	v := c.Query("limit")
+	if v == "" {
+		return 0, true
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit < 0 {
+		c.JSON(400, gin.H{"error": "invalid limit"})
+		return 0, false
+	}
+	return limit, true
+}
+
+// getPorts возвращает все порты или ищет их по названию, UN/LOCODE и ID (?q=)
+func getPorts(c *gin.Context) {
+	limit, ok := queryLimit(c)
+	if !ok {
+		return
+	}
+	if q := c.Query("q"); q != "" {
+		c.JSON(200, portRegistry.Search(q, limit))
+		return
+	}
+	ports := portRegistry.List()
+	if limit > 0 && len(ports) > limit {
+		ports = ports[:limit]
+	}
+	c.JSON(200, ports)
+}
+
+// getNearestPorts возвращает ближайшие к точке (?lat=&lon= или ?place=) порты, принимающие судно с осадкой draught
+func getNearestPorts(c *gin.Context) {
+	position, _, ok := queryPosition(c)
+	if !ok {
+		return
+	}
+	draught, _, err := queryFloat(c, "draught")
+	if err != nil || draught < 0 {
+		c.JSON(400, gin.H{"error": "invalid draught"})
+		return
+	}
+	limit, ok := queryLimit(c)
+	if !ok {
+		return
+	}
+	if limit == 0 {
+		limit = 5
+	}
+	c.JSON(200, portRegistry.Nearest(position, draught, limit))
+}
+
+func getPort(c *gin.Context) {
+	p, found := portRegistry.Get(c.Param("id"))
+	if !found {
+		c.JSON(404, gin.H{"error": "port not found"})
+		return
+	}
+	c.JSON(200, p)
+}
+
+func createPort(c *gin.Context) {
+	var p models.Port
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(400, gin.H{"error": "invalid port: " + err.Error()})
+		return
+	}
+	created, err := portRegistry.Create(p)
+	if err != nil {
+		portError(c, err)
+		return
+	}
+	c.JSON(201, created)
+}
+
+func updatePort(c *gin.Context) {
+	var p models.Port
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(400, gin.H{"error": "invalid port: " + err.Error()})
+		return
+	}
+	updated, err := portRegistry.Update(c.Param("id"), p)
+	if err != nil {
+		portError(c, err)
+		return
+	}
+	c.JSON(200, updated)
+}
+
+func deletePort(c *gin.Context) {
+	if err := portRegistry.Delete(c.Param("id")); err != nil {
+		portError(c, err)
+		return
+	}
+	c.Status(204)
+}
+
+// importPorts добавляет или заменяет порты из тела запроса: CSV (text/csv или ?format=csv)
+// или GeoJSON FeatureCollection
+func importPorts(c *gin.Context) {
+	format := c.Query("format")
+	if format == "" {
+		format = "geojson"
+		if strings.Contains(c.ContentType(), "csv") {
+			format = "csv"
+		}
+	}
+
+	var ports []models.Port
+	var err error
+	switch format {
+	case "csv":
+		ports, err = service.ParsePortsCSV(c.Request.Body)
+	case "geojson":
+		ports, err = service.ParsePortsGeoJSON(c.Request.Body)
+	default:
+		c.JSON(400, gin.H{"error": "format must be csv or geojson"})
+		return
+	}
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	n, err := portRegistry.Import(ports)
+	if err != nil {
+		portError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"imported": n, "total": len(portRegistry.List())})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/s3nkyh/arcticeroute/service"
+)
+
+// routeAssignment - тело запроса назначения маршрута
+type routeAssignment struct {
+	Route        service.Route `json:"route"`
+	Corridor     float64       `json:"corridor"`      // Коридор, м; 0 - по умолчанию
+	PlannedSpeed float64       `json:"planned_speed"` // Плановая скорость, узлы
+}
+
+// paramMMSI читает MMSI из пути запроса
+func paramMMSI(c *gin.Context) (int32, bool) {
+	mmsi, err := strconv.ParseInt(c.Param("mmsi"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "invalid mmsi"})
+		return 0, false
+	}
+	return int32(mmsi), true
+}
+
+func getShipRoute(c *gin.Context) {
+	mmsi, ok := paramMMSI(c)
+	if !ok {
+		return
+	}
+	plan, progress, found := routeMonitor.Plan(mmsi)
+	if !found {
+		c.JSON(404, gin.H{"error": "no route assigned"})
+		return
+	}
+	c.JSON(200, gin.H{"plan": plan, "progress": progress})
+}
+
+func assignShipRoute(c *gin.Context) {
+	mmsi, ok := paramMMSI(c)
+	if !ok {
+		return
+	}
+	var req routeAssignment
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "invalid route: " + err.Error()})
+		return
+	}
+	if req.Corridor < 0 || req.PlannedSpeed < 0 {
+		c.JSON(400, gin.H{"error": "corridor and planned_speed must not be negative"})
+		return
+	}
+
+	if _, err := routeMonitor.Assign(mmsi, req.Route, req.Corridor, req.PlannedSpeed); err != nil {
+		if errors.Is(err, service.ErrInvalidRoute) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	getShipRoute(c)
+}
+
+func unassignShipRoute(c *gin.Context) {
+	mmsi, ok := paramMMSI(c)
+	if !ok {
+		return
+	}
+	removed, err := routeMonitor.Unassign(mmsi)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if !removed {
+		c.JSON(404, gin.H{"error": "no route assigned"})
+		return
+	}
+	c.Status(204)
+}
+
+func getRouteProgress(c *gin.Context) {
+	c.JSON(200, routeMonitor.Progress())
+}
+
+func getRouteDeviations(c *gin.Context) {
+	var mmsi int64
+	if v := c.Query("mmsi"); v != "" {
+		var err error
+		if mmsi, err = strconv.ParseInt(v, 10, 32); err != nil {
+			c.JSON(400, gin.H{"error": "invalid mmsi"})
+			return
+		}
+	}
+	c.JSON(200, routeMonitor.Deviations(int32(mmsi)))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/s3nkyh/arcticeroute/service"
+)
+
+// getSARCandidates возвращает суда, которые быстрее всех подойдут к месту бедствия (?lat=&lon= или ?place=).
+// Время задается at (по умолчанию текущее); exclude - MMSI терпящего бедствие судна.
+func getSARCandidates(c *gin.Context) {
+	at, ok := estimateTime(c)
+	if !ok {
+		return
+	}
+	position, place, ok := queryPosition(c)
+	if !ok {
+		return
+	}
+
+	var exclude int32
+	if v := c.Query("exclude"); v != "" {
+		mmsi, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "invalid exclude"})
+			return
+		}
+		exclude = int32(mmsi)
+	}
+	limit := 20
+	if v := c.Query("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			c.JSON(400, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = parsed
+	}
+
+	result, err := sarAssist.Candidates(position, at, exclude, limit)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidDiversion) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	result.Place = place
+	c.JSON(200, result)
+}
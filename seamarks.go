@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/paulmach/orb/geojson"
+	"github.com/s3nkyh/arcticeroute/api"
+	"github.com/s3nkyh/arcticeroute/models"
+	"github.com/s3nkyh/arcticeroute/service"
+)
+
+// refreshFairway загружает буи фарватеров для привязки подходов к портам и обновляет их раз в сутки
+func refreshFairway() {
+	for {
+		if err := updateFairway(); err != nil {
+			log.Println("Fairway marks error:", err)
+			if errors.Is(err, api.ErrNoOSMSource) || errors.Is(err, models.ErrInvalidBBox) {
+				return
+			}
+			time.Sleep(time.Hour)
+			continue
+		}
+		time.Sleep(24 * time.Hour)
+	}
+}
+
+// updateFairway заменяет буи фарватеров.
+// Область задается SEAMARK_BBOX (south,west,north,east), по умолчанию область морских путей.
+func updateFairway() error {
+	bbox, err := envBBox("SEAMARK_BBOX", service.SeawayBBox)
+	if err != nil {
+		return err
+	}
+	seamarks, err := api.GetSeamarks(context.Background(), osmSource, bbox)
+	if err != nil {
+		return err
+	}
+	fairway.SetMarks(service.FairwayMarks(seamarks))
+	log.Printf("Fairway marks loaded: %d of %d seamarks", fairway.Marks(), len(seamarks))
+	return nil
+}
+
+// getSeamarks возвращает навигационные знаки в области bbox как GeoJSON;
+// type - список типов seamark через запятую (buoy_lateral,light_major,...)
+func getSeamarks(c *gin.Context) {
+	bbox, ok := queryOverpassBBox(c, &service.SeawayBBox)
+	if !ok {
+		return
+	}
+	seamarks, err := api.GetSeamarks(c.Request.Context(), osmSource, *bbox)
+	if err != nil {
+		osmError(c, err)
+		return
+	}
+
+	types := make(map[string]bool)
+	for _, t := range strings.Split(c.Query("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types[t] = true
+		}
+	}
+	fc := geojson.NewFeatureCollection()
+	for _, s := range seamarks {
+		if len(types) == 0 || types[s.Type] {
+			fc.Append(s.Feature())
+		}
+	}
+	c.JSON(200, fc)
+}
+
+// getHarbours возвращает гавани и порты OSM в области bbox как GeoJSON
+func getHarbours(c *gin.Context) {
+	bbox, ok := queryOverpassBBox(c, &service.SeawayBBox)
+	if !ok {
+		return
+	}
+	harbours, err := api.GetHarbours(c.Request.Context(), osmSource, *bbox)
+	if err != nil {
+		osmError(c, err)
+		return
+	}
+
+	fc := geojson.NewFeatureCollection()
+	for _, h := range harbours {
+		fc.Append(h.Feature())
+	}
+	c.JSON(200, fc)
+}
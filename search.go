@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/s3nkyh/arcticeroute/api"
+	"github.com/s3nkyh/arcticeroute/models"
+	"github.com/s3nkyh/arcticeroute/service"
+)
+
+// loadGazetteer загружает справочник географических названий из GAZETTEER_FILE
+// или встроенный справочник Арктики
+func loadGazetteer() ([]models.Place, error) {
+	if path := os.Getenv("GAZETTEER_FILE"); path != "" {
+		return service.ReadGazetteer(path)
+	}
+	return service.DefaultGazetteer(), nil
+}
+
+// refreshPlaces загружает названия мест OSM для поиска и обновляет их раз в сутки
+func refreshPlaces() {
+	for {
+		if err := updatePlaces(); err != nil {
+			log.Println("OSM places error:", err)
+			if errors.Is(err, api.ErrNoOSMSource) || errors.Is(err, models.ErrInvalidBBox) {
+				return
+			}
+			time.Sleep(time.Hour)
+			continue
+		}
+		time.Sleep(24 * time.Hour)
+	}
+}
+
+// updatePlaces заменяет места OSM в геокодере.
+// Область задается PLACES_BBOX (south,west,north,east), по умолчанию область морских путей.
+func updatePlaces() error {
+	bbox, err := envBBox("PLACES_BBOX", service.SeawayBBox)
+	if err != nil {
+		return err
+	}
+	places, err := api.GetPlaces(context.Background(), osmSource, bbox)
+	if err != nil {
+		return err
+	}
+	geocoder.SetPlaces(service.PlaceSourceOSM, places)
+	log.Printf("OSM places loaded: %d", len(places))
+	return nil
+}
+
+// queryPosition читает позицию из параметров lat и lon или из названия места place
+// (порт, мыс, пролив, поселок). Найденное место возвращается и указывается в заголовке
+// X-Resolved-Place; неоднозначное название дает 409 с кандидатами.
+// При ошибке отвечает клиенту и возвращает false.
+func queryPosition(c *gin.Context) (models.Point, *service.PlaceMatch, bool) {
+	if name := c.Query("place"); name != "" {
+		place, err := geocoder.Resolve(name)
+		var ambiguous *service.AmbiguousPlaceError
+		switch {
+		case errors.As(err, &ambiguous):
+			c.JSON(409, gin.H{"error": err.Error(), "candidates": ambiguous.Candidates})
+			return models.Point{}, nil, false
+		case err != nil:
+			c.JSON(404, gin.H{"error": err.Error()})
+			return models.Point{}, nil, false
+		}
+		c.Header("X-Resolved-Place", place.ID)
+		return place.Point(), &place, true
+	}
+	lat, hasLat, errLat := queryFloat(c, "lat")
+	lon, hasLon, errLon := queryFloat(c, "lon")
+	if !hasLat || !hasLon || errLat != nil || errLon != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		c.JSON(400, gin.H{"error": "lat and lon or place are required"})
+		return models.Point{}, nil, false
+	}
+	return models.Point{Lat: lat, Lon: lon}, nil, true
+}
+
+// getSearch ищет места по названию (?q=) среди портов, справочника и мест OSM,
+// с транслитерацией и опечатками
+func getSearch(c *gin.Context) {
+	q := c.Query("q")
+	if q == "" {
+		c.JSON(400, gin.H{"error": "q is required"})
+		return
+	}
+	limit, ok := queryLimit(c)
+	if !ok {
+		return
+	}
+	if limit == 0 {
+		limit = 10
+	}
+	c.JSON(200, geocoder.Search(q, limit))
+}
@@ -0,0 +1,389 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// ==============================
+// АНОМАЛИИ И ПОДМЕНА AIS
+// ==============================
+
+// Типы аномалий
+const (
+	AnomalySpeed     = "implausible_speed" // Невозможная скорость между позициями
+	AnomalyJump      = "position_jump"     // Скачок позиции
+	AnomalyGap       = "ais_gap"           // Долгое отсутствие сигнала ("темный" период)
+	AnomalyCollision = "mmsi_collision"    // Два передатчика с одним MMSI
+	AnomalyOnLand    = "on_land"           // Позиция на суше
+)
+
+// Уровни серьезности
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Anomaly - обнаруженная аномалия трека
+type Anomaly struct {
+	ID         int64     `json:"id"`                 // Порядковый номер
+	MMSI       int32     `json:"mmsi"`               // Судно
+	Name       string    `json:"name"`               // Название судна
+	Type       string    `json:"type"`               // Тип аномалии
+	Severity   string    `json:"severity"`           // info, warning, critical
+	Message    string    `json:"message"`            // Описание
+	Latitude   float64   `json:"latitude"`           // Позиция, на которой сработал детектор
+	Longitude  float64   `json:"longitude"`          //
+	Speed      float64   `json:"speed,omitempty"`    // Расчетная скорость, узлы
+	Distance   float64   `json:"distance,omitempty"` // Расстояние между позициями, м
+	Gap        float64   `json:"gap,omitempty"`      // Длительность пропуска, с
+	DetectedAt time.Time `json:"detected_at"`        // Время позиции
+}
+
+// AnomalyConfig - пороги детектора
+type AnomalyConfig struct {
+	MaxSpeed        float64       // Предельная правдоподобная скорость, узлы
+	JumpDistance    float64       // Скачок дальше этого - jump, м
+	MinInterval     time.Duration // Между более близкими по времени позициями скорость не считается
+	GapWarning      time.Duration // Пропуск сигнала - предупреждение
+	GapCritical     time.Duration // Пропуск сигнала - критично
+	CollisionWindow int           // Сколько последних позиций проверять на второй передатчик
+	Cooldown        time.Duration // Повтор аномалии того же типа по судну не чаще
+	MaxStored       int           // Сколько аномалий хранить
+	SweepInterval   time.Duration // Как часто искать замолчавшие суда и сохранять аномалии
+}
+
+// DefaultAnomalyConfig - пороги по умолчанию
+func DefaultAnomalyConfig() AnomalyConfig {
+	return AnomalyConfig{
+		MaxSpeed:        50,
+		JumpDistance:    20000,
+		MinInterval:     2 * time.Second,
+		GapWarning:      2 * time.Hour,
+		GapCritical:     12 * time.Hour,
+		CollisionWindow: 10,
+		Cooldown:        10 * time.Minute,
+		MaxStored:       2000,
+		SweepInterval:   5 * time.Minute,
+	}
+}
+
+// AnomalyDetector - проверка каждой новой позиции по истории трека
+type AnomalyDetector struct {
+	store *ShipStore
+	land  *LandDetector
+	hub   *LiveHub
+	cfg   AnomalyConfig
+	geo   *GeoUtils
+	path  string
+
+	mu        sync.RWMutex
+	anomalies []Anomaly
+	nextID    int64
+	lastSeen  map[string]time.Time // "<mmsi>/<тип>" -> время последней аномалии
+	silent    map[int32]string     // Замолчавшие суда -> уровень уже поднятой тревоги
+	dirty     bool
+}
+
+// NewAnomalyDetector загружает сохраненные аномалии из path, подписывает детектор
+// на обновления позиций и запускает периодический поиск замолчавших судов.
+// land может быть nil - тогда проверка на сушу не выполняется.
+func NewAnomalyDetector(store *ShipStore, land *LandDetector, hub *LiveHub, path string, cfg AnomalyConfig) (*AnomalyDetector, error) {
+	var anomalies []Anomaly
+	if err := loadJSON(path, &anomalies); err != nil {
+		return nil, fmt.Errorf("load anomalies: %w", err)
+	}
+
+	d := &AnomalyDetector{
+		store:     store,
+		land:      land,
+		hub:       hub,
+		cfg:       cfg,
+		geo:       &GeoUtils{},
+		path:      path,
+		anomalies: anomalies,
+		lastSeen:  make(map[string]time.Time),
+		silent:    make(map[int32]string),
+	}
+	for _, a := range anomalies {
+		d.nextID = max(d.nextID, a.ID)
+	}
+	store.OnUpdate(d.Check)
+	if cfg.SweepInterval > 0 {
+		go d.sweepLoop()
+	}
+	return d, nil
+}
+
+// Check проверяет новую позицию судна относительно его трека
+func (d *AnomalyDetector) Check(ship models.Ship) {
+	d.mu.Lock()
+	_, wasSilent := d.silent[ship.MMSI]
+	delete(d.silent, ship.MMSI)
+	d.mu.Unlock()
+
+	position := models.Point{Lat: ship.Latitude, Lon: ship.Longitude}
+
+	if d.land != nil && d.land.IsLand(position) {
+		d.report(ship, Anomaly{
+			Type:     AnomalyOnLand,
+			Severity: SeverityWarning,
+			Message:  "Позиция судна на суше",
+		})
+	}
+
+	if ship.HasMotion() && ship.SOG > d.cfg.MaxSpeed {
+		d.report(ship, Anomaly{
+			Type:     AnomalySpeed,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("Переданная скорость %.1f уз невозможна", ship.SOG),
+			Speed:    ship.SOG,
+		})
+	}
+
+	track := d.store.Track(ship.MMSI)
+	if len(track) < 2 {
+		return
+	}
+	prev := track[len(track)-2]
+
+	interval := ship.Timestamp.Sub(prev.Timestamp)
+	if interval >= d.cfg.GapWarning {
+		severity := SeverityWarning
+		if interval >= d.cfg.GapCritical {
+			severity = SeverityCritical
+		}
+		message := fmt.Sprintf("Нет сигнала AIS %s", interval.Round(time.Minute))
+		if wasSilent {
+			message = fmt.Sprintf("Сигнал AIS восстановлен после %s", interval.Round(time.Minute))
+		}
+		d.report(ship, Anomaly{
+			Type:     AnomalyGap,
+			Severity: severity,
+			Message:  message,
+			Gap:      interval.Seconds(),
+		})
+	}
+
+	if d.plausible(prev, ship) {
+		return
+	}
+
+	// Новая позиция продолжает более ранний трек, а предыдущая - другой:
+	// позиции чередуются, значит MMSI используют два передатчика
+	last := len(track) - 1
+	if d.matchesEarlier(track, last, last-2) && d.matchesEarlier(track, last-1, last-2) {
+		d.report(ship, Anomaly{
+			Type:     AnomalyCollision,
+			Severity: SeverityCritical,
+			Message:  "Позиции чередуются между двумя треками: MMSI используется двумя судами",
+			Distance: d.distance(prev, ship),
+		})
+		return
+	}
+
+	distance := d.distance(prev, ship)
+	speed := d.impliedSpeed(prev, ship)
+	if distance >= d.cfg.JumpDistance {
+		d.report(ship, Anomaly{
+			Type:     AnomalyJump,
+			Severity: SeverityCritical,
+			Message:  fmt.Sprintf("Скачок позиции на %.1f км", distance/1000),
+			Speed:    speed,
+			Distance: distance,
+		})
+		return
+	}
+
+	d.report(ship, Anomaly{
+		Type:     AnomalySpeed,
+		Severity: SeverityWarning,
+		Message:  fmt.Sprintf("Скорость между позициями %.1f уз", speed),
+		Speed:    speed,
+		Distance: distance,
+	})
+}
+
+// matchesEarlier сообщает, согласуется ли track[i] с одной из позиций
+// track[from], track[from-1], ... в пределах CollisionWindow
+func (d *AnomalyDetector) matchesEarlier(track []models.Ship, i, from int) bool {
+	for j := from; j >= 0 && j >= i-1-d.cfg.CollisionWindow; j-- {
+		if d.plausible(track[j], track[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// plausible сообщает, могло ли одно судно пройти от a до b за прошедшее время
+func (d *AnomalyDetector) plausible(a, b models.Ship) bool {
+	if b.Timestamp.Sub(a.Timestamp) < d.cfg.MinInterval {
+		// Слишком близко по времени для оценки скорости - сравниваем с расстоянием
+		return d.distance(a, b) < d.cfg.MaxSpeed*knotsToMS*d.cfg.MinInterval.Seconds()
+	}
+	return d.impliedSpeed(a, b) <= d.cfg.MaxSpeed
+}
+
+// distance - расстояние между позициями, м
+func (d *AnomalyDetector) distance(a, b models.Ship) float64 {
+	return d.geo.Distance(
+		models.Point{Lat: a.Latitude, Lon: a.Longitude},
+		models.Point{Lat: b.Latitude, Lon: b.Longitude},
+	)
+}
+
+// impliedSpeed - скорость, нужная для перехода от a к b, узлы
+func (d *AnomalyDetector) impliedSpeed(a, b models.Ship) float64 {
+	seconds := b.Timestamp.Sub(a.Timestamp).Seconds()
+	if seconds < 1 {
+		seconds = 1
+	}
+	return d.distance(a, b) / seconds / knotsToMS
+}
+
+// Sweep ищет суда, не передававшие позицию дольше GapWarning, и поднимает
+// тревогу ais_gap, не дожидаясь следующего сообщения. По одному молчанию
+// тревога поднимается один раз на каждый уровень серьезности.
+func (d *AnomalyDetector) Sweep() []Anomaly {
+	now := d.store.Now()
+	var raised []Anomaly
+	for _, ship := range d.store.All() {
+		silence := now.Sub(ship.Timestamp)
+		if silence < d.cfg.GapWarning {
+			continue
+		}
+		severity := SeverityWarning
+		if silence >= d.cfg.GapCritical {
+			severity = SeverityCritical
+		}
+
+		d.mu.Lock()
+		reported, ok := d.silent[ship.MMSI]
+		if ok && severityRank(reported) >= severityRank(severity) {
+			d.mu.Unlock()
+			continue
+		}
+		d.silent[ship.MMSI] = severity
+		d.mu.Unlock()
+
+		anomaly, ok := d.report(ship, Anomaly{
+			Type:       AnomalyGap,
+			Severity:   severity,
+			Message:    fmt.Sprintf("Нет сигнала AIS %s", silence.Round(time.Minute)),
+			Gap:        silence.Seconds(),
+			DetectedAt: now,
+		})
+		if ok {
+			raised = append(raised, anomaly)
+		}
+	}
+	return raised
+}
+
+// sweepLoop периодически ищет замолчавшие суда и сохраняет аномалии
+func (d *AnomalyDetector) sweepLoop() {
+	ticker := time.NewTicker(d.cfg.SweepInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		d.Sweep()
+		if err := d.Flush(); err != nil {
+			log.Println("Anomalies save error:", err)
+		}
+	}
+}
+
+// Flush сохраняет аномалии, если появились новые
+func (d *AnomalyDetector) Flush() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.dirty || d.path == "" {
+		return nil
+	}
+	if err := saveJSON(d.path, d.anomalies); err != nil {
+		return err
+	}
+	d.dirty = false
+	return nil
+}
+
+// report сохраняет аномалию, если такая же не возникала недавно.
+// Время обнаружения по умолчанию - время позиции судна.
+func (d *AnomalyDetector) report(ship models.Ship, anomaly Anomaly) (Anomaly, bool) {
+	key := fmt.Sprintf("%d/%s", ship.MMSI, anomaly.Type)
+
+	d.mu.Lock()
+	if last, ok := d.lastSeen[key]; ok && anomaly.Type != AnomalyGap &&
+		ship.Timestamp.Sub(last) < d.cfg.Cooldown {
+		d.mu.Unlock()
+		return Anomaly{}, false
+	}
+	d.lastSeen[key] = ship.Timestamp
+
+	d.nextID++
+	anomaly.ID = d.nextID
+	anomaly.MMSI = ship.MMSI
+	anomaly.Name = ship.Name
+	anomaly.Latitude = ship.Latitude
+	anomaly.Longitude = ship.Longitude
+	if anomaly.DetectedAt.IsZero() {
+		anomaly.DetectedAt = ship.Timestamp
+	}
+
+	d.anomalies = append(d.anomalies, anomaly)
+	if len(d.anomalies) > d.cfg.MaxStored {
+		d.anomalies = append(d.anomalies[:0:0], d.anomalies[len(d.anomalies)-d.cfg.MaxStored:]...)
+	}
+	d.dirty = true
+	d.mu.Unlock()
+
+	if d.hub != nil {
+		d.hub.Publish("anomaly", anomaly)
+	}
+	return anomaly, true
+}
+
+// AnomalyFilter - отбор аномалий; пустые поля не ограничивают
+type AnomalyFilter struct {
+	MMSI        int32
+	Type        string
+	MinSeverity string
+}
+
+// severityRank упорядочивает уровни серьезности
+func severityRank(severity string) int {
+	switch severity {
+	case SeverityCritical:
+		return 2
+	case SeverityWarning:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Anomalies возвращает сохраненные аномалии, новые первыми
+func (d *AnomalyDetector) Anomalies(filter AnomalyFilter) []Anomaly {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	result := make([]Anomaly, 0)
+	for i := len(d.anomalies) - 1; i >= 0; i-- {
+		a := d.anomalies[i]
+		if filter.MMSI != 0 && a.MMSI != filter.MMSI {
+			continue
+		}
+		if filter.Type != "" && a.Type != filter.Type {
+			continue
+		}
+		if filter.MinSeverity != "" && severityRank(a.Severity) < severityRank(filter.MinSeverity) {
+			continue
+		}
+		result = append(result, a)
+	}
+	return result
+}
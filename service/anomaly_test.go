@@ -0,0 +1,79 @@
+package service
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+func TestAnomalySweepFlagsSilentShips(t *testing.T) {
+	store := NewShipStore()
+	store.UseDataClock()
+	cfg := DefaultAnomalyConfig()
+	cfg.SweepInterval = 0
+	path := filepath.Join(t.TempDir(), "anomalies.json")
+	d, err := NewAnomalyDetector(store, nil, nil, path, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	silent := models.Ship{MMSI: 1, Name: "SILENT", Latitude: 69, Longitude: 33, Timestamp: start}
+	store.Update(silent)
+
+	// Часы хранилища двигает только второе судно
+	steps := []struct {
+		at       time.Duration
+		severity string // Пусто - тревоги нет
+	}{
+		{1 * time.Hour, ""},
+		{3 * time.Hour, SeverityWarning},
+		{4 * time.Hour, ""},
+		{13 * time.Hour, SeverityCritical},
+		{20 * time.Hour, ""},
+	}
+	for _, step := range steps {
+		at := start.Add(step.at)
+		store.Update(models.Ship{MMSI: 2, Latitude: 70, Longitude: 40, Timestamp: at})
+
+		raised := d.Sweep()
+		if step.severity == "" {
+			if len(raised) != 0 {
+				t.Errorf("after %v: unexpected anomalies %+v", step.at, raised)
+			}
+			continue
+		}
+		if len(raised) != 1 || raised[0].MMSI != 1 || raised[0].Type != AnomalyGap || raised[0].Severity != step.severity {
+			t.Fatalf("after %v: got %+v, want one %s ais_gap for MMSI 1", step.at, raised, step.severity)
+		}
+		if !raised[0].DetectedAt.Equal(at) || raised[0].Gap != step.at.Seconds() {
+			t.Errorf("after %v: detected at %v with gap %.0f s", step.at, raised[0].DetectedAt, raised[0].Gap)
+		}
+	}
+
+	// Судно снова в эфире: молчание закрывается, следующая тишина снова дает тревогу
+	silent.Timestamp = start.Add(21 * time.Hour)
+	store.Update(silent)
+	if got := d.Anomalies(AnomalyFilter{MMSI: 1}); len(got) != 3 || !strings.Contains(got[0].Message, "восстановлен") {
+		t.Fatalf("resume anomalies = %+v", got)
+	}
+
+	if err := d.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := NewAnomalyDetector(NewShipStore(), nil, nil, path, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, after := d.Anomalies(AnomalyFilter{}), reloaded.Anomalies(AnomalyFilter{})
+	if len(after) != len(before) || after[0].ID != before[0].ID {
+		t.Fatalf("reloaded %d anomalies, want %d", len(after), len(before))
+	}
+	reloaded.report(silent, Anomaly{Type: AnomalyOnLand, Severity: SeverityWarning})
+	if got := reloaded.Anomalies(AnomalyFilter{})[0].ID; got != before[0].ID+1 {
+		t.Errorf("new anomaly ID %d, want %d", got, before[0].ID+1)
+	}
+}
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+const (
+	earthRadius = 6371000.0       // Радиус Земли в метрах
+	metersPerNM = 1852.0          // Метров в морской миле
+	knotsToMS   = 1852.0 / 3600.0 // Узлы в м/с
+
+	// gridPadding - запас шага сетки: полярная проекция растягивает
+	// расстояния вдоль параллелей (до ~5% на 60° с.ш.)
+	gridPadding = 1.1
+)
+
+// ==============================
+// ОПАСНОЕ СБЛИЖЕНИЕ (CPA/TCPA)
+// ==============================
+
+// CollisionConfig - пороги контроля сближения
+type CollisionConfig struct {
+	Range        float64       // Радиус поиска пар, м
+	CPALimit     float64       // Тревога, если CPA меньше, м
+	TCPALimit    time.Duration // ... и сближение наступит не позже
+	MaxFixAge    time.Duration // Более старые позиции не учитываются
+	ScanInterval time.Duration // Минимальный интервал между проверками
+}
+
+// DefaultCollisionConfig - пороги по умолчанию для узких ледовых каналов
+func DefaultCollisionConfig() CollisionConfig {
+	return CollisionConfig{
+		Range:        12 * metersPerNM,
+		CPALimit:     0.5 * metersPerNM,
+		TCPALimit:    20 * time.Minute,
+		MaxFixAge:    10 * time.Minute,
+		ScanInterval: 10 * time.Second,
+	}
+}
+
+// CollisionAlert - предупреждение об опасном сближении пары судов
+type CollisionAlert struct {
+	ID        string    `json:"id"`         // "<mmsi1>-<mmsi2>", mmsi1 < mmsi2
+	MMSI1     int32     `json:"mmsi1"`      // Первое судно
+	MMSI2     int32     `json:"mmsi2"`      // Второе судно
+	Name1     string    `json:"name1"`      // Название первого судна
+	Name2     string    `json:"name2"`      // Название второго судна
+	Distance  float64   `json:"distance"`   // Текущее расстояние, м
+	CPA       float64   `json:"cpa"`        // Дистанция кратчайшего сближения, м
+	TCPA      float64   `json:"tcpa"`       // Время до кратчайшего сближения, с
+	RaisedAt  time.Time `json:"raised_at"`  // Когда тревога возникла
+	UpdatedAt time.Time `json:"updated_at"` // Последний пересчет
+}
+
+// ComputeCPA вычисляет CPA (м), TCPA (с) и текущее расстояние (м) между
+// судами, приводя обе позиции к моменту at по SOG/COG. Расчет ведется в
+// равнопромежуточной проекции по средней широте пары: координаты b берутся
+// относительно a, масштаб по долготе - косинус средней широты.
+// Отрицательное TCPA означает, что суда расходятся.
+func ComputeCPA(a, b models.Ship, at time.Time) (cpa, tcpa, distance float64) {
+	// Вектор от a к b на плоскости, масштаб по долготе - по средней широте
+	cosLat := math.Cos((a.Latitude + b.Latitude) / 2 * math.Pi / 180)
+	dLon := b.Longitude - a.Longitude
+	if dLon > 180 {
+		dLon -= 360
+	} else if dLon < -180 {
+		dLon += 360
+	}
+	rx := dLon * math.Pi / 180 * cosLat * earthRadius
+	ry := (b.Latitude - a.Latitude) * math.Pi / 180 * earthRadius
+
+	avx, avy := velocity(a)
+	bvx, bvy := velocity(b)
+
+	// Приводим позиции к общему моменту
+	ta := at.Sub(a.Timestamp).Seconds()
+	tb := at.Sub(b.Timestamp).Seconds()
+	rx += bvx*tb - avx*ta
+	ry += bvy*tb - avy*ta
+
+	vx := bvx - avx
+	vy := bvy - avy
+	distance = math.Hypot(rx, ry)
+
+	v2 := vx*vx + vy*vy
+	if v2 < 1e-9 {
+		return distance, 0, distance // Относительного движения нет
+	}
+
+	tcpa = -(rx*vx + ry*vy) / v2
+	cpa = math.Hypot(rx+vx*tcpa, ry+vy*tcpa)
+	return cpa, tcpa, distance
+}
+
+// velocity возвращает вектор скорости (восток, север) в м/с
+func velocity(s models.Ship) (float64, float64) {
+	if !s.HasMotion() {
+		return 0, 0
+	}
+	v := s.SOG * knotsToMS
+	c := s.COG * math.Pi / 180
+	return v * math.Sin(c), v * math.Cos(c)
+}
+
+// gridCell - ячейка пространственной сетки
+type gridCell struct{ x, y int }
+
+// polarXY проецирует точку на плоскость, касательную к Северному полюсу
+// (азимутальная равнопромежуточная проекция). Для Арктики искажение мало,
+// поэтому сетка с шагом в метрах не зависит от схождения меридианов.
+func polarXY(lat, lon float64) (float64, float64) {
+	rho := (90 - lat) * math.Pi / 180 * earthRadius
+	theta := lon * math.Pi / 180
+	return rho * math.Sin(theta), -rho * math.Cos(theta)
+}
+
+// projectedXY приводит позицию судна к моменту at по SOG/COG, как ComputeCPA, и проецирует
+// ее на полярную плоскость. Суда с отметками давностью до MaxFixAge, сошедшиеся за это
+// время, так попадают в соседние ячейки сетки.
+func projectedXY(s models.Ship, at time.Time) (float64, float64) {
+	p := models.Point{Lat: s.Latitude, Lon: s.Longitude}
+	vx, vy := velocity(s)
+	if dt := at.Sub(s.Timestamp).Seconds(); dt > 0 && (vx != 0 || vy != 0) {
+		p = (&GeoUtils{}).Destination(p, s.COG, math.Hypot(vx, vy)*dt)
+	}
+	return polarXY(p.Lat, p.Lon)
+}
+
+// CollisionMonitor - периодическая проверка сближений по хранилищу судов
+type CollisionMonitor struct {
+	store *ShipStore
+	hub   *LiveHub
+	cfg   CollisionConfig
+
+	mu       sync.Mutex
+	active   map[string]*CollisionAlert
+	lastScan time.Time
+}
+
+// NewCollisionMonitor создает монитор и подписывает его на обновления позиций
+func NewCollisionMonitor(store *ShipStore, hub *LiveHub, cfg CollisionConfig) *CollisionMonitor {
+	m := &CollisionMonitor{
+		store:  store,
+		hub:    hub,
+		cfg:    cfg,
+		active: make(map[string]*CollisionAlert),
+	}
+	store.OnUpdate(m.observe)
+	return m
+}
+
+// observe запускает проверку не чаще ScanInterval по часам хранилища
+func (m *CollisionMonitor) observe(models.Ship) {
+	now := m.store.Now()
+
+	m.mu.Lock()
+	due := now.Sub(m.lastScan) >= m.cfg.ScanInterval
+	m.mu.Unlock()
+
+	if due {
+		m.Scan(now)
+	}
+}
+
+// Scan проверяет все пары судов в пределах Range и обновляет активные тревоги.
+// Суда раскладываются по сетке с шагом Range по позициям на момент now, поэтому
+// сравниваются только соседи из смежных ячеек, а не все пары.
+func (m *CollisionMonitor) Scan(now time.Time) []CollisionAlert {
+	cellSize := m.cfg.Range * gridPadding
+	grid := make(map[gridCell][]models.Ship)
+	for _, ship := range m.store.All() {
+		if now.Sub(ship.Timestamp) > m.cfg.MaxFixAge {
+			continue
+		}
+		x, y := projectedXY(ship, now)
+		cell := gridCell{int(math.Floor(x / cellSize)), int(math.Floor(y / cellSize))}
+		grid[cell] = append(grid[cell], ship)
+	}
+
+	found := make(map[string]CollisionAlert)
+	for cell, ships := range grid {
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				neighbours := grid[gridCell{cell.x + dx, cell.y + dy}]
+				for _, a := range ships {
+					for _, b := range neighbours {
+						if a.MMSI >= b.MMSI {
+							continue // Каждая пара один раз
+						}
+						if alert, ok := m.assess(a, b, now); ok {
+							found[alert.ID] = alert
+						}
+					}
+				}
+			}
+		}
+	}
+
+	m.mu.Lock()
+	m.lastScan = now
+
+	var raised []CollisionAlert
+	for id, alert := range found {
+		if prev, ok := m.active[id]; ok {
+			alert.RaisedAt = prev.RaisedAt
+		} else {
+			raised = append(raised, alert)
+		}
+		a := alert
+		m.active[id] = &a
+	}
+	for id := range m.active {
+		if _, ok := found[id]; !ok {
+			delete(m.active, id) // Опасность миновала
+		}
+	}
+	m.mu.Unlock()
+
+	if m.hub != nil {
+		for _, alert := range raised {
+			m.hub.Publish("collision_alert", alert)
+		}
+	}
+
+	return raised
+}
+
+// assess оценивает пару судов и возвращает тревогу при нарушении порогов
+func (m *CollisionMonitor) assess(a, b models.Ship, now time.Time) (CollisionAlert, bool) {
+	cpa, tcpa, distance := ComputeCPA(a, b, now)
+	if distance > m.cfg.Range {
+		return CollisionAlert{}, false
+	}
+	if tcpa < 0 || tcpa > m.cfg.TCPALimit.Seconds() || cpa > m.cfg.CPALimit {
+		return CollisionAlert{}, false
+	}
+
+	return CollisionAlert{
+		ID:        fmt.Sprintf("%d-%d", a.MMSI, b.MMSI),
+		MMSI1:     a.MMSI,
+		MMSI2:     b.MMSI,
+		Name1:     a.Name,
+		Name2:     b.Name,
+		Distance:  distance,
+		CPA:       cpa,
+		TCPA:      tcpa,
+		RaisedAt:  now,
+		UpdatedAt: now,
+	}, true
+}
+
+// Alerts возвращает активные тревоги, ближайшие по времени первыми
+func (m *CollisionMonitor) Alerts() []CollisionAlert {
+	m.mu.Lock()
+	alerts := make([]CollisionAlert, 0, len(m.active))
+	for _, alert := range m.active {
+		alerts = append(alerts, *alert)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].TCPA < alerts[j].TCPA })
+	return alerts
+}
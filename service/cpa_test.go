@@ -0,0 +1,41 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+func TestCollisionScanProjectsStaleFixes(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	cfg := DefaultCollisionConfig()
+	cfg.MaxFixAge = time.Hour
+
+	tests := []struct {
+		name   string
+		age    time.Duration // Давность отметок обоих судов
+		sog    float64       // Скорость каждого судна навстречу другому, узлы
+		gap    float64       // Расстояние между отметками, мили
+		raised bool
+	}{
+		{"fresh fixes close by", 0, 10, 2, true},
+		{"stale fixes that converged", 50 * time.Minute, 20, 40, true},
+		{"stale fixes still far apart", 50 * time.Minute, 5, 40, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewShipStore()
+			m := NewCollisionMonitor(store, nil, cfg)
+			// Суда на одном меридиане идут навстречу друг другу
+			at := now.Add(-tt.age)
+			store.Update(models.Ship{MMSI: 273000001, Latitude: 70, Longitude: 40, SOG: tt.sog, COG: 0, Timestamp: at})
+			store.Update(models.Ship{MMSI: 273000002, Latitude: 70 + tt.gap/60, Longitude: 40, SOG: tt.sog, COG: 180, Timestamp: at})
+
+			raised := m.Scan(now)
+			if got := len(raised) > 0; got != tt.raised {
+				t.Errorf("raised %+v, want alert %v", raised, tt.raised)
+			}
+		})
+	}
+}
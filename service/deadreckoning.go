@@ -0,0 +1,108 @@
+package service
+
+import (
+	"math"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// ==============================
+// СЧИСЛЕНИЕ ПОЗИЦИИ
+// ==============================
+
+// DeadReckoningConfig - параметры счисления позиции по последнему сообщению
+type DeadReckoningConfig struct {
+	MinAge       time.Duration // Более свежие сообщения не досчитываются
+	StaleAfter   time.Duration // Сообщение считается устаревшим
+	MaxHorizon   time.Duration // Дальше счисление не продолжается
+	MaxTurn      time.Duration // Сколько времени учитывается скорость поворота
+	Step         time.Duration // Шаг интегрирования при повороте
+	BaseError    float64       // Начальная неопределенность, м
+	SpeedError   float64       // Доля пройденного пути, добавляемая к неопределенности
+	DriftSpeed   float64       // Скорость неучтенного дрейфа (течение, лед), м/с
+	MinMovingSOG float64       // Ниже этой скорости судно считается стоящим, узлы
+}
+
+// DefaultDeadReckoningConfig - параметры счисления по умолчанию
+func DefaultDeadReckoningConfig() DeadReckoningConfig {
+	return DeadReckoningConfig{
+		MinAge:       30 * time.Second,
+		StaleAfter:   30 * time.Minute,
+		MaxHorizon:   12 * time.Hour,
+		MaxTurn:      10 * time.Minute,
+		Step:         30 * time.Second,
+		BaseError:    50,
+		SpeedError:   0.1,
+		DriftSpeed:   0.25,
+		MinMovingSOG: 0.5,
+	}
+}
+
+// PredictPosition досчитывает позицию судна на момент at по SOG, COG и ROT.
+// Поворот учитывается только первые MaxTurn, дальше судно идет прямо.
+// Радиус неопределенности растет с пройденным путем и временем.
+func PredictPosition(ship models.Ship, at time.Time, cfg DeadReckoningConfig) models.ShipEstimate {
+	age := at.Sub(ship.Timestamp)
+	if age < 0 {
+		age = 0
+	}
+
+	estimate := models.ShipEstimate{
+		Ship:               ship,
+		At:                 at,
+		Age:                age.Seconds(),
+		Stale:              age > cfg.StaleAfter,
+		PredictedLatitude:  ship.Latitude,
+		PredictedLongitude: ship.Longitude,
+		Uncertainty:        cfg.BaseError,
+	}
+	if age <= cfg.MinAge {
+		return estimate
+	}
+
+	horizon := age
+	if horizon > cfg.MaxHorizon {
+		horizon = cfg.MaxHorizon
+	}
+
+	position := models.Point{Lat: ship.Latitude, Lon: ship.Longitude}
+	travelled := 0.0
+
+	if ship.HasMotion() && ship.SOG >= cfg.MinMovingSOG {
+		geo := &GeoUtils{}
+		speed := ship.SOG * knotsToMS
+		course := ship.COG
+
+		// Поворот интегрируем шагами, прямой участок - одним отрезком
+		turn := time.Duration(0)
+		if ship.ROT != 0 {
+			turn = horizon
+			if turn > cfg.MaxTurn {
+				turn = cfg.MaxTurn
+			}
+		}
+		for elapsed := time.Duration(0); elapsed < turn; elapsed += cfg.Step {
+			dt := cfg.Step
+			if elapsed+dt > turn {
+				dt = turn - elapsed
+			}
+			// Курс в середине шага
+			mid := course + ship.ROT*dt.Minutes()/2
+			position = geo.Destination(position, mid, speed*dt.Seconds())
+			travelled += speed * dt.Seconds()
+			course = math.Mod(course+ship.ROT*dt.Minutes()+360, 360)
+		}
+
+		if straight := horizon - turn; straight > 0 {
+			position = geo.Destination(position, course, speed*straight.Seconds())
+			travelled += speed * straight.Seconds()
+		}
+	}
+
+	estimate.Estimated = true
+	estimate.PredictedLatitude = position.Lat
+	estimate.PredictedLongitude = position.Lon
+	estimate.Uncertainty = cfg.BaseError + cfg.SpeedError*travelled + cfg.DriftSpeed*age.Seconds()
+	return estimate
+}
@@ -0,0 +1,366 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// ==============================
+// КОНТРОЛЬ СЛЕДОВАНИЯ МАРШРУТУ
+// ==============================
+
+// Состояние судна относительно маршрута
+const (
+	RouteNoPosition = "no_position" // Позиций после назначения маршрута не было
+	RouteOnTrack    = "on_track"    // В пределах коридора
+	RouteOffTrack   = "off_track"   // За пределами коридора
+	RouteArrived    = "arrived"     // Дошло до конечной точки; состояние конечное до нового маршрута
+)
+
+// ErrInvalidRoute - в маршруте меньше двух точек
+var ErrInvalidRoute = errors.New("route must have at least two points")
+
+// RoutePlan - маршрут, назначенный судну
+type RoutePlan struct {
+	MMSI         int32      `json:"mmsi"`
+	Route        Route      `json:"route"`
+	Corridor     float64    `json:"corridor"`                // Допустимое боковое отклонение, м
+	PlannedSpeed float64    `json:"planned_speed,omitempty"` // Плановая скорость для ETA, узлы
+	AssignedAt   time.Time  `json:"assigned_at"`
+	ArrivedAt    *time.Time `json:"arrived_at,omitempty"` // Когда судно дошло до конечной точки
+}
+
+// RouteProgress - положение судна относительно назначенного маршрута
+type RouteProgress struct {
+	MMSI       int32      `json:"mmsi"`
+	Name       string     `json:"name,omitempty"`
+	Status     string     `json:"status"`
+	Leg        int        `json:"leg"`         // Номер текущего участка (от 0)
+	CrossTrack float64    `json:"cross_track"` // Боковое отклонение, м; > 0 - правее линии пути
+	AlongTrack float64    `json:"along_track"` // Пройдено вдоль маршрута, м
+	Remaining  float64    `json:"remaining"`   // Осталось до конечной точки, м
+	Progress   float64    `json:"progress"`    // Доля пройденного (0..1)
+	Speed      float64    `json:"speed"`       // Скорость для расчета ETA, узлы
+	ETA        *time.Time `json:"eta,omitempty"`
+	UpdatedAt  time.Time  `json:"updated_at,omitempty"` // Время позиции
+}
+
+// RouteDeviation - выход судна из коридора маршрута или возврат в него
+type RouteDeviation struct {
+	ID         int64     `json:"id"`
+	MMSI       int32     `json:"mmsi"`
+	Name       string    `json:"name"`
+	Returned   bool      `json:"returned"`    // true - судно вернулось в коридор
+	CrossTrack float64   `json:"cross_track"` // Боковое отклонение, м
+	Corridor   float64   `json:"corridor"`    // Ширина коридора (в каждую сторону), м
+	Leg        int       `json:"leg"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// RouteMonitorConfig - параметры контроля маршрутов
+type RouteMonitorConfig struct {
+	Corridor     float64 // Коридор по умолчанию, м
+	MinSpeed     float64 // Ниже этой SOG ETA считается по плановой скорости, узлы
+	ArrivalRange float64 // Ближе к конечной точке - судно пришло, м
+	MaxStored    int     // Сколько событий отклонения хранить
+}
+
+// DefaultRouteMonitorConfig - коридор 1 миля
+func DefaultRouteMonitorConfig() RouteMonitorConfig {
+	return RouteMonitorConfig{
+		Corridor:     1 * metersPerNM,
+		MinSpeed:     1.0,
+		ArrivalRange: 1 * metersPerNM,
+		MaxStored:    2000,
+	}
+}
+
+// routeTrack - назначенный маршрут с текущим положением судна
+type routeTrack struct {
+	plan     RoutePlan
+	legStart []float64 // Расстояние от начала маршрута до начала участка, м
+	progress RouteProgress
+}
+
+// RouteMonitor - сопоставление позиций AIS с назначенными маршрутами
+type RouteMonitor struct {
+	store *ShipStore
+	hub   *LiveHub
+	cfg   RouteMonitorConfig
+	geo   *GeoUtils
+	path  string
+
+	mu         sync.RWMutex
+	tracks     map[int32]*routeTrack
+	deviations []RouteDeviation
+	nextID     int64
+}
+
+// NewRouteMonitor загружает назначенные маршруты из path и подписывается на позиции
+func NewRouteMonitor(store *ShipStore, hub *LiveHub, path string, cfg RouteMonitorConfig) (*RouteMonitor, error) {
+	var plans []RoutePlan
+	if err := loadJSON(path, &plans); err != nil {
+		return nil, fmt.Errorf("load routes: %w", err)
+	}
+
+	m := &RouteMonitor{
+		store:  store,
+		hub:    hub,
+		cfg:    cfg,
+		geo:    &GeoUtils{},
+		path:   path,
+		tracks: make(map[int32]*routeTrack),
+	}
+	for _, plan := range plans {
+		if len(plan.Route.Points) >= 2 {
+			m.tracks[plan.MMSI] = m.newTrack(plan)
+		}
+	}
+	store.OnUpdate(m.Observe)
+	return m, nil
+}
+
+// newTrack готовит маршрут к сопоставлению
+func (m *RouteMonitor) newTrack(plan RoutePlan) *routeTrack {
+	points := plan.Route.Points
+	t := &routeTrack{plan: plan, legStart: make([]float64, len(points))}
+	for i := 1; i < len(points); i++ {
+		t.legStart[i] = t.legStart[i-1] + m.geo.Distance(points[i-1], points[i])
+	}
+	t.plan.Route.Length = t.legStart[len(points)-1]
+	t.progress = RouteProgress{MMSI: plan.MMSI, Status: RouteNoPosition, Remaining: t.plan.Route.Length}
+	if plan.ArrivedAt != nil {
+		t.progress = RouteProgress{MMSI: plan.MMSI, Status: RouteArrived, Leg: len(points) - 2,
+			AlongTrack: t.plan.Route.Length, Progress: 1, UpdatedAt: *plan.ArrivedAt}
+	}
+	return t
+}
+
+// Assign назначает судну маршрут. corridor <= 0 - коридор по умолчанию.
+// Если позиция судна уже известна, положение на маршруте считается сразу.
+func (m *RouteMonitor) Assign(mmsi int32, route Route, corridor, plannedSpeed float64) (RoutePlan, error) {
+	if len(route.Points) < 2 {
+		return RoutePlan{}, ErrInvalidRoute
+	}
+	if corridor <= 0 {
+		corridor = m.cfg.Corridor
+	}
+	plan := RoutePlan{
+		MMSI:         mmsi,
+		Route:        route,
+		Corridor:     corridor,
+		PlannedSpeed: plannedSpeed,
+		AssignedAt:   m.store.Now(),
+	}
+	track := m.newTrack(plan)
+
+	m.mu.Lock()
+	prev, existed := m.tracks[mmsi]
+	m.tracks[mmsi] = track
+	if err := m.save(); err != nil {
+		if existed {
+			m.tracks[mmsi] = prev
+		} else {
+			delete(m.tracks, mmsi)
+		}
+		m.mu.Unlock()
+		return RoutePlan{}, err
+	}
+	m.mu.Unlock()
+
+	if ship, ok := m.store.Get(mmsi); ok {
+		m.Observe(ship)
+	}
+	return track.plan, nil
+}
+
+// Unassign снимает маршрут с судна
+func (m *RouteMonitor) Unassign(mmsi int32) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prev, ok := m.tracks[mmsi]
+	if !ok {
+		return false, nil
+	}
+	delete(m.tracks, mmsi)
+	if err := m.save(); err != nil {
+		m.tracks[mmsi] = prev
+		return false, err
+	}
+	return true, nil
+}
+
+// save записывает назначенные маршруты на диск; вызывается под блокировкой
+func (m *RouteMonitor) save() error {
+	plans := make([]RoutePlan, 0, len(m.tracks))
+	for _, t := range m.tracks {
+		plans = append(plans, t.plan)
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].MMSI < plans[j].MMSI })
+	return saveJSON(m.path, plans)
+}
+
+// Observe пересчитывает положение судна на маршруте по новой позиции
+func (m *RouteMonitor) Observe(ship models.Ship) {
+	m.mu.Lock()
+	track, ok := m.tracks[ship.MMSI]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+
+	// Пришедшее судно остается в статусе arrived, пока ему не назначат новый маршрут:
+	// уход от причала после прихода не считается отклонением
+	prevStatus := track.progress.Status
+	if prevStatus == RouteArrived {
+		m.mu.Unlock()
+		return
+	}
+	progress := m.locate(track, ship)
+	track.progress = progress
+	if progress.Status == RouteArrived {
+		arrivedAt := ship.Timestamp
+		track.plan.ArrivedAt = &arrivedAt
+		if err := m.save(); err != nil {
+			log.Println("Routes save error:", err)
+		}
+	}
+
+	var deviation *RouteDeviation
+	leftCorridor := progress.Status == RouteOffTrack && prevStatus != RouteOffTrack
+	returned := prevStatus == RouteOffTrack && progress.Status == RouteOnTrack
+	if leftCorridor || returned {
+		m.nextID++
+		deviation = &RouteDeviation{
+			ID:         m.nextID,
+			MMSI:       ship.MMSI,
+			Name:       ship.Name,
+			Returned:   returned,
+			CrossTrack: progress.CrossTrack,
+			Corridor:   track.plan.Corridor,
+			Leg:        progress.Leg,
+			Latitude:   ship.Latitude,
+			Longitude:  ship.Longitude,
+			DetectedAt: ship.Timestamp,
+		}
+		m.deviations = appendCapped(m.deviations, *deviation, m.cfg.MaxStored)
+	}
+	m.mu.Unlock()
+
+	if deviation != nil && m.hub != nil {
+		m.hub.Publish("route_deviation", *deviation)
+	}
+}
+
+// locate находит ближайший участок маршрута и считает отклонение, прогресс и ETA.
+// Поиск начинается с предыдущего участка, чтобы судно не "перескакивало" назад
+// на маршрутах, проходящих близко к самим себе.
+func (m *RouteMonitor) locate(track *routeTrack, ship models.Ship) RouteProgress {
+	points := track.plan.Route.Points
+	position := models.Point{Lat: ship.Latitude, Lon: ship.Longitude}
+	total := track.plan.Route.Length
+
+	from := 0
+	if track.progress.Status != RouteNoPosition && track.progress.Leg > 0 {
+		from = track.progress.Leg - 1
+	}
+
+	best := RouteProgress{Leg: -1}
+	bestDistance := math.MaxFloat64
+	for leg := from; leg < len(points)-1; leg++ {
+		a, b := points[leg], points[leg+1]
+		legLength := track.legStart[leg+1] - track.legStart[leg]
+
+		xte, along := m.geo.CrossTrack(position, a, b)
+		distance := math.Abs(xte)
+		switch {
+		case along < 0:
+			along, distance = 0, m.geo.Distance(position, a)
+		case along > legLength:
+			along, distance = legLength, m.geo.Distance(position, b)
+		}
+
+		if distance < bestDistance {
+			bestDistance = distance
+			best = RouteProgress{Leg: leg, CrossTrack: xte, AlongTrack: track.legStart[leg] + along}
+		}
+	}
+
+	best.MMSI = ship.MMSI
+	best.Name = ship.Name
+	best.UpdatedAt = ship.Timestamp
+	best.Remaining = math.Max(0, total-best.AlongTrack)
+	if total > 0 {
+		best.Progress = best.AlongTrack / total
+	}
+
+	switch {
+	case best.Leg == len(points)-2 && m.geo.Distance(position, points[len(points)-1]) <= m.cfg.ArrivalRange:
+		// Приход засчитывается только на последнем участке: круговой маршрут или маршрут,
+		// проходящий у конечной точки раньше, не завершается на первом проходе
+		best.Status = RouteArrived
+		best.Remaining = 0
+		best.Progress = 1
+	case bestDistance > track.plan.Corridor:
+		best.Status = RouteOffTrack
+	default:
+		best.Status = RouteOnTrack
+	}
+
+	best.Speed = track.plan.PlannedSpeed
+	if ship.HasMotion() && ship.SOG >= m.cfg.MinSpeed {
+		best.Speed = ship.SOG
+	}
+	if best.Speed > 0 {
+		eta := ship.Timestamp.Add(time.Duration(best.Remaining / (best.Speed * knotsToMS) * float64(time.Second)))
+		best.ETA = &eta
+	}
+	return best
+}
+
+// Plan возвращает маршрут судна и текущее положение на нем
+func (m *RouteMonitor) Plan(mmsi int32) (RoutePlan, RouteProgress, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	track, ok := m.tracks[mmsi]
+	if !ok {
+		return RoutePlan{}, RouteProgress{}, false
+	}
+	return track.plan, track.progress, true
+}
+
+// Progress возвращает положение всех судов с назначенными маршрутами
+func (m *RouteMonitor) Progress() []RouteProgress {
+	m.mu.RLock()
+	result := make([]RouteProgress, 0, len(m.tracks))
+	for _, track := range m.tracks {
+		result = append(result, track.progress)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].MMSI < result[j].MMSI })
+	return result
+}
+
+// Deviations возвращает события отклонения, новые первыми; mmsi = 0 - по всем судам
+func (m *RouteMonitor) Deviations(mmsi int32) []RouteDeviation {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]RouteDeviation, 0)
+	for i := len(m.deviations) - 1; i >= 0; i-- {
+		if mmsi == 0 || m.deviations[i].MMSI == mmsi {
+			result = append(result, m.deviations[i])
+		}
+	}
+	return result
+}
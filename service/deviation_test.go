@@ -0,0 +1,96 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+func TestRouteArrivedIsTerminal(t *testing.T) {
+	store := NewShipStore()
+	path := filepath.Join(t.TempDir(), "routes.json")
+	monitor, err := NewRouteMonitor(store, nil, path, DefaultRouteMonitorConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	route := Route{Points: []models.Point{{Lat: 69, Lon: 33}, {Lat: 70, Lon: 33}}}
+	if _, err := monitor.Assign(273000101, route, 0, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	steps := []struct {
+		lat, lon float64
+		want     string
+	}{
+		{69.5, 33, RouteOnTrack},
+		{69.995, 33, RouteArrived},
+		{69.9, 34, RouteArrived}, // Отошло от причала в сторону
+		{69.5, 33, RouteArrived}, // Возвращается по тому же маршруту
+	}
+	for i, step := range steps {
+		store.Update(models.Ship{MMSI: 273000101, Latitude: step.lat, Longitude: step.lon, SOG: 10, Timestamp: start.Add(time.Duration(i) * time.Hour)})
+		if _, progress, _ := monitor.Plan(273000101); progress.Status != step.want {
+			t.Fatalf("step %d: status %s, want %s", i, progress.Status, step.want)
+		}
+	}
+	if deviations := monitor.Deviations(0); len(deviations) != 0 {
+		t.Errorf("leaving after arrival raised deviations %+v", deviations)
+	}
+
+	// Приход переживает перезапуск
+	reloaded, err := NewRouteMonitor(NewShipStore(), nil, path, DefaultRouteMonitorConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, progress, _ := reloaded.Plan(273000101)
+	if progress.Status != RouteArrived || plan.ArrivedAt == nil || !plan.ArrivedAt.Equal(start.Add(time.Hour)) {
+		t.Fatalf("after reload: status %s, arrived at %v", progress.Status, plan.ArrivedAt)
+	}
+
+	// Новый маршрут начинает новый рейс
+	back := Route{Points: []models.Point{{Lat: 70, Lon: 33}, {Lat: 69, Lon: 33}}}
+	if _, err := monitor.Assign(273000101, back, 0, 10); err != nil {
+		t.Fatal(err)
+	}
+	if plan, progress, _ := monitor.Plan(273000101); progress.Status != RouteOnTrack || plan.ArrivedAt != nil {
+		t.Errorf("new route: status %s, arrived at %v", progress.Status, plan.ArrivedAt)
+	}
+}
+
+func TestRouteArrivalNeedsLastLeg(t *testing.T) {
+	store := NewShipStore()
+	monitor, err := NewRouteMonitor(store, nil, filepath.Join(t.TempDir(), "routes.json"), DefaultRouteMonitorConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Круговой маршрут кончается в полумиле от начала
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	store.Update(models.Ship{MMSI: 273000102, Latitude: 69, Longitude: 33, SOG: 10, Timestamp: start})
+	route := Route{Points: []models.Point{{Lat: 69, Lon: 33}, {Lat: 70, Lon: 33}, {Lat: 70, Lon: 35}, {Lat: 69.008, Lon: 33}}}
+	if _, err := monitor.Assign(273000102, route, 0, 10); err != nil {
+		t.Fatal(err)
+	}
+	if _, progress, _ := monitor.Plan(273000102); progress.Status != RouteOnTrack {
+		t.Fatalf("at assignment: status %s, want %s", progress.Status, RouteOnTrack)
+	}
+
+	steps := []struct {
+		lat, lon float64
+		want     string
+	}{
+		{69.004, 33, RouteOnTrack},
+		{69.5, 33, RouteOnTrack},
+		{70, 34, RouteOnTrack},
+		{69.5, 34, RouteOnTrack},
+		{69.008, 33, RouteArrived},
+	}
+	for i, step := range steps {
+		store.Update(models.Ship{MMSI: 273000102, Latitude: step.lat, Longitude: step.lon, SOG: 10, Timestamp: start.Add(time.Duration(i+1) * time.Hour)})
+		if _, progress, _ := monitor.Plan(273000102); progress.Status != step.want {
+			t.Fatalf("step %d: status %s on leg %d, want %s", i, progress.Status, progress.Leg, step.want)
+		}
+	}
+}
@@ -0,0 +1,530 @@
+package service
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// ==============================
+// ЭКСТРЕННЫЙ ЗАХОД В ПОРТ
+// ==============================
+
+// ErrInvalidDiversion - некорректный запрос подбора порта
+var ErrInvalidDiversion = errors.New("invalid diversion request")
+
+// DiversionConfig - параметры подбора порта захода
+type DiversionConfig struct {
+	Speed           float64 // Скорость по умолчанию, узлы
+	IceSpeedFactor  float64 // Доля скорости на участках в ледовый сезон
+	UnderKeel       float64 // Запас воды под килем на участках с ограниченной глубиной, м
+	ConnectRadius   float64 // Радиус поиска поворотных точек вокруг позиции судна, м
+	HazardClearance float64 // Дополнительный отступ от ледовых опасностей, м
+}
+
+// DefaultDiversionConfig - 10 узлов, половина скорости во льду, 1 м под килем
+func DefaultDiversionConfig() DiversionConfig {
+	return DiversionConfig{
+		Speed:           10,
+		IceSpeedFactor:  0.5,
+		UnderKeel:       1.0,
+		ConnectRadius:   150000,
+		HazardClearance: 1 * metersPerNM,
+	}
+}
+
+// DiversionRequest - откуда и каким судном нужно уйти
+type DiversionRequest struct {
+	From    models.Point         // Текущая позиция
+	At      time.Time            // Момент выхода; определяет ледовый сезон
+	Profile models.VesselProfile // Осадка и ледовый класс
+	Speed   float64              // Скорость, узлы; 0 - по умолчанию
+	RankBy  string               // "time" (по умолчанию) или "distance"
+}
+
+// Diversion - достижимый порт или место убежища
+type Diversion struct {
+	Haven    Haven     `json:"haven"`
+	Route    Route     `json:"route"`
+	Distance float64   `json:"distance"` // Длина пути по сети морских путей, м
+	Direct   float64   `json:"direct"`   // Расстояние по прямой, м
+	Duration float64   `json:"duration"` // Время перехода, с
+	ETA      time.Time `json:"eta"`
+}
+
+// Unreachable - место, куда судно уйти не может, и причина
+type Unreachable struct {
+	Haven  Haven  `json:"haven"`
+	Reason string `json:"reason"`
+}
+
+// DiversionPlan - места захода, ближайшие по времени перехода (или расстоянию) первыми
+type DiversionPlan struct {
+	From        models.Point         `json:"from"`
+	Place       *PlaceMatch          `json:"place,omitempty"` // Место, найденное по названию в запросе
+	At          time.Time            `json:"at"`
+	Profile     models.VesselProfile `json:"profile"`
+	Speed       float64              `json:"speed"` // Узлы
+	Options     []Diversion          `json:"options"`
+	Unreachable []Unreachable        `json:"unreachable"`
+	Warnings    []string             `json:"warnings,omitempty"`
+}
+
+// DiversionPlanner - подбор порта или места убежища по сети морских путей
+// с учетом осадки, ледового класса и действующих ледовых опасностей
+type DiversionPlanner struct {
+	mu      sync.RWMutex // Защищает router и havens при замене мест убежища
+	router  *MarineRouter
+	havens  []Haven
+	hazards *IceHazardMonitor
+	fairway *Fairway        // Необязательная привязка подходов к буям фарватера
+	traffic *trafficWeights // Необязательные веса наблюдаемого движения
+	cfg     DiversionConfig
+}
+
+// trafficWeights - параметры удорожания ребер сети вне наезженных трасс
+type trafficWeights struct {
+	density  *TrafficDensity
+	window   string
+	strength float64
+}
+
+// NewDiversionPlanner создает планировщик; hazards может быть nil
+func NewDiversionPlanner(router *MarineRouter, havens []Haven, hazards *IceHazardMonitor, cfg DiversionConfig) *DiversionPlanner {
+	return &DiversionPlanner{router: router, havens: havens, hazards: hazards, cfg: cfg}
+}
+
+// UseFairway включает проводку подходов к портам по буям фарватера
+func (p *DiversionPlanner) UseFairway(f *Fairway) {
+	p.fairway = f
+}
+
+// SetHavens заменяет порты и места убежища и перестраивает сеть морских путей
+func (p *DiversionPlanner) SetHavens(havens []Haven) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.router, p.havens = p.build(havens), havens
+}
+
+// Rebuild перестраивает сеть морских путей, например после загрузки полигонов суши,
+// по которым проверяются участки от мест убежища до сети
+func (p *DiversionPlanner) Rebuild() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.router = p.build(p.havens)
+}
+
+// UseTrafficWeights включает веса наблюдаемого движения окна window (пусто - все окна):
+// ребра вне наезженных трасс дорожают до 1 + strength раз. Веса применяются сразу
+// и пересчитываются RefreshTrafficWeights.
+func (p *DiversionPlanner) UseTrafficWeights(density *TrafficDensity, window string, strength float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.traffic = &trafficWeights{density: density, window: window, strength: strength}
+	p.router = p.build(p.havens)
+}
+
+// RefreshTrafficWeights перестраивает сеть по накопленной плотности движения
+func (p *DiversionPlanner) RefreshTrafficWeights() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.traffic != nil {
+		p.router = p.build(p.havens)
+	}
+}
+
+// build строит новую сеть морских путей; вызывается под блокировкой.
+// Сеть не меняется после построения, поэтому снимки планировщика читают ее без блокировки.
+func (p *DiversionPlanner) build(havens []Haven) *MarineRouter {
+	router := NewArcticRouter(havens, p.router.landDetector)
+	if p.traffic != nil {
+		router.ApplyTrafficWeights(p.traffic.density, p.traffic.window, p.traffic.strength)
+	}
+	return router
+}
+
+// current возвращает снимок планировщика, который не меняется при замене мест убежища
+func (p *DiversionPlanner) current() *DiversionPlanner {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return &DiversionPlanner{router: p.router, havens: p.havens, hazards: p.hazards, fairway: p.fairway, traffic: p.traffic, cfg: p.cfg}
+}
+
+// Havens возвращает зарегистрированные порты и места убежища
+func (p *DiversionPlanner) Havens() []Haven {
+	return append([]Haven(nil), p.current().havens...)
+}
+
+// diversionLeg - состояние поиска кратчайших путей
+type diversionLeg struct {
+	duration float64 // с
+	distance float64 // м
+	parent   string  // Предыдущий узел; пусто - участок от позиции судна
+}
+
+// cost - стоимость пути для ранжирования: длина при rank=distance, иначе время
+func (l diversionLeg) cost(rankBy string) float64 {
+	if rankBy == "distance" {
+		return l.distance
+	}
+	return l.duration
+}
+
+// Plan прокладывает пути ко всем местам захода и ранжирует их по времени перехода или длине пути
+func (p *DiversionPlanner) Plan(req DiversionRequest) (DiversionPlan, error) {
+	p = p.current()
+	if req.From.Lat < -90 || req.From.Lat > 90 || req.From.Lon < -180 || req.From.Lon > 180 {
+		return DiversionPlan{}, fmt.Errorf("%w: position out of range", ErrInvalidDiversion)
+	}
+	if req.Speed < 0 || req.Profile.Draught < 0 {
+		return DiversionPlan{}, fmt.Errorf("%w: speed and draught must not be negative", ErrInvalidDiversion)
+	}
+	if req.RankBy != "" && req.RankBy != "time" && req.RankBy != "distance" {
+		return DiversionPlan{}, fmt.Errorf("%w: rank must be time or distance", ErrInvalidDiversion)
+	}
+	if req.Speed == 0 {
+		req.Speed = p.cfg.Speed
+	}
+
+	plan := DiversionPlan{
+		From:        req.From,
+		At:          req.At,
+		Profile:     req.Profile,
+		Speed:       req.Speed,
+		Options:     make([]Diversion, 0),
+		Unreachable: make([]Unreachable, 0),
+	}
+	if req.Profile.Draught == 0 {
+		plan.Warnings = append(plan.Warnings, "draught unknown: depth limits not checked")
+	}
+	if IceClassRank(req.Profile.IceClass) == 0 {
+		plan.Warnings = append(plan.Warnings, "no ice class: ice-season areas avoided")
+	}
+
+	env := p.environment(req.At)
+	legs, far := p.search(req, env)
+	if far {
+		plan.Warnings = append(plan.Warnings, "position is far from the route network")
+	}
+
+	for _, h := range p.havens {
+		if reason := p.refuses(h, req); reason != "" {
+			plan.Unreachable = append(plan.Unreachable, Unreachable{Haven: h, Reason: reason})
+			continue
+		}
+		leg, ok := legs[h.ID]
+		if !ok {
+			plan.Unreachable = append(plan.Unreachable, Unreachable{Haven: h, Reason: "no route within draught, ice and hazard limits"})
+			continue
+		}
+
+		points := p.pathPoints(legs, h.ID, req.From)
+		safe, message := p.assess(legs, h.ID, req, far)
+		if snapped, length, ok := p.snapApproach(points, req.At, after(req.At, leg.duration), env); ok && leg.distance > 0 {
+			// Время перехода пересчитывается пропорционально изменившейся длине
+			leg.duration *= length / leg.distance
+			points, leg.distance = snapped, length
+		}
+		plan.Options = append(plan.Options, Diversion{
+			Haven: h,
+			Route: Route{
+				Points:  points,
+				Length:  leg.distance,
+				IsSafe:  safe,
+				Message: message,
+			},
+			Distance: leg.distance,
+			Direct:   p.router.geo.Distance(req.From, h.Point),
+			Duration: leg.duration,
+			ETA:      req.At.Add(time.Duration(leg.duration * float64(time.Second))),
+		})
+	}
+
+	sort.Slice(plan.Options, func(i, j int) bool {
+		a, b := plan.Options[i], plan.Options[j]
+		if req.RankBy == "distance" && a.Distance != b.Distance {
+			return a.Distance < b.Distance
+		}
+		if a.Duration != b.Duration {
+			return a.Duration < b.Duration
+		}
+		return a.Distance < b.Distance
+	})
+	return plan, nil
+}
+
+// assess проверяет путь до узла id на то, что не удалось проверить при поиске:
+// начальный участок вне сети морских путей и глубины при неизвестной осадке
+func (p *DiversionPlanner) assess(legs map[string]diversionLeg, id string, req DiversionRequest, far bool) (bool, string) {
+	if far {
+		return false, "Участок вне сети морских путей: суша, глубины и лед не проверены"
+	}
+	if req.Profile.Draught == 0 {
+		ng := p.router.navGraph
+		for child := id; legs[child].parent != ""; child = legs[child].parent {
+			for _, edge := range ng.edges[legs[child].parent] {
+				if edge.To == child && edge.Limits.Depth > 0 {
+					return false, fmt.Sprintf("Осадка неизвестна: участок с глубиной %.1f м не проверен", edge.Limits.Depth)
+				}
+			}
+		}
+	}
+	return true, "Маршрут успешно построен"
+}
+
+// refuses возвращает причину, по которой место не принимает судно; пусто - принимает
+func (p *DiversionPlanner) refuses(h Haven, req DiversionRequest) string {
+	if h.MaxDraft > 0 && req.Profile.Draught > h.MaxDraft {
+		return fmt.Sprintf("draught %.1f m exceeds max draft %.1f m", req.Profile.Draught, h.MaxDraft)
+	}
+	if !h.Permits(req.Profile.IceClass, req.At) {
+		return fmt.Sprintf("ice class %s required in ice season", h.IceClass)
+	}
+	return ""
+}
+
+// passageEnv - ледовые опасности на момент расчета и перекрытые ими ребра
+type passageEnv struct {
+	hazards  []IceHazard // Неподвижные опасности
+	drifting []IceHazard // Дрейфующие: проверяются по прогнозному положению на время прохода
+	blocked  map[*NavEdge]bool
+}
+
+// environment собирает действующие ледовые опасности на момент at
+func (p *DiversionPlanner) environment(at time.Time) *passageEnv {
+	env := &passageEnv{blocked: make(map[*NavEdge]bool)}
+	if p.hazards != nil {
+		for _, h := range p.hazards.Hazards(at) {
+			if h.Drifting() {
+				env.drifting = append(env.drifting, h)
+			} else {
+				env.hazards = append(env.hazards, h)
+			}
+		}
+	}
+	if len(env.hazards) == 0 {
+		return env
+	}
+	ng := p.router.navGraph
+	for _, edges := range ng.edges {
+		for _, edge := range edges {
+			if !p.clear(ng.nodes[edge.From].Point, ng.nodes[edge.To].Point, env.hazards) {
+				env.blocked[edge] = true
+			}
+		}
+	}
+	return env
+}
+
+// passable сообщает, может ли судно пройти ребро
+func (p *DiversionPlanner) passable(edge *NavEdge, req DiversionRequest, env *passageEnv) bool {
+	if edge.Limits.Depth > 0 && req.Profile.Draught > 0 && req.Profile.Draught+p.cfg.UnderKeel > edge.Limits.Depth {
+		return false
+	}
+	if !edge.Limits.Permits(req.Profile.IceClass, req.At) {
+		return false
+	}
+	return !env.blocked[edge]
+}
+
+// segmentDistance - наименьшее расстояние от точки p до отрезка a-b, м
+func (p *DiversionPlanner) segmentDistance(pt, a, b models.Point) float64 {
+	xte, along := p.router.geo.CrossTrack(pt, a, b)
+	switch {
+	case along < 0:
+		return p.router.geo.Distance(pt, a)
+	case along > p.router.geo.Distance(a, b):
+		return p.router.geo.Distance(pt, b)
+	default:
+		return math.Abs(xte)
+	}
+}
+
+// clear сообщает, что отрезок a-b не проходит через ледовые опасности
+func (p *DiversionPlanner) clear(a, b models.Point, hazards []IceHazard) bool {
+	for _, h := range hazards {
+		if p.segmentDistance(h.Point, a, b) < h.Radius+p.cfg.HazardClearance {
+			return false
+		}
+	}
+	return true
+}
+
+// clearOfDrift сообщает, что отрезок a-b, проходимый с from до to, не задевает
+// дрейфующие опасности в их прогнозных положениях за это время
+func (p *DiversionPlanner) clearOfDrift(a, b models.Point, from, to time.Time, env *passageEnv) bool {
+	for _, h := range env.drifting {
+		if h.ExpiresAt != nil && !h.ExpiresAt.After(from) {
+			continue
+		}
+		for _, f := range h.Positions(from, to) {
+			if p.segmentDistance(f.Point, a, b) < f.Radius+p.cfg.HazardClearance {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// free сообщает, что отрезок a-b, проходимый с from до to, свободен от всех ледовых опасностей
+func (p *DiversionPlanner) free(a, b models.Point, from, to time.Time, env *passageEnv) bool {
+	return p.clear(a, b, env.hazards) && p.clearOfDrift(a, b, from, to, env)
+}
+
+// after - момент через seconds секунд после at
+func after(at time.Time, seconds float64) time.Time {
+	return at.Add(time.Duration(seconds * float64(time.Second)))
+}
+
+// regionIce - ледовые условия в точке: требования ближайшего участка сети морских путей
+func (p *DiversionPlanner) regionIce(pt models.Point) IceRequirement {
+	ng := p.router.navGraph
+	var nearest IceRequirement
+	best := math.MaxFloat64
+	for _, edges := range ng.edges {
+		for _, edge := range edges {
+			if d := p.segmentDistance(pt, ng.nodes[edge.From].Point, ng.nodes[edge.To].Point); d < best {
+				best, nearest = d, edge.Limits.IceRequirement
+			}
+		}
+	}
+	return nearest
+}
+
+// legDuration - время прохождения расстояния distance, с
+func (p *DiversionPlanner) legDuration(distance float64, req DiversionRequest, ice bool) float64 {
+	speed := req.Speed * 1852 / 3600 // м/с
+	if ice {
+		speed *= p.cfg.IceSpeedFactor
+	}
+	return distance / speed
+}
+
+// search находит кратчайшие пути от позиции судна до всех узлов (Дейкстра):
+// по времени перехода или, при rank=distance, по длине пути.
+// far - рядом с позицией нет узлов сети, путь начат от ближайшего.
+func (p *DiversionPlanner) search(req DiversionRequest, env *passageEnv) (map[string]diversionLeg, bool) {
+	ng := p.router.navGraph
+	legs := make(map[string]diversionLeg)
+	queue := make(priorityQueue, 0)
+	startIce := p.regionIce(req.From).InSeason(req.At)
+
+	// Начальные участки от позиции судна до поворотных точек поблизости.
+	// К портам напрямую не идем: подходы к ним ограничены по глубине.
+	var nearest *NavNode
+	nearestDist := math.MaxFloat64
+	for _, node := range ng.nodes {
+		d := p.router.geo.Distance(req.From, node.Point)
+		if node.Type == "waypoint" && d < nearestDist {
+			nearest, nearestDist = node, d
+		}
+		if node.Type != "waypoint" || d > p.cfg.ConnectRadius || p.router.landDetector.crosses(req.From, node.Point) {
+			continue
+		}
+		duration := p.legDuration(d, req, startIce)
+		if !p.free(req.From, node.Point, req.At, after(req.At, duration), env) {
+			continue
+		}
+		p.seed(legs, &queue, node.ID, diversionLeg{duration: duration, distance: d}, req.RankBy)
+	}
+	far := len(legs) == 0
+	if far && nearest != nil {
+		p.seed(legs, &queue, nearest.ID, diversionLeg{duration: p.legDuration(nearestDist, req, startIce), distance: nearestDist}, req.RankBy)
+	}
+
+	done := make(map[string]bool)
+	for queue.Len() > 0 {
+		current := heap.Pop(&queue).(*pathNode)
+		if done[current.nodeID] {
+			continue
+		}
+		done[current.nodeID] = true
+		if node := ng.nodes[current.nodeID]; node.Type != "waypoint" {
+			continue // Через порты и места убежища транзитом не идем
+		}
+
+		from := legs[current.nodeID]
+		for _, edge := range ng.edges[current.nodeID] {
+			if done[edge.To] || !p.passable(edge, req, env) {
+				continue
+			}
+			leg := diversionLeg{
+				duration: from.duration + p.legDuration(edge.Distance, req, edge.Limits.InSeason(req.At)),
+				distance: from.distance + edge.Distance,
+				parent:   current.nodeID,
+			}
+			if prev, ok := legs[edge.To]; ok && prev.cost(req.RankBy) <= leg.cost(req.RankBy) {
+				continue
+			}
+			// Дрейфующие опасности проверяются на время прохода ребра
+			if len(env.drifting) > 0 && !p.clearOfDrift(ng.nodes[edge.From].Point, ng.nodes[edge.To].Point,
+				after(req.At, from.duration), after(req.At, leg.duration), env) {
+				continue
+			}
+			legs[edge.To] = leg
+			heap.Push(&queue, &pathNode{nodeID: edge.To, cost: leg.cost(req.RankBy), total: leg.cost(req.RankBy)})
+		}
+	}
+	return legs, far
+}
+
+// seed добавляет начальный участок от позиции судна до узла
+func (p *DiversionPlanner) seed(legs map[string]diversionLeg, queue *priorityQueue, id string, leg diversionLeg, rankBy string) {
+	legs[id] = leg
+	heap.Push(queue, &pathNode{nodeID: id, cost: leg.cost(rankBy), total: leg.cost(rankBy)})
+}
+
+// snapApproach проводит подход к порту через ворота фарватера, если новые участки
+// не пересекают сушу и ледовые опасности за время перехода from-to; возвращает точки и длину маршрута
+func (p *DiversionPlanner) snapApproach(points []models.Point, from, to time.Time, env *passageEnv) ([]models.Point, float64, bool) {
+	if p.fairway == nil {
+		return nil, 0, false
+	}
+	snapped := p.fairway.SnapApproach(points)
+	if slices.Equal(snapped, points) {
+		return nil, 0, false
+	}
+	length := 0.0
+	for i := 1; i < len(snapped); i++ {
+		if p.router.landDetector.crosses(snapped[i-1], snapped[i]) || !p.free(snapped[i-1], snapped[i], from, to, env) {
+			return nil, 0, false
+		}
+		length += p.router.geo.Distance(snapped[i-1], snapped[i])
+	}
+	return snapped, length, true
+}
+
+// pathPoints восстанавливает точки пути от позиции from до узла id
+func (p *DiversionPlanner) pathPoints(legs map[string]diversionLeg, id string, from models.Point) []models.Point {
+	var points []models.Point
+	for ; id != ""; id = legs[id].parent {
+		points = append(points, p.router.navGraph.nodes[id].Point)
+	}
+	points = append(points, from)
+	for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+		points[i], points[j] = points[j], points[i]
+	}
+	return points
+}
+
+// crosses сообщает, пересекает ли отрезок a-b сушу (проверка точками примерно через 2 км)
+func (ld *LandDetector) crosses(a, b models.Point) bool {
+	if ld.PolygonCount() == 0 {
+		return false
+	}
+	geo := &GeoUtils{}
+	steps := int(math.Min(math.Max(geo.Distance(a, b)/2000, 1), 200))
+	for i := 1; i < steps; i++ {
+		if ld.IsLand(geo.IntermediatePoint(a, b, float64(i)/float64(steps))) {
+			return true
+		}
+	}
+	return false
+}
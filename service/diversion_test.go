@@ -0,0 +1,114 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// testDiversionPlanner - сеть из двух путей к порту: короткий через лед по прямой (w0-a-port)
+// и длинный в обход льда (w0-b-port); на подходе к порту ограничение глубины 12 м
+func testDiversionPlanner() *DiversionPlanner {
+	ng := NewNavigationGraph()
+	nodes := []*NavNode{
+		{ID: "w0", Point: models.Point{Lat: 69, Lon: 40}, Type: "waypoint"},
+		{ID: "a", Point: models.Point{Lat: 69, Lon: 43}, Type: "waypoint"},
+		{ID: "b", Point: models.Point{Lat: 69.6, Lon: 43}, Type: "waypoint"},
+		{ID: "w1", Point: models.Point{Lat: 69, Lon: 45.9}, Type: "waypoint"},
+		{ID: "port", Point: models.Point{Lat: 69, Lon: 46}, Type: HavenPort},
+	}
+	for _, n := range nodes {
+		ng.AddNode(n)
+	}
+	ice := EdgeLimits{IceRequirement: IceRequirement{IceClass: "Arc4", IceMonths: []int{1}}}
+	links := []struct {
+		from, to string
+		limits   EdgeLimits
+	}{
+		{"w0", "a", ice},
+		{"a", "w1", ice},
+		{"w0", "b", EdgeLimits{}},
+		{"b", "w1", EdgeLimits{}},
+		{"w1", "port", EdgeLimits{Depth: 12}},
+	}
+	for _, l := range links {
+		ng.AddEdge(l.from, l.to, 1)
+		ng.AddEdge(l.to, l.from, 1)
+		for _, pair := range [][2]string{{l.from, l.to}, {l.to, l.from}} {
+			for _, e := range ng.edges[pair[0]] {
+				if e.To == pair[1] {
+					e.Limits = l.limits
+				}
+			}
+		}
+	}
+
+	router := &MarineRouter{landDetector: NewLandDetector(60, 90, -180, 180), navGraph: ng, geo: &GeoUtils{}}
+	havens := []Haven{{ID: "port", Name: "Port", Kind: HavenPort, Point: nodes[4].Point}}
+	cfg := DefaultDiversionConfig()
+	cfg.ConnectRadius = 10000
+	return NewDiversionPlanner(router, havens, nil, cfg)
+}
+
+func TestDiversionPlan(t *testing.T) {
+	planner := testDiversionPlanner()
+	winter := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		req     DiversionRequest
+		via     string // Узел, через который должен пройти путь
+		safe    bool
+		message string
+	}{
+		{
+			name: "fastest avoids slow ice",
+			req:  DiversionRequest{From: models.Point{Lat: 69, Lon: 40.05}, At: winter, Profile: models.VesselProfile{Draught: 8, IceClass: "Arc5"}},
+			via:  "b", safe: true,
+		},
+		{
+			name: "rank by distance searches the shortest path",
+			req:  DiversionRequest{From: models.Point{Lat: 69, Lon: 40.05}, At: winter, Profile: models.VesselProfile{Draught: 8, IceClass: "Arc5"}, RankBy: "distance"},
+			via:  "a", safe: true,
+		},
+		{
+			name: "unknown draught on a depth-limited approach",
+			req:  DiversionRequest{From: models.Point{Lat: 69, Lon: 40.05}, At: winter, Profile: models.VesselProfile{IceClass: "Arc5"}},
+			via:  "b", safe: false, message: "Осадка неизвестна",
+		},
+		{
+			name: "position far from the network",
+			req:  DiversionRequest{From: models.Point{Lat: 71, Lon: 40}, At: winter, Profile: models.VesselProfile{Draught: 8, IceClass: "Arc5"}},
+			via:  "b", safe: false, message: "вне сети",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planner.Plan(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(plan.Options) != 1 {
+				t.Fatalf("got %d options, unreachable %+v", len(plan.Options), plan.Unreachable)
+			}
+			route := plan.Options[0].Route
+			found := false
+			for _, p := range route.Points {
+				if p == planner.router.navGraph.nodes[tt.via].Point {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("route %v does not pass %s", route.Points, tt.via)
+			}
+			if route.IsSafe != tt.safe {
+				t.Errorf("is_safe = %v (%s), want %v", route.IsSafe, route.Message, tt.safe)
+			}
+			if tt.message != "" && !strings.Contains(route.Message, tt.message) {
+				t.Errorf("message %q does not mention %q", route.Message, tt.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,506 @@
+package service
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// ==============================
+// ДРЕЙФ АЙСБЕРГОВ
+// ==============================
+
+// ErrInvalidGrid - поле ветра или течения задано неверно
+var ErrInvalidGrid = errors.New("invalid flow grid")
+
+// Поля, по которым рассчитывается дрейф
+const (
+	GridWind    = "wind"    // Ветер на 10 м
+	GridCurrent = "current" // Поверхностное течение
+)
+
+// FlowGrid - поле скорости (ветра или течения) на сетке широта/долгота, м/с.
+// Шаг сетки может быть неравномерным; поле без времени считается постоянным.
+type FlowGrid struct {
+	times []time.Time
+	lats  []float64
+	lons  []float64
+	u, v  [][]float64 // [время][широта*len(lons)+долгота]; NaN - нет данных
+}
+
+// FlowGridInfo - охват загруженного поля
+type FlowGridInfo struct {
+	Kind   string      `json:"kind"`
+	Points int         `json:"points"` // Узлов сетки на один срок
+	Times  int         `json:"times"`
+	From   *time.Time  `json:"from,omitempty"`
+	To     *time.Time  `json:"to,omitempty"`
+	BBox   models.BBox `json:"bbox"`
+}
+
+// Info описывает охват поля
+func (g *FlowGrid) Info(kind string) FlowGridInfo {
+	info := FlowGridInfo{
+		Kind:   kind,
+		Points: len(g.lats) * len(g.lons),
+		Times:  len(g.u),
+		BBox:   models.BBox{South: g.lats[0], West: g.lons[0], North: g.lats[len(g.lats)-1], East: g.lons[len(g.lons)-1]},
+	}
+	if len(g.times) > 0 {
+		from, to := g.times[0], g.times[len(g.times)-1]
+		info.From, info.To = &from, &to
+	}
+	return info
+}
+
+// At возвращает скорость в точке p на момент t: билинейно по узлам сетки и линейно
+// между сроками. Вне сроков берется ближайший срок; вне сетки ok = false.
+func (g *FlowGrid) At(p models.Point, t time.Time) (u, v float64, ok bool) {
+	i, fi, ok := bracket(g.lats, p.Lat)
+	if !ok {
+		return 0, 0, false
+	}
+	j, fj, ok := bracket(g.lons, p.Lon)
+	if !ok {
+		return 0, 0, false
+	}
+
+	k, fk := 0, 0.0
+	if len(g.times) > 1 {
+		k = sort.Search(len(g.times), func(n int) bool { return g.times[n].After(t) }) - 1
+		switch {
+		case k < 0:
+			k = 0
+		case k >= len(g.times)-1:
+			k = len(g.times) - 1
+		default:
+			fk = t.Sub(g.times[k]).Seconds() / g.times[k+1].Sub(g.times[k]).Seconds()
+		}
+	}
+
+	u0, v0, ok0 := g.interpolate(k, i, j, fi, fj)
+	if fk == 0 {
+		return u0, v0, ok0
+	}
+	u1, v1, ok1 := g.interpolate(k+1, i, j, fi, fj)
+	switch {
+	case ok0 && ok1:
+		return u0 + (u1-u0)*fk, v0 + (v1-v0)*fk, true
+	case ok0:
+		return u0, v0, true
+	default:
+		return u1, v1, ok1
+	}
+}
+
+// interpolate - билинейная интерполяция в ячейке (i, j) срока k; узлы без данных пропускаются
+func (g *FlowGrid) interpolate(k, i, j int, fi, fj float64) (u, v float64, ok bool) {
+	var sum float64
+	for di := 0; di <= 1; di++ {
+		for dj := 0; dj <= 1; dj++ {
+			wi, wj := 1-fi, 1-fj
+			if di == 1 {
+				wi = fi
+			}
+			if dj == 1 {
+				wj = fj
+			}
+			ii, jj := min(i+di, len(g.lats)-1), min(j+dj, len(g.lons)-1)
+			n := ii*len(g.lons) + jj
+			if w := wi * wj; w > 0 && !math.IsNaN(g.u[k][n]) {
+				u += g.u[k][n] * w
+				v += g.v[k][n] * w
+				sum += w
+			}
+		}
+	}
+	if sum == 0 {
+		return 0, 0, false
+	}
+	return u / sum, v / sum, true
+}
+
+// bracket находит ячейку оси axis, в которую попадает x, и долю пути внутри нее
+func bracket(axis []float64, x float64) (int, float64, bool) {
+	if x < axis[0] || x > axis[len(axis)-1] {
+		return 0, 0, false
+	}
+	if len(axis) == 1 {
+		return 0, 0, true
+	}
+	i := sort.SearchFloat64s(axis, x)
+	if i > 0 && (i == len(axis) || axis[i] > x) {
+		i--
+	}
+	if i == len(axis)-1 {
+		return i, 0, true
+	}
+	return i, (x - axis[i]) / (axis[i+1] - axis[i]), true
+}
+
+// flowColumns - названия колонок в выгрузках моделей ветра и течений
+var flowColumns = map[string]string{
+	"time": "time", "datetime": "time", "valid_time": "time",
+	"lat": "lat", "latitude": "lat",
+	"lon": "lon", "lng": "lon", "longitude": "lon",
+	"u": "u", "u10": "u", "uwnd": "u", "uo": "u", "water_u": "u", "eastward": "u",
+	"v": "v", "v10": "v", "vwnd": "v", "vo": "v", "water_v": "v", "northward": "v",
+}
+
+// ReadFlowGrid читает поле скорости из CSV-файла
+func ReadFlowGrid(path string) (*FlowGrid, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	g, err := ParseFlowGridCSV(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return g, nil
+}
+
+// ParseFlowGridCSV читает поле скорости из CSV с колонками [time,] lat, lon, u, v
+// (u - к востоку, v - к северу, м/с). Строки с "#" - комментарии.
+func ParseFlowGridCSV(r io.Reader) (*FlowGrid, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, h := range header {
+		if name, ok := flowColumns[strings.ToLower(strings.TrimSpace(h))]; ok {
+			columns[name] = i
+		}
+	}
+	for _, required := range []string{"lat", "lon", "u", "v"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: column %s is required", ErrInvalidGrid, required)
+		}
+	}
+
+	type sample struct {
+		t        time.Time
+		lat, lon float64
+		u, v     float64
+		hasTime  bool
+		line     int
+	}
+	var samples []sample
+	times := make(map[time.Time]bool)
+	lats := make(map[float64]bool)
+	lons := make(map[float64]bool)
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		s := sample{line: line}
+		numbers := []struct {
+			name string
+			dst  *float64
+		}{{"lat", &s.lat}, {"lon", &s.lon}, {"u", &s.u}, {"v", &s.v}}
+		for _, n := range numbers {
+			if *n.dst, err = strconv.ParseFloat(field(n.name), 64); err != nil {
+				return nil, fmt.Errorf("line %d: %w: %s %q", line, ErrInvalidGrid, n.name, field(n.name))
+			}
+		}
+		if s.lat < -90 || s.lat > 90 || s.lon < -180 || s.lon > 180 {
+			return nil, fmt.Errorf("line %d: %w: position out of range", line, ErrInvalidGrid)
+		}
+		if v := field("time"); v != "" {
+			if s.t, err = parseIcebergTime(v); err != nil {
+				return nil, fmt.Errorf("line %d: %w: time %q", line, ErrInvalidGrid, v)
+			}
+			s.t, s.hasTime = s.t.UTC(), true
+			times[s.t] = true
+		}
+		lats[s.lat], lons[s.lon] = true, true
+		samples = append(samples, s)
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("%w: no grid points", ErrInvalidGrid)
+	}
+
+	g := &FlowGrid{lats: sortedKeys(lats), lons: sortedKeys(lons)}
+	for t := range times {
+		g.times = append(g.times, t)
+	}
+	sort.Slice(g.times, func(i, j int) bool { return g.times[i].Before(g.times[j]) })
+	steps := max(len(g.times), 1)
+	size := len(g.lats) * len(g.lons)
+	g.u, g.v = make([][]float64, steps), make([][]float64, steps)
+	for k := range g.u {
+		g.u[k], g.v[k] = make([]float64, size), make([]float64, size)
+		for n := range g.u[k] {
+			g.u[k][n], g.v[k][n] = math.NaN(), math.NaN()
+		}
+	}
+	for _, s := range samples {
+		k := 0
+		if len(g.times) > 0 {
+			if !s.hasTime {
+				return nil, fmt.Errorf("line %d: %w: time is required when other rows have it", s.line, ErrInvalidGrid)
+			}
+			k = sort.Search(len(g.times), func(n int) bool { return !g.times[n].Before(s.t) })
+		}
+		n := sort.SearchFloat64s(g.lats, s.lat)*len(g.lons) + sort.SearchFloat64s(g.lons, s.lon)
+		g.u[k][n], g.v[k][n] = s.u, s.v
+	}
+	return g, nil
+}
+
+// sortedKeys возвращает значения множества по возрастанию
+func sortedKeys(set map[float64]bool) []float64 {
+	keys := make([]float64, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Float64s(keys)
+	return keys
+}
+
+// DriftConfig - параметры модели дрейфа
+type DriftConfig struct {
+	WindFactor    float64       // Доля скорости ветра, с которой ветер сносит айсберг
+	Step          time.Duration // Шаг интегрирования
+	FixInterval   time.Duration // Шаг прогнозной траектории опасности
+	MaxTrack      time.Duration // Наибольшая длина траектории после наблюдения
+	MaxSpeed      float64       // Ограничение скорости дрейфа, м/с
+	InitialError  float64       // Ошибка места наблюдения, м
+	VelocityError float64       // Ошибка скорости дрейфа по полям, м/с
+	NoDataError   float64       // Ошибка скорости вне полей ветра и течения, м/с
+	NoDataMax     float64       // Наибольшая ошибка, накопленная вне полей, м
+	DriftError    float64       // Дополнительная ошибка вдоль дрейфа, доля пройденного
+}
+
+// DefaultDriftConfig - эмпирический снос ветром 2%, шаг 10 минут, траектория до 10 суток
+func DefaultDriftConfig() DriftConfig {
+	return DriftConfig{
+		WindFactor:    0.02,
+		Step:          10 * time.Minute,
+		FixInterval:   time.Hour,
+		MaxTrack:      10 * 24 * time.Hour,
+		MaxSpeed:      2,
+		InitialError:  1 * metersPerNM,
+		VelocityError: 0.05,
+		NoDataError:   0.2,
+		NoDataMax:     5 * metersPerNM,
+		DriftError:    0.25,
+	}
+}
+
+// DriftEllipse - эллипс неопределенности прогнозного положения
+type DriftEllipse struct {
+	Major       float64 `json:"major"`       // Большая полуось, м
+	Minor       float64 `json:"minor"`       // Малая полуось, м
+	Orientation float64 `json:"orientation"` // Азимут большой полуоси (вдоль дрейфа), градусы
+}
+
+// Polygon возвращает эллипс с центром center как многоугольник из 36 точек ([lon, lat])
+func (e DriftEllipse) Polygon(center models.Point) orb.Polygon {
+	geo := &GeoUtils{}
+	ring := make(orb.Ring, 0, 37)
+	for i := 0; i < 36; i++ {
+		theta := float64(i) * 10 * math.Pi / 180
+		x, y := e.Major*math.Cos(theta), e.Minor*math.Sin(theta)
+		p := geo.Destination(center, e.Orientation+math.Atan2(y, x)*180/math.Pi, math.Hypot(x, y))
+		ring = append(ring, orb.Point{p.Lon, p.Lat})
+	}
+	ring = append(ring, ring[0])
+	return orb.Polygon{ring}
+}
+
+// DriftFix - прогнозное положение айсберга
+type DriftFix struct {
+	At      time.Time    `json:"at"`
+	Point   models.Point `json:"point"`
+	Speed   float64      `json:"speed"`             // Скорость дрейфа, м/с
+	Heading float64      `json:"heading"`           // Направление дрейфа, градусы
+	Drifted float64      `json:"drifted"`           // Пройдено от места наблюдения, м
+	Ellipse DriftEllipse `json:"ellipse"`           // Неопределенность положения
+	NoData  bool         `json:"no_data,omitempty"` // Нет ни ветра, ни течения: айсберг считается неподвижным
+}
+
+// IcebergForecast - прогноз дрейфа наблюдавшегося айсберга
+type IcebergForecast struct {
+	Iceberg models.IcebergObservation `json:"iceberg"`
+	Radius  float64                   `json:"radius"` // Радиус опасной зоны вокруг айсберга, м
+	Fixes   []DriftFix                `json:"fixes"`
+}
+
+// DriftModel - прогноз дрейфа айсбергов по полям ветра и течения
+type DriftModel struct {
+	cfg DriftConfig
+	geo *GeoUtils
+
+	mu    sync.RWMutex
+	grids map[string]*FlowGrid
+}
+
+// NewDriftModel создает модель без полей: пока поля не загружены, айсберги считаются неподвижными
+func NewDriftModel(cfg DriftConfig) *DriftModel {
+	return &DriftModel{cfg: cfg, geo: &GeoUtils{}, grids: make(map[string]*FlowGrid)}
+}
+
+// SetGrid заменяет поле ветра или течения
+func (m *DriftModel) SetGrid(kind string, g *FlowGrid) error {
+	if kind != GridWind && kind != GridCurrent {
+		return fmt.Errorf("%w: kind must be %s or %s", ErrInvalidGrid, GridWind, GridCurrent)
+	}
+	m.mu.Lock()
+	m.grids[kind] = g
+	m.mu.Unlock()
+	return nil
+}
+
+// Grids описывает загруженные поля
+func (m *DriftModel) Grids() []FlowGridInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make([]FlowGridInfo, 0, len(m.grids))
+	for _, kind := range []string{GridWind, GridCurrent} {
+		if g, ok := m.grids[kind]; ok {
+			result = append(result, g.Info(kind))
+		}
+	}
+	return result
+}
+
+// velocity - скорость дрейфа в точке: течение плюс снос ветром, м/с. ok = false - нет ни одного поля.
+func (m *DriftModel) velocity(wind, current *FlowGrid, p models.Point, t time.Time) (u, v float64, ok bool) {
+	if current != nil {
+		if cu, cv, found := current.At(p, t); found {
+			u, v, ok = cu, cv, true
+		}
+	}
+	if wind != nil {
+		if wu, wv, found := wind.At(p, t); found {
+			u += m.cfg.WindFactor * wu
+			v += m.cfg.WindFactor * wv
+			ok = true
+		}
+	}
+	if speed := math.Hypot(u, v); speed > m.cfg.MaxSpeed {
+		u, v = u*m.cfg.MaxSpeed/speed, v*m.cfg.MaxSpeed/speed
+	}
+	return u, v, ok
+}
+
+// Forecast сдвигает айсберг от места наблюдения по полям ветра и течения и возвращает
+// его положения на моменты times (по возрастанию); моменты раньше наблюдения пропускаются
+func (m *DriftModel) Forecast(o models.IcebergObservation, times []time.Time) []DriftFix {
+	m.mu.RLock()
+	wind, current := m.grids[GridWind], m.grids[GridCurrent]
+	m.mu.RUnlock()
+
+	origin := o.Point()
+	fix := DriftFix{At: o.ObservedAt, Point: origin}
+	growth := 0.0 // Накопленная ошибка скорости по полям, м
+	noData := 0.0 // Накопленная ошибка вне полей, м; не больше NoDataMax
+	fixes := make([]DriftFix, 0, len(times))
+	for _, target := range times {
+		if target.Before(o.ObservedAt) {
+			continue
+		}
+		for fix.At.Before(target) {
+			dt := min(m.cfg.Step, target.Sub(fix.At))
+			u, v, ok := m.velocity(wind, current, fix.Point, fix.At)
+			if distance := math.Hypot(u, v) * dt.Seconds(); distance > 0 {
+				fix.Point = m.geo.Destination(fix.Point, heading(u, v), distance)
+				fix.Drifted += distance
+			}
+			if ok {
+				growth += m.cfg.VelocityError * dt.Seconds()
+			} else {
+				noData = min(noData+m.cfg.NoDataError*dt.Seconds(), m.cfg.NoDataMax)
+			}
+			fix.At = fix.At.Add(dt)
+		}
+		u, v, ok := m.velocity(wind, current, fix.Point, fix.At)
+		fix.Speed, fix.Heading, fix.NoData = math.Hypot(u, v), heading(u, v), !ok
+
+		minor := math.Hypot(m.cfg.InitialError, growth+noData)
+		fix.Ellipse = DriftEllipse{
+			Major: math.Hypot(minor, m.cfg.DriftError*fix.Drifted),
+			Minor: minor,
+		}
+		if fix.Drifted > 0 {
+			fix.Ellipse.Orientation = m.geo.Bearing(origin, fix.Point)
+		}
+		fix.Point.Name = o.ID
+		fixes = append(fixes, fix)
+	}
+	return fixes
+}
+
+// heading - направление движения со скоростью (u, v), градусы от севера
+func heading(u, v float64) float64 {
+	return math.Mod(math.Atan2(u, v)*180/math.Pi+360, 360)
+}
+
+// ForecastTimes возвращает моменты from, from+step, ... до from+hours включительно;
+// при неположительном шаге - nil
+func ForecastTimes(from time.Time, hours float64, step time.Duration) []time.Time {
+	if step <= 0 {
+		return nil
+	}
+	until := from.Add(time.Duration(hours * float64(time.Hour)))
+	var times []time.Time
+	for t := from; !t.After(until); t = t.Add(step) {
+		times = append(times, t)
+	}
+	return times
+}
+
+// Track возвращает прогнозную траекторию айсберга как положения опасности с радиусом radius,
+// увеличенным на большую полуось эллипса неопределенности, от наблюдения до истечения.
+// Пока айсберг не сдвинулся и полей в его месте нет, радиус остается radius, как без прогноза.
+func (m *DriftModel) Track(o models.IcebergObservation, radius float64) []HazardFix {
+	until := o.ExpiresAt
+	if limit := o.ObservedAt.Add(m.cfg.MaxTrack); until.After(limit) {
+		until = limit
+	}
+	times := ForecastTimes(o.ObservedAt, until.Sub(o.ObservedAt).Hours(), m.cfg.FixInterval)
+	if n := len(times); n == 0 || times[n-1].Before(until) {
+		times = append(times, until)
+	}
+
+	fixes := m.Forecast(o, times)
+	track := make([]HazardFix, len(fixes))
+	for i, f := range fixes {
+		track[i] = HazardFix{At: f.At, Point: f.Point, Radius: radius + f.Ellipse.Major, NoData: f.NoData}
+		if f.NoData && f.Drifted == 0 {
+			track[i].Radius = radius
+		}
+	}
+	return track
+}
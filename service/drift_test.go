@@ -0,0 +1,90 @@
+package service
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// testCurrent - течение 0,1 м/с на восток в Баренцевом море
+const testCurrent = "lat,lon,u,v\n60,20,0.1,0\n60,60,0.1,0\n80,20,0.1,0\n80,60,0.1,0\n"
+
+func TestDriftWithoutData(t *testing.T) {
+	cfg := DefaultDriftConfig()
+	observed := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	const radius = 500.0
+
+	tests := []struct {
+		name     string
+		current  string // Пусто - поле течения не загружено
+		lat, lon float64
+		noData   bool
+		maxMajor float64 // Наибольшая большая полуось эллипса через 72 ч, м
+	}{
+		{"no grids", "", 72, 40, true, math.Hypot(cfg.InitialError, cfg.NoDataMax)},
+		{"outside the grid", testCurrent, 75, 100, true, math.Hypot(cfg.InitialError, cfg.NoDataMax)},
+		{"inside the grid", testCurrent, 72, 40, false, 2 * (cfg.InitialError + cfg.VelocityError*72*3600 + cfg.DriftError*0.1*72*3600)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewDriftModel(cfg)
+			if tt.current != "" {
+				g, err := ParseFlowGridCSV(strings.NewReader(tt.current))
+				if err != nil {
+					t.Fatal(err)
+				}
+				m.SetGrid(GridCurrent, g)
+			}
+			o := models.IcebergObservation{ID: "berg", Lat: tt.lat, Lon: tt.lon, ObservedAt: observed, ExpiresAt: observed.Add(72 * time.Hour)}
+
+			fixes := m.Forecast(o, []time.Time{o.ExpiresAt})
+			if len(fixes) != 1 {
+				t.Fatalf("got %d fixes, want 1", len(fixes))
+			}
+			if fixes[0].NoData != tt.noData {
+				t.Errorf("no_data = %v, want %v", fixes[0].NoData, tt.noData)
+			}
+			if major := fixes[0].Ellipse.Major; major > tt.maxMajor {
+				t.Errorf("ellipse major %.0f m after 72 h, want at most %.0f m", major, tt.maxMajor)
+			}
+
+			track := m.Track(o, radius)
+			last := track[len(track)-1]
+			if last.NoData != tt.noData {
+				t.Errorf("track no_data = %v, want %v", last.NoData, tt.noData)
+			}
+			switch {
+			case tt.noData && last.Radius != radius:
+				t.Errorf("radius %.0f m without data, want the static %.0f m", last.Radius, radius)
+			case !tt.noData && last.Radius <= radius:
+				t.Errorf("radius %.0f m does not grow with the forecast", last.Radius)
+			}
+		})
+	}
+}
+
+func TestForecastTimes(t *testing.T) {
+	from := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		hours float64
+		step  time.Duration
+		count int
+	}{
+		{"hourly for a day", 24, time.Hour, 25},
+		{"step longer than the forecast", 2, 3 * time.Hour, 1},
+		{"zero step", 24, 0, 0},
+		{"negative step", 24, -time.Hour, 0},
+		{"overflowed step", 24, math.MinInt64, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ForecastTimes(from, tt.hours, tt.step); len(got) != tt.count {
+				t.Errorf("got %d times, want %d", len(got), tt.count)
+			}
+		})
+	}
+}
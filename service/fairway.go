@@ -0,0 +1,213 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// ==============================
+// ПРИВЯЗКА МАРШРУТА К БУЯМ ФАРВАТЕРА
+// ==============================
+
+// fairwayTypes - знаки, обозначающие фарватер: осевые (safe water) и латеральные
+var fairwayTypes = map[string]bool{
+	"buoy_safe_water":   true,
+	"beacon_safe_water": true,
+	"buoy_lateral":      true,
+	"beacon_lateral":    true,
+}
+
+// safeWaterTypes - осевые знаки: их можно проходить вплотную, поворотная точка ставится на сам знак
+var safeWaterTypes = map[string]bool{"buoy_safe_water": true, "beacon_safe_water": true}
+
+// FairwayMark - знак фарватера, через который проводится подход к порту
+type FairwayMark struct {
+	ID       string       `json:"id"` // osm/<тип>/<id>
+	Type     string       `json:"type"`
+	Category string       `json:"category,omitempty"` // Сторона латерального знака: port, starboard
+	Point    models.Point `json:"point"`
+}
+
+// FairwayMarks выбирает из навигационных знаков буи и вехи фарватера
+func FairwayMarks(seamarks []models.Seamark) []FairwayMark {
+	var marks []FairwayMark
+	for _, s := range seamarks {
+		if !fairwayTypes[s.Type] {
+			continue
+		}
+		marks = append(marks, FairwayMark{
+			ID:       fmt.Sprintf("osm/%s/%d", s.OSMType, s.ID),
+			Type:     s.Type,
+			Category: s.Category,
+			Point:    models.Point{Name: s.Name, Lat: s.Lat, Lon: s.Lon},
+		})
+	}
+	return marks
+}
+
+// fairwayGate - точка, через которую маршрут проходит фарватер: осевой знак
+// или середина между парой латеральных знаков левой и правой стороны
+type fairwayGate struct {
+	id    string
+	point models.Point
+}
+
+// fairwayGates строит ворота фарватера. Латеральные знаки объединяются в пары левый-правый
+// не дальше width друг от друга, начиная с ближайших; знак без пары в маршрут не попадает,
+// чтобы судно не вели на сам буй.
+func fairwayGates(marks []FairwayMark, width float64, geo *GeoUtils) []fairwayGate {
+	var gates []fairwayGate
+	var port, starboard []FairwayMark
+	for _, m := range marks {
+		switch {
+		case safeWaterTypes[m.Type]:
+			gates = append(gates, fairwayGate{id: m.ID, point: m.Point})
+		case m.Category == "port":
+			port = append(port, m)
+		case m.Category == "starboard":
+			starboard = append(starboard, m)
+		}
+	}
+
+	type pair struct {
+		port, starboard int
+		dist            float64
+	}
+	var pairs []pair
+	for i, p := range port {
+		for j, s := range starboard {
+			if d := geo.Distance(p.Point, s.Point); d <= width {
+				pairs = append(pairs, pair{i, j, d})
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].dist < pairs[j].dist })
+
+	pairedPort := make(map[int]bool)
+	pairedStarboard := make(map[int]bool)
+	for _, p := range pairs {
+		if pairedPort[p.port] || pairedStarboard[p.starboard] {
+			continue
+		}
+		pairedPort[p.port], pairedStarboard[p.starboard] = true, true
+		a, b := port[p.port], starboard[p.starboard]
+		gates = append(gates, fairwayGate{
+			id:    a.ID + "+" + b.ID,
+			point: models.Point{Lat: (a.Point.Lat + b.Point.Lat) / 2, Lon: (a.Point.Lon + b.Point.Lon) / 2},
+		})
+	}
+	return gates
+}
+
+// FairwayConfig - параметры привязки
+type FairwayConfig struct {
+	Approach float64 // Радиус подхода вокруг порта назначения, в котором работает привязка, м
+	Snap     float64 // Наибольшее смещение поворотной точки к знаку, м
+	Corridor float64 // Полуширина коридора последнего участка, ворота в котором включаются в маршрут, м
+	Width    float64 // Наибольшее расстояние между левым и правым знаками одних ворот, м
+}
+
+// DefaultFairwayConfig - подход 20 миль, смещение до 1 мили, коридор 0.5 мили, ворота до 1 км
+func DefaultFairwayConfig() FairwayConfig {
+	return FairwayConfig{Approach: 20 * 1852, Snap: 1852, Corridor: 926, Width: 1000}
+}
+
+// Fairway - знаки фарватеров, к которым привязываются подходы маршрутов к портам
+type Fairway struct {
+	cfg FairwayConfig
+	geo *GeoUtils
+
+	mu    sync.RWMutex
+	marks []FairwayMark
+	gates []fairwayGate
+}
+
+// NewFairway создает пустой набор знаков
+func NewFairway(cfg FairwayConfig) *Fairway {
+	return &Fairway{cfg: cfg, geo: &GeoUtils{}}
+}
+
+// SetMarks заменяет знаки фарватеров и строит по ним ворота
+func (f *Fairway) SetMarks(marks []FairwayMark) {
+	gates := fairwayGates(marks, f.cfg.Width, f.geo)
+	f.mu.Lock()
+	f.marks, f.gates = marks, gates
+	f.mu.Unlock()
+}
+
+// Marks возвращает число знаков
+func (f *Fairway) Marks() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.marks)
+}
+
+// SnapApproach проводит подход к последней точке маршрута (порту) через ворота фарватера:
+// поворотные точки в зоне подхода переносятся к ближайшим воротам в пределах Snap,
+// а ворота в коридоре последнего участка добавляются в маршрут по порядку следования.
+// Латеральные знаки остаются по своим сторонам: маршрут идет по середине между ними.
+func (f *Fairway) SnapApproach(points []models.Point) []models.Point {
+	if len(points) < 2 {
+		return points
+	}
+	port := points[len(points)-1]
+
+	f.mu.RLock()
+	var near []fairwayGate
+	for _, g := range f.gates {
+		if f.geo.Distance(g.point, port) <= f.cfg.Approach {
+			near = append(near, g)
+		}
+	}
+	f.mu.RUnlock()
+	if len(near) == 0 {
+		return points
+	}
+
+	used := make(map[string]bool)
+	out := append([]models.Point(nil), points...)
+	for i := 1; i < len(out)-1; i++ {
+		if f.geo.Distance(out[i], port) > f.cfg.Approach {
+			continue
+		}
+		best, bestDist := -1, f.cfg.Snap
+		for j, g := range near {
+			if d := f.geo.Distance(out[i], g.point); !used[g.id] && d <= bestDist {
+				best, bestDist = j, d
+			}
+		}
+		if best >= 0 {
+			used[near[best].id] = true
+			out[i] = near[best].point
+		}
+	}
+
+	// Ворота вдоль последнего участка в порядке удаления от его начала
+	from := out[len(out)-2]
+	legLength := f.geo.Distance(from, port)
+	type onLeg struct {
+		gate  fairwayGate
+		along float64
+	}
+	var leg []onLeg
+	for _, g := range near {
+		if used[g.id] {
+			continue
+		}
+		xte, along := f.geo.CrossTrack(g.point, from, port)
+		if along > 0 && along < legLength && math.Abs(xte) <= f.cfg.Corridor {
+			leg = append(leg, onLeg{g, along})
+		}
+	}
+	sort.Slice(leg, func(i, j int) bool { return leg[i].along < leg[j].along })
+
+	snapped := append([]models.Point(nil), out[:len(out)-1]...)
+	for _, l := range leg {
+		snapped = append(snapped, l.gate.point)
+	}
+	return append(snapped, port)
+}
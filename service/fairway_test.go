@@ -0,0 +1,70 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+func TestFairwaySnapApproach(t *testing.T) {
+	// Подход к порту с запада по параллели 69°; фарватер шириной около 400 м
+	port := models.Point{Lat: 69, Lon: 33.5}
+	route := []models.Point{{Lat: 69, Lon: 32}, {Lat: 69.001, Lon: 33.2}, port}
+	lateral := func(id, side string, lat, lon float64) FairwayMark {
+		return FairwayMark{ID: id, Type: "buoy_lateral", Category: side, Point: models.Point{Lat: lat, Lon: lon}}
+	}
+
+	tests := []struct {
+		name  string
+		marks []FairwayMark
+		want  []models.Point
+	}{
+		{
+			name:  "lone lateral buoy is not a waypoint",
+			marks: []FairwayMark{lateral("p1", "port", 69.002, 33.2)},
+			want:  route,
+		},
+		{
+			name:  "waypoint moves to the middle of a gate",
+			marks: []FairwayMark{lateral("p1", "port", 69.002, 33.2), lateral("s1", "starboard", 68.998, 33.2)},
+			want:  []models.Point{route[0], {Lat: 69, Lon: 33.2}, port},
+		},
+		{
+			name:  "safe water mark is passed over",
+			marks: []FairwayMark{{ID: "sw", Type: "buoy_safe_water", Point: models.Point{Lat: 69.0005, Lon: 33.21}}},
+			want:  []models.Point{route[0], {Lat: 69.0005, Lon: 33.21}, port},
+		},
+		{
+			name: "gates on the final leg are added in order",
+			marks: []FairwayMark{
+				lateral("p2", "port", 69.0025, 33.4), lateral("s2", "starboard", 68.9985, 33.4),
+				lateral("p3", "port", 69.002, 33.3), lateral("s3", "starboard", 68.998, 33.3),
+				lateral("s4", "starboard", 68.99, 33.45), // Слишком далеко от левого знака для ворот
+			},
+			want: []models.Point{route[0], route[1], {Lat: 69, Lon: 33.3}, {Lat: 69.0005, Lon: 33.4}, port},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFairway(DefaultFairwayConfig())
+			f.SetMarks(tt.marks)
+			got := f.SnapApproach(route)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if math.Abs(got[i].Lat-tt.want[i].Lat) > 1e-9 || math.Abs(got[i].Lon-tt.want[i].Lon) > 1e-9 {
+					t.Errorf("point %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+			for _, p := range got {
+				for _, m := range tt.marks {
+					if m.Type == "buoy_lateral" && p.Lat == m.Point.Lat && p.Lon == m.Point.Lon {
+						t.Errorf("route steers onto lateral mark %s", m.ID)
+					}
+				}
+			}
+		})
+	}
+}
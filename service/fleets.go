@@ -0,0 +1,279 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// ==============================
+// ФЛОТЫ
+// ==============================
+
+// Статусы судна во флоте
+const (
+	FleetUnderway = "underway"  // На ходу
+	FleetAtAnchor = "at_anchor" // На якоре или стоит вне причала
+	FleetMoored   = "moored"    // У причала
+	FleetNotSeen  = "not_seen"  // Нет позиций дольше порога
+)
+
+var (
+	ErrFleetNotFound = errors.New("fleet not found")
+	ErrInvalidFleet  = errors.New("invalid fleet")
+)
+
+// FleetVesselStatus - состояние судна флота
+type FleetVesselStatus struct {
+	models.FleetVessel
+	Name     string       `json:"name,omitempty"`
+	Status   string       `json:"status"`
+	LastSeen *time.Time   `json:"last_seen,omitempty"` // Время последней позиции
+	Position *models.Ship `json:"position,omitempty"`  // Последняя позиция
+}
+
+// FleetSummary - сводка по флоту: число судов в каждом статусе
+type FleetSummary struct {
+	Total    int `json:"total"`
+	Underway int `json:"underway"`
+	AtAnchor int `json:"at_anchor"`
+	Moored   int `json:"moored"`
+	NotSeen  int `json:"not_seen"`
+}
+
+// FleetStatus - флот с состоянием судов
+type FleetStatus struct {
+	models.Fleet
+	Summary FleetSummary        `json:"summary"`
+	Status  []FleetVesselStatus `json:"status"`
+}
+
+// FleetStore - флоты, сохраняемые в JSON-файл
+type FleetStore struct {
+	path string
+
+	mu     sync.RWMutex
+	fleets map[string]models.Fleet
+}
+
+// NewFleetStore загружает флоты из файла path (если он есть)
+func NewFleetStore(path string) (*FleetStore, error) {
+	var fleets []models.Fleet
+	if err := loadJSON(path, &fleets); err != nil {
+		return nil, fmt.Errorf("load fleets: %w", err)
+	}
+
+	s := &FleetStore{path: path, fleets: make(map[string]models.Fleet)}
+	for _, f := range fleets {
+		s.fleets[f.ID] = f
+	}
+	return s, nil
+}
+
+// List возвращает флоты, упорядоченные по названию
+func (s *FleetStore) List() []models.Fleet {
+	s.mu.RLock()
+	fleets := make([]models.Fleet, 0, len(s.fleets))
+	for _, f := range s.fleets {
+		fleets = append(fleets, f)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(fleets, func(i, j int) bool { return fleets[i].Name < fleets[j].Name })
+	return fleets
+}
+
+// Get возвращает флот по ID
+func (s *FleetStore) Get(id string) (models.Fleet, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f, ok := s.fleets[id]
+	return f, ok
+}
+
+// Members возвращает множество MMSI флота
+func (s *FleetStore) Members(id string) (map[int32]bool, bool) {
+	f, ok := s.Get(id)
+	if !ok {
+		return nil, false
+	}
+	members := make(map[int32]bool, len(f.Vessels))
+	for _, v := range f.Vessels {
+		members[v.MMSI] = true
+	}
+	return members, true
+}
+
+// Includes сообщает, входит ли во флот хотя бы одно из судов; второй результат -
+// существует ли флот. Состав читается на момент вызова, поэтому правки флота
+// сразу видны подписчикам потока.
+func (s *FleetStore) Includes(id string, mmsis []int32) (bool, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f, ok := s.fleets[id]
+	if !ok {
+		return false, false
+	}
+	for _, v := range f.Vessels {
+		if slices.Contains(mmsis, v.MMSI) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// Create сохраняет новый флот и присваивает ему ID
+func (s *FleetStore) Create(f models.Fleet) (models.Fleet, error) {
+	if err := normalizeFleet(&f); err != nil {
+		return models.Fleet{}, err
+	}
+	id, err := newID()
+	if err != nil {
+		return models.Fleet{}, err
+	}
+	f.ID = id
+	f.CreatedAt = time.Now().UTC()
+	f.UpdatedAt = f.CreatedAt
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fleets[f.ID] = f
+	if err := s.save(); err != nil {
+		delete(s.fleets, f.ID)
+		return models.Fleet{}, err
+	}
+	return f, nil
+}
+
+// Update заменяет название, описание и состав флота
+func (s *FleetStore) Update(id string, f models.Fleet) (models.Fleet, error) {
+	if err := normalizeFleet(&f); err != nil {
+		return models.Fleet{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.fleets[id]
+	if !ok {
+		return models.Fleet{}, ErrFleetNotFound
+	}
+	f.ID = id
+	f.CreatedAt = prev.CreatedAt
+	f.UpdatedAt = time.Now().UTC()
+
+	s.fleets[id] = f
+	if err := s.save(); err != nil {
+		s.fleets[id] = prev
+		return models.Fleet{}, err
+	}
+	return f, nil
+}
+
+// Delete удаляет флот
+func (s *FleetStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.fleets[id]
+	if !ok {
+		return ErrFleetNotFound
+	}
+
+	delete(s.fleets, id)
+	if err := s.save(); err != nil {
+		s.fleets[id] = prev
+		return err
+	}
+	return nil
+}
+
+// save записывает флоты на диск; вызывается под блокировкой
+func (s *FleetStore) save() error {
+	fleets := make([]models.Fleet, 0, len(s.fleets))
+	for _, f := range s.fleets {
+		fleets = append(fleets, f)
+	}
+	sort.Slice(fleets, func(i, j int) bool { return fleets[i].ID < fleets[j].ID })
+	return saveJSON(s.path, fleets)
+}
+
+// normalizeFleet проверяет флот и убирает повторы MMSI
+func normalizeFleet(f *models.Fleet) error {
+	f.Name = strings.TrimSpace(f.Name)
+	if f.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidFleet)
+	}
+
+	seen := make(map[int32]bool, len(f.Vessels))
+	vessels := make([]models.FleetVessel, 0, len(f.Vessels))
+	for _, v := range f.Vessels {
+		if v.MMSI < 100000000 || v.MMSI > 999999999 {
+			return fmt.Errorf("%w: %v", ErrInvalidFleet, ErrInvalidMMSI)
+		}
+		if seen[v.MMSI] {
+			continue
+		}
+		seen[v.MMSI] = true
+		v.Label = strings.TrimSpace(v.Label)
+		vessels = append(vessels, v)
+	}
+	f.Vessels = vessels
+	return nil
+}
+
+// vesselStatus определяет состояние судна по последней позиции.
+// Стоящее судно без статуса "moored" считается стоящим на якоре.
+func vesselStatus(ship models.Ship, ok bool, now time.Time, notSeen time.Duration) string {
+	switch {
+	case !ok || now.Sub(ship.Timestamp) > notSeen:
+		return FleetNotSeen
+	case ship.NavStatus == navStatusMoored:
+		return FleetMoored
+	case ship.NavStatus == navStatusAtAnchor:
+		return FleetAtAnchor
+	case ship.HasMotion() && ship.SOG <= DefaultVoyageConfig().StopSpeed:
+		return FleetAtAnchor
+	default:
+		return FleetUnderway
+	}
+}
+
+// Status собирает состояние судов флота на момент now.
+// Суда без позиций дольше notSeen получают статус not_seen.
+func (s *FleetStore) Status(f models.Fleet, ships *ShipStore, now time.Time, notSeen time.Duration) FleetStatus {
+	status := FleetStatus{Fleet: f, Status: make([]FleetVesselStatus, 0, len(f.Vessels))}
+
+	for _, v := range f.Vessels {
+		ship, ok := ships.Get(v.MMSI)
+		vs := FleetVesselStatus{
+			FleetVessel: v,
+			Status:      vesselStatus(ship, ok, now, notSeen),
+		}
+		if ok {
+			vs.Name = ship.Name
+			vs.LastSeen = &ship.Timestamp
+			vs.Position = &ship
+		} else if static, found := ships.Static(v.MMSI); found {
+			vs.Name = static.Name
+		}
+
+		switch vs.Status {
+		case FleetUnderway:
+			status.Summary.Underway++
+		case FleetAtAnchor:
+			status.Summary.AtAnchor++
+		case FleetMoored:
+			status.Summary.Moored++
+		case FleetNotSeen:
+			status.Summary.NotSeen++
+		}
+		status.Status = append(status.Status, vs)
+	}
+	status.Summary.Total = len(f.Vessels)
+	return status
+}
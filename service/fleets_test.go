@@ -0,0 +1,43 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+func TestFleetIncludesFollowsEdits(t *testing.T) {
+	store, err := NewFleetStore(filepath.Join(t.TempDir(), "fleets.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fleet, err := store.Create(models.Fleet{Name: "Own", Vessels: []models.FleetVessel{{MMSI: 273000101}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(step string, mmsis []int32, wantMember, wantFound bool) {
+		t.Helper()
+		member, found := store.Includes(fleet.ID, mmsis)
+		if member != wantMember || found != wantFound {
+			t.Errorf("%s: Includes(%v) = %v, %v; want %v, %v", step, mmsis, member, found, wantMember, wantFound)
+		}
+	}
+
+	check("member", []int32{273000101}, true, true)
+	check("collision pair with a member", []int32{273000999, 273000101}, true, true)
+	check("outsider", []int32{273000102}, false, true)
+	check("no vessels in event", nil, false, true)
+
+	fleet.Vessels = append(fleet.Vessels, models.FleetVessel{MMSI: 273000102, Label: "charter"})
+	if _, err := store.Update(fleet.ID, fleet); err != nil {
+		t.Fatal(err)
+	}
+	check("added after subscribing", []int32{273000102}, true, true)
+
+	if err := store.Delete(fleet.ID); err != nil {
+		t.Fatal(err)
+	}
+	check("deleted fleet", []int32{273000101}, false, false)
+}
@@ -0,0 +1,483 @@
+package service
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// ==============================
+// ПОИСК МЕСТ ПО НАЗВАНИЮ
+// ==============================
+
+// ErrPlaceNotFound - место с таким названием не найдено
+var ErrPlaceNotFound = errors.New("place not found")
+
+// Источники мест в порядке предпочтения при равной похожести
+const (
+	PlaceSourcePorts     = "ports"
+	PlaceSourceGazetteer = "gazetteer"
+	PlaceSourceOSM       = "osm"
+)
+
+var placeSourceRank = map[string]int{PlaceSourcePorts: 0, PlaceSourceGazetteer: 1, PlaceSourceOSM: 2}
+
+// minPlaceScore - наименьшая похожесть, при которой место попадает в результаты
+const minPlaceScore = 0.5
+
+// resolvePlaceScore - наименьшая похожесть, при которой Resolve принимает место без уточнения:
+// полное совпадение с точностью до написания, название без родового слова
+// или начало названия не короче 70% его длины. Опечатки требуют выбора из кандидатов.
+const resolvePlaceScore = 0.87
+
+// resolveCandidates - сколько кандидатов предлагается, когда место не определено однозначно
+const resolveCandidates = 5
+
+// AmbiguousPlaceError - название не определяет место однозначно: похожих мест несколько
+// или ни одно не совпадает достаточно близко
+type AmbiguousPlaceError struct {
+	Query      string
+	Candidates []PlaceMatch
+}
+
+func (e *AmbiguousPlaceError) Error() string {
+	return fmt.Sprintf("place %q is ambiguous: %d candidates", e.Query, len(e.Candidates))
+}
+
+// PlaceMatch - найденное место и похожесть названия на запрос (0-1)
+type PlaceMatch struct {
+	models.Place
+	Score   float64 `json:"score"`
+	Matched string  `json:"matched"` // Название или другое название, совпавшее с запросом
+}
+
+// placeEntry - место с подготовленными к сравнению названиями
+type placeEntry struct {
+	place models.Place
+	names []string   // Название и другие названия
+	keys  [][]string // Нормализованные слова каждого названия
+}
+
+// Geocoder - поиск мест по названию среди портов, справочника географических названий и мест OSM.
+// Названия сравниваются после транслитерации кириллицы и упрощения написания,
+// так что "Сабетта", "Sabetta" и "sabeta" находят одно место.
+type Geocoder struct {
+	mu      sync.RWMutex
+	sources map[string][]placeEntry
+}
+
+// NewGeocoder создает пустой геокодер
+func NewGeocoder() *Geocoder {
+	return &Geocoder{sources: make(map[string][]placeEntry)}
+}
+
+// SetPlaces заменяет места источника source
+func (g *Geocoder) SetPlaces(source string, places []models.Place) {
+	entries := make([]placeEntry, 0, len(places))
+	for _, p := range places {
+		e := placeEntry{place: p}
+		for _, name := range append([]string{p.Name}, p.AltNames...) {
+			if key := placeKey(name); len(key) > 0 {
+				e.names = append(e.names, name)
+				e.keys = append(e.keys, key)
+			}
+		}
+		if len(e.keys) > 0 {
+			entries = append(entries, e)
+		}
+	}
+
+	g.mu.Lock()
+	g.sources[source] = entries
+	g.mu.Unlock()
+}
+
+// Counts возвращает число мест по источникам
+func (g *Geocoder) Counts() map[string]int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	counts := make(map[string]int, len(g.sources))
+	for source, entries := range g.sources {
+		counts[source] = len(entries)
+	}
+	return counts
+}
+
+// Search ищет места по названию; самые похожие первыми, при равной похожести -
+// порты, затем справочник, затем OSM. Одноименные места ближе 10 км друг к другу
+// выдаются один раз.
+func (g *Geocoder) Search(query string, limit int) []PlaceMatch {
+	q := placeKey(query)
+	result := []PlaceMatch{}
+	if len(q) == 0 {
+		return result
+	}
+
+	var matches []PlaceMatch
+	g.mu.RLock()
+	for _, entries := range g.sources {
+		for _, e := range entries {
+			best, matched := 0.0, ""
+			for i, key := range e.keys {
+				if s := keySimilarity(q, key); s > best {
+					best, matched = s, e.names[i]
+				}
+			}
+			if best >= minPlaceScore {
+				matches = append(matches, PlaceMatch{Place: e.place, Score: best, Matched: matched})
+			}
+		}
+	}
+	g.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if placeSourceRank[a.Source] != placeSourceRank[b.Source] {
+			return placeSourceRank[a.Source] < placeSourceRank[b.Source]
+		}
+		return a.Name < b.Name
+	})
+
+	geo := &GeoUtils{}
+	for _, m := range matches {
+		duplicate := false
+		for _, r := range result {
+			if strings.Join(placeKey(r.Name), " ") == strings.Join(placeKey(m.Name), " ") &&
+				geo.Distance(r.Point(), m.Point()) < 10000 {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+		result = append(result, m)
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result
+}
+
+// Resolve возвращает место по названию. Запрос вида "69.5, 33.2" читается как координаты.
+// Место принимается, только если оно совпадает с запросом не хуже resolvePlaceScore
+// и другое место не совпадает так же; иначе возвращается *AmbiguousPlaceError с кандидатами.
+func (g *Geocoder) Resolve(query string) (PlaceMatch, error) {
+	if p, ok := parseLatLon(query); ok {
+		place := models.Place{ID: "position", Name: strings.TrimSpace(query), Kind: "position", Lat: p.Lat, Lon: p.Lon}
+		return PlaceMatch{Place: place, Score: 1, Matched: place.Name}, nil
+	}
+	matches := g.Search(query, resolveCandidates)
+	if len(matches) == 0 {
+		return PlaceMatch{}, fmt.Errorf("%w: %s", ErrPlaceNotFound, query)
+	}
+	best := matches[0]
+	if best.Score < resolvePlaceScore || len(matches) > 1 && matches[1].Score == best.Score {
+		return PlaceMatch{}, &AmbiguousPlaceError{Query: query, Candidates: matches}
+	}
+	return best, nil
+}
+
+// parseLatLon читает пару "широта, долгота"
+func parseLatLon(s string) (models.Point, bool) {
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' || unicode.IsSpace(r) })
+	if len(parts) != 2 {
+		return models.Point{}, false
+	}
+	lat, err1 := strconv.ParseFloat(parts[0], 64)
+	lon, err2 := strconv.ParseFloat(parts[1], 64)
+	if err1 != nil || err2 != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return models.Point{}, false
+	}
+	return models.Point{Lat: lat, Lon: lon}, true
+}
+
+// PlacesFromPorts превращает записи реестра портов в места; UN/LOCODE становится другим названием
+func PlacesFromPorts(ports []models.Port) []models.Place {
+	places := make([]models.Place, len(ports))
+	for i, p := range ports {
+		names := append([]string(nil), p.AltNames...)
+		if p.LOCODE != "" {
+			names = append(names, p.LOCODE)
+		}
+		places[i] = models.Place{
+			ID:       PlaceSourcePorts + "/" + p.ID,
+			Name:     p.Name,
+			AltNames: names,
+			Kind:     p.Kind,
+			Source:   PlaceSourcePorts,
+			Lat:      p.Lat,
+			Lon:      p.Lon,
+		}
+	}
+	return places
+}
+
+// ==============================
+// НОРМАЛИЗАЦИЯ И СРАВНЕНИЕ НАЗВАНИЙ
+// ==============================
+
+// cyrillicLatin - транслитерация русских букв
+var cyrillicLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+// latinLetters - буквы с диакритикой в северных названиях
+var latinLetters = map[rune]string{
+	'ø': "o", 'ö': "o", 'ó': "o", 'å': "a", 'ä': "a", 'á': "a", 'æ': "ae",
+	'é': "e", 'è': "e", 'ü': "u", 'ú': "u", 'í': "i", 'ß': "ss",
+}
+
+// spellingFolds - замены, сводящие разные системы транслитерации к одному написанию
+var spellingFolds = strings.NewReplacer(
+	"ja", "a", "ju", "u", "jo", "e", "je", "e",
+	"shch", "sh", "sch", "sh", "tch", "ch", "dzh", "j", "zh", "j",
+	"kh", "h", "tz", "c", "ts", "c", "ph", "f", "ck", "k", "q", "k", "w", "v", "x", "ks",
+	"ye", "e", "yo", "e", "ya", "a", "yu", "u", "iy", "i", "yy", "i", "y", "i",
+)
+
+// genericTerms - родовые слова названий (в упрощенном написании) на одном языке,
+// чтобы "мыс Челюскин" совпадал с "Cape Chelyuskin"
+var genericTerms = map[string]string{
+	"mis": "cape", "ostrov": "island", "ostrova": "islands", "arhipelag": "archipelago",
+	"zaliv": "bay", "guba": "bay", "buhta": "bay", "gulf": "bay",
+	"proliv": "strait", "poluostrov": "peninsula", "more": "sea",
+}
+
+// transliterate переводит название в нижний регистр латиницей без диакритики: "Вардё" - "varde"
+func transliterate(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case cyrillicLatin[r] != "" || r == 'ъ' || r == 'ь':
+			b.WriteString(cyrillicLatin[r])
+		case latinLetters[r] != "":
+			b.WriteString(latinLetters[r])
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// placeKey разбивает название на слова в нижнем регистре латиницей с упрощенным написанием
+func placeKey(name string) []string {
+	var b strings.Builder
+	for _, r := range transliterate(name) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+		case r == '\'' || r == '’':
+		default:
+			b.WriteByte(' ')
+		}
+	}
+
+	var key []string
+	for _, word := range strings.Fields(b.String()) {
+		word = collapseDoubles(spellingFolds.Replace(word))
+		if generic, ok := genericTerms[word]; ok {
+			word = generic
+		}
+		key = append(key, word)
+	}
+	return key
+}
+
+// collapseDoubles заменяет удвоенные буквы одной: Sabetta - Sabeta
+func collapseDoubles(s string) string {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if i == 0 || s[i] != s[i-1] {
+			out = append(out, s[i])
+		}
+	}
+	return string(out)
+}
+
+// allowedTypos - допустимое число опечаток в слове длины n
+func allowedTypos(n int) int {
+	switch {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// keySimilarity оценивает похожесть названия name на запрос q:
+// 1 - полное совпадение, около 0.9 - начало названия, около 0.7 - с опечатками
+func keySimilarity(q, name []string) float64 {
+	qs, ns := strings.Join(q, ""), strings.Join(name, "")
+	if qs == ns {
+		return 1
+	}
+	best := 0.0
+	if len(qs) >= 3 && strings.HasPrefix(ns, qs) {
+		best = 0.8 + 0.1*float64(len(qs))/float64(len(ns))
+	}
+	if d := editDistance(qs, ns); d <= allowedTypos(len(ns)) {
+		best = max(best, 0.75-0.1*float64(d))
+	}
+
+	// Пословное сравнение: каждое слово запроса должно найтись в названии
+	total := 0.0
+	for _, qw := range q {
+		wordBest := 0.0
+		for _, nw := range name {
+			wordBest = max(wordBest, wordSimilarity(qw, nw))
+		}
+		if wordBest == 0 {
+			return best
+		}
+		total += wordBest
+	}
+	coverage := min(1, float64(len(q))/float64(len(name)))
+	return max(best, 0.95*total/float64(len(q))*(0.85+0.15*coverage))
+}
+
+// wordSimilarity оценивает похожесть слова запроса q на слово названия w
+func wordSimilarity(q, w string) float64 {
+	switch {
+	case q == w:
+		return 1
+	case len(q) >= 3 && strings.HasPrefix(w, q):
+		return 0.8 + 0.1*float64(len(q))/float64(len(w))
+	}
+	if d := editDistance(q, w); d <= allowedTypos(len(w)) {
+		return 0.85 - 0.15*float64(d)
+	}
+	return 0
+}
+
+// editDistance - расстояние Дамерау-Левенштейна (с перестановкой соседних букв)
+func editDistance(a, b string) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
+
+// ==============================
+// СПРАВОЧНИК ГЕОГРАФИЧЕСКИХ НАЗВАНИЙ
+// ==============================
+
+//go:embed data/gazetteer.csv
+var defaultGazetteerCSV []byte
+
+// DefaultGazetteer - мысы, проливы, острова, моря и поселки Арктики
+func DefaultGazetteer() []models.Place {
+	places, err := ParseGazetteer(bytes.NewReader(defaultGazetteerCSV))
+	if err != nil {
+		panic("embedded gazetteer: " + err.Error())
+	}
+	return places
+}
+
+// ReadGazetteer читает справочник из CSV-файла
+func ReadGazetteer(path string) ([]models.Place, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	places, err := ParseGazetteer(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return places, nil
+}
+
+// ParseGazetteer читает CSV с колонками name, alt_names (через ";"), kind, lat, lon;
+// строки с "#" - комментарии
+func ParseGazetteer(r io.Reader) ([]models.Place, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, required := range []string{"name", "lat", "lon"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("column %s is required", required)
+		}
+	}
+
+	var places []models.Place
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return places, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		lat, err1 := strconv.ParseFloat(field("lat"), 64)
+		lon, err2 := strconv.ParseFloat(field("lon"), 64)
+		if err1 != nil || err2 != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			return nil, fmt.Errorf("line %d: invalid position", line)
+		}
+		name := field("name")
+		if name == "" {
+			return nil, fmt.Errorf("line %d: name is required", line)
+		}
+		places = append(places, models.Place{
+			ID:       PlaceSourceGazetteer + "/" + strings.Trim(slugPattern.ReplaceAllString(transliterate(name), "_"), "_"),
+			Name:     name,
+			AltNames: splitSemicolon(field("alt_names")),
+			Kind:     field("kind"),
+			Source:   PlaceSourceGazetteer,
+			Lat:      lat,
+			Lon:      lon,
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testGeocoder() *Geocoder {
+	g := NewGeocoder()
+	g.SetPlaces(PlaceSourcePorts, PlacesFromPorts(DefaultPorts()))
+	g.SetPlaces(PlaceSourceGazetteer, DefaultGazetteer())
+	return g
+}
+
+func TestKeySimilarity(t *testing.T) {
+	tests := []struct {
+		query, name string
+		min, max    float64
+	}{
+		{"Сабетта", "Sabetta", 1, 1},
+		{"sabeta", "Sabetta", 1, 1},
+		{"Мыс Челюскин", "Cape Chelyuskin", 1, 1},
+		{"Chelyuskin", "Cape Chelyuskin", resolvePlaceScore, 0.95},
+		{"Sabet", "Sabetta", resolvePlaceScore, 0.95},
+		{"Sab", "Sabetta", minPlaceScore, resolvePlaceScore},
+		{"Sabtta", "Sabetta", minPlaceScore, resolvePlaceScore},
+		{"Dikosn", "Dikson", minPlaceScore, resolvePlaceScore},
+		{"Murmansk", "Sabetta", 0, minPlaceScore},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query+"/"+tt.name, func(t *testing.T) {
+			got := keySimilarity(placeKey(tt.query), placeKey(tt.name))
+			if got < tt.min || got > tt.max || got == tt.max && tt.max < 1 {
+				t.Errorf("score %.3f, want in [%.2f, %.2f)", got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestGeocoderResolve(t *testing.T) {
+	g := testGeocoder()
+	tests := []struct {
+		query     string
+		id        string
+		ambiguous bool
+		notFound  bool
+	}{
+		{query: "Сабетта", id: "ports/sabetta"},
+		{query: "karskie vorota", id: "gazetteer/kara_gates"},
+		{query: "Chelyuskin", id: "gazetteer/cape_chelyuskin"},
+		{query: "69.5, 33.2", id: "position"},
+		{query: "Sabtta", ambiguous: true},
+		{query: "Kanin", id: "gazetteer/kanin_nos"},
+		{query: "Dikosn", ambiguous: true},
+		{query: "Qwertyuiop", notFound: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			place, err := g.Resolve(tt.query)
+			var ambiguous *AmbiguousPlaceError
+			switch {
+			case tt.ambiguous:
+				if !errors.As(err, &ambiguous) || len(ambiguous.Candidates) == 0 {
+					t.Fatalf("Resolve = %+v, %v; want candidates", place, err)
+				}
+			case tt.notFound:
+				if !errors.Is(err, ErrPlaceNotFound) {
+					t.Fatalf("Resolve = %+v, %v; want not found", place, err)
+				}
+			case err != nil || place.ID != tt.id:
+				t.Fatalf("Resolve = %+v, %v; want %s", place, err, tt.id)
+			}
+		})
+	}
+}
+
+func TestParseGazetteerTransliteratesIDs(t *testing.T) {
+	places, err := ParseGazetteer(strings.NewReader("name,alt_names,kind,lat,lon\nИндига,,village,67.69,49.02\nVardø,,town,70.37,31.11\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"gazetteer/indiga", "gazetteer/vardo"} {
+		if places[i].ID != want {
+			t.Errorf("ID %q, want %q", places[i].ID, want)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// ==============================
+// ГЕОЗОНЫ
+// ==============================
+
+// Формы геозон
+const (
+	GeofencePolygon = "polygon"
+	GeofenceCircle  = "circle"
+)
+
+// События геозон
+const (
+	GeofenceEnter = "enter" // Судно вошло в зону
+	GeofenceExit  = "exit"  // Судно вышло из зоны
+	GeofenceDwell = "dwell" // Судно находится в зоне дольше порога
+)
+
+// defaultDwell - порог пребывания в зоне, если он не задан
+const defaultDwell = 30 * time.Minute
+
+// geofenceFlushInterval - как часто новые события геозон записываются на диск
+const geofenceFlushInterval = time.Minute
+
+var (
+	ErrGeofenceNotFound = errors.New("geofence not found")
+	ErrInvalidGeofence  = errors.New("invalid geofence")
+)
+
+// Geofence - пользовательская геозона: многоугольник или круг
+type Geofence struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Kind      string         `json:"kind"`              // polygon или circle
+	Polygon   []models.Point `json:"polygon,omitempty"` // Вершины многоугольника
+	Center    *models.Point  `json:"center,omitempty"`  // Центр круга
+	Radius    float64        `json:"radius,omitempty"`  // Радиус круга, м
+	Dwell     float64        `json:"dwell,omitempty"`   // Порог события dwell, с; 0 - 30 минут
+	CreatedAt time.Time      `json:"created_at"`
+
+	ring  orb.Ring  // Многоугольник в координатах orb
+	bound orb.Bound // Рамка многоугольника для быстрой проверки
+}
+
+// GeofenceEvent - вход, выход или длительное пребывание судна в геозоне
+type GeofenceEvent struct {
+	ID         int64     `json:"id"`
+	GeofenceID string    `json:"geofence_id"`
+	Geofence   string    `json:"geofence"` // Название зоны
+	Type       string    `json:"type"`     // enter, exit, dwell
+	MMSI       int32     `json:"mmsi"`
+	Name       string    `json:"name"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	EnteredAt  time.Time `json:"entered_at"`         // Время входа в зону
+	Duration   float64   `json:"duration,omitempty"` // Время в зоне к моменту события, с
+	Timestamp  time.Time `json:"timestamp"`          // Время позиции
+}
+
+// fenceOccupant - судно внутри геозоны
+type fenceOccupant struct {
+	enteredAt time.Time
+	dwelled   bool // Событие dwell уже отправлено
+}
+
+// GeofenceMonitor - проверка каждой позиции AIS по геозонам
+type GeofenceMonitor struct {
+	hub        *LiveHub
+	geo        *GeoUtils
+	path       string
+	eventsPath string
+	maxStored  int
+
+	mu        sync.RWMutex
+	fences    map[string]*Geofence
+	occupants map[string]map[int32]*fenceOccupant // ID зоны -> суда внутри
+	events    []GeofenceEvent
+	nextID    int64
+	dirty     bool // Есть события, не записанные в eventsPath
+}
+
+// NewGeofenceMonitor загружает геозоны из path и их события из eventsPath и подписывается
+// на позиции. Новые события записываются в eventsPath раз в geofenceFlushInterval и при Flush.
+func NewGeofenceMonitor(store *ShipStore, hub *LiveHub, path, eventsPath string) (*GeofenceMonitor, error) {
+	var fences []Geofence
+	if err := loadJSON(path, &fences); err != nil {
+		return nil, fmt.Errorf("load geofences: %w", err)
+	}
+	var events []GeofenceEvent
+	if err := loadJSON(eventsPath, &events); err != nil {
+		return nil, fmt.Errorf("load geofence events: %w", err)
+	}
+
+	m := &GeofenceMonitor{
+		hub:        hub,
+		geo:        &GeoUtils{},
+		path:       path,
+		eventsPath: eventsPath,
+		maxStored:  5000,
+		fences:     make(map[string]*Geofence),
+		occupants:  make(map[string]map[int32]*fenceOccupant),
+		events:     events,
+	}
+	for _, e := range events {
+		m.nextID = max(m.nextID, e.ID)
+	}
+	for i := range fences {
+		fence := fences[i]
+		if err := m.prepare(&fence); err != nil {
+			return nil, fmt.Errorf("geofence %s: %w", fence.ID, err)
+		}
+		m.fences[fence.ID] = &fence
+		m.occupants[fence.ID] = make(map[int32]*fenceOccupant)
+	}
+	store.OnUpdate(m.Observe)
+	go m.flushLoop()
+	return m, nil
+}
+
+// flushLoop периодически записывает новые события геозон
+func (m *GeofenceMonitor) flushLoop() {
+	ticker := time.NewTicker(geofenceFlushInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := m.Flush(); err != nil {
+			log.Println("Geofence events save error:", err)
+		}
+	}
+}
+
+// Flush сохраняет события геозон, если появились новые
+func (m *GeofenceMonitor) Flush() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.dirty || m.eventsPath == "" {
+		return nil
+	}
+	if err := saveJSON(m.eventsPath, m.events); err != nil {
+		return err
+	}
+	m.dirty = false
+	return nil
+}
+
+// prepare проверяет геозону и готовит многоугольник к проверке точек
+func (m *GeofenceMonitor) prepare(f *Geofence) error {
+	f.Name = strings.TrimSpace(f.Name)
+	if f.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidGeofence)
+	}
+	if f.Dwell < 0 {
+		return fmt.Errorf("%w: dwell must not be negative", ErrInvalidGeofence)
+	}
+
+	switch f.Kind {
+	case GeofencePolygon:
+		if len(f.Polygon) < 3 {
+			return fmt.Errorf("%w: polygon needs at least 3 points", ErrInvalidGeofence)
+		}
+		f.Center, f.Radius = nil, 0
+		f.ring = make(orb.Ring, 0, len(f.Polygon)+1)
+		for _, p := range f.Polygon {
+			if !validLatLon(p) {
+				return fmt.Errorf("%w: polygon point out of range", ErrInvalidGeofence)
+			}
+			f.ring = append(f.ring, orb.Point{p.Lon, p.Lat})
+		}
+		if !f.ring.Closed() {
+			f.ring = append(f.ring, f.ring[0])
+		}
+		f.bound = f.ring.Bound()
+
+	case GeofenceCircle:
+		if f.Center == nil || !validLatLon(*f.Center) || f.Radius <= 0 {
+			return fmt.Errorf("%w: circle needs a valid center and positive radius", ErrInvalidGeofence)
+		}
+		f.Polygon = nil
+
+	default:
+		return fmt.Errorf("%w: kind must be polygon or circle", ErrInvalidGeofence)
+	}
+	return nil
+}
+
+// validLatLon проверяет диапазон координат
+func validLatLon(p models.Point) bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lon >= -180 && p.Lon <= 180
+}
+
+// contains сообщает, находится ли точка внутри геозоны
+func (m *GeofenceMonitor) contains(f *Geofence, p models.Point) bool {
+	if f.Kind == GeofenceCircle {
+		return m.geo.Distance(*f.Center, p) <= f.Radius
+	}
+	point := orb.Point{p.Lon, p.Lat}
+	return f.bound.Contains(point) && planar.RingContains(f.ring, point)
+}
+
+// dwell - порог пребывания для геозоны
+func (f *Geofence) dwell() time.Duration {
+	if f.Dwell <= 0 {
+		return defaultDwell
+	}
+	return time.Duration(f.Dwell * float64(time.Second))
+}
+
+// Observe проверяет позицию судна по всем геозонам
+func (m *GeofenceMonitor) Observe(ship models.Ship) {
+	position := models.Point{Lat: ship.Latitude, Lon: ship.Longitude}
+	var events []GeofenceEvent
+
+	m.mu.Lock()
+	for id, fence := range m.fences {
+		occupants := m.occupants[id]
+		occupant, wasInside := occupants[ship.MMSI]
+		inside := m.contains(fence, position)
+
+		switch {
+		case inside && !wasInside:
+			occupants[ship.MMSI] = &fenceOccupant{enteredAt: ship.Timestamp}
+			events = append(events, m.event(fence, GeofenceEnter, ship, ship.Timestamp))
+		case !inside && wasInside:
+			delete(occupants, ship.MMSI)
+			events = append(events, m.event(fence, GeofenceExit, ship, occupant.enteredAt))
+		case inside && !occupant.dwelled && ship.Timestamp.Sub(occupant.enteredAt) >= fence.dwell():
+			occupant.dwelled = true
+			events = append(events, m.event(fence, GeofenceDwell, ship, occupant.enteredAt))
+		}
+	}
+	m.mu.Unlock()
+
+	if m.hub != nil {
+		for _, event := range events {
+			m.hub.Publish("geofence", event)
+		}
+	}
+}
+
+// event сохраняет событие геозоны; вызывается под блокировкой
+func (m *GeofenceMonitor) event(f *Geofence, eventType string, ship models.Ship, enteredAt time.Time) GeofenceEvent {
+	m.nextID++
+	event := GeofenceEvent{
+		ID:         m.nextID,
+		GeofenceID: f.ID,
+		Geofence:   f.Name,
+		Type:       eventType,
+		MMSI:       ship.MMSI,
+		Name:       ship.Name,
+		Latitude:   ship.Latitude,
+		Longitude:  ship.Longitude,
+		EnteredAt:  enteredAt,
+		Duration:   ship.Timestamp.Sub(enteredAt).Seconds(),
+		Timestamp:  ship.Timestamp,
+	}
+	m.events = appendCapped(m.events, event, m.maxStored)
+	m.dirty = true
+	return event
+}
+
+// List возвращает геозоны, упорядоченные по названию
+func (m *GeofenceMonitor) List() []Geofence {
+	m.mu.RLock()
+	fences := make([]Geofence, 0, len(m.fences))
+	for _, f := range m.fences {
+		fences = append(fences, *f)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(fences, func(i, j int) bool { return fences[i].Name < fences[j].Name })
+	return fences
+}
+
+// Get возвращает геозону и MMSI судов внутри нее
+func (m *GeofenceMonitor) Get(id string) (Geofence, []int32, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	f, ok := m.fences[id]
+	if !ok {
+		return Geofence{}, nil, false
+	}
+	inside := make([]int32, 0, len(m.occupants[id]))
+	for mmsi := range m.occupants[id] {
+		inside = append(inside, mmsi)
+	}
+	sort.Slice(inside, func(i, j int) bool { return inside[i] < inside[j] })
+	return *f, inside, true
+}
+
+// Create добавляет геозону. Суда попадают в нее со следующей позицией.
+func (m *GeofenceMonitor) Create(f Geofence) (Geofence, error) {
+	if err := m.prepare(&f); err != nil {
+		return Geofence{}, err
+	}
+	id, err := newID()
+	if err != nil {
+		return Geofence{}, err
+	}
+	f.ID = id
+	f.CreatedAt = time.Now().UTC()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fences[id] = &f
+	m.occupants[id] = make(map[int32]*fenceOccupant)
+	if err := m.save(); err != nil {
+		delete(m.fences, id)
+		delete(m.occupants, id)
+		return Geofence{}, err
+	}
+	return f, nil
+}
+
+// Update заменяет геозону; суда внутри будут пересчитаны по следующим позициям
+func (m *GeofenceMonitor) Update(id string, f Geofence) (Geofence, error) {
+	if err := m.prepare(&f); err != nil {
+		return Geofence{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prev, ok := m.fences[id]
+	if !ok {
+		return Geofence{}, ErrGeofenceNotFound
+	}
+	f.ID = id
+	f.CreatedAt = prev.CreatedAt
+
+	m.fences[id] = &f
+	if err := m.save(); err != nil {
+		m.fences[id] = prev
+		return Geofence{}, err
+	}
+	return f, nil
+}
+
+// Delete удаляет геозону
+func (m *GeofenceMonitor) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prev, ok := m.fences[id]
+	if !ok {
+		return ErrGeofenceNotFound
+	}
+	occupants := m.occupants[id]
+
+	delete(m.fences, id)
+	delete(m.occupants, id)
+	if err := m.save(); err != nil {
+		m.fences[id] = prev
+		m.occupants[id] = occupants
+		return err
+	}
+	return nil
+}
+
+// save записывает геозоны на диск; вызывается под блокировкой
+func (m *GeofenceMonitor) save() error {
+	fences := make([]Geofence, 0, len(m.fences))
+	for _, f := range m.fences {
+		fences = append(fences, *f)
+	}
+	sort.Slice(fences, func(i, j int) bool { return fences[i].ID < fences[j].ID })
+	return saveJSON(m.path, fences)
+}
+
+// GeofenceEventFilter - отбор событий; пустые поля не ограничивают
+type GeofenceEventFilter struct {
+	GeofenceID string
+	MMSI       int32
+	Type       string
+	Since      time.Time
+}
+
+// Events возвращает события геозон, новые первыми
+func (m *GeofenceMonitor) Events(filter GeofenceEventFilter) []GeofenceEvent {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]GeofenceEvent, 0)
+	for i := len(m.events) - 1; i >= 0; i-- {
+		e := m.events[i]
+		if filter.GeofenceID != "" && e.GeofenceID != filter.GeofenceID {
+			continue
+		}
+		if filter.MMSI != 0 && e.MMSI != filter.MMSI {
+			continue
+		}
+		if filter.Type != "" && e.Type != filter.Type {
+			continue
+		}
+		if !filter.Since.IsZero() && e.Timestamp.Before(filter.Since) {
+			continue
+		}
+		result = append(result, e)
+	}
+	return result
+}
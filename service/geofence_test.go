@@ -0,0 +1,47 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+func TestGeofenceEventsSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+	path, eventsPath := filepath.Join(dir, "geofences.json"), filepath.Join(dir, "geofence_events.json")
+	store := NewShipStore()
+	m, err := NewGeofenceMonitor(store, nil, path, eventsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fence, err := m.Create(Geofence{Name: "Kola Bay", Kind: GeofenceCircle, Center: &models.Point{Lat: 69, Lon: 33}, Radius: 10000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	// Судно входит в зону и выходит из нее
+	for i, lat := range []float64{70, 69, 70} {
+		m.Observe(models.Ship{MMSI: 273000101, Latitude: lat, Longitude: 33, Timestamp: start.Add(time.Duration(i) * time.Hour)})
+	}
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewGeofenceMonitor(NewShipStore(), nil, path, eventsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := reloaded.Events(GeofenceEventFilter{GeofenceID: fence.ID})
+	if len(events) != 2 || events[0].Type != GeofenceExit || events[1].Type != GeofenceEnter {
+		t.Fatalf("after restart: events %+v, want exit and enter", events)
+	}
+
+	// Нумерация событий продолжается после перезапуска
+	reloaded.Observe(models.Ship{MMSI: 273000101, Latitude: 69, Longitude: 33, Timestamp: start.Add(4 * time.Hour)})
+	if latest := reloaded.Events(GeofenceEventFilter{})[0]; latest.ID <= events[0].ID {
+		t.Errorf("new event ID %d repeats an earlier ID %d", latest.ID, events[0].ID)
+	}
+}
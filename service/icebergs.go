@@ -0,0 +1,560 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// ==============================
+// НАБЛЮДЕНИЯ АЙСБЕРГОВ
+// ==============================
+
+var (
+	ErrIcebergNotFound = errors.New("iceberg not found")
+	ErrInvalidIceberg  = errors.New("invalid iceberg observation")
+)
+
+// Классы размеров айсбергов (по классификации Международного ледового патруля)
+const (
+	IcebergGrowler   = "growler"    // Менее 5 м над водой
+	IcebergBergyBit  = "bergy_bit"  // 5-14 м
+	IcebergSmall     = "small"      // 15-60 м
+	IcebergMedium    = "medium"     // 61-122 м
+	IcebergLarge     = "large"      // 123-213 м
+	IcebergVeryLarge = "very_large" // Более 213 м
+	IcebergUnknown   = "unknown"
+)
+
+// IcebergSourceShip - источник донесений с судов
+const IcebergSourceShip = "ship"
+
+// icebergSizes - написания классов размера в файлах ледовых служб
+var icebergSizes = map[string]string{
+	"growler": IcebergGrowler, "gr": IcebergGrowler,
+	"bergy_bit": IcebergBergyBit, "bergybit": IcebergBergyBit, "bb": IcebergBergyBit,
+	"small": IcebergSmall, "sm": IcebergSmall,
+	"medium": IcebergMedium, "med": IcebergMedium,
+	"large": IcebergLarge, "lg": IcebergLarge,
+	"very_large": IcebergVeryLarge, "verylarge": IcebergVeryLarge, "vlg": IcebergVeryLarge,
+	"unknown": IcebergUnknown, "": IcebergUnknown,
+}
+
+// icebergSizeByLength - верхняя граница длины класса, м
+var icebergSizeByLength = []struct {
+	maxLength float64
+	size      string
+}{
+	{5, IcebergGrowler}, {15, IcebergBergyBit}, {61, IcebergSmall},
+	{123, IcebergMedium}, {214, IcebergLarge},
+}
+
+// IcebergConfig - срок действия наблюдений и радиусы опасных зон
+type IcebergConfig struct {
+	TTL    time.Duration      // Срок действия наблюдения без явного expires
+	Retain time.Duration      // Сколько хранить истекшие наблюдения
+	Radius map[string]float64 // Радиус опасной зоны по классу размера, м
+}
+
+// DefaultIcebergConfig - наблюдение действует трое суток, зона от 0.5 до 5 миль
+func DefaultIcebergConfig() IcebergConfig {
+	return IcebergConfig{
+		TTL:    72 * time.Hour,
+		Retain: 30 * 24 * time.Hour,
+		Radius: map[string]float64{
+			IcebergGrowler:   0.5 * metersPerNM,
+			IcebergBergyBit:  1 * metersPerNM,
+			IcebergSmall:     2 * metersPerNM,
+			IcebergMedium:    3 * metersPerNM,
+			IcebergLarge:     4 * metersPerNM,
+			IcebergVeryLarge: 5 * metersPerNM,
+			IcebergUnknown:   3 * metersPerNM,
+		},
+	}
+}
+
+// IcebergStore - наблюдения айсбергов, сохраняемые в JSON-файл
+type IcebergStore struct {
+	path  string
+	cfg   IcebergConfig
+	clock func() time.Time
+	drift *DriftModel
+
+	mu        sync.RWMutex
+	obs       map[string]models.IcebergObservation
+	listeners []func([]models.IcebergObservation)
+	notifyMu  sync.Mutex // Оповещения идут по одному, чтобы подписчики не получили старый снимок последним
+}
+
+// NewIcebergStore загружает наблюдения из файла path (если он есть)
+func NewIcebergStore(path string, cfg IcebergConfig) (*IcebergStore, error) {
+	var list []models.IcebergObservation
+	if err := loadJSON(path, &list); err != nil {
+		return nil, fmt.Errorf("load icebergs: %w", err)
+	}
+	s := &IcebergStore{path: path, cfg: cfg, clock: time.Now, obs: make(map[string]models.IcebergObservation)}
+	for _, o := range list {
+		// Прежние ID вида "источник/номер" не проходили в путь /icebergs/:id
+		if strings.Contains(o.ID, "/") {
+			id, _ := strings.CutPrefix(o.ID, o.Source+"/")
+			o.ID = icebergID(o.Source, id)
+		}
+		s.obs[o.ID] = o
+	}
+	return s, nil
+}
+
+// UseClock задает часы, по которым проверяется время наблюдений и истечение,
+// например часы хранилища позиций при воспроизведении записи
+func (s *IcebergStore) UseClock(now func() time.Time) {
+	s.clock = now
+}
+
+// UseDrift включает прогноз дрейфа: опасные зоны айсбергов смещаются по траектории дрейфа
+func (s *IcebergStore) UseDrift(m *DriftModel) {
+	s.drift = m
+}
+
+// OnChange подписывает fn на изменения; fn получает все хранимые наблюдения
+func (s *IcebergStore) OnChange(fn func([]models.IcebergObservation)) {
+	s.mu.Lock()
+	s.listeners = append(s.listeners, fn)
+	s.mu.Unlock()
+}
+
+// List возвращает наблюдения, действующие в момент at (все, если all), новые первыми
+func (s *IcebergStore) List(at time.Time, all bool) []models.IcebergObservation {
+	s.mu.RLock()
+	result := make([]models.IcebergObservation, 0, len(s.obs))
+	for _, o := range s.obs {
+		if all || o.Active(at) {
+			result = append(result, o)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].ObservedAt.Equal(result[j].ObservedAt) {
+			return result[i].ObservedAt.After(result[j].ObservedAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// Get возвращает наблюдение по ID
+func (s *IcebergStore) Get(id string) (models.IcebergObservation, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	o, ok := s.obs[id]
+	return o, ok
+}
+
+// Report сохраняет донесение судна об айсберге
+func (s *IcebergStore) Report(o models.IcebergObservation) (models.IcebergObservation, error) {
+	id, err := newID()
+	if err != nil {
+		return models.IcebergObservation{}, err
+	}
+	o.ID = id
+	if o.Source == "" {
+		o.Source = IcebergSourceShip
+	}
+	if err := s.normalize(&o, s.clock().UTC()); err != nil {
+		return models.IcebergObservation{}, err
+	}
+	if _, err := s.Import([]models.IcebergObservation{o}); err != nil {
+		return models.IcebergObservation{}, err
+	}
+	o, _ = s.Get(id)
+	return o, nil
+}
+
+// Import добавляет наблюдения или заменяет наблюдения с теми же ID и удаляет
+// давно истекшие; возвращает число записанных наблюдений. Наблюдения без ID
+// получают ID по источнику, времени и месту, так что повторный импорт файла их не дублирует.
+func (s *IcebergStore) Import(list []models.IcebergObservation) (int, error) {
+	now := s.clock().UTC()
+	for i := range list {
+		if err := s.normalize(&list[i], now); err != nil {
+			return 0, fmt.Errorf("observation %d: %w", i+1, err)
+		}
+	}
+
+	s.mu.Lock()
+	prev := s.obs
+	s.obs = make(map[string]models.IcebergObservation, len(prev)+len(list))
+	for id, o := range prev {
+		if now.Sub(o.ExpiresAt) < s.cfg.Retain {
+			s.obs[id] = o
+		}
+	}
+	for _, o := range list {
+		if old, ok := s.obs[o.ID]; ok {
+			o.CreatedAt = old.CreatedAt
+		}
+		s.obs[o.ID] = o
+	}
+	if err := s.save(); err != nil {
+		s.obs = prev
+		s.mu.Unlock()
+		return 0, err
+	}
+	s.mu.Unlock()
+
+	s.changed()
+	return len(list), nil
+}
+
+// Delete удаляет наблюдение
+func (s *IcebergStore) Delete(id string) error {
+	s.mu.Lock()
+	prev, ok := s.obs[id]
+	if !ok {
+		s.mu.Unlock()
+		return ErrIcebergNotFound
+	}
+	delete(s.obs, id)
+	if err := s.save(); err != nil {
+		s.obs[id] = prev
+		s.mu.Unlock()
+		return err
+	}
+	s.mu.Unlock()
+
+	s.changed()
+	return nil
+}
+
+// Radius возвращает радиус опасной зоны айсберга класса size
+func (s *IcebergStore) Radius(size string) float64 {
+	if r, ok := s.cfg.Radius[size]; ok {
+		return r
+	}
+	return s.cfg.Radius[IcebergUnknown]
+}
+
+// Hazards превращает наблюдения в круговые ледовые опасности, действующие до истечения наблюдения.
+// С прогнозом дрейфа у опасности есть траектория до истечения наблюдения.
+func (s *IcebergStore) Hazards(list []models.IcebergObservation) []IceHazard {
+	hazards := make([]IceHazard, 0, len(list))
+	for _, o := range list {
+		expires := o.ExpiresAt
+		h := IceHazard{
+			ID:        "iceberg/" + o.ID,
+			Kind:      HazardIceberg,
+			Name:      strings.ReplaceAll(o.Size, "_", " ") + " iceberg",
+			Point:     o.Point(),
+			Radius:    s.Radius(o.Size),
+			ExpiresAt: &expires,
+		}
+		if s.drift != nil {
+			h.Track = s.drift.Track(o, h.Radius)
+		}
+		hazards = append(hazards, h)
+	}
+	return hazards
+}
+
+// Forecast прогнозирует дрейф наблюдений на моменты times
+func (s *IcebergStore) Forecast(list []models.IcebergObservation, times []time.Time) []IcebergForecast {
+	result := make([]IcebergForecast, 0, len(list))
+	for _, o := range list {
+		f := IcebergForecast{Iceberg: o, Radius: s.Radius(o.Size), Fixes: make([]DriftFix, 0)}
+		if s.drift != nil {
+			f.Fixes = s.drift.Forecast(o, times)
+		}
+		result = append(result, f)
+	}
+	return result
+}
+
+// changed оповещает подписчиков; вызывается без блокировки. Снимок берется под notifyMu,
+// поэтому при одновременных изменениях последним приходит самый новый.
+func (s *IcebergStore) changed() {
+	s.notifyMu.Lock()
+	defer s.notifyMu.Unlock()
+
+	s.mu.RLock()
+	listeners := slices.Clone(s.listeners)
+	s.mu.RUnlock()
+
+	list := s.List(time.Time{}, true)
+	for _, fn := range listeners {
+		fn(list)
+	}
+}
+
+// save записывает наблюдения на диск; вызывается под блокировкой
+func (s *IcebergStore) save() error {
+	list := make([]models.IcebergObservation, 0, len(s.obs))
+	for _, o := range s.obs {
+		list = append(list, o)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return saveJSON(s.path, list)
+}
+
+// normalize проверяет наблюдение, определяет класс размера и срок действия
+func (s *IcebergStore) normalize(o *models.IcebergObservation, now time.Time) error {
+	if o.Lat < -90 || o.Lat > 90 || o.Lon < -180 || o.Lon > 180 || (o.Lat == 0 && o.Lon == 0) {
+		return fmt.Errorf("%w: position out of range", ErrInvalidIceberg)
+	}
+	if o.ObservedAt.IsZero() {
+		return fmt.Errorf("%w: observation time is required", ErrInvalidIceberg)
+	}
+	if o.ObservedAt.After(now.Add(time.Hour)) {
+		return fmt.Errorf("%w: observation time is in the future", ErrInvalidIceberg)
+	}
+	if o.Length < 0 {
+		return fmt.Errorf("%w: length must not be negative", ErrInvalidIceberg)
+	}
+
+	size, ok := icebergSizes[strings.NewReplacer(" ", "_", "-", "_").Replace(strings.ToLower(strings.TrimSpace(o.Size)))]
+	if !ok {
+		return fmt.Errorf("%w: unknown size class %q", ErrInvalidIceberg, o.Size)
+	}
+	if size == IcebergUnknown && o.Length > 0 {
+		size = IcebergVeryLarge
+		for _, c := range icebergSizeByLength {
+			if o.Length < c.maxLength {
+				size = c.size
+				break
+			}
+		}
+	}
+	o.Size = size
+
+	o.Source = strings.TrimSpace(o.Source)
+	if o.Source == "" {
+		return fmt.Errorf("%w: source is required", ErrInvalidIceberg)
+	}
+	o.ObservedAt = o.ObservedAt.UTC()
+	if o.ExpiresAt.IsZero() {
+		o.ExpiresAt = o.ObservedAt.Add(s.cfg.TTL)
+	}
+	o.ExpiresAt = o.ExpiresAt.UTC()
+	if !o.ExpiresAt.After(o.ObservedAt) {
+		return fmt.Errorf("%w: expires_at must be after observed_at", ErrInvalidIceberg)
+	}
+	if o.ID == "" {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%.5f|%.5f", o.Source, o.ObservedAt.Format(time.RFC3339), o.Lat, o.Lon)))
+		o.ID = hex.EncodeToString(sum[:8])
+	}
+	if o.CreatedAt.IsZero() {
+		o.CreatedAt = now
+	}
+	return nil
+}
+
+// ==============================
+// ЗАГРУЗКА НАБЛЮДЕНИЙ ИЗ ФАЙЛОВ
+// ==============================
+
+// ReadIcebergs читает наблюдения из CSV (.csv) или GeoJSON (.geojson, .json).
+// Источником наблюдений без колонки source считается имя файла.
+func ReadIcebergs(path string) ([]models.IcebergObservation, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	source := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	var list []models.IcebergObservation
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		list, err = ParseIcebergsCSV(f, source)
+	case ".geojson", ".json":
+		list, err = ParseIcebergsGeoJSON(f, source)
+	default:
+		return nil, fmt.Errorf("%s: unsupported iceberg format", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return list, nil
+}
+
+// icebergColumns - названия колонок и свойств в файлах ледовых служб
+var icebergColumns = map[string]string{
+	"id": "id", "iceberg_id": "id",
+	"time": "time", "observed_at": "time", "datetime": "time", "date": "time", "sighting_date": "time",
+	"lat": "lat", "latitude": "lat",
+	"lon": "lon", "lng": "lon", "longitude": "lon",
+	"size": "size", "size_class": "size", "size class": "size",
+	"length": "length", "length_m": "length",
+	"source":  "source",
+	"expires": "expires", "expires_at": "expires",
+	"mmsi":     "mmsi",
+	"reporter": "reporter", "ship": "reporter",
+	"note": "note", "remarks": "note",
+}
+
+// icebergTimeLayouts - форматы времени наблюдения; время без пояса считается UTC
+var icebergTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04Z07:00", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+func parseIcebergTime(v string) (time.Time, error) {
+	for _, layout := range icebergTimeLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: time %q", ErrInvalidIceberg, v)
+}
+
+// icebergID строит ID наблюдения из его номера id в источнике source. Номер и источник могут
+// содержать "/" и другие символы, поэтому ID - хэш: его можно подставить в путь /icebergs/:id.
+func icebergID(source, id string) string {
+	sum := sha256.Sum256([]byte(source + "|" + id))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ParseIcebergsCSV читает наблюдения из CSV с заголовком; строки с "#" - комментарии
+func ParseIcebergsCSV(r io.Reader, source string) ([]models.IcebergObservation, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, h := range header {
+		if name, ok := icebergColumns[strings.ToLower(strings.TrimSpace(h))]; ok {
+			columns[name] = i
+		}
+	}
+	for _, required := range []string{"time", "lat", "lon"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: column %s is required", ErrInvalidIceberg, required)
+		}
+	}
+
+	var list []models.IcebergObservation
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return list, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		o, err := icebergFromFields(func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}, source)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		list = append(list, o)
+	}
+}
+
+// ParseIcebergsGeoJSON читает наблюдения из FeatureCollection точек
+func ParseIcebergsGeoJSON(r io.Reader, source string) ([]models.IcebergObservation, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	fc, err := geojson.UnmarshalFeatureCollection(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIceberg, err)
+	}
+
+	list := make([]models.IcebergObservation, 0, len(fc.Features))
+	for i, f := range fc.Features {
+		pt, ok := f.Geometry.(orb.Point)
+		if !ok {
+			return nil, fmt.Errorf("%w: feature %d is not a point", ErrInvalidIceberg, i+1)
+		}
+		props := make(map[string]string)
+		for key, v := range f.Properties {
+			if name, ok := icebergColumns[strings.ToLower(key)]; ok {
+				switch v := v.(type) {
+				case string:
+					props[name] = strings.TrimSpace(v)
+				case float64:
+					props[name] = strconv.FormatFloat(v, 'f', -1, 64)
+				}
+			}
+		}
+		if id, ok := f.ID.(string); ok && props["id"] == "" {
+			props["id"] = id
+		}
+		props["lon"] = strconv.FormatFloat(pt[0], 'f', -1, 64)
+		props["lat"] = strconv.FormatFloat(pt[1], 'f', -1, 64)
+
+		o, err := icebergFromFields(func(name string) string { return props[name] }, source)
+		if err != nil {
+			return nil, fmt.Errorf("feature %d: %w", i+1, err)
+		}
+		list = append(list, o)
+	}
+	return list, nil
+}
+
+// icebergFromFields собирает наблюдение из текстовых полей. ID из файла дополняется источником,
+// чтобы наблюдения разных служб с одинаковыми номерами не заменяли друг друга.
+func icebergFromFields(field func(string) string, source string) (models.IcebergObservation, error) {
+	o := models.IcebergObservation{
+		Size:     field("size"),
+		Source:   field("source"),
+		Reporter: field("reporter"),
+		Note:     field("note"),
+	}
+	if o.Source == "" {
+		o.Source = source
+	}
+	if id := field("id"); id != "" {
+		o.ID = icebergID(o.Source, id)
+	}
+
+	var err error
+	if o.ObservedAt, err = parseIcebergTime(field("time")); err != nil {
+		return o, err
+	}
+	if v := field("expires"); v != "" {
+		if o.ExpiresAt, err = parseIcebergTime(v); err != nil {
+			return o, err
+		}
+	}
+	numbers := []struct {
+		name string
+		dst  *float64
+	}{{"lat", &o.Lat}, {"lon", &o.Lon}, {"length", &o.Length}}
+	for _, n := range numbers {
+		if v := field(n.name); v != "" {
+			if *n.dst, err = strconv.ParseFloat(v, 64); err != nil {
+				return o, fmt.Errorf("%w: %s %q", ErrInvalidIceberg, n.name, v)
+			}
+		}
+	}
+	if v := field("mmsi"); v != "" {
+		mmsi, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return o, fmt.Errorf("%w: mmsi %q", ErrInvalidIceberg, v)
+		}
+		o.MMSI = int32(mmsi)
+	}
+	return o, nil
+}
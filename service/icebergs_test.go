@@ -0,0 +1,110 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+func TestIcebergIDsFitThePath(t *testing.T) {
+	tests := []struct {
+		name   string
+		csv    string
+		source string
+	}{
+		{"plain number", "id,time,lat,lon\n17,2026-05-01T00:00Z,72,40\n", "upload"},
+		{"number with a slash", "id,time,lat,lon\nA23a/1,2026-05-01T00:00Z,72,40\n", "upload"},
+		{"source with a slash", "id,time,lat,lon\n17,2026-05-01T00:00Z,72,40\n", "nic/usnic"},
+	}
+	seen := make(map[string]string)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := ParseIcebergsCSV(strings.NewReader(tt.csv), tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			again, _ := ParseIcebergsCSV(strings.NewReader(tt.csv), tt.source)
+			id := list[0].ID
+			if id == "" || strings.ContainsAny(id, "/?#% ") {
+				t.Errorf("ID %q does not fit into /icebergs/:id", id)
+			}
+			if again[0].ID != id {
+				t.Errorf("reimport gives ID %q, want %q", again[0].ID, id)
+			}
+			if other, ok := seen[id]; ok {
+				t.Errorf("ID %q repeats the ID of %q", id, other)
+			}
+			seen[id] = tt.name
+		})
+	}
+}
+
+func TestIcebergStoreMigratesSlashIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "icebergs.json")
+	observed := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	old := []models.IcebergObservation{{
+		ID: "upload/17", Source: "upload", Lat: 72, Lon: 40,
+		ObservedAt: observed, ExpiresAt: observed.Add(72 * time.Hour),
+	}}
+	data, _ := json.Marshal(old)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewIcebergStore(path, DefaultIcebergConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, _ := ParseIcebergsCSV(strings.NewReader("id,time,lat,lon\n17,2026-05-01T00:00Z,72,40\n"), "upload")
+	if _, ok := s.Get(list[0].ID); !ok {
+		t.Fatalf("stored observation is not found by the new ID %q", list[0].ID)
+	}
+	if err := s.Delete(list[0].ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIcebergStoreNotifiesLatestLast(t *testing.T) {
+	s, err := NewIcebergStore(filepath.Join(t.TempDir(), "icebergs.json"), DefaultIcebergConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.UseClock(func() time.Time { return now })
+
+	// Медленный подписчик: без упорядочивания оповещений старый снимок приходит последним
+	var mu sync.Mutex
+	var last []models.IcebergObservation
+	s.OnChange(func(list []models.IcebergObservation) {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		last = list
+		mu.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			o := models.IcebergObservation{Source: "upload", Lat: 72, Lon: 40, ObservedAt: now, Note: fmt.Sprint(i)}
+			o.ID = "berg"
+			if _, err := s.Import([]models.IcebergObservation{o}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	want, _ := s.Get("berg")
+	if len(last) != 1 || last[0].Note != want.Note {
+		t.Errorf("listener saw %+v last, store has note %q", last, want.Note)
+	}
+}
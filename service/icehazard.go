@@ -0,0 +1,392 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// ==============================
+// ЛЕДОВЫЕ ОПАСНОСТИ
+// ==============================
+
+// Виды ледовых опасностей
+const (
+	HazardTidewaterGlacier = "tidewater_glacier" // Ледник, выходящий к морю (источник айсбергов)
+	HazardIceberg          = "iceberg"           // Наблюдавшийся айсберг
+)
+
+// IceHazard - ледовая опасность: точка с радиусом охвата
+type IceHazard struct {
+	ID        string       `json:"id"`
+	Kind      string       `json:"kind"`
+	Name      string       `json:"name,omitempty"`
+	Point     models.Point `json:"point"`
+	Radius    float64      `json:"radius"`               // Радиус охвата, м
+	Source    string       `json:"source"`               // Источник: osm, наблюдение и т.п.
+	ExpiresAt *time.Time   `json:"expires_at,omitempty"` // После этого момента не учитывается
+	Track     []HazardFix  `json:"track,omitempty"`      // Прогноз дрейфа; пусто - опасность неподвижна
+}
+
+// HazardFix - прогнозное положение дрейфующей опасности
+type HazardFix struct {
+	At     time.Time    `json:"at"`
+	Point  models.Point `json:"point"`
+	Radius float64      `json:"radius"`            // Радиус охвата с учетом неопределенности прогноза, м
+	NoData bool         `json:"no_data,omitempty"` // Нет ни ветра, ни течения: положение не прогнозируется
+}
+
+// Drifting сообщает, что у опасности есть прогноз дрейфа
+func (h IceHazard) Drifting() bool {
+	return len(h.Track) > 0
+}
+
+// At возвращает опасность с положением и радиусом на момент t по прогнозу дрейфа.
+// До начала прогноза берется первое положение, после конца - последнее.
+func (h IceHazard) At(t time.Time) IceHazard {
+	if !h.Drifting() {
+		return h
+	}
+	fix := h.fixAt(t)
+	h.Point, h.Radius = fix.Point, fix.Radius
+	return h
+}
+
+// fixAt интерполирует прогнозное положение на момент t
+func (h IceHazard) fixAt(t time.Time) HazardFix {
+	i := sort.Search(len(h.Track), func(n int) bool { return h.Track[n].At.After(t) })
+	switch {
+	case i == 0:
+		return h.Track[0]
+	case i == len(h.Track):
+		return h.Track[i-1]
+	}
+	a, b := h.Track[i-1], h.Track[i]
+	f := t.Sub(a.At).Seconds() / b.At.Sub(a.At).Seconds()
+	return HazardFix{
+		At:     t,
+		Point:  (&GeoUtils{}).IntermediatePoint(a.Point, b.Point, f),
+		Radius: a.Radius + (b.Radius-a.Radius)*f,
+		NoData: a.NoData || b.NoData,
+	}
+}
+
+// Positions возвращает положения опасности за промежуток from-to: на его концах
+// и все прогнозные положения внутри
+func (h IceHazard) Positions(from, to time.Time) []HazardFix {
+	if !h.Drifting() {
+		return []HazardFix{{At: from, Point: h.Point, Radius: h.Radius}}
+	}
+	fixes := []HazardFix{h.fixAt(from)}
+	for _, f := range h.Track {
+		if f.At.After(from) && f.At.Before(to) {
+			fixes = append(fixes, f)
+		}
+	}
+	if to.After(from) {
+		fixes = append(fixes, h.fixAt(to))
+	}
+	return fixes
+}
+
+// IceWarning - предупреждение о сближении судна с ледовой опасностью
+type IceWarning struct {
+	MMSI          int32        `json:"mmsi"`
+	Name          string       `json:"name"`
+	Hazard        IceHazard    `json:"hazard"`
+	Severity      string       `json:"severity"`        // warning или critical
+	Distance      float64      `json:"distance"`        // От текущей позиции до края опасности, м
+	Bearing       float64      `json:"bearing"`         // Пеленг с судна на опасность, градусы
+	Closest       float64      `json:"closest"`         // Наименьшее расстояние на прогнозном пути, м
+	TimeToClosest float64      `json:"time_to_closest"` // Через сколько наступит наибольшее сближение, с
+	ClosestAt     models.Point `json:"closest_at"`      // Позиция судна в момент наибольшего сближения
+	DetectedAt    time.Time    `json:"detected_at"`     // Время позиции
+}
+
+// IceHazardConfig - пороги предупреждений
+type IceHazardConfig struct {
+	WarnRange     float64       // Сближение до края опасности ближе - warning, м
+	CriticalRange float64       // Ближе - critical, м
+	LookAhead     time.Duration // Насколько вперед проверяется прогнозный путь
+	Step          time.Duration // Шаг прогнозного пути
+	CellSize      float64       // Размер ячейки пространственной сетки, м
+}
+
+// DefaultIceHazardConfig - 5 миль, 1 миля, прогноз на час
+func DefaultIceHazardConfig() IceHazardConfig {
+	return IceHazardConfig{
+		WarnRange:     5 * metersPerNM,
+		CriticalRange: 1 * metersPerNM,
+		LookAhead:     time.Hour,
+		Step:          5 * time.Minute,
+		CellSize:      25000,
+	}
+}
+
+// IceHazardMonitor - проверка позиций и прогнозного пути судов по ледовым опасностям
+type IceHazardMonitor struct {
+	hub *LiveHub
+	cfg IceHazardConfig
+	geo *GeoUtils
+
+	mu        sync.RWMutex
+	hazards   map[string]IceHazard            // ID -> опасность
+	bySource  map[string][]string             // Источник -> ID опасностей
+	grid      map[gridCell][]string           // Ячейка сетки -> ID опасностей
+	maxRadius float64                         // Наибольший радиус охвата
+	warnings  map[int32]map[string]IceWarning // MMSI -> ID опасности -> предупреждение
+}
+
+// NewIceHazardMonitor создает монитор без опасностей и подписывает его на позиции
+func NewIceHazardMonitor(store *ShipStore, hub *LiveHub, cfg IceHazardConfig) *IceHazardMonitor {
+	m := &IceHazardMonitor{
+		hub:      hub,
+		cfg:      cfg,
+		geo:      &GeoUtils{},
+		hazards:  make(map[string]IceHazard),
+		bySource: make(map[string][]string),
+		grid:     make(map[gridCell][]string),
+		warnings: make(map[int32]map[string]IceWarning),
+	}
+	store.OnUpdate(m.Check)
+	return m
+}
+
+// cellOf - ячейка сетки точки в полярной проекции
+func (m *IceHazardMonitor) cellOf(p models.Point) gridCell {
+	x, y := polarXY(p.Lat, p.Lon)
+	return gridCell{int(math.Floor(x / m.cfg.CellSize)), int(math.Floor(y / m.cfg.CellSize))}
+}
+
+// SetHazards заменяет все опасности источника source
+func (m *IceHazardMonitor) SetHazards(source string, hazards []IceHazard) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range m.bySource[source] {
+		delete(m.hazards, id)
+	}
+	ids := make([]string, 0, len(hazards))
+	for _, h := range hazards {
+		h.Source = source
+		if h.ID == "" {
+			h.ID = fmt.Sprintf("%s/%d", source, len(ids))
+		}
+		m.hazards[h.ID] = h
+		ids = append(ids, h.ID)
+	}
+	m.bySource[source] = ids
+	m.reindex()
+}
+
+// reindex перестраивает пространственную сетку; вызывается под блокировкой
+func (m *IceHazardMonitor) reindex() {
+	m.grid = make(map[gridCell][]string)
+	m.maxRadius = 0
+	for id, h := range m.hazards {
+		cell := m.cellOf(h.Point)
+		m.grid[cell] = append(m.grid[cell], id)
+		m.maxRadius = math.Max(m.maxRadius, h.Radius)
+		// Дрейфующая опасность индексируется по месту наблюдения, охват - вся траектория
+		for _, f := range h.Track {
+			m.maxRadius = math.Max(m.maxRadius, m.geo.Distance(h.Point, f.Point)+f.Radius)
+		}
+	}
+}
+
+// Hazards возвращает опасности, действующие на момент at; дрейфующие - в положении на этот момент
+func (m *IceHazardMonitor) Hazards(at time.Time) []IceHazard {
+	m.mu.RLock()
+	result := make([]IceHazard, 0, len(m.hazards))
+	for _, h := range m.hazards {
+		if h.ExpiresAt == nil || h.ExpiresAt.After(at) {
+			result = append(result, h.At(at))
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// nearby добавляет к out опасности, край которых может быть ближе reach к точке; вызывается под блокировкой
+func (m *IceHazardMonitor) nearby(p models.Point, reach float64, seen map[string]bool, out []IceHazard) []IceHazard {
+	center := m.cellOf(p)
+	span := int(math.Ceil((reach + m.maxRadius) / m.cfg.CellSize))
+	for dx := -span; dx <= span; dx++ {
+		for dy := -span; dy <= span; dy++ {
+			for _, id := range m.grid[gridCell{center.x + dx, center.y + dy}] {
+				if !seen[id] {
+					seen[id] = true
+					out = append(out, m.hazards[id])
+				}
+			}
+		}
+	}
+	return out
+}
+
+// Check проверяет текущую позицию и прогнозный путь судна.
+// О новых сближениях сообщается в живой канал событием "ice_warning".
+func (m *IceHazardMonitor) Check(ship models.Ship) {
+	position := models.Point{Lat: ship.Latitude, Lon: ship.Longitude}
+
+	// Прогнозный путь по счислению: текущая позиция и точки через Step
+	dr := DefaultDeadReckoningConfig()
+	dr.MinAge = 0
+	path := []models.Point{position}
+	if ship.HasMotion() && ship.SOG >= dr.MinMovingSOG {
+		for t := m.cfg.Step; t <= m.cfg.LookAhead; t += m.cfg.Step {
+			est := PredictPosition(ship, ship.Timestamp.Add(t), dr)
+			path = append(path, models.Point{Lat: est.PredictedLatitude, Lon: est.PredictedLongitude})
+		}
+	}
+
+	m.mu.Lock()
+	seen := make(map[string]bool)
+	var candidates []IceHazard
+	for _, p := range path {
+		candidates = m.nearby(p, m.cfg.WarnRange, seen, candidates)
+	}
+
+	current := make(map[string]IceWarning)
+	for _, h := range candidates {
+		if h.ExpiresAt != nil && !h.ExpiresAt.After(ship.Timestamp) {
+			continue
+		}
+		h = h.At(ship.Timestamp)
+
+		w := IceWarning{
+			MMSI:       ship.MMSI,
+			Name:       ship.Name,
+			Hazard:     h,
+			Distance:   math.Max(0, m.geo.Distance(position, h.Point)-h.Radius),
+			Bearing:    m.geo.Bearing(position, h.Point),
+			Closest:    math.MaxFloat64,
+			DetectedAt: ship.Timestamp,
+		}
+		for i, p := range path {
+			d := math.Max(0, m.geo.Distance(p, h.Point)-h.Radius)
+			if d < w.Closest {
+				w.Closest = d
+				w.ClosestAt = p
+				w.TimeToClosest = (time.Duration(i) * m.cfg.Step).Seconds()
+			}
+		}
+		if w.Closest > m.cfg.WarnRange {
+			continue
+		}
+		w.Severity = SeverityWarning
+		if w.Closest <= m.cfg.CriticalRange {
+			w.Severity = SeverityCritical
+		}
+		current[h.ID] = w
+	}
+
+	previous := m.warnings[ship.MMSI]
+	if len(current) > 0 {
+		m.warnings[ship.MMSI] = current
+	} else {
+		delete(m.warnings, ship.MMSI)
+	}
+	m.mu.Unlock()
+
+	if m.hub == nil {
+		return
+	}
+	for id, w := range current {
+		prev, existed := previous[id]
+		if !existed || severityRank(w.Severity) > severityRank(prev.Severity) {
+			m.hub.Publish("ice_warning", w)
+		}
+	}
+}
+
+// Warnings возвращает действующие предупреждения судна, ближайшие первыми
+func (m *IceHazardMonitor) Warnings(mmsi int32) []IceWarning {
+	m.mu.RLock()
+	result := make([]IceWarning, 0, len(m.warnings[mmsi]))
+	for _, w := range m.warnings[mmsi] {
+		result = append(result, w)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Closest < result[j].Closest })
+	return result
+}
+
+// AllWarnings возвращает действующие предупреждения всех судов
+func (m *IceHazardMonitor) AllWarnings() []IceWarning {
+	m.mu.RLock()
+	result := make([]IceWarning, 0)
+	for _, byHazard := range m.warnings {
+		for _, w := range byHazard {
+			result = append(result, w)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].MMSI != result[j].MMSI {
+			return result[i].MMSI < result[j].MMSI
+		}
+		return result[i].Closest < result[j].Closest
+	})
+	return result
+}
+
+// GlacierHazards превращает ледники OSM, выходящие к морю, в ледовые опасности с радиусом охвата radius:
+// граница ледника покрывается цепочкой опасностей через каждые radius метров, чтобы учитывался
+// фронт откола. Ледники на суше и ледники без контура опасностей не дают.
+func GlacierHazards(glaciers []models.Glacier, radius float64) []IceHazard {
+	hazards := make([]IceHazard, 0, len(glaciers))
+	for _, g := range glaciers {
+		if !g.Tidewater || len(g.Geometry) == 0 {
+			continue
+		}
+		id := fmt.Sprintf("osm/%s/%d", g.Type, g.ID)
+		for i, p := range glacierFront(g, radius) {
+			hazards = append(hazards, IceHazard{
+				ID:     fmt.Sprintf("%s/%d", id, i),
+				Kind:   HazardTidewaterGlacier,
+				Name:   g.Name,
+				Point:  p,
+				Radius: radius,
+			})
+		}
+	}
+	return hazards
+}
+
+// glacierFront - точки внешних контуров ледника не реже чем через spacing метров
+func glacierFront(g models.Glacier, spacing float64) []models.Point {
+	geo := &GeoUtils{}
+	var points []models.Point
+	for _, polygon := range g.Geometry {
+		ring := polygon[0]
+		for i := 0; i+1 < len(ring); i++ {
+			a := models.Point{Name: g.Name, Lat: ring[i][1], Lon: ring[i][0]}
+			b := models.Point{Name: g.Name, Lat: ring[i+1][1], Lon: ring[i+1][0]}
+			points = append(points, a)
+			d := geo.Distance(a, b)
+			for k := 1.0; k*spacing < d; k++ {
+				p := geo.IntermediatePoint(a, b, k*spacing/d)
+				p.Name = g.Name
+				points = append(points, p)
+			}
+		}
+	}
+
+	// Вершины чаще spacing прореживаются
+	thinned := make([]models.Point, 0, len(points))
+	for _, p := range points {
+		if n := len(thinned); n == 0 || geo.Distance(thinned[n-1], p) >= spacing/2 {
+			thinned = append(thinned, p)
+		}
+	}
+	return thinned
+}
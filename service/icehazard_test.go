@@ -0,0 +1,38 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+func TestGlacierHazardsOnlyTidewaterFronts(t *testing.T) {
+	// Контур около 11 x 7 км у побережья Новой Земли
+	outline := orb.MultiPolygon{{{{56, 75}, {56.2, 75}, {56.2, 75.1}, {56, 75.1}, {56, 75}}}}
+	const radius = 5000.0
+
+	tests := []struct {
+		name    string
+		glacier models.Glacier
+		hazards bool
+	}{
+		{"tidewater glacier with an outline", models.Glacier{ID: 1, Type: "way", Tidewater: true, Geometry: outline}, true},
+		{"tidewater glacier without an outline", models.Glacier{ID: 2, Type: "node", Tidewater: true, Latitude: 75, Longitude: 56}, false},
+		{"land glacier with an outline", models.Glacier{ID: 3, Type: "way", Geometry: outline}, false},
+		{"land glacier without an outline", models.Glacier{ID: 4, Type: "node", Latitude: 75, Longitude: 56}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hazards := GlacierHazards([]models.Glacier{tt.glacier}, radius)
+			if got := len(hazards) > 0; got != tt.hazards {
+				t.Fatalf("got %d hazards, want any: %v", len(hazards), tt.hazards)
+			}
+			for _, h := range hazards {
+				if h.Kind != HazardTidewaterGlacier || h.Radius != radius {
+					t.Errorf("hazard %+v, want a %s front of %g m", h, HazardTidewaterGlacier, radius)
+				}
+			}
+		})
+	}
+}
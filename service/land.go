@@ -0,0 +1,346 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/clip"
+	"github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/planar"
+	"github.com/paulmach/orb/simplify"
+)
+
+// ==============================
+// ПОЛИГОНЫ СУШИ ИЗ БЕРЕГОВОЙ ЛИНИИ
+// ==============================
+
+// LandConfig - параметры построения полигонов суши
+type LandConfig struct {
+	Tolerance float64 // Допуск упрощения контуров, м
+	MinArea   float64 // Острова меньшей площади отбрасываются, м²
+}
+
+// DefaultLandConfig - упрощение до 100 м, без скал меньше 0.01 км²
+func DefaultLandConfig() LandConfig {
+	return LandConfig{Tolerance: 100, MinArea: 10000}
+}
+
+// BuildLandPolygons строит полигоны суши из линий берега OSM в пределах region.
+// По правилам OSM суша лежит слева от направления линии. Замкнутые кольца внутри
+// региона становятся островами (или водоемами, если обходятся по часовой стрелке),
+// а линии, пересекающие край региона, обрезаются и замыкаются вдоль его границы.
+func BuildLandPolygons(lines []orb.LineString, region orb.Bound, cfg LandConfig) []orb.Polygon {
+	var polygons []orb.Polygon
+	var holes []orb.Ring
+	var pieces []orb.LineString
+
+	for _, chain := range stitchCoastlines(lines) {
+		closed := chain[0].Equal(chain[len(chain)-1])
+		if closed && region.Contains(chain.Bound().Min) && region.Contains(chain.Bound().Max) {
+			ring := orb.Ring(chain)
+			if len(ring) < 4 {
+				continue
+			}
+			if ring.Orientation() == orb.CCW {
+				polygons = append(polygons, orb.Polygon{ring})
+			} else {
+				holes = append(holes, ring)
+			}
+			continue
+		}
+
+		clipped := clip.LineString(region, chain)
+		// Кольцо, начинающееся внутри региона, разрезается на первом и последнем куске
+		if n := len(clipped); closed && n > 1 && clipped[n-1][len(clipped[n-1])-1].Equal(clipped[0][0]) {
+			clipped[0] = append(clipped[n-1], clipped[0][1:]...)
+			clipped = clipped[:n-1]
+		}
+		for _, piece := range clipped {
+			if len(piece) >= 2 {
+				pieces = append(pieces, snapToEdge(piece, region))
+			}
+		}
+	}
+	polygons = append(polygons, closeAlongEdge(pieces, region)...)
+
+	for _, hole := range holes {
+		for i := range polygons {
+			if planar.RingContains(polygons[i][0], hole[0]) {
+				polygons[i] = append(polygons[i], hole)
+				break
+			}
+		}
+	}
+
+	// Упрощение с допуском в градусах широты; по долготе в высоких широтах оно строже
+	dp := simplify.DouglasPeucker(cfg.Tolerance / 111320)
+	result := make([]orb.Polygon, 0, len(polygons))
+	for _, p := range polygons {
+		if cfg.Tolerance > 0 {
+			p = dp.Polygon(p.Clone())
+		}
+		if len(p) == 0 || len(p[0]) < 4 || geo.Area(p) < cfg.MinArea {
+			continue
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// stitchCoastlines сшивает линии берега конец к началу, сохраняя направление
+func stitchCoastlines(lines []orb.LineString) []orb.LineString {
+	byStart := make(map[orb.Point][]int)
+	for i, ls := range lines {
+		if len(ls) >= 2 {
+			byStart[ls[0]] = append(byStart[ls[0]], i)
+		}
+	}
+	used := make([]bool, len(lines))
+
+	next := func(p orb.Point) int {
+		for _, j := range byStart[p] {
+			if !used[j] {
+				return j
+			}
+		}
+		return -1
+	}
+
+	var chains []orb.LineString
+	// Сначала цепочки от линий, которые ничем не продолжаются назад, затем оставшиеся кольца
+	hasPrev := make([]bool, len(lines))
+	for _, ls := range lines {
+		if len(ls) >= 2 {
+			for _, j := range byStart[ls[len(ls)-1]] {
+				hasPrev[j] = true
+			}
+		}
+	}
+	order := make([]int, 0, len(lines))
+	for i := range lines {
+		if !hasPrev[i] {
+			order = append(order, i)
+		}
+	}
+	for i := range lines {
+		if hasPrev[i] {
+			order = append(order, i)
+		}
+	}
+
+	for _, i := range order {
+		if used[i] || len(lines[i]) < 2 {
+			continue
+		}
+		used[i] = true
+		chain := append(orb.LineString(nil), lines[i]...)
+		for !chain[0].Equal(chain[len(chain)-1]) {
+			j := next(chain[len(chain)-1])
+			if j < 0 {
+				break
+			}
+			used[j] = true
+			chain = append(chain, lines[j][1:]...)
+		}
+		chains = append(chains, chain)
+	}
+	return chains
+}
+
+// edgeParam - положение точки на границе региона при обходе против часовой стрелки
+// от юго-западного угла: южная сторона, восточная, северная, западная
+func edgeParam(p orb.Point, b orb.Bound) float64 {
+	w, h := b.Max[0]-b.Min[0], b.Max[1]-b.Min[1]
+	dists := []float64{
+		math.Abs(p[1] - b.Min[1]), // Юг
+		math.Abs(p[0] - b.Max[0]), // Восток
+		math.Abs(p[1] - b.Max[1]), // Север
+		math.Abs(p[0] - b.Min[0]), // Запад
+	}
+	side := 0
+	for i, d := range dists {
+		if d < dists[side] {
+			side = i
+		}
+	}
+	switch side {
+	case 0:
+		return p[0] - b.Min[0]
+	case 1:
+		return w + p[1] - b.Min[1]
+	case 2:
+		return w + h + b.Max[0] - p[0]
+	default:
+		return 2*w + h + b.Max[1] - p[1]
+	}
+}
+
+// edgePoint - ближайшая к p точка границы региона
+func edgePoint(p orb.Point, b orb.Bound) orb.Point {
+	candidates := []orb.Point{
+		{p[0], b.Min[1]}, {b.Max[0], p[1]}, {p[0], b.Max[1]}, {b.Min[0], p[1]},
+	}
+	best := candidates[0]
+	for _, c := range candidates[1:] {
+		if planar.Distance(p, c) < planar.Distance(p, best) {
+			best = c
+		}
+	}
+	return best
+}
+
+// snapToEdge доводит концы обрезанной линии до границы региона:
+// линия берега могла оборваться внутри региона из-за неполных данных
+func snapToEdge(ls orb.LineString, b orb.Bound) orb.LineString {
+	const eps = 1e-9
+	onEdge := func(p orb.Point) bool {
+		return math.Abs(p[0]-b.Min[0]) < eps || math.Abs(p[0]-b.Max[0]) < eps ||
+			math.Abs(p[1]-b.Min[1]) < eps || math.Abs(p[1]-b.Max[1]) < eps
+	}
+	if !onEdge(ls[0]) {
+		ls = append(orb.LineString{edgePoint(ls[0], b)}, ls...)
+	}
+	if !onEdge(ls[len(ls)-1]) {
+		ls = append(ls, edgePoint(ls[len(ls)-1], b))
+	}
+	return ls
+}
+
+// closeAlongEdge замыкает куски берега в полигоны: от точки выхода куска идем по границе
+// региона против часовой стрелки (суша остается слева) до ближайшего входа следующего куска
+func closeAlongEdge(pieces []orb.LineString, b orb.Bound) []orb.Polygon {
+	w, h := b.Max[0]-b.Min[0], b.Max[1]-b.Min[1]
+	perimeter := 2 * (w + h)
+	corners := []struct {
+		t float64
+		p orb.Point
+	}{
+		{0, b.Min},
+		{w, orb.Point{b.Max[0], b.Min[1]}},
+		{w + h, b.Max},
+		{2*w + h, orb.Point{b.Min[0], b.Max[1]}},
+	}
+	ahead := func(from, to float64) float64 {
+		return math.Mod(to-from+perimeter, perimeter)
+	}
+
+	starts := make([]float64, len(pieces))
+	for i, piece := range pieces {
+		starts[i] = edgeParam(piece[0], b)
+	}
+
+	used := make([]bool, len(pieces))
+	var polygons []orb.Polygon
+	for first := range pieces {
+		if used[first] {
+			continue
+		}
+		used[first] = true
+		ring := append(orb.Ring(nil), pieces[first]...)
+		current := first
+
+		for guard := 0; guard <= len(pieces); guard++ {
+			exit := edgeParam(pieces[current][len(pieces[current])-1], b)
+
+			best, bestGap := -1, math.MaxFloat64
+			for j := range pieces {
+				if used[j] && j != first {
+					continue
+				}
+				if gap := ahead(exit, starts[j]); gap < bestGap {
+					best, bestGap = j, gap
+				}
+			}
+
+			// Углы региона между выходом и следующим входом
+			var passed []float64
+			byT := make(map[float64]orb.Point)
+			for _, c := range corners {
+				if gap := ahead(exit, c.t); gap > 0 && gap < bestGap {
+					passed = append(passed, gap)
+					byT[gap] = c.p
+				}
+			}
+			sort.Float64s(passed)
+			for _, gap := range passed {
+				ring = append(ring, byT[gap])
+			}
+
+			if best == first {
+				break
+			}
+			used[best] = true
+			ring = append(ring, pieces[best]...)
+			current = best
+		}
+
+		ring = append(ring, ring[0])
+		if len(ring) >= 4 {
+			polygons = append(polygons, orb.Polygon{ring})
+		}
+	}
+	return polygons
+}
+
+// ==============================
+// КЭШ ПОЛИГОНОВ СУШИ
+// ==============================
+
+// LandCache - полигоны суши с параметрами, по которым они построены
+type LandCache struct {
+	BBox      string                     `json:"bbox"`
+	Source    string                     `json:"source"` // overpass или путь к выгрузке OSM
+	Tolerance float64                    `json:"tolerance"`
+	MinArea   float64                    `json:"min_area"`
+	CreatedAt time.Time                  `json:"created_at"`
+	Land      *geojson.FeatureCollection `json:"land"`
+}
+
+// NewLandCache упаковывает полигоны суши, построенные с настройками cfg, для сохранения
+func NewLandCache(bbox, source string, cfg LandConfig, polygons []orb.Polygon) LandCache {
+	fc := geojson.NewFeatureCollection()
+	for _, p := range polygons {
+		fc.Append(geojson.NewFeature(p))
+	}
+	return LandCache{BBox: bbox, Source: source, Tolerance: cfg.Tolerance, MinArea: cfg.MinArea, CreatedAt: time.Now().UTC(), Land: fc}
+}
+
+// Matches сообщает, что кэш построен для той же области и источника с теми же настройками
+func (c *LandCache) Matches(bbox, source string, cfg LandConfig) bool {
+	return c.BBox == bbox && c.Source == source && c.Tolerance == cfg.Tolerance && c.MinArea == cfg.MinArea
+}
+
+// Polygons возвращает полигоны суши из кэша
+func (c *LandCache) Polygons() []orb.Polygon {
+	if c.Land == nil {
+		return nil
+	}
+	polygons := make([]orb.Polygon, 0, len(c.Land.Features))
+	for _, f := range c.Land.Features {
+		if p, ok := f.Geometry.(orb.Polygon); ok {
+			polygons = append(polygons, p)
+		}
+	}
+	return polygons
+}
+
+// LoadLandCache читает кэш; отсутствующий файл дает nil без ошибки
+func LoadLandCache(path string) (*LandCache, error) {
+	var c LandCache
+	if err := loadJSON(path, &c); err != nil {
+		return nil, fmt.Errorf("load land cache: %w", err)
+	}
+	if c.Land == nil {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+// SaveLandCache записывает кэш полигонов суши
+func SaveLandCache(path string, c LandCache) error {
+	return saveJSON(path, c)
+}
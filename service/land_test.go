@@ -0,0 +1,188 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+// landRegion - регион тестов 10x10 градусов
+var landRegion = orb.Bound{Min: orb.Point{0, 0}, Max: orb.Point{10, 10}}
+
+// checkLand проверяет число полигонов, их площади и положение контрольных точек
+func checkLand(t *testing.T, polygons []orb.Polygon, areas []float64, land, water []orb.Point) {
+	t.Helper()
+	got := make([]float64, len(polygons))
+	for i, p := range polygons {
+		ring := p[0]
+		if !ring.Closed() {
+			t.Errorf("polygon %d ring is not closed: %v", i, ring)
+		}
+		if ring.Orientation() != orb.CCW {
+			t.Errorf("polygon %d ring is not counter-clockwise: %v", i, ring)
+		}
+		got[i] = planar.Area(p)
+	}
+	sort.Float64s(got)
+	sort.Float64s(areas)
+	if len(got) != len(areas) {
+		t.Fatalf("got polygons of area %v, want %v", got, areas)
+	}
+	for i := range got {
+		if math.Abs(got[i]-areas[i]) > 1e-9 {
+			t.Errorf("got polygons of area %v, want %v", got, areas)
+			break
+		}
+	}
+
+	inLand := func(p orb.Point) bool {
+		for _, poly := range polygons {
+			if planar.PolygonContains(poly, p) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, p := range land {
+		if !inLand(p) {
+			t.Errorf("%v should be land", p)
+		}
+	}
+	for _, p := range water {
+		if inLand(p) {
+			t.Errorf("%v should be water", p)
+		}
+	}
+}
+
+func TestCloseAlongEdge(t *testing.T) {
+	tests := []struct {
+		name   string
+		pieces []orb.LineString
+		areas  []float64
+		land   []orb.Point
+		water  []orb.Point
+	}{
+		{
+			name:   "land to the north",
+			pieces: []orb.LineString{{{0, 5}, {4, 6}, {10, 5}}},
+			areas:  []float64{50 - 5},
+			land:   []orb.Point{{5, 8}, {1, 9.9}, {9.9, 9.9}},
+			water:  []orb.Point{{5, 2}, {0.1, 0.1}},
+		},
+		{
+			name:   "land to the south",
+			pieces: []orb.LineString{{{10, 5}, {0, 5}}},
+			areas:  []float64{50},
+			land:   []orb.Point{{5, 2}, {0.1, 0.1}, {9.9, 0.1}},
+			water:  []orb.Point{{5, 8}},
+		},
+		{
+			name:   "cape across the region",
+			pieces: []orb.LineString{{{0, 3}, {10, 3}}, {{10, 7}, {0, 7}}},
+			areas:  []float64{40},
+			land:   []orb.Point{{5, 5}},
+			water:  []orb.Point{{5, 1}, {5, 9}},
+		},
+		{
+			name:   "bay between two shores",
+			pieces: []orb.LineString{{{10, 3}, {0, 3}}, {{0, 7}, {10, 7}}},
+			areas:  []float64{30, 30},
+			land:   []orb.Point{{5, 1}, {5, 9}},
+			water:  []orb.Point{{5, 5}},
+		},
+		{
+			name:   "opposite corners",
+			pieces: []orb.LineString{{{2, 0}, {0, 2}}, {{8, 10}, {10, 8}}},
+			areas:  []float64{2, 2},
+			land:   []orb.Point{{0.5, 0.5}, {9.5, 9.5}},
+			water:  []orb.Point{{5, 5}, {9.5, 0.5}, {0.5, 9.5}},
+		},
+		{
+			name:   "peninsula entering and leaving through the same side",
+			pieces: []orb.LineString{{{3, 0}, {3, 6}, {6, 6}, {6, 0}}},
+			areas:  []float64{82},
+			land:   []orb.Point{{1, 1}, {8, 1}, {5, 9}},
+			water:  []orb.Point{{4.5, 3}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkLand(t, closeAlongEdge(tt.pieces, landRegion), tt.areas, tt.land, tt.water)
+		})
+	}
+}
+
+func TestBuildLandPolygons(t *testing.T) {
+	raw := LandConfig{}
+	tests := []struct {
+		name  string
+		lines []orb.LineString
+		areas []float64
+		land  []orb.Point
+		water []orb.Point
+	}{
+		{
+			name:  "coastline split into ways is stitched",
+			lines: []orb.LineString{{{-1, 5}, {5, 5}}, {{5, 5}, {11, 5}}},
+			areas: []float64{50},
+			land:  []orb.Point{{5, 8}},
+			water: []orb.Point{{5, 2}},
+		},
+		{
+			name:  "island inside the region",
+			lines: []orb.LineString{{{4, 4}, {6, 4}, {6, 6}, {4, 6}, {4, 4}}},
+			areas: []float64{4},
+			land:  []orb.Point{{5, 5}},
+			water: []orb.Point{{1, 1}},
+		},
+		{
+			name:  "lake inside land becomes a hole",
+			lines: []orb.LineString{{{-1, 2}, {11, 2}}, {{4, 4}, {4, 6}, {6, 6}, {6, 4}, {4, 4}}},
+			areas: []float64{80 - 4},
+			land:  []orb.Point{{2, 8}},
+			water: []orb.Point{{5, 5}, {5, 1}},
+		},
+		{
+			name:  "coastline ending inside the region is extended to the edge",
+			lines: []orb.LineString{{{-1, 5}, {9, 5}}},
+			areas: []float64{50},
+			land:  []orb.Point{{5, 8}},
+			water: []orb.Point{{5, 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkLand(t, BuildLandPolygons(tt.lines, landRegion, raw), tt.areas, tt.land, tt.water)
+		})
+	}
+}
+
+func TestLandCacheMatches(t *testing.T) {
+	cfg := DefaultLandConfig()
+	cache := NewLandCache("64,28,81,85", "overpass", cfg, nil)
+
+	tests := []struct {
+		name   string
+		bbox   string
+		source string
+		cfg    LandConfig
+		want   bool
+	}{
+		{"same settings", "64,28,81,85", "overpass", cfg, true},
+		{"other area", "64,28,81,90", "overpass", cfg, false},
+		{"other source", "64,28,81,85", "pbf:arctic.osm.pbf", cfg, false},
+		{"other tolerance", "64,28,81,85", "overpass", LandConfig{Tolerance: 50, MinArea: cfg.MinArea}, false},
+		{"other minimum island area", "64,28,81,85", "overpass", LandConfig{Tolerance: cfg.Tolerance, MinArea: 1e6}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cache.Matches(tt.bbox, tt.source, tt.cfg); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// LiveEvent - событие живого канала
+type LiveEvent struct {
+	Type string      `json:"type"` // Тип события: "position", "collision_alert", ...
+	Data interface{} `json:"data"` // Содержимое события
+}
+
+// LiveHub - рассылка событий подписчикам живого канала.
+// Медленный подписчик теряет события, но не блокирует остальных.
+// Слушатели получают каждое событие синхронно и не теряют их.
+type LiveHub struct {
+	mu        sync.RWMutex
+	subs      map[chan LiveEvent]struct{}
+	listeners map[int]func(LiveEvent)
+	nextID    int
+}
+
+// NewLiveHub создает пустой канал рассылки
+func NewLiveHub() *LiveHub {
+	return &LiveHub{subs: make(map[chan LiveEvent]struct{}), listeners: make(map[int]func(LiveEvent))}
+}
+
+// Publish рассылает событие всем подписчикам
+func (h *LiveHub) Publish(eventType string, data interface{}) {
+	event := LiveEvent{Type: eventType, Data: data}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, fn := range h.listeners {
+		fn(event)
+	}
+	for ch := range h.subs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Listen вызывает fn для каждого события в горутине публикации и возвращает функцию отписки.
+// fn не должна блокироваться: долгую обработку она передает в свою очередь.
+func (h *LiveHub) Listen(fn func(LiveEvent)) func() {
+	h.mu.Lock()
+	id := h.nextID
+	h.nextID++
+	h.listeners[id] = fn
+	h.mu.Unlock()
+
+	return func() {
+		h.mu.Lock()
+		delete(h.listeners, id)
+		h.mu.Unlock()
+	}
+}
+
+// Subscribe возвращает канал событий и функцию отписки
+func (h *LiveHub) Subscribe(buffer int) (<-chan LiveEvent, func()) {
+	ch := make(chan LiveEvent, buffer)
+
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs, ch)
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// MMSIs возвращает суда, к которым относится событие
+func (e LiveEvent) MMSIs() []int32 {
+	switch data := e.Data.(type) {
+	case models.Ship:
+		return []int32{data.MMSI}
+	case CollisionAlert:
+		return []int32{data.MMSI1, data.MMSI2}
+	case Anomaly:
+		return []int32{data.MMSI}
+	case RouteDeviation:
+		return []int32{data.MMSI}
+	case GeofenceEvent:
+		return []int32{data.MMSI}
+	case IceWarning:
+		return []int32{data.MMSI}
+	default:
+		return nil
+	}
+}
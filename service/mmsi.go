@@ -0,0 +1,171 @@
+package service
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ==============================
+// MMSI И ФЛАГ СУДНА
+// ==============================
+
+// Типы станций по структуре MMSI (МСЭ-R M.585)
+const (
+	StationShip          = "ship"          // MIDXXXXXX
+	StationGroup         = "group"         // 0MIDXXXXX
+	StationCoast         = "coast_station" // 00MIDXXXX
+	StationSARAircraft   = "sar_aircraft"  // 111MIDXXX
+	StationAtoN          = "aton"          // 99MIDXXXX
+	StationCraft         = "auxiliary"     // 98MIDXXXX - плавсредство судна
+	StationHandheld      = "handheld"      // 8MIDXXXXX - носимая УКВ станция
+	StationSART          = "ais_sart"      // 970XXYYYY
+	StationMOB           = "mob"           // 972XXYYYY
+	StationEPIRB         = "epirb"         // 974XXYYYY
+	StationUnknownFormat = "unknown"       // Формат не распознан
+)
+
+// ErrInvalidMMSI - MMSI не из девяти цифр
+var ErrInvalidMMSI = errors.New("mmsi must be 9 digits")
+
+//go:embed data/mid.csv
+var midCSV string
+
+// FlagState - государство флага по MID
+type FlagState struct {
+	MID     int    `json:"mid"`     // Maritime Identification Digits
+	ISO     string `json:"iso"`     // Код страны ISO 3166-1 alpha-2
+	Country string `json:"country"` // Название
+}
+
+// MMSIInfo - результат разбора MMSI
+type MMSIInfo struct {
+	MMSI    string     `json:"mmsi"`           // Девять цифр с ведущими нулями
+	Kind    string     `json:"kind"`           // Тип станции
+	Valid   bool       `json:"valid"`          // MID найден в таблице МСЭ
+	MID     int        `json:"mid,omitempty"`  // Выделенный MID
+	Flag    *FlagState `json:"flag,omitempty"` // Государство флага
+	Comment string     `json:"comment,omitempty"`
+}
+
+var (
+	midOnce  sync.Once
+	midTable map[int]FlagState
+)
+
+// loadMIDTable разбирает встроенную таблицу MID
+func loadMIDTable() {
+	midTable = make(map[int]FlagState)
+
+	records, err := csv.NewReader(strings.NewReader(midCSV)).ReadAll()
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded MID table: %v", err))
+	}
+	for _, rec := range records[1:] { // Пропускаем заголовок
+		mid, err := strconv.Atoi(rec[0])
+		if err != nil {
+			panic(fmt.Sprintf("invalid MID %q in embedded table", rec[0]))
+		}
+		midTable[mid] = FlagState{MID: mid, ISO: rec[1], Country: rec[2]}
+	}
+}
+
+// LookupMID возвращает государство флага по MID
+func LookupMID(mid int) (FlagState, bool) {
+	midOnce.Do(loadMIDTable)
+	flag, ok := midTable[mid]
+	return flag, ok
+}
+
+// LookupFlag находит государство флага по коду ISO или названию страны
+func LookupFlag(query string) (FlagState, bool) {
+	midOnce.Do(loadMIDTable)
+	query = strings.TrimSpace(query)
+	for _, flag := range midTable {
+		if flag.MatchesFlag(query) {
+			return flag, true
+		}
+	}
+	return FlagState{}, false
+}
+
+// ParseMMSI проверяет формат MMSI и определяет тип станции и флаг
+func ParseMMSI(s string) (MMSIInfo, error) {
+	s = strings.TrimSpace(s)
+	if len(s) != 9 {
+		return MMSIInfo{}, ErrInvalidMMSI
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return MMSIInfo{}, ErrInvalidMMSI
+		}
+	}
+
+	info := MMSIInfo{MMSI: s}
+	midAt := -1
+
+	switch {
+	case strings.HasPrefix(s, "111"):
+		info.Kind, midAt = StationSARAircraft, 3
+	case strings.HasPrefix(s, "970"):
+		info.Kind = StationSART
+	case strings.HasPrefix(s, "972"):
+		info.Kind = StationMOB
+	case strings.HasPrefix(s, "974"):
+		info.Kind = StationEPIRB
+	case strings.HasPrefix(s, "99"):
+		info.Kind, midAt = StationAtoN, 2
+	case strings.HasPrefix(s, "98"):
+		info.Kind, midAt = StationCraft, 2
+	case strings.HasPrefix(s, "00"):
+		info.Kind, midAt = StationCoast, 2
+	case s[0] == '0':
+		info.Kind, midAt = StationGroup, 1
+	case s[0] == '8':
+		info.Kind, midAt = StationHandheld, 1
+	case s[0] >= '2' && s[0] <= '7':
+		info.Kind, midAt = StationShip, 0
+	default:
+		info.Kind = StationUnknownFormat
+		info.Comment = "Неизвестный формат MMSI"
+		return info, nil
+	}
+
+	if midAt < 0 {
+		// Аварийные устройства не содержат MID
+		info.Valid = true
+		return info, nil
+	}
+
+	info.MID, _ = strconv.Atoi(s[midAt : midAt+3])
+	if flag, ok := LookupMID(info.MID); ok {
+		info.Valid = true
+		info.Flag = &flag
+	} else {
+		info.Comment = "MID не выделен МСЭ"
+	}
+	return info, nil
+}
+
+// ClassifyMMSI разбирает числовой MMSI из сообщения AIS.
+// Ведущие нули в числе теряются, поэтому оно дополняется до девяти цифр.
+func ClassifyMMSI(mmsi int32) MMSIInfo {
+	if mmsi <= 0 || mmsi > 999999999 {
+		return MMSIInfo{MMSI: strconv.Itoa(int(mmsi)), Kind: StationUnknownFormat, Comment: ErrInvalidMMSI.Error()}
+	}
+	info, _ := ParseMMSI(fmt.Sprintf("%09d", mmsi))
+	return info
+}
+
+// MatchesFlag сообщает, совпадает ли флаг с кодом ISO или названием страны
+func (f *FlagState) MatchesFlag(query string) bool {
+	if f == nil || query == "" {
+		return false
+	}
+	query = strings.TrimSpace(query)
+	return strings.EqualFold(f.ISO, query) || strings.EqualFold(f.Country, query)
+}
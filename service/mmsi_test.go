@@ -0,0 +1,62 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+func TestClassifyMMSI(t *testing.T) {
+	tests := []struct {
+		mmsi int32
+		kind string
+		iso  string
+	}{
+		{273000101, StationShip, "RU"},
+		{2730001, StationCoast, "RU"},
+		{992731234, StationAtoN, "RU"},
+		{111273123, StationSARAircraft, "RU"},
+		{970123456, StationSART, ""},
+		{100000000, StationUnknownFormat, ""},
+		{-1, StationUnknownFormat, ""},
+	}
+	for _, tt := range tests {
+		info := ClassifyMMSI(tt.mmsi)
+		iso := ""
+		if info.Flag != nil {
+			iso = info.Flag.ISO
+		}
+		if info.Kind != tt.kind || iso != tt.iso {
+			t.Errorf("ClassifyMMSI(%d) = %s/%q, want %s/%q", tt.mmsi, info.Kind, iso, tt.kind, tt.iso)
+		}
+	}
+}
+
+func TestShipFlagFilter(t *testing.T) {
+	store := NewShipStore()
+	store.Update(models.Ship{MMSI: 273000101, Latitude: 69, Longitude: 33})
+	ship, _ := store.Get(273000101)
+
+	tests := []struct {
+		query string
+		found bool
+		match bool
+	}{
+		{"RU", true, true},
+		{" ru ", true, true},
+		{"Russia", true, true},
+		{"NO", true, false},
+		{"Atlantis", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		flag, found := LookupFlag(tt.query)
+		if found != tt.found {
+			t.Errorf("LookupFlag(%q) found = %v, want %v", tt.query, found, tt.found)
+			continue
+		}
+		if match := found && ship.Flag == flag.ISO; match != tt.match {
+			t.Errorf("flag %q matches ship flag %q = %v, want %v", tt.query, ship.Flag, match, tt.match)
+		}
+	}
+}
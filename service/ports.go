@@ -0,0 +1,592 @@
+package service
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// ==============================
+// РЕЕСТР ПОРТОВ
+// ==============================
+
+var (
+	ErrPortNotFound = errors.New("port not found")
+	ErrInvalidPort  = errors.New("invalid port")
+)
+
+//go:embed data/ports.csv
+var defaultPortsCSV []byte
+
+// DefaultPorts - порты и места убежища западного сектора Северного морского пути
+func DefaultPorts() []models.Port {
+	ports, err := ParsePortsCSV(bytes.NewReader(defaultPortsCSV))
+	if err != nil {
+		panic("embedded ports: " + err.Error())
+	}
+	return ports
+}
+
+// PortDistance - порт и расстояние до него
+type PortDistance struct {
+	models.Port
+	Distance float64 `json:"distance"` // м
+}
+
+// PortRegistry - реестр портов, сохраняемый в JSON-файл
+type PortRegistry struct {
+	path string
+	geo  *GeoUtils
+
+	mu        sync.RWMutex
+	ports     map[string]models.Port
+	listeners []func([]models.Port)
+
+	notifyMu sync.Mutex // Оповещения идут по одному, чтобы подписчики не получили старый снимок последним
+}
+
+// NewPortRegistry загружает реестр из файла path (если он есть)
+func NewPortRegistry(path string) (*PortRegistry, error) {
+	var ports []models.Port
+	if err := loadJSON(path, &ports); err != nil {
+		return nil, fmt.Errorf("load ports: %w", err)
+	}
+
+	r := &PortRegistry{path: path, geo: &GeoUtils{}, ports: make(map[string]models.Port)}
+	for _, p := range ports {
+		r.ports[p.ID] = p
+	}
+	return r, nil
+}
+
+// OnChange подписывает fn на изменения реестра; fn получает все порты
+func (r *PortRegistry) OnChange(fn func([]models.Port)) {
+	r.mu.Lock()
+	r.listeners = append(r.listeners, fn)
+	r.mu.Unlock()
+}
+
+// List возвращает порты, упорядоченные по названию
+func (r *PortRegistry) List() []models.Port {
+	r.mu.RLock()
+	ports := make([]models.Port, 0, len(r.ports))
+	for _, p := range r.ports {
+		ports = append(ports, p)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(ports, func(i, j int) bool { return ports[i].Name < ports[j].Name })
+	return ports
+}
+
+// Points возвращает положения портов
+func (r *PortRegistry) Points() []models.Point {
+	ports := r.List()
+	points := make([]models.Point, len(ports))
+	for i, p := range ports {
+		points[i] = p.Point()
+	}
+	return points
+}
+
+// Get возвращает порт по ID
+func (r *PortRegistry) Get(id string) (models.Port, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.ports[id]
+	return p, ok
+}
+
+// Search ищет порты по названию, другим названиям, UN/LOCODE и ID без учета регистра:
+// сначала точные совпадения, затем по началу слова, затем вхождения
+func (r *PortRegistry) Search(query string, limit int) []models.Port {
+	q := strings.ToLower(strings.TrimSpace(query))
+	if q == "" {
+		return []models.Port{}
+	}
+
+	type match struct {
+		port  models.Port
+		score int
+	}
+	var matches []match
+	for _, p := range r.List() {
+		best := 0
+		for _, name := range append([]string{p.Name, p.LOCODE, strings.ReplaceAll(p.LOCODE, " ", ""), p.ID}, p.AltNames...) {
+			name = strings.ToLower(name)
+			switch {
+			case name == "":
+			case name == q:
+				best = max(best, 3)
+			case strings.HasPrefix(name, q) || strings.Contains(name, " "+q) || strings.Contains(name, "("+q):
+				best = max(best, 2)
+			case strings.Contains(name, q):
+				best = max(best, 1)
+			}
+		}
+		if best > 0 {
+			matches = append(matches, match{p, best})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+	result := make([]models.Port, 0, len(matches))
+	for _, m := range matches {
+		if limit > 0 && len(result) == limit {
+			break
+		}
+		result = append(result, m.port)
+	}
+	return result
+}
+
+// Nearest возвращает до limit ближайших к точке портов, принимающих судно с осадкой draught (0 - любые)
+func (r *PortRegistry) Nearest(point models.Point, draught float64, limit int) []PortDistance {
+	var result []PortDistance
+	for _, p := range r.List() {
+		if draught > 0 && p.MaxDraft > 0 && p.MaxDraft < draught {
+			continue
+		}
+		result = append(result, PortDistance{Port: p, Distance: r.geo.Distance(point, p.Point())})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Distance < result[j].Distance })
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	if result == nil {
+		result = []PortDistance{}
+	}
+	return result
+}
+
+// Create добавляет порт. ID берется из запроса, иначе из UN/LOCODE, иначе из названия.
+func (r *PortRegistry) Create(p models.Port) (models.Port, error) {
+	if err := normalizePort(&p); err != nil {
+		return models.Port{}, err
+	}
+	p.UpdatedAt = time.Now().UTC()
+
+	r.mu.Lock()
+	if _, exists := r.ports[p.ID]; exists {
+		r.mu.Unlock()
+		return models.Port{}, fmt.Errorf("%w: port %s already exists", ErrInvalidPort, p.ID)
+	}
+	r.ports[p.ID] = p
+	if err := r.save(); err != nil {
+		delete(r.ports, p.ID)
+		r.mu.Unlock()
+		return models.Port{}, err
+	}
+	r.mu.Unlock()
+
+	r.changed()
+	return p, nil
+}
+
+// Update заменяет данные порта
+func (r *PortRegistry) Update(id string, p models.Port) (models.Port, error) {
+	p.ID = id
+	if err := normalizePort(&p); err != nil {
+		return models.Port{}, err
+	}
+	p.UpdatedAt = time.Now().UTC()
+
+	r.mu.Lock()
+	prev, ok := r.ports[id]
+	if !ok {
+		r.mu.Unlock()
+		return models.Port{}, ErrPortNotFound
+	}
+	r.ports[id] = p
+	if err := r.save(); err != nil {
+		r.ports[id] = prev
+		r.mu.Unlock()
+		return models.Port{}, err
+	}
+	r.mu.Unlock()
+
+	r.changed()
+	return p, nil
+}
+
+// Delete удаляет порт
+func (r *PortRegistry) Delete(id string) error {
+	r.mu.Lock()
+	prev, ok := r.ports[id]
+	if !ok {
+		r.mu.Unlock()
+		return ErrPortNotFound
+	}
+	delete(r.ports, id)
+	if err := r.save(); err != nil {
+		r.ports[id] = prev
+		r.mu.Unlock()
+		return err
+	}
+	r.mu.Unlock()
+
+	r.changed()
+	return nil
+}
+
+// Import добавляет порты или заменяет порты с теми же ID; возвращает число записанных портов
+func (r *PortRegistry) Import(ports []models.Port) (int, error) {
+	now := time.Now().UTC()
+	for i := range ports {
+		if err := normalizePort(&ports[i]); err != nil {
+			return 0, fmt.Errorf("port %d (%s): %w", i+1, ports[i].Name, err)
+		}
+		ports[i].UpdatedAt = now
+	}
+
+	r.mu.Lock()
+	prev := make(map[string]models.Port, len(r.ports))
+	for id, p := range r.ports {
+		prev[id] = p
+	}
+	for _, p := range ports {
+		r.ports[p.ID] = p
+	}
+	if err := r.save(); err != nil {
+		r.ports = prev
+		r.mu.Unlock()
+		return 0, err
+	}
+	r.mu.Unlock()
+
+	r.changed()
+	return len(ports), nil
+}
+
+// changed оповещает подписчиков; вызывается без блокировки. Снимок берется под notifyMu,
+// поэтому при одновременных изменениях последним приходит самый новый.
+func (r *PortRegistry) changed() {
+	r.notifyMu.Lock()
+	defer r.notifyMu.Unlock()
+
+	r.mu.RLock()
+	listeners := slices.Clone(r.listeners)
+	r.mu.RUnlock()
+
+	ports := r.List()
+	for _, fn := range listeners {
+		fn(ports)
+	}
+}
+
+// save записывает реестр на диск; вызывается под блокировкой
+func (r *PortRegistry) save() error {
+	ports := make([]models.Port, 0, len(r.ports))
+	for _, p := range r.ports {
+		ports = append(ports, p)
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].ID < ports[j].ID })
+	return saveJSON(r.path, ports)
+}
+
+var (
+	locodePattern = regexp.MustCompile(`^[A-Z]{2} ?[A-Z2-9]{3}$`)
+	slugPattern   = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// normalizePort проверяет порт и приводит поля к единому виду
+func normalizePort(p *models.Port) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidPort)
+	}
+	if p.Lat < -90 || p.Lat > 90 || p.Lon < -180 || p.Lon > 180 || (p.Lat == 0 && p.Lon == 0) {
+		return fmt.Errorf("%w: position out of range", ErrInvalidPort)
+	}
+
+	p.LOCODE = strings.ToUpper(strings.TrimSpace(p.LOCODE))
+	if p.LOCODE != "" {
+		if !locodePattern.MatchString(p.LOCODE) {
+			return fmt.Errorf("%w: locode must look like \"RU MMK\"", ErrInvalidPort)
+		}
+		p.LOCODE = p.LOCODE[:2] + " " + strings.TrimSpace(p.LOCODE[2:])
+		if p.Country == "" {
+			p.Country = p.LOCODE[:2]
+		}
+	}
+	p.Country = strings.ToUpper(strings.TrimSpace(p.Country))
+
+	p.ID = strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(p.ID), "_"), "_")
+	if p.ID == "" && p.LOCODE != "" {
+		p.ID = strings.ToLower(strings.ReplaceAll(p.LOCODE, " ", ""))
+	}
+	if p.ID == "" {
+		p.ID = strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(p.Name), "_"), "_")
+	}
+	if p.ID == "" {
+		return fmt.Errorf("%w: id is required for a name without latin letters", ErrInvalidPort)
+	}
+
+	switch p.Kind {
+	case "":
+		p.Kind = HavenPort
+	case HavenPort, HavenRefuge:
+	default:
+		return fmt.Errorf("%w: kind must be %s or %s", ErrInvalidPort, HavenPort, HavenRefuge)
+	}
+	if p.MaxDraft < 0 || p.Berths < 0 {
+		return fmt.Errorf("%w: max_draft and berths must not be negative", ErrInvalidPort)
+	}
+	if p.IceClass != "" && IceClassRank(p.IceClass) == 0 {
+		return fmt.Errorf("%w: unknown ice class %q", ErrInvalidPort, p.IceClass)
+	}
+	for _, m := range p.IceMonths {
+		if m < 1 || m > 12 {
+			return fmt.Errorf("%w: ice months must be 1-12", ErrInvalidPort)
+		}
+	}
+	for i, a := range p.Approach {
+		if a.Lat < -90 || a.Lat > 90 || a.Lon < -180 || a.Lon > 180 {
+			return fmt.Errorf("%w: approach point %d out of range", ErrInvalidPort, i+1)
+		}
+	}
+
+	p.AltNames = cleanList(p.AltNames, false)
+	p.Services = cleanList(p.Services, true)
+	return nil
+}
+
+// cleanList убирает пустые значения и повторы; при lower приводит к нижнему регистру
+func cleanList(values []string, lower bool) []string {
+	var out []string
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if lower {
+			v = strings.ToLower(v)
+		}
+		if v != "" && !slices.Contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// ==============================
+// ЗАГРУЗКА ПОРТОВ ИЗ ФАЙЛОВ
+// ==============================
+
+// ReadPorts читает порты из CSV (.csv) или GeoJSON (.geojson, .json)
+func ReadPorts(path string) ([]models.Port, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ports []models.Port
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		ports, err = ParsePortsCSV(f)
+	case ".geojson", ".json":
+		ports, err = ParsePortsGeoJSON(f)
+	default:
+		return nil, fmt.Errorf("%s: unsupported ports format", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return ports, nil
+}
+
+// portColumns - названия колонок CSV, включая варианты из World Port Index
+var portColumns = map[string]string{
+	"id": "id", "locode": "locode", "un/locode": "locode", "unlocode": "locode",
+	"name": "name", "main port name": "name", "port name": "name",
+	"alt_names": "alt_names", "alternate port name": "alt_names",
+	"country": "country", "country code": "country",
+	"kind": "kind",
+	"lat":  "lat", "latitude": "lat",
+	"lon": "lon", "lng": "lon", "longitude": "lon",
+	"max_draft": "max_draft", "maximum vessel draft (m)": "max_draft",
+	"berths":     "berths",
+	"services":   "services",
+	"ice_class":  "ice_class",
+	"ice_months": "ice_months",
+	"approach":   "approach",
+}
+
+// ParsePortsCSV читает порты из CSV с заголовком. Списки разделяются ";",
+// подходные точки задаются парами "широта долгота" через ";". Строки с "#" - комментарии.
+func ParsePortsCSV(r io.Reader) ([]models.Port, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, h := range header {
+		if name, ok := portColumns[strings.ToLower(strings.TrimSpace(h))]; ok {
+			columns[name] = i
+		}
+	}
+	for _, required := range []string{"name", "lat", "lon"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: column %s is required", ErrInvalidPort, required)
+		}
+	}
+
+	var ports []models.Port
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return ports, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		p, err := portFromFields(field)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ports = append(ports, p)
+	}
+}
+
+// ParsePortsGeoJSON читает порты из FeatureCollection точек; свойства называются как колонки CSV,
+// списки задаются массивами или строками через ";"
+func ParsePortsGeoJSON(r io.Reader) ([]models.Port, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	fc, err := geojson.UnmarshalFeatureCollection(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPort, err)
+	}
+
+	ports := make([]models.Port, 0, len(fc.Features))
+	for i, f := range fc.Features {
+		pt, ok := f.Geometry.(orb.Point)
+		if !ok {
+			return nil, fmt.Errorf("%w: feature %d is not a point", ErrInvalidPort, i+1)
+		}
+		props := make(map[string]string)
+		for key, v := range f.Properties {
+			name, ok := portColumns[strings.ToLower(key)]
+			if !ok {
+				continue
+			}
+			switch v := v.(type) {
+			case string:
+				props[name] = v
+			case float64:
+				props[name] = strconv.FormatFloat(v, 'f', -1, 64)
+			case []interface{}:
+				items := make([]string, 0, len(v))
+				for _, item := range v {
+					if b, err := json.Marshal(item); err == nil {
+						items = append(items, strings.Trim(string(b), `"`))
+					}
+				}
+				props[name] = strings.Join(items, ";")
+			}
+		}
+		props["lon"] = strconv.FormatFloat(pt[0], 'f', -1, 64)
+		props["lat"] = strconv.FormatFloat(pt[1], 'f', -1, 64)
+
+		p, err := portFromFields(func(name string) string { return strings.TrimSpace(props[name]) })
+		if err != nil {
+			return nil, fmt.Errorf("feature %d: %w", i+1, err)
+		}
+		ports = append(ports, p)
+	}
+	return ports, nil
+}
+
+// portFromFields собирает порт из текстовых полей
+func portFromFields(field func(string) string) (models.Port, error) {
+	p := models.Port{
+		ID:       field("id"),
+		LOCODE:   field("locode"),
+		Name:     field("name"),
+		AltNames: splitSemicolon(field("alt_names")),
+		Country:  field("country"),
+		Kind:     field("kind"),
+		Services: splitSemicolon(field("services")),
+		IceClass: field("ice_class"),
+	}
+
+	numbers := []struct {
+		name string
+		dst  *float64
+	}{{"lat", &p.Lat}, {"lon", &p.Lon}, {"max_draft", &p.MaxDraft}}
+	for _, n := range numbers {
+		if v := field(n.name); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return models.Port{}, fmt.Errorf("%w: %s %q", ErrInvalidPort, n.name, v)
+			}
+			*n.dst = f
+		}
+	}
+	if v := field("berths"); v != "" {
+		berths, err := strconv.Atoi(v)
+		if err != nil {
+			return models.Port{}, fmt.Errorf("%w: berths %q", ErrInvalidPort, v)
+		}
+		p.Berths = berths
+	}
+	for _, v := range splitSemicolon(field("ice_months")) {
+		m, err := strconv.Atoi(v)
+		if err != nil {
+			return models.Port{}, fmt.Errorf("%w: ice month %q", ErrInvalidPort, v)
+		}
+		p.IceMonths = append(p.IceMonths, m)
+	}
+	for _, v := range splitSemicolon(field("approach")) {
+		parts := strings.Fields(strings.ReplaceAll(v, ",", " "))
+		if len(parts) != 2 {
+			return models.Port{}, fmt.Errorf("%w: approach point %q", ErrInvalidPort, v)
+		}
+		lat, err1 := strconv.ParseFloat(parts[0], 64)
+		lon, err2 := strconv.ParseFloat(parts[1], 64)
+		if err1 != nil || err2 != nil {
+			return models.Port{}, fmt.Errorf("%w: approach point %q", ErrInvalidPort, v)
+		}
+		p.Approach = append(p.Approach, models.Point{Name: p.Name + " approach", Lat: lat, Lon: lon})
+	}
+	return p, nil
+}
+
+// splitSemicolon разбирает список через ";"
+func splitSemicolon(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ";") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
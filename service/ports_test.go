@@ -0,0 +1,52 @@
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+func TestPortRegistryNotifiesLatestLast(t *testing.T) {
+	r, err := NewPortRegistry(filepath.Join(t.TempDir(), "ports.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Import(DefaultPorts()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Медленный подписчик: без упорядочивания оповещений старый снимок приходит последним
+	var mu sync.Mutex
+	var last []models.Port
+	r.OnChange(func(ports []models.Port) {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		last = ports
+		mu.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p, _ := r.Get("murmansk")
+			p.Name = fmt.Sprintf("Murmansk %d", i)
+			if _, err := r.Update("murmansk", p); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	want, _ := r.Get("murmansk")
+	i := slices.IndexFunc(last, func(p models.Port) bool { return p.ID == "murmansk" })
+	if i < 0 || last[i].Name != want.Name {
+		t.Errorf("listener saw %+v last, registry has %q", last, want.Name)
+	}
+}
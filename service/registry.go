@@ -0,0 +1,311 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// ==============================
+// РЕЕСТР СУДОВ
+// ==============================
+
+// registryFlushInterval - как часто изменения из потока AIS сбрасываются на диск
+const registryFlushInterval = 30 * time.Second
+
+// ErrInvalidVessel - некорректные ручные правки
+var ErrInvalidVessel = errors.New("invalid vessel")
+
+// VesselRegistry - постоянный реестр судов по MMSI с поиском по IMO.
+// Статические сообщения AIS сливаются с накопленными данными,
+// смены названия сохраняются в истории.
+type VesselRegistry struct {
+	path string
+
+	mu      sync.RWMutex
+	vessels map[int32]*models.Vessel
+	byIMO   map[int32]int32 // IMO -> MMSI
+	dirty   bool
+}
+
+// NewVesselRegistry загружает реестр из path и запускает периодическое сохранение
+func NewVesselRegistry(path string) (*VesselRegistry, error) {
+	var vessels []models.Vessel
+	if err := loadJSON(path, &vessels); err != nil {
+		return nil, fmt.Errorf("load vessels: %w", err)
+	}
+
+	r := &VesselRegistry{
+		path:    path,
+		vessels: make(map[int32]*models.Vessel),
+		byIMO:   make(map[int32]int32),
+	}
+	for i := range vessels {
+		v := vessels[i]
+		r.vessels[v.MMSI] = &v
+		r.indexIMO(&v, 0)
+	}
+
+	go r.flushLoop()
+	return r, nil
+}
+
+// indexIMO обновляет индекс IMO после изменения записи; prev - номер IMO
+// до изменения. Вызывается под блокировкой.
+func (r *VesselRegistry) indexIMO(v *models.Vessel, prev int32) {
+	imo := v.Profile().IMO
+	if prev != 0 && prev != imo && r.byIMO[prev] == v.MMSI {
+		delete(r.byIMO, prev)
+		// Номер может остаться за другим судном, например после смены MMSI
+		var owner *models.Vessel
+		for _, other := range r.vessels {
+			if other.Profile().IMO == prev && (owner == nil || other.UpdatedAt.After(owner.UpdatedAt)) {
+				owner = other
+			}
+		}
+		if owner != nil {
+			r.byIMO[prev] = owner.MMSI
+		}
+	}
+	if imo != 0 {
+		r.byIMO[imo] = v.MMSI
+	}
+}
+
+// flushLoop сохраняет накопившиеся изменения
+func (r *VesselRegistry) flushLoop() {
+	ticker := time.NewTicker(registryFlushInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := r.Flush(); err != nil {
+			log.Println("Vessel registry save error:", err)
+		}
+	}
+}
+
+// Flush сохраняет реестр, если он изменился
+func (r *VesselRegistry) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.dirty {
+		return nil
+	}
+	if err := r.save(); err != nil {
+		return err
+	}
+	r.dirty = false
+	return nil
+}
+
+// save записывает реестр на диск; вызывается под блокировкой
+func (r *VesselRegistry) save() error {
+	vessels := make([]models.Vessel, 0, len(r.vessels))
+	for _, v := range r.vessels {
+		vessels = append(vessels, *v)
+	}
+	sort.Slice(vessels, func(i, j int) bool { return vessels[i].MMSI < vessels[j].MMSI })
+	return saveJSON(r.path, vessels)
+}
+
+// Observe сливает статическое сообщение AIS с записью судна.
+// Сообщение 24 приходит частями, поэтому пустые поля не затирают известные.
+func (r *VesselRegistry) Observe(static models.ShipStatic) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	v, ok := r.vessels[static.MMSI]
+	if !ok {
+		v = &models.Vessel{MMSI: static.MMSI, FirstSeen: static.Timestamp}
+		r.vessels[static.MMSI] = v
+	}
+
+	prevIMO := v.Profile().IMO
+	ais := &v.AIS
+	ais.MMSI = static.MMSI
+	ais.Timestamp = static.Timestamp
+	if static.IMO != 0 {
+		ais.IMO = static.IMO
+	}
+	if static.CallSign != "" {
+		ais.CallSign = static.CallSign
+	}
+	if static.ShipType != 0 {
+		ais.ShipType = static.ShipType
+	}
+	if static.Length != 0 {
+		ais.Length = static.Length
+	}
+	if static.Beam != 0 {
+		ais.Beam = static.Beam
+	}
+	if static.Draught != 0 {
+		ais.Draught = static.Draught
+	}
+	if static.Destination != "" {
+		ais.Destination = static.Destination
+	}
+	if static.Name != "" && static.Name != ais.Name {
+		ais.Name = static.Name
+		recordName(v, static.Name, static.Timestamp)
+	}
+
+	v.UpdatedAt = static.Timestamp
+	r.indexIMO(v, prevIMO)
+	r.dirty = true
+}
+
+// recordName закрывает текущее название в истории и открывает новое
+func recordName(v *models.Vessel, name string, at time.Time) {
+	if n := len(v.NameHistory); n > 0 {
+		last := &v.NameHistory[n-1]
+		if last.Name == name {
+			return
+		}
+		closed := at
+		last.To = &closed
+	}
+	v.NameHistory = append(v.NameHistory, models.NameChange{Name: name, From: at})
+}
+
+// Get возвращает запись судна по MMSI
+func (r *VesselRegistry) Get(mmsi int32) (models.Vessel, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v, ok := r.vessels[mmsi]
+	if !ok {
+		return models.Vessel{}, false
+	}
+	return copyVessel(v), true
+}
+
+// ByIMO возвращает запись судна по номеру IMO
+func (r *VesselRegistry) ByIMO(imo int32) (models.Vessel, bool) {
+	r.mu.RLock()
+	mmsi, ok := r.byIMO[imo]
+	r.mu.RUnlock()
+	if !ok {
+		return models.Vessel{}, false
+	}
+	return r.Get(mmsi)
+}
+
+// copyVessel копирует запись вместе с историей названий
+func copyVessel(v *models.Vessel) models.Vessel {
+	c := *v
+	c.NameHistory = append([]models.NameChange(nil), v.NameHistory...)
+	return c
+}
+
+// Profile возвращает характеристики судна для маршрутизации
+func (r *VesselRegistry) Profile(mmsi int32) (models.VesselProfile, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v, ok := r.vessels[mmsi]
+	if !ok {
+		return models.VesselProfile{}, false
+	}
+	return v.Profile(), true
+}
+
+// DisplayName подставляет название из реестра вместо пустого или "Unknown"
+func (r *VesselRegistry) DisplayName(mmsi int32, name string) string {
+	if name != "" && name != "Unknown" {
+		return name
+	}
+	if p, ok := r.Profile(mmsi); ok && p.Name != "" {
+		return p.Name
+	}
+	return name
+}
+
+// Search ищет суда по части названия (в том числе прежнего) или позывного;
+// пустой запрос возвращает весь реестр
+func (r *VesselRegistry) Search(query string, limit int) []models.VesselProfile {
+	query = strings.ToUpper(strings.TrimSpace(query))
+
+	r.mu.RLock()
+	result := make([]models.VesselProfile, 0)
+	for _, v := range r.vessels {
+		if query == "" || matchesVessel(v, query) {
+			result = append(result, v.Profile())
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].MMSI < result[j].MMSI })
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// matchesVessel сравнивает запрос в верхнем регистре с названиями и позывным
+func matchesVessel(v *models.Vessel, query string) bool {
+	if strings.Contains(strings.ToUpper(v.Profile().Name), query) ||
+		strings.Contains(strings.ToUpper(v.AIS.CallSign), query) {
+		return true
+	}
+	for _, n := range v.NameHistory {
+		if strings.Contains(strings.ToUpper(n.Name), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetOverrides заменяет ручные правки судна и сразу сохраняет реестр.
+// Судно, еще не передававшее статических данных, добавляется в реестр.
+func (r *VesselRegistry) SetOverrides(mmsi int32, o models.VesselOverrides) (models.Vessel, error) {
+	if mmsi < 100000000 || mmsi > 999999999 {
+		return models.Vessel{}, fmt.Errorf("%w: %v", ErrInvalidVessel, ErrInvalidMMSI)
+	}
+	if o.Draught < 0 || o.Length < 0 || o.Beam < 0 || o.IMO < 0 {
+		return models.Vessel{}, fmt.Errorf("%w: dimensions must not be negative", ErrInvalidVessel)
+	}
+	o.Name = strings.TrimSpace(o.Name)
+	o.IceClass = strings.TrimSpace(o.IceClass)
+	o.Owner = strings.TrimSpace(o.Owner)
+	o.Operator = strings.TrimSpace(o.Operator)
+	capabilities := make([]string, 0, len(o.Capabilities))
+	for _, c := range o.Capabilities {
+		if c = strings.ToLower(strings.TrimSpace(c)); c != "" && !slices.Contains(capabilities, c) {
+			capabilities = append(capabilities, c)
+		}
+	}
+	o.Capabilities = nil
+	if len(capabilities) > 0 {
+		o.Capabilities = capabilities
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	v, ok := r.vessels[mmsi]
+	if !ok {
+		now := time.Now().UTC()
+		v = &models.Vessel{MMSI: mmsi, FirstSeen: now}
+		r.vessels[mmsi] = v
+	}
+	prev := v.Overrides
+	prevIMO := v.Profile().IMO
+	v.Overrides = o
+	v.UpdatedAt = time.Now().UTC()
+
+	if err := r.save(); err != nil {
+		v.Overrides = prev
+		if !ok {
+			delete(r.vessels, mmsi)
+		}
+		return models.Vessel{}, err
+	}
+	r.dirty = false
+	r.indexIMO(v, prevIMO)
+	return copyVessel(v), nil
+}
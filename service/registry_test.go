@@ -0,0 +1,72 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+func TestVesselRegistryIMOIndex(t *testing.T) {
+	r, err := NewVesselRegistry(filepath.Join(t.TempDir(), "vessels.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		apply func()
+		want  map[int32]int32 // IMO -> MMSI; 0 - номер не найден
+	}{
+		{
+			name:  "static report",
+			apply: func() { r.Observe(models.ShipStatic{MMSI: 273000101, IMO: 9000001, Timestamp: at}) },
+			want:  map[int32]int32{9000001: 273000101},
+		},
+		{
+			name:  "corrected IMO in AIS drops the old number",
+			apply: func() { r.Observe(models.ShipStatic{MMSI: 273000101, IMO: 9000002, Timestamp: at.Add(time.Hour)}) },
+			want:  map[int32]int32{9000001: 0, 9000002: 273000101},
+		},
+		{
+			name: "manual override replaces the AIS number",
+			apply: func() {
+				if _, err := r.SetOverrides(273000101, models.VesselOverrides{IMO: 9000003}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: map[int32]int32{9000002: 0, 9000003: 273000101},
+		},
+		{
+			name: "clearing the override restores the AIS number",
+			apply: func() {
+				if _, err := r.SetOverrides(273000101, models.VesselOverrides{}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: map[int32]int32{9000003: 0, 9000002: 273000101},
+		},
+		{
+			name: "number stays with the other vessel after reflagging",
+			apply: func() {
+				r.Observe(models.ShipStatic{MMSI: 311000101, IMO: 9000002, Timestamp: at.Add(2 * time.Hour)})
+				r.Observe(models.ShipStatic{MMSI: 311000101, IMO: 9000004, Timestamp: at.Add(3 * time.Hour)})
+			},
+			want: map[int32]int32{9000002: 273000101, 9000004: 311000101},
+		},
+	}
+	for _, tt := range tests {
+		tt.apply()
+		for imo, mmsi := range tt.want {
+			v, found := r.ByIMO(imo)
+			if mmsi == 0 && found {
+				t.Errorf("%s: IMO %d still resolves to %d", tt.name, imo, v.MMSI)
+			}
+			if mmsi != 0 && (!found || v.MMSI != mmsi) {
+				t.Errorf("%s: IMO %d resolves to %d (found %v), want %d", tt.name, imo, v.MMSI, found, mmsi)
+			}
+		}
+	}
+}
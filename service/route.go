@@ -3,6 +3,8 @@ package service
 import (
 	"container/heap"
 	"math"
+	"slices"
+	"sync"
 
 	"github.com/paulmach/orb"
 	"github.com/paulmach/orb/planar"
@@ -30,10 +32,13 @@ type NavNode struct {
 
 // NavEdge - ребро между узлами в графе
 type NavEdge struct {
-	From     string  `json:"from"`     // ID начального узла
-	To       string  `json:"to"`       // ID конечного узла
-	Distance float64 `json:"distance"` // Расстояние в метрах
-	Cost     float64 `json:"cost"`     // Стоимость прохождения
+	From     string     `json:"from"`     // ID начального узла
+	To       string     `json:"to"`       // ID конечного узла
+	Distance float64    `json:"distance"` // Расстояние в метрах
+	Cost     float64    `json:"cost"`     // Стоимость прохождения
+	Limits   EdgeLimits `json:"limits"`   // Ограничения по осадке и льду
+
+	multiplier float64 // Исходный множитель стоимости
 }
 
 // ==============================
@@ -103,14 +108,57 @@ func (g *GeoUtils) IntermediatePoint(p1, p2 models.Point, fraction float64) mode
 	}
 }
 
+// Destination вычисляет точку на заданном расстоянии (м) и азимуте (градусы) от исходной
+func (g *GeoUtils) Destination(p models.Point, bearing, distance float64) models.Point {
+	lat1 := p.Lat * math.Pi / 180
+	lon1 := p.Lon * math.Pi / 180
+	brng := bearing * math.Pi / 180
+	δ := distance / 6371000 // Угловое расстояние в радианах
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(δ) +
+		math.Cos(lat1)*math.Sin(δ)*math.Cos(brng))
+	lon2 := lon1 + math.Atan2(
+		math.Sin(brng)*math.Sin(δ)*math.Cos(lat1),
+		math.Cos(δ)-math.Sin(lat1)*math.Sin(lat2))
+
+	lon := math.Mod(lon2*180/math.Pi+540, 360) - 180 // Нормализация в [-180, 180)
+
+	return models.Point{
+		Name: p.Name,
+		Lat:  lat2 * 180 / math.Pi,
+		Lon:  lon,
+	}
+}
+
+// CrossTrack вычисляет боковое отклонение точки p от дуги a-b и пройденное вдоль нее
+// расстояние (м). Отклонение положительно справа от линии пути.
+// Расстояние вдоль дуги отрицательно, если точка позади a.
+func (g *GeoUtils) CrossTrack(p, a, b models.Point) (xte, along float64) {
+	const R = 6371000
+
+	d13 := g.Distance(a, p) / R
+	θ13 := g.Bearing(a, p) * math.Pi / 180
+	θ12 := g.Bearing(a, b) * math.Pi / 180
+
+	xt := math.Asin(math.Sin(d13) * math.Sin(θ13-θ12))
+	at := math.Acos(math.Max(-1, math.Min(1, math.Cos(d13)/math.Cos(xt))))
+	if math.Cos(θ13-θ12) < 0 {
+		at = -at
+	}
+	return xt * R, at * R
+}
+
 // ==============================
 // СИСТЕМА ОПРЕДЕЛЕНИЯ СУШИ
 // ==============================
 
 // LandDetector - определяет, находится ли точка на суше
 type LandDetector struct {
+	mu           sync.RWMutex
 	landPolygons []orb.Polygon // Полигоны суши
+	landBounds   []orb.Bound   // Границы полигонов для быстрой отсечки
 	region       orb.Bound     // Границы региона
+	listeners    []func()
 }
 
 // NewLandDetector создает детектор суши для региона
@@ -134,7 +182,41 @@ func (ld *LandDetector) AddLandPolygon(points []models.Point) {
 	ring = append(ring, ring[0])
 
 	polygon := orb.Polygon{ring}
+	ld.mu.Lock()
 	ld.landPolygons = append(ld.landPolygons, polygon)
+	ld.landBounds = append(ld.landBounds, polygon.Bound())
+	ld.mu.Unlock()
+}
+
+// SetLandPolygons заменяет все полигоны суши (координаты orb: [lon, lat])
+func (ld *LandDetector) SetLandPolygons(polygons []orb.Polygon) {
+	bounds := make([]orb.Bound, len(polygons))
+	for i, p := range polygons {
+		bounds[i] = p.Bound()
+	}
+	ld.mu.Lock()
+	ld.landPolygons = polygons
+	ld.landBounds = bounds
+	listeners := slices.Clone(ld.listeners)
+	ld.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn()
+	}
+}
+
+// OnChange подписывает fn на замену полигонов суши SetLandPolygons
+func (ld *LandDetector) OnChange(fn func()) {
+	ld.mu.Lock()
+	ld.listeners = append(ld.listeners, fn)
+	ld.mu.Unlock()
+}
+
+// PolygonCount возвращает число полигонов суши
+func (ld *LandDetector) PolygonCount() int {
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+	return len(ld.landPolygons)
 }
 
 // IsLand определяет, находится ли точка на суше
@@ -146,9 +228,11 @@ func (ld *LandDetector) IsLand(point models.Point) bool {
 		return false
 	}
 
-	// Проверка всех полигонов суши
-	for _, polygon := range ld.landPolygons {
-		if planar.PolygonContains(polygon, orbPoint) {
+	// Проверка полигонов суши, в границы которых попадает точка
+	ld.mu.RLock()
+	defer ld.mu.RUnlock()
+	for i, polygon := range ld.landPolygons {
+		if ld.landBounds[i].Contains(orbPoint) && planar.PolygonContains(polygon, orbPoint) {
 			return true
 		}
 	}
@@ -236,6 +320,8 @@ func (ng *NavigationGraph) AddEdge(fromID, toID string, costMultiplier float64)
 		To:       toID,
 		Distance: distance,
 		Cost:     distance * costMultiplier,
+
+		multiplier: costMultiplier,
 	}
 
 	ng.edges[fromID] = append(ng.edges[fromID], edge)
@@ -329,6 +415,9 @@ func (ng *NavigationGraph) FindPath(startID, endID string) []*NavNode {
 		if current.nodeID == endID {
 			return ng.reconstructPath(cameFrom, current)
 		}
+		if current.cost > gScore[current.nodeID] {
+			continue // Устаревшая запись очереди: узел уже достигнут дешевле
+		}
 
 		for _, edge := range ng.edges[current.nodeID] {
 			tentativeG := gScore[current.nodeID] + edge.Cost
@@ -345,6 +434,7 @@ func (ng *NavigationGraph) FindPath(startID, endID string) []*NavNode {
 					cost:      tentativeG,
 					heuristic: heuristic,
 					total:     total,
+					parent:    current,
 				}
 
 				heap.Push(&openSet, neighbor)
@@ -388,6 +478,11 @@ func NewMarineRouter(minLat, maxLat, minLon, maxLon float64) *MarineRouter {
 	}
 }
 
+// UseLandDetector подключает общий детектор суши, например построенный из береговой линии OSM
+func (mr *MarineRouter) UseLandDetector(ld *LandDetector) {
+	mr.landDetector = ld
+}
+
 // CalculateRoute вычисляет морской маршрут между точками
 func (mr *MarineRouter) CalculateRoute(start, end models.Point) *Route {
 	// 1. Проверяем и корректируем точки
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// ==============================
+// ПОИСК И СПАСАНИЕ
+// ==============================
+
+// ErrNoPassage - судно не может дойти до точки
+var ErrNoPassage = errors.New("no passage")
+
+// Passage - путь судна до произвольной точки
+type Passage struct {
+	Route    Route     `json:"route"`
+	Distance float64   `json:"distance"` // Длина пути, м
+	Direct   float64   `json:"direct"`   // Расстояние по прямой, м
+	Duration float64   `json:"duration"` // Время перехода, с
+	ETA      time.Time `json:"eta"`
+}
+
+// passageTarget - точка назначения с подходами к ней от поворотных точек
+type passageTarget struct {
+	point      models.Point
+	ice        IceRequirement     // Ледовые условия в районе точки
+	approaches map[string]float64 // Узел -> длина подхода от него, м
+	far        bool               // Поблизости нет поворотных точек, подход не проверен
+}
+
+// target готовит подходы к точке; если поблизости нет поворотных точек, подход идет от ближайшей
+func (p *DiversionPlanner) target(to models.Point, env *passageEnv) passageTarget {
+	t := passageTarget{point: to, ice: p.regionIce(to), approaches: make(map[string]float64)}
+
+	var nearest *NavNode
+	nearestDist := math.MaxFloat64
+	for _, node := range p.router.navGraph.nodes {
+		if node.Type != "waypoint" {
+			continue
+		}
+		d := p.router.geo.Distance(node.Point, to)
+		if d < nearestDist {
+			nearest, nearestDist = node, d
+		}
+		if d <= p.cfg.ConnectRadius && !p.router.landDetector.crosses(node.Point, to) && p.clear(node.Point, to, env.hazards) {
+			t.approaches[node.ID] = d
+		}
+	}
+	if len(t.approaches) == 0 && nearest != nil {
+		t.approaches[nearest.ID] = nearestDist
+		t.far = true
+	}
+	return t
+}
+
+// passage прокладывает самый быстрый путь от req.From до точки:
+// напрямую, если точка рядом и путь свободен, иначе по сети морских путей
+func (p *DiversionPlanner) passage(req DiversionRequest, t passageTarget, env *passageEnv) (Passage, error) {
+	if !t.ice.Permits(req.Profile.IceClass, req.At) {
+		return Passage{}, fmt.Errorf("%w: ice class %s required at the position", ErrNoPassage, t.ice.IceClass)
+	}
+	inIce := t.ice.InSeason(req.At)
+	direct := p.router.geo.Distance(req.From, t.point)
+
+	best := Passage{Direct: direct, Duration: math.MaxFloat64}
+	best.Route.IsSafe, best.Route.Message = true, "Маршрут успешно построен"
+	if direct <= p.cfg.ConnectRadius && !p.router.landDetector.crosses(req.From, t.point) &&
+		p.free(req.From, t.point, req.At, after(req.At, p.legDuration(direct, req, inIce)), env) {
+		best.Route.Points = []models.Point{req.From, t.point}
+		best.Distance = direct
+		best.Duration = p.legDuration(direct, req, inIce)
+	}
+
+	legs, far := p.search(req, env)
+	for id, approach := range t.approaches {
+		leg, ok := legs[id]
+		if !ok {
+			continue
+		}
+		duration := leg.duration + p.legDuration(approach, req, inIce)
+		if duration < best.Duration && p.clearOfDrift(p.router.navGraph.nodes[id].Point, t.point,
+			after(req.At, leg.duration), after(req.At, duration), env) {
+			best.Route.Points = append(p.pathPoints(legs, id, req.From), t.point)
+			best.Distance = leg.distance + approach
+			best.Duration = duration
+			best.Route.IsSafe, best.Route.Message = p.assess(legs, id, req, far || t.far)
+		}
+	}
+	if best.Duration == math.MaxFloat64 {
+		return Passage{}, fmt.Errorf("%w: no route within draught, ice and hazard limits", ErrNoPassage)
+	}
+
+	best.Route.Length = best.Distance
+	best.ETA = req.At.Add(time.Duration(best.Duration * float64(time.Second)))
+	return best, nil
+}
+
+// SARConfig - параметры подбора судов для спасательной операции
+type SARConfig struct {
+	MaxDistance float64       // Суда дальше этого расстояния по прямой не рассматриваются, м
+	MaxAge      time.Duration // Суда с более старой позицией не рассматриваются
+	MinSpeed    float64       // При меньшей скорости судна берется скорость по умолчанию, узлы
+	Speed       float64       // Скорость по умолчанию, узлы
+}
+
+// DefaultSARConfig - 300 миль, позиции не старше 6 часов
+func DefaultSARConfig() SARConfig {
+	return SARConfig{
+		MaxDistance: 300 * metersPerNM,
+		MaxAge:      6 * time.Hour,
+		MinSpeed:    3,
+		Speed:       10,
+	}
+}
+
+// SARCandidate - судно, которое может подойти к месту бедствия
+type SARCandidate struct {
+	MMSI      int32        `json:"mmsi"`
+	Name      string       `json:"name"`
+	Position  models.Point `json:"position"` // Позиция на момент запроса (со счислением)
+	Age       float64      `json:"age"`      // Возраст последнего сообщения, с
+	Estimated bool         `json:"estimated"`
+	Stale     bool         `json:"stale"`
+	NavStatus int32        `json:"nav_status"`
+	Speed     float64      `json:"speed"` // Скорость перехода, узлы
+	Passage
+
+	Profile      models.VesselProfile `json:"profile"`
+	Capabilities []string             `json:"capabilities"`
+}
+
+// SARUnavailable - судно поблизости, которое не может подойти к месту бедствия
+type SARUnavailable struct {
+	MMSI   int32   `json:"mmsi"`
+	Name   string  `json:"name"`
+	Direct float64 `json:"direct"` // м
+	Reason string  `json:"reason"`
+}
+
+// SARResult - суда, способные быстрее всех подойти к месту бедствия
+type SARResult struct {
+	Position    models.Point     `json:"position"`
+	Place       *PlaceMatch      `json:"place,omitempty"` // Место, найденное по названию в запросе
+	At          time.Time        `json:"at"`
+	Candidates  []SARCandidate   `json:"candidates"`
+	Unavailable []SARUnavailable `json:"unavailable"`
+}
+
+// SARAssist - подбор судов для поиска и спасания по позициям из ShipStore,
+// характеристикам из реестра судов и путям по сети морских путей
+type SARAssist struct {
+	store    *ShipStore
+	registry *VesselRegistry
+	planner  *DiversionPlanner
+	cfg      SARConfig
+}
+
+// NewSARAssist создает подбор судов для спасательных операций
+func NewSARAssist(store *ShipStore, registry *VesselRegistry, planner *DiversionPlanner, cfg SARConfig) *SARAssist {
+	return &SARAssist{store: store, registry: registry, planner: planner, cfg: cfg}
+}
+
+// Candidates возвращает до limit судов, быстрее всех достигающих position после момента at.
+// Судно exclude (терпящее бедствие) не рассматривается.
+func (s *SARAssist) Candidates(position models.Point, at time.Time, exclude int32, limit int) (SARResult, error) {
+	if position.Lat < -90 || position.Lat > 90 || position.Lon < -180 || position.Lon > 180 {
+		return SARResult{}, fmt.Errorf("%w: position out of range", ErrInvalidDiversion)
+	}
+
+	result := SARResult{
+		Position:    position,
+		At:          at,
+		Candidates:  make([]SARCandidate, 0),
+		Unavailable: make([]SARUnavailable, 0),
+	}
+	planner := s.planner.current()
+	env := planner.environment(at)
+	target := planner.target(position, env)
+	geo := &GeoUtils{}
+
+	for _, est := range s.store.Estimates(at) {
+		if est.MMSI == exclude || est.Age > s.cfg.MaxAge.Seconds() {
+			continue
+		}
+		from := models.Point{Name: est.Name, Lat: est.PredictedLatitude, Lon: est.PredictedLongitude}
+		direct := geo.Distance(from, position)
+		if direct > s.cfg.MaxDistance {
+			continue
+		}
+
+		profile, found := s.registry.Profile(est.MMSI)
+		if !found {
+			profile = models.VesselProfile{MMSI: est.MMSI, Name: est.Name, ShipType: est.ShipType}
+		}
+		speed := s.cfg.Speed
+		if est.HasMotion() && est.SOG >= s.cfg.MinSpeed {
+			speed = est.SOG
+		}
+
+		req := DiversionRequest{From: from, At: at, Profile: profile, Speed: speed}
+		passage, err := planner.passage(req, target, env)
+		if err != nil {
+			result.Unavailable = append(result.Unavailable, SARUnavailable{
+				MMSI: est.MMSI, Name: est.Name, Direct: direct, Reason: err.Error(),
+			})
+			continue
+		}
+
+		result.Candidates = append(result.Candidates, SARCandidate{
+			MMSI:         est.MMSI,
+			Name:         est.Name,
+			Position:     from,
+			Age:          est.Age,
+			Estimated:    est.Estimated,
+			Stale:        est.Stale,
+			NavStatus:    est.NavStatus,
+			Speed:        speed,
+			Passage:      passage,
+			Profile:      profile,
+			Capabilities: Capabilities(profile),
+		})
+	}
+
+	sort.Slice(result.Candidates, func(i, j int) bool {
+		return result.Candidates[i].Duration < result.Candidates[j].Duration
+	})
+	sort.Slice(result.Unavailable, func(i, j int) bool {
+		return result.Unavailable[i].Direct < result.Unavailable[j].Direct
+	})
+	if limit > 0 && len(result.Candidates) > limit {
+		result.Candidates = result.Candidates[:limit]
+	}
+	return result, nil
+}
+
+// shipTypeCapabilities - возможности, следующие из типа судна AIS
+var shipTypeCapabilities = map[int32][]string{
+	31: {"towing"},
+	32: {"towing"},
+	51: {"sar"},
+	52: {"towing"},
+	53: {"tender"},
+	55: {"law_enforcement"},
+	58: {"medical"},
+}
+
+// Capabilities сводит возможности судна: по типу AIS, ледовому классу и ручным правкам реестра
+func Capabilities(profile models.VesselProfile) []string {
+	capabilities := make([]string, 0)
+	add := func(c string) {
+		if !slices.Contains(capabilities, c) {
+			capabilities = append(capabilities, c)
+		}
+	}
+
+	for _, c := range shipTypeCapabilities[profile.ShipType] {
+		add(c)
+	}
+	switch {
+	case profile.ShipType >= 60 && profile.ShipType <= 69:
+		add("passenger_capacity")
+	case profile.ShipType >= 80 && profile.ShipType <= 89:
+		add("tanker")
+	}
+	switch rank := IceClassRank(profile.IceClass); {
+	case rank >= 4:
+		add("arctic_class")
+	case rank > 0:
+		add("ice_strengthened")
+	}
+	for _, c := range profile.Capabilities {
+		add(c)
+	}
+	return capabilities
+}
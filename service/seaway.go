@@ -0,0 +1,329 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// ==============================
+// ЛЕДОВЫЕ КЛАССЫ И ОГРАНИЧЕНИЯ
+// ==============================
+
+// iceClassRanks - сравнимые ранги ледовых классов РМРС, полярных классов IACS
+// и финско-шведских классов; чем больше ранг, тем тяжелее допустимый лед
+var iceClassRanks = map[string]int{
+	"ICE1": 1, "1C": 1,
+	"ICE2": 2, "1B": 2,
+	"ICE3": 3, "1A": 3, "1ASUPER": 3, "1AS": 3,
+	"ARC4": 4, "PC7": 4,
+	"ARC5": 5, "PC6": 5,
+	"ARC6": 6, "PC5": 6,
+	"ARC7": 7, "PC4": 7,
+	"ARC8": 8, "PC3": 8,
+	"ARC9": 9, "PC2": 9, "PC1": 9,
+}
+
+// IceClassRank возвращает ранг ледового класса; 0 - без ледового класса или класс неизвестен
+func IceClassRank(class string) int {
+	key := strings.ToUpper(strings.NewReplacer(" ", "", "-", "", "_", "").Replace(class))
+	return iceClassRanks[key]
+}
+
+// IceRequirement - минимальный ледовый класс на время ледового сезона
+type IceRequirement struct {
+	IceClass  string `json:"ice_class,omitempty"`  // Минимальный ледовый класс; пусто - без ограничений
+	IceMonths []int  `json:"ice_months,omitempty"` // Месяцы ледового сезона (1-12); пусто - круглый год
+}
+
+// InSeason сообщает, действует ли требование в момент at
+func (r IceRequirement) InSeason(at time.Time) bool {
+	if r.IceClass == "" {
+		return false
+	}
+	if len(r.IceMonths) == 0 {
+		return true
+	}
+	month := int(at.Month())
+	for _, m := range r.IceMonths {
+		if m == month {
+			return true
+		}
+	}
+	return false
+}
+
+// Permits сообщает, может ли судно с ледовым классом iceClass пройти в момент at
+func (r IceRequirement) Permits(iceClass string, at time.Time) bool {
+	return !r.InSeason(at) || IceClassRank(iceClass) >= IceClassRank(r.IceClass)
+}
+
+// EdgeLimits - ограничения ребра навигационного графа
+type EdgeLimits struct {
+	Depth float64 `json:"depth,omitempty"` // Проходная глубина, м; 0 - без ограничений
+	IceRequirement
+}
+
+// ==============================
+// ПОРТЫ И МЕСТА УБЕЖИЩА
+// ==============================
+
+// Виды мест, куда может уйти судно
+const (
+	HavenPort   = "port"   // Порт
+	HavenRefuge = "refuge" // Место убежища: защищенный рейд или малый порт
+)
+
+// Haven - порт или место убежища
+type Haven struct {
+	ID       string         `json:"id"`
+	Name     string         `json:"name"`
+	Kind     string         `json:"kind"`
+	Point    models.Point   `json:"point"`
+	MaxDraft float64        `json:"max_draft,omitempty"` // Наибольшая допустимая осадка, м; 0 - без ограничений
+	Services []string       `json:"services,omitempty"`  // Ремонт, бункеровка, медпомощь и т.п.
+	Approach []models.Point `json:"approach,omitempty"`  // Подходные точки от моря к порту
+	IceRequirement
+}
+
+// Ледовые сезоны районов
+var (
+	whiteSeaIce = IceRequirement{IceClass: "Ice2", IceMonths: []int{12, 1, 2, 3, 4, 5}}
+	pechoraIce  = IceRequirement{IceClass: "Arc4", IceMonths: []int{12, 1, 2, 3, 4, 5}}
+	karaIce     = IceRequirement{IceClass: "Arc4", IceMonths: []int{11, 12, 1, 2, 3, 4, 5, 6}}
+	northIce    = IceRequirement{IceClass: "Arc5", IceMonths: []int{10, 11, 12, 1, 2, 3, 4, 5, 6, 7}}
+)
+
+// HavensFromPorts строит места убежища из записей реестра портов
+func HavensFromPorts(ports []models.Port) []Haven {
+	havens := make([]Haven, len(ports))
+	for i, p := range ports {
+		havens[i] = Haven{
+			ID:             p.ID,
+			Name:           p.Name,
+			Kind:           p.Kind,
+			Point:          p.Point(),
+			MaxDraft:       p.MaxDraft,
+			Services:       p.Services,
+			Approach:       p.Approach,
+			IceRequirement: IceRequirement{IceClass: p.IceClass, IceMonths: p.IceMonths},
+		}
+	}
+	return havens
+}
+
+// SeawayBBox - область, покрываемая сетью морских путей
+var SeawayBBox = models.MustParseBBox("64,28,81,85")
+
+// seawayWaypoints - поворотные точки основных морских путей
+var seawayWaypoints = []models.Point{
+	{Name: "kola_exit", Lat: 69.45, Lon: 33.75},
+	{Name: "pechenga_app", Lat: 69.85, Lon: 31.6},
+	{Name: "barents_w", Lat: 70.6, Lon: 36.0},
+	{Name: "barents_c", Lat: 70.4, Lon: 42.0},
+	{Name: "barents_n", Lat: 74.5, Lon: 48.0},
+	{Name: "barents_ne", Lat: 77.2, Lon: 60.0},
+	{Name: "kanin_n", Lat: 69.1, Lon: 43.6},
+	{Name: "voronka", Lat: 67.6, Lon: 42.2},
+	{Name: "gorlo", Lat: 66.35, Lon: 41.3},
+	{Name: "white_sea", Lat: 65.5, Lon: 38.9},
+	{Name: "dvina_bar", Lat: 64.95, Lon: 40.05},
+	{Name: "kolguev_w", Lat: 69.2, Lon: 47.0},
+	{Name: "pechora_w", Lat: 69.7, Lon: 51.5},
+	{Name: "pechora_c", Lat: 69.6, Lon: 56.0},
+	{Name: "kara_gate", Lat: 70.4, Lon: 58.0},
+	{Name: "kara_sw", Lat: 70.5, Lon: 62.0},
+	{Name: "kara_c", Lat: 72.0, Lon: 65.0},
+	{Name: "bely_n", Lat: 73.7, Lon: 70.0},
+	{Name: "ob_n", Lat: 72.9, Lon: 73.3},
+	{Name: "kara_e", Lat: 74.2, Lon: 77.5},
+	{Name: "kara_n", Lat: 75.8, Lon: 73.0},
+	{Name: "zhelaniya", Lat: 77.3, Lon: 69.5},
+	{Name: "dikson_app", Lat: 73.65, Lon: 79.9},
+}
+
+// seawayBerths - порты, к которым ведут подходы сети морских путей. Подход достается месту
+// убежища, ближайшему к порту в пределах berthRadius, независимо от его идентификатора.
+var seawayBerths = []models.Point{
+	{Name: "murmansk", Lat: 68.97, Lon: 33.07},
+	{Name: "pechenga", Lat: 69.65, Lon: 31.37},
+	{Name: "arkhangelsk", Lat: 64.54, Lon: 40.51},
+	{Name: "bugrino", Lat: 68.78, Lon: 49.3},
+	{Name: "amderma", Lat: 69.76, Lon: 61.67},
+	{Name: "sabetta", Lat: 71.27, Lon: 72.07},
+	{Name: "dikson", Lat: 73.51, Lon: 80.55},
+}
+
+// berthRadius - наибольшее расстояние от порта подхода до места убежища, которому достается подход, м
+const berthRadius = 20000
+
+// seawayLane - двусторонний участок морского пути
+type seawayLane struct {
+	a, b   string
+	limits EdgeLimits
+}
+
+// seawayLanes - участки между поворотными точками и подходы к портам seawayBerths
+var seawayLanes = []seawayLane{
+	{"murmansk", "kola_exit", EdgeLimits{}},
+	{"kola_exit", "pechenga_app", EdgeLimits{}},
+	{"pechenga_app", "pechenga", EdgeLimits{Depth: 9}},
+	{"pechenga_app", "barents_w", EdgeLimits{}},
+	{"kola_exit", "barents_w", EdgeLimits{}},
+	{"kola_exit", "kanin_n", EdgeLimits{}},
+	{"barents_w", "barents_c", EdgeLimits{}},
+	{"barents_c", "kanin_n", EdgeLimits{}},
+	{"barents_c", "kolguev_w", EdgeLimits{}},
+	{"barents_c", "barents_n", EdgeLimits{}},
+	{"kanin_n", "voronka", EdgeLimits{IceRequirement: whiteSeaIce}},
+	{"voronka", "gorlo", EdgeLimits{IceRequirement: whiteSeaIce}},
+	{"gorlo", "white_sea", EdgeLimits{IceRequirement: whiteSeaIce}},
+	{"white_sea", "dvina_bar", EdgeLimits{IceRequirement: whiteSeaIce}},
+	{"dvina_bar", "arkhangelsk", EdgeLimits{Depth: 10.2, IceRequirement: whiteSeaIce}},
+	{"kanin_n", "kolguev_w", EdgeLimits{IceRequirement: pechoraIce}},
+	{"kolguev_w", "bugrino", EdgeLimits{IceRequirement: pechoraIce}},
+	{"kolguev_w", "pechora_w", EdgeLimits{IceRequirement: pechoraIce}},
+	{"barents_n", "pechora_w", EdgeLimits{IceRequirement: pechoraIce}},
+	{"pechora_w", "pechora_c", EdgeLimits{IceRequirement: pechoraIce}},
+	{"pechora_c", "kara_gate", EdgeLimits{IceRequirement: karaIce}},
+	{"kara_gate", "kara_sw", EdgeLimits{IceRequirement: karaIce}},
+	{"kara_sw", "amderma", EdgeLimits{Depth: 5.5, IceRequirement: karaIce}},
+	{"kara_sw", "kara_c", EdgeLimits{IceRequirement: karaIce}},
+	{"kara_c", "bely_n", EdgeLimits{IceRequirement: karaIce}},
+	{"bely_n", "ob_n", EdgeLimits{IceRequirement: karaIce}},
+	{"ob_n", "sabetta", EdgeLimits{Depth: 15.1, IceRequirement: karaIce}},
+	{"bely_n", "kara_e", EdgeLimits{IceRequirement: karaIce}},
+	{"kara_e", "dikson_app", EdgeLimits{IceRequirement: karaIce}},
+	{"dikson_app", "dikson", EdgeLimits{Depth: 10, IceRequirement: karaIce}},
+	{"barents_n", "barents_ne", EdgeLimits{}},
+	{"barents_ne", "zhelaniya", EdgeLimits{IceRequirement: northIce}},
+	{"zhelaniya", "kara_n", EdgeLimits{IceRequirement: northIce}},
+	{"kara_n", "kara_e", EdgeLimits{IceRequirement: karaIce}},
+	{"kara_n", "bely_n", EdgeLimits{IceRequirement: karaIce}},
+}
+
+// AddLane добавляет двусторонний участок пути с ограничениями
+func (ng *NavigationGraph) AddLane(aID, bID string, limits EdgeLimits) {
+	ng.AddEdge(aID, bID, 1.0)
+	ng.AddEdge(bID, aID, 1.0)
+	for _, pair := range [][2]string{{aID, bID}, {bID, aID}} {
+		edges := ng.edges[pair[0]]
+		if n := len(edges); n > 0 && edges[n-1].To == pair[1] {
+			edges[n-1].Limits = limits
+		}
+	}
+}
+
+// havenLinkRadius - наибольшее расстояние от места убежища без участка в сети до поворотной точки, м
+const havenLinkRadius = 400000
+
+// NewArcticRouter создает маршрутизатор с сетью морских путей западной Арктики.
+// Места havens становятся узлами графа. Подходные точки места образуют цепочку
+// узлов "<id>/approach/<n>", к первой из которых ведут участки сети. Подходы сети
+// к портам seawayBerths достаются ближайшим к ним местам; остальные места соединяются
+// с ближайшей поворотной точкой в пределах havenLinkRadius, до которой путь не пересекает
+// сушу детектора land (nil - без полигонов суши).
+func NewArcticRouter(havens []Haven, land *LandDetector) *MarineRouter {
+	mr := NewMarineRouter(60.0, 90.0, -180.0, 180.0)
+	if land != nil {
+		mr.UseLandDetector(land)
+	}
+	ng := mr.navGraph
+
+	for _, p := range seawayWaypoints {
+		ng.AddNode(&NavNode{ID: p.Name, Point: p, Type: "waypoint"})
+	}
+
+	// berths - место убежища, которому достается подход сети к порту
+	berths := make(map[string]string)
+	for _, b := range seawayBerths {
+		nearest, best := "", 0.0
+		for _, h := range havens {
+			if d := mr.geo.Distance(b, h.Point); d <= berthRadius && (nearest == "" || d < best) {
+				nearest, best = h.ID, d
+			}
+		}
+		if nearest != "" {
+			berths[b.Name] = nearest
+		}
+	}
+	linked := make(map[string]bool)
+	for _, id := range berths {
+		linked[id] = true
+	}
+
+	// entry - узел, через который место соединяется с сетью
+	entry := make(map[string]string)
+	for _, h := range havens {
+		ng.AddNode(&NavNode{ID: h.ID, Point: h.Point, Type: h.Kind})
+		limits := EdgeLimits{IceRequirement: h.IceRequirement}
+		entry[h.ID] = h.ID
+		next := h.ID
+		for n := len(h.Approach) - 1; n >= 0; n-- {
+			id := fmt.Sprintf("%s/approach/%d", h.ID, n+1)
+			ng.AddNode(&NavNode{ID: id, Point: h.Approach[n], Type: "waypoint"})
+			ng.AddLane(id, next, limits)
+			entry[h.ID], next = id, id
+		}
+		if linked[h.ID] {
+			continue
+		}
+		if nearest := mr.nearestReachable(ng.nodes[entry[h.ID]].Point); nearest != "" {
+			ng.AddLane(entry[h.ID], nearest, limits)
+		}
+	}
+
+	// node - узел сети для конца участка: поворотная точка или вход места, получившего подход
+	node := func(name string) (string, bool) {
+		if _, ok := ng.nodes[name]; ok && !isBerth(name) {
+			return name, true
+		}
+		if id, ok := berths[name]; ok {
+			return entry[id], true
+		}
+		return "", false
+	}
+	for _, lane := range seawayLanes {
+		a, okA := node(lane.a)
+		b, okB := node(lane.b)
+		if okA && okB {
+			ng.AddLane(a, b, lane.limits)
+		}
+	}
+	return mr
+}
+
+// isBerth сообщает, что конец участка сети - порт из seawayBerths
+func isBerth(name string) bool {
+	for _, b := range seawayBerths {
+		if b.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// nearestReachable возвращает ближайшую к point поворотную точку сети в пределах havenLinkRadius,
+// путь до которой не пересекает сушу; пусто - такой нет
+func (mr *MarineRouter) nearestReachable(point models.Point) string {
+	type candidate struct {
+		name string
+		dist float64
+	}
+	var candidates []candidate
+	for _, p := range seawayWaypoints {
+		if d := mr.geo.Distance(point, p); d <= havenLinkRadius {
+			candidates = append(candidates, candidate{p.Name, d})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].dist < candidates[j].dist })
+	for _, c := range candidates {
+		if !mr.landDetector.crosses(point, mr.navGraph.nodes[c.name].Point) {
+			return c.name
+		}
+	}
+	return ""
+}
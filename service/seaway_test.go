@@ -0,0 +1,90 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// links возвращает участки сети из узла id: конец -> ограничения
+func links(mr *MarineRouter, id string) map[string]EdgeLimits {
+	out := make(map[string]EdgeLimits)
+	for _, e := range mr.navGraph.edges[id] {
+		out[e.To] = e.Limits
+	}
+	return out
+}
+
+func TestNewArcticRouterHavenLinks(t *testing.T) {
+	// Остров между Индигой и ближайшей к ней поворотной точкой kolguev_w
+	island := orb.Polygon{{{47.7, 68.3}, {48.3, 68.3}, {48.3, 68.5}, {47.7, 68.5}, {47.7, 68.3}}}
+	// Лагуна, со всех сторон закрытая сушей
+	lagoon := orb.Polygon{
+		{{55, 67}, {57, 67}, {57, 68}, {55, 68}, {55, 67}},
+		{{55.9, 67.4}, {56.1, 67.4}, {56.1, 67.6}, {55.9, 67.6}, {55.9, 67.4}},
+	}
+
+	tests := []struct {
+		name   string
+		haven  Haven
+		land   []orb.Polygon
+		link   string // Поворотная точка, с которой соединено место; пусто - не соединено
+		notVia string // Поворотная точка, с которой место соединяться не должно
+		depth  float64
+	}{
+		{
+			name:  "imported port gets the Dvina lane by position",
+			haven: Haven{ID: "ruarh", Kind: HavenPort, Point: models.Point{Lat: 64.55, Lon: 40.52}},
+			link:  "dvina_bar", depth: 10.2,
+		},
+		{
+			name:  "haven links to the nearest waypoint",
+			haven: Haven{ID: "indiga", Kind: HavenRefuge, Point: models.Point{Lat: 67.65, Lon: 49.0}},
+			link:  "kolguev_w",
+		},
+		{
+			name:   "link around land",
+			haven:  Haven{ID: "indiga", Kind: HavenRefuge, Point: models.Point{Lat: 67.65, Lon: 49.0}},
+			land:   []orb.Polygon{island},
+			notVia: "kolguev_w",
+		},
+		{
+			name:  "haven closed by land stays unlinked",
+			haven: Haven{ID: "lagoon", Kind: HavenRefuge, Point: models.Point{Lat: 67.5, Lon: 56}},
+			land:  []orb.Polygon{lagoon},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			land := NewLandDetector(60, 90, -180, 180)
+			land.SetLandPolygons(tt.land)
+			mr := NewArcticRouter([]Haven{tt.haven}, land)
+
+			got := links(mr, tt.haven.ID)
+			switch {
+			case tt.notVia != "":
+				if len(got) != 1 {
+					t.Fatalf("links %v, want one", got)
+				}
+				for to := range got {
+					if to == tt.notVia || land.crosses(tt.haven.Point, mr.navGraph.nodes[to].Point) {
+						t.Errorf("linked to %s across land", to)
+					}
+				}
+			case tt.link == "":
+				if len(got) != 0 {
+					t.Errorf("links %v, want none", got)
+				}
+			default:
+				limits, ok := got[tt.link]
+				if !ok || len(got) != 1 {
+					t.Fatalf("links %v, want %s", got, tt.link)
+				}
+				if limits.Depth != tt.depth {
+					t.Errorf("depth %.1f, want %.1f", limits.Depth, tt.depth)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// ==============================
+// ХРАНИЛИЩЕ ПОЗИЦИЙ СУДОВ
+// ==============================
+
+// Ограничения истории треков
+const (
+	maxTrackPoints = 2000           // Точек на одно судно
+	trackRetention = 72 * time.Hour // Глубина истории
+)
+
+// ShipStore - последние известные позиции судов и их треки по MMSI
+type ShipStore struct {
+	mu        sync.RWMutex
+	ships     map[int32]models.Ship
+	tracks    map[int32][]models.Ship
+	statics   map[int32]models.ShipStatic
+	latest    time.Time // Время самой свежей позиции
+	dataClock bool      // Часы по времени данных (воспроизведение записи)
+	listeners []func(models.Ship)
+	dr        DeadReckoningConfig
+}
+
+// NewShipStore создает пустое хранилище
+func NewShipStore() *ShipStore {
+	return &ShipStore{
+		ships:   make(map[int32]models.Ship),
+		tracks:  make(map[int32][]models.Ship),
+		statics: make(map[int32]models.ShipStatic),
+		dr:      DefaultDeadReckoningConfig(),
+	}
+}
+
+// SetDeadReckoning задает параметры счисления позиций
+func (s *ShipStore) SetDeadReckoning(cfg DeadReckoningConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dr = cfg
+}
+
+// UseDataClock переключает часы хранилища на время самой свежей позиции.
+// Нужно при воспроизведении записи, чтобы расчеты не зависели от реального времени.
+func (s *ShipStore) UseDataClock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dataClock = true
+}
+
+// Now возвращает текущее время хранилища
+func (s *ShipStore) Now() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.dataClock {
+		return s.latest
+	}
+	return time.Now().UTC()
+}
+
+// OnUpdate регистрирует обработчик новых позиций.
+// Обработчики вызываются последовательно вне блокировки хранилища.
+func (s *ShipStore) OnUpdate(fn func(models.Ship)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// Update сохраняет позицию судна и оповещает обработчиков.
+// Флаг судна определяется по MID в его MMSI, тип - по статическим данным.
+func (s *ShipStore) Update(ship models.Ship) {
+	if info := ClassifyMMSI(ship.MMSI); info.Flag != nil {
+		ship.Flag = info.Flag.ISO
+	}
+
+	s.mu.Lock()
+	if prev, ok := s.ships[ship.MMSI]; ok && ship.Timestamp.Before(prev.Timestamp) {
+		s.mu.Unlock()
+		return // Устаревшее сообщение
+	}
+	if static, ok := s.statics[ship.MMSI]; ok {
+		ship.ShipType = static.ShipType
+	}
+	s.ships[ship.MMSI] = ship
+	s.tracks[ship.MMSI] = appendTrack(s.tracks[ship.MMSI], ship)
+	if ship.Timestamp.After(s.latest) {
+		s.latest = ship.Timestamp
+	}
+	listeners := s.listeners
+	s.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(ship)
+	}
+}
+
+// Get возвращает последнюю позицию судна
+func (s *ShipStore) Get(mmsi int32) (models.Ship, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ship, ok := s.ships[mmsi]
+	return ship, ok
+}
+
+// UpdateStatic сохраняет статические данные судна.
+// Сообщение 24 приходит частями, поэтому пустые поля не затирают известные.
+func (s *ShipStore) UpdateStatic(static models.ShipStatic) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	merged := s.statics[static.MMSI]
+	merged.MMSI = static.MMSI
+	merged.Timestamp = static.Timestamp
+	if static.IMO != 0 {
+		merged.IMO = static.IMO
+	}
+	if static.CallSign != "" {
+		merged.CallSign = static.CallSign
+	}
+	if static.Name != "" {
+		merged.Name = static.Name
+	}
+	if static.ShipType != 0 {
+		merged.ShipType = static.ShipType
+	}
+	if static.Length != 0 {
+		merged.Length = static.Length
+	}
+	if static.Beam != 0 {
+		merged.Beam = static.Beam
+	}
+	if static.Draught != 0 {
+		merged.Draught = static.Draught
+	}
+	if static.Destination != "" {
+		merged.Destination = static.Destination
+	}
+	s.statics[static.MMSI] = merged
+}
+
+// Static возвращает известные статические данные судна
+func (s *ShipStore) Static(mmsi int32) (models.ShipStatic, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	static, ok := s.statics[mmsi]
+	return static, ok
+}
+
+// appendTrack добавляет точку в трек и отбрасывает старые
+func appendTrack(track []models.Ship, ship models.Ship) []models.Ship {
+	track = append(track, ship)
+
+	cut := 0
+	if len(track) > maxTrackPoints {
+		cut = len(track) - maxTrackPoints
+	}
+	for cut < len(track)-1 && ship.Timestamp.Sub(track[cut].Timestamp) > trackRetention {
+		cut++
+	}
+	if cut > 0 {
+		track = append(track[:0:0], track[cut:]...)
+	}
+	return track
+}
+
+// Track возвращает историю позиций судна, от старых к новым
+func (s *ShipStore) Track(mmsi int32) []models.Ship {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]models.Ship(nil), s.tracks[mmsi]...)
+}
+
+// All возвращает все суда, упорядоченные по MMSI
+func (s *ShipStore) All() []models.Ship {
+	s.mu.RLock()
+	ships := make([]models.Ship, 0, len(s.ships))
+	for _, ship := range s.ships {
+		ships = append(ships, ship)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(ships, func(i, j int) bool { return ships[i].MMSI < ships[j].MMSI })
+	return ships
+}
+
+// Estimate возвращает позицию судна, досчитанную на момент at
+func (s *ShipStore) Estimate(mmsi int32, at time.Time) (models.ShipEstimate, bool) {
+	s.mu.RLock()
+	ship, ok := s.ships[mmsi]
+	cfg := s.dr
+	s.mu.RUnlock()
+
+	if !ok {
+		return models.ShipEstimate{}, false
+	}
+	return PredictPosition(ship, at, cfg), true
+}
+
+// Estimates возвращает досчитанные на момент at позиции всех судов
+func (s *ShipStore) Estimates(at time.Time) []models.ShipEstimate {
+	s.mu.RLock()
+	cfg := s.dr
+	s.mu.RUnlock()
+
+	ships := s.All()
+	estimates := make([]models.ShipEstimate, len(ships))
+	for i, ship := range ships {
+		estimates[i] = PredictPosition(ship, at, cfg)
+	}
+	return estimates
+}
@@ -0,0 +1,356 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/geo/s2"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// ==============================
+// ПЛОТНОСТЬ ДВИЖЕНИЯ
+// ==============================
+
+// Окна агрегации
+const (
+	WindowAll    = "all"
+	WindowYear   = "year"
+	WindowSeason = "season"
+	WindowMonth  = "month"
+	WindowWeek   = "week"
+)
+
+// ShipCategory сводит тип судна AIS к группе для статистики
+func ShipCategory(shipType int32) string {
+	switch {
+	case shipType == 30:
+		return "fishing"
+	case shipType >= 31 && shipType <= 32, shipType == 52:
+		return "tug"
+	case shipType == 35, shipType == 55:
+		return "military"
+	case shipType >= 36 && shipType <= 37:
+		return "pleasure"
+	case shipType >= 40 && shipType <= 49:
+		return "high_speed"
+	case shipType == 51:
+		return "sar"
+	case shipType >= 60 && shipType <= 69:
+		return "passenger"
+	case shipType >= 70 && shipType <= 79:
+		return "cargo"
+	case shipType >= 80 && shipType <= 89:
+		return "tanker"
+	case shipType == 0:
+		return "unknown"
+	default:
+		return "other"
+	}
+}
+
+// WindowKey возвращает ключ временного окна для момента t:
+// "2025", "2025-winter" (декабрь относится к зиме следующего года), "2025-01", "2025-W03"
+func WindowKey(t time.Time, window string) string {
+	t = t.UTC()
+	switch window {
+	case WindowYear:
+		return fmt.Sprintf("%d", t.Year())
+	case WindowSeason:
+		year := t.Year()
+		var season string
+		switch t.Month() {
+		case time.December:
+			year++
+			season = "winter"
+		case time.January, time.February:
+			season = "winter"
+		case time.March, time.April, time.May:
+			season = "spring"
+		case time.June, time.July, time.August:
+			season = "summer"
+		default:
+			season = "autumn"
+		}
+		return fmt.Sprintf("%d-%s", year, season)
+	case WindowMonth:
+		return t.Format("2006-01")
+	case WindowWeek:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	default:
+		return WindowAll
+	}
+}
+
+// TrafficConfig - параметры агрегации
+type TrafficConfig struct {
+	Level          int           // Уровень ячеек S2 (10 - около 10 км)
+	Window         string        // Окно агрегации
+	SampleInterval time.Duration // Судно учитывается в ячейке не чаще
+}
+
+// DefaultTrafficConfig - сезонная сетка S2 уровня 10
+func DefaultTrafficConfig() TrafficConfig {
+	return TrafficConfig{
+		Level:          10,
+		Window:         WindowSeason,
+		SampleInterval: 10 * time.Minute,
+	}
+}
+
+// TrafficCell - агрегат ячейки сетки
+type TrafficCell struct {
+	Token     string       `json:"token"`     // Токен ячейки S2
+	Window    string       `json:"window"`    // Временное окно
+	Category  string       `json:"category"`  // Группа судов, пусто - все
+	Positions int          `json:"positions"` // Учтено позиций
+	Vessels   int          `json:"vessels"`   // Различных судов
+	Center    models.Point `json:"center"`    // Центр ячейки
+	cell      s2.CellID
+}
+
+// TrafficQuery - выборка из агрегата; пустые поля - без ограничения
+type TrafficQuery struct {
+	Window   string       // Ключ окна, например "2025-summer"
+	Category string       // Группа судов
+	Level    int          // Уровень выдачи, не мельче уровня агрегации; 0 - уровень агрегации
+	BBox     *models.BBox // Область, в которой лежат центры ячеек; nil - без ограничения
+}
+
+type trafficKey struct {
+	window   string
+	category string
+	cell     s2.CellID
+}
+
+type trafficBin struct {
+	positions int
+	vessels   map[int32]struct{}
+}
+
+type trafficSample struct {
+	cell s2.CellID
+	at   time.Time
+}
+
+// TrafficDensity - накопление позиций AIS по ячейкам S2, окнам и группам судов
+type TrafficDensity struct {
+	cfg TrafficConfig
+
+	mu      sync.RWMutex
+	bins    map[trafficKey]*trafficBin
+	last    map[int32]trafficSample
+	windows map[string]struct{}
+}
+
+// NewTrafficDensity создает пустой агрегат
+func NewTrafficDensity(cfg TrafficConfig) *TrafficDensity {
+	return &TrafficDensity{
+		cfg:     cfg,
+		bins:    make(map[trafficKey]*trafficBin),
+		last:    make(map[int32]trafficSample),
+		windows: make(map[string]struct{}),
+	}
+}
+
+// Add учитывает позицию судна. Повторные позиции в той же ячейке
+// чаще SampleInterval пропускаются, чтобы стоящие суда не перевешивали идущие.
+func (d *TrafficDensity) Add(ship models.Ship) {
+	cell := s2.CellIDFromLatLng(s2.LatLngFromDegrees(ship.Latitude, ship.Longitude)).Parent(d.cfg.Level)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if prev, ok := d.last[ship.MMSI]; ok && prev.cell == cell &&
+		ship.Timestamp.Sub(prev.at) < d.cfg.SampleInterval {
+		return
+	}
+	d.last[ship.MMSI] = trafficSample{cell: cell, at: ship.Timestamp}
+
+	key := trafficKey{
+		window:   WindowKey(ship.Timestamp, d.cfg.Window),
+		category: ShipCategory(ship.ShipType),
+		cell:     cell,
+	}
+	bin, ok := d.bins[key]
+	if !ok {
+		bin = &trafficBin{vessels: make(map[int32]struct{})}
+		d.bins[key] = bin
+	}
+	bin.positions++
+	bin.vessels[ship.MMSI] = struct{}{}
+	d.windows[key.window] = struct{}{}
+}
+
+// Windows возвращает ключи окон, по которым есть данные
+func (d *TrafficDensity) Windows() []string {
+	d.mu.RLock()
+	windows := make([]string, 0, len(d.windows))
+	for w := range d.windows {
+		windows = append(windows, w)
+	}
+	d.mu.RUnlock()
+
+	sort.Strings(windows)
+	return windows
+}
+
+// Query сводит ячейки по запросу, объединяя окна, группы и дочерние ячейки
+func (d *TrafficDensity) Query(q TrafficQuery) []TrafficCell {
+	level := d.cfg.Level
+	if q.Level > 0 && q.Level < level {
+		level = q.Level
+	}
+
+	type merged struct {
+		positions int
+		vessels   map[int32]struct{}
+	}
+	cells := make(map[s2.CellID]*merged)
+
+	d.mu.RLock()
+	for key, bin := range d.bins {
+		if q.Window != "" && key.window != q.Window {
+			continue
+		}
+		if q.Category != "" && key.category != q.Category {
+			continue
+		}
+		id := key.cell.Parent(level)
+		m, ok := cells[id]
+		if !ok {
+			m = &merged{vessels: make(map[int32]struct{})}
+			cells[id] = m
+		}
+		m.positions += bin.positions
+		for mmsi := range bin.vessels {
+			m.vessels[mmsi] = struct{}{}
+		}
+	}
+	d.mu.RUnlock()
+
+	result := make([]TrafficCell, 0, len(cells))
+	for id, m := range cells {
+		center := id.LatLng()
+		if q.BBox != nil && !q.BBox.Contains(center.Lat.Degrees(), center.Lng.Degrees()) {
+			continue
+		}
+		result = append(result, TrafficCell{
+			Token:     id.ToToken(),
+			Window:    q.Window,
+			Category:  q.Category,
+			Positions: m.positions,
+			Vessels:   len(m.vessels),
+			Center:    models.Point{Lat: center.Lat.Degrees(), Lon: center.Lng.Degrees()},
+			cell:      id,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Positions > result[j].Positions })
+	return result
+}
+
+// GeoJSON возвращает ячейки запроса как полигоны с числом позиций и судов
+func (d *TrafficDensity) GeoJSON(q TrafficQuery) *geojson.FeatureCollection {
+	fc := geojson.NewFeatureCollection()
+	for _, c := range d.Query(q) {
+		feature := geojson.NewFeature(cellPolygon(c.cell))
+		feature.Properties["token"] = c.Token
+		feature.Properties["positions"] = c.Positions
+		feature.Properties["vessels"] = c.Vessels
+		if c.Window != "" {
+			feature.Properties["window"] = c.Window
+		}
+		if c.Category != "" {
+			feature.Properties["category"] = c.Category
+		}
+		fc.Append(feature)
+	}
+	return fc
+}
+
+// cellPolygon строит полигон ячейки S2. Долготы разворачиваются относительно
+// первой вершины, чтобы ячейки у антимеридиана не растягивались на весь мир.
+func cellPolygon(id s2.CellID) orb.Polygon {
+	cell := s2.CellFromCellID(id)
+	ring := make(orb.Ring, 0, 5)
+	for k := 0; k < 4; k++ {
+		ll := s2.LatLngFromPoint(cell.Vertex(k))
+		lon := ll.Lng.Degrees()
+		if k > 0 {
+			for lon-ring[0][0] > 180 {
+				lon -= 360
+			}
+			for lon-ring[0][0] < -180 {
+				lon += 360
+			}
+		}
+		ring = append(ring, orb.Point{lon, ll.Lat.Degrees()})
+	}
+	ring = append(ring, ring[0])
+	return orb.Polygon{ring}
+}
+
+// DensityAt возвращает плотность в точке относительно самой загруженной ячейки (0..1)
+func (d *TrafficDensity) DensityAt(p models.Point, window string) float64 {
+	return d.densityLookup(window)(p)
+}
+
+// densityLookup готовит функцию нормированной плотности для окна
+func (d *TrafficDensity) densityLookup(window string) func(models.Point) float64 {
+	counts := make(map[s2.CellID]int)
+	maxCount := 0
+	for _, c := range d.Query(TrafficQuery{Window: window}) {
+		counts[c.cell] = c.Positions
+		if c.Positions > maxCount {
+			maxCount = c.Positions
+		}
+	}
+
+	return func(p models.Point) float64 {
+		if maxCount == 0 {
+			return 0
+		}
+		id := s2.CellIDFromLatLng(s2.LatLngFromDegrees(p.Lat, p.Lon)).Parent(d.cfg.Level)
+		return float64(counts[id]) / float64(maxCount)
+	}
+}
+
+// ApplyTrafficWeights удорожает ребра графа, идущие вне наезженных трасс:
+// стоимость = длина * исходный множитель * (1 + strength * (1 - средняя плотность)).
+// Стоимость не становится меньше длины, поэтому расстояние по прямой остается
+// допустимой эвристикой A*. strength = 0 возвращает исходные стоимости.
+func (ng *NavigationGraph) ApplyTrafficWeights(density *TrafficDensity, window string, strength float64) {
+	strength = math.Max(0, strength)
+	lookup := density.densityLookup(window)
+
+	for _, edges := range ng.edges {
+		for _, edge := range edges {
+			if edge.Distance == 0 {
+				continue
+			}
+			from := ng.nodes[edge.From].Point
+			to := ng.nodes[edge.To].Point
+
+			// Пробы вдоль ребра примерно через 5 км
+			samples := int(math.Min(math.Max(edge.Distance/5000, 1), 50))
+			sum := 0.0
+			for i := 0; i <= samples; i++ {
+				sum += lookup(ng.geo.IntermediatePoint(from, to, float64(i)/float64(samples)))
+			}
+			avg := sum / float64(samples+1)
+
+			edge.Cost = edge.Distance * edge.multiplier * (1 + strength*(1-avg))
+		}
+	}
+}
+
+// ApplyTrafficWeights передает веса наблюдаемого движения в граф маршрутизатора
+func (mr *MarineRouter) ApplyTrafficWeights(density *TrafficDensity, window string, strength float64) {
+	mr.navGraph.ApplyTrafficWeights(density, window, strength)
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// testGrid строит граф-решетку rows x cols с шагом 0.5° по широте и 1° по долготе
+// и ребрами к соседям, в том числе по диагонали
+func testGrid(rows, cols int) *NavigationGraph {
+	ng := NewNavigationGraph()
+	id := func(r, c int) string { return fmt.Sprintf("n%d_%d", r, c) }
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			ng.AddNode(&NavNode{ID: id(r, c), Point: models.Point{Lat: 69 + 0.5*float64(r), Lon: 40 + float64(c)}, Type: "waypoint"})
+		}
+	}
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			for _, d := range [][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}} {
+				rr, cc := r+d[0], c+d[1]
+				if rr < rows && cc >= 0 && cc < cols {
+					ng.AddEdge(id(r, c), id(rr, cc), 1.0)
+					ng.AddEdge(id(rr, cc), id(r, c), 1.0)
+				}
+			}
+		}
+	}
+	return ng
+}
+
+// dijkstraCost - эталонная стоимость кратчайшего пути без эвристики
+func dijkstraCost(ng *NavigationGraph, from, to string) float64 {
+	dist := map[string]float64{from: 0}
+	done := make(map[string]bool)
+	for {
+		current, best := "", math.Inf(1)
+		for id, d := range dist {
+			if !done[id] && d < best {
+				current, best = id, d
+			}
+		}
+		if current == "" {
+			return math.Inf(1)
+		}
+		if current == to {
+			return best
+		}
+		done[current] = true
+		for _, e := range ng.edges[current] {
+			if d, ok := dist[e.To]; !ok || best+e.Cost < d {
+				dist[e.To] = best + e.Cost
+			}
+		}
+	}
+}
+
+// pathCost - стоимость пути по ребрам графа
+func pathCost(t *testing.T, ng *NavigationGraph, path []*NavNode) float64 {
+	t.Helper()
+	cost := 0.0
+	for i := 1; i < len(path); i++ {
+		found := false
+		for _, e := range ng.edges[path[i-1].ID] {
+			if e.To == path[i].ID {
+				cost += e.Cost
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("path uses missing edge %s -> %s", path[i-1].ID, path[i].ID)
+		}
+	}
+	return cost
+}
+
+func TestApplyTrafficWeightsKeepsAStarOptimal(t *testing.T) {
+	// Наезженная трасса вдоль верхнего ряда решетки и по правому краю
+	density := NewTrafficDensity(DefaultTrafficConfig())
+	start := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 200; i++ {
+		at := start.Add(time.Duration(i) * time.Hour)
+		density.Add(models.Ship{MMSI: int32(1000 + i%20), Latitude: 71, Longitude: 40 + 5*float64(i%100)/100, Timestamp: at})
+		density.Add(models.Ship{MMSI: int32(2000 + i%20), Latitude: 69 + 2*float64(i%100)/100, Longitude: 45, Timestamp: at})
+	}
+
+	tests := []struct {
+		name     string
+		strength float64
+	}{
+		{"no weighting", 0},
+		{"mild", 0.5},
+		{"strong", 1},
+		{"very strong", 5},
+		{"negative is ignored", -2},
+	}
+	pairs := [][2]string{{"n0_0", "n4_5"}, {"n0_0", "n0_5"}, {"n2_0", "n2_5"}, {"n4_0", "n0_5"}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ng := testGrid(5, 6)
+			ng.ApplyTrafficWeights(density, "", tt.strength)
+
+			for _, edges := range ng.edges {
+				for _, e := range edges {
+					if e.Cost < e.Distance {
+						t.Fatalf("edge %s -> %s cost %.0f below distance %.0f: heuristic not admissible", e.From, e.To, e.Cost, e.Distance)
+					}
+				}
+			}
+			for _, pair := range pairs {
+				path := ng.FindPath(pair[0], pair[1])
+				if len(path) == 0 {
+					t.Fatalf("%s -> %s: no path", pair[0], pair[1])
+				}
+				got, want := pathCost(t, ng, path), dijkstraCost(ng, pair[0], pair[1])
+				if math.Abs(got-want) > 1e-6*want {
+					t.Errorf("%s -> %s: A* cost %.1f, optimal %.1f", pair[0], pair[1], got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyTrafficWeightsPrefersBusyLanes(t *testing.T) {
+	density := NewTrafficDensity(DefaultTrafficConfig())
+	start := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 500; i++ {
+		density.Add(models.Ship{MMSI: int32(i % 50), Latitude: 71, Longitude: 40 + 5*float64(i)/500, Timestamp: start.Add(time.Duration(i) * time.Hour)})
+	}
+
+	ng := testGrid(5, 6)
+	ng.ApplyTrafficWeights(density, "", 1)
+	if busy, quiet := ng.edges["n4_0"][0].Cost/ng.edges["n4_0"][0].Distance, ng.edges["n0_0"][0].Cost/ng.edges["n0_0"][0].Distance; busy >= quiet {
+		t.Errorf("busy lane factor %.2f not below quiet lane factor %.2f", busy, quiet)
+	}
+}
@@ -0,0 +1,351 @@
+package service
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// ==============================
+// ЗАХОДЫ В ПОРТ И РЕЙСЫ
+// ==============================
+
+// Навигационные статусы AIS, означающие стоянку
+const (
+	navStatusAtAnchor = 1
+	navStatusMoored   = 5
+)
+
+// Типы стоянок
+const (
+	StayPortCall  = "port_call" // Стоянка у причала
+	StayAnchorage = "anchorage" // Стоянка на рейде
+)
+
+// Статусы рейса
+const (
+	VoyageUnderway  = "underway"  // Судно в пути
+	VoyageCompleted = "completed" // Судно пришло в порт назначения
+)
+
+// PortZone - геозона порта: акватория и рейдовая зона вокруг нее
+type PortZone struct {
+	Name            string       `json:"name"`
+	Center          models.Point `json:"center"`
+	Radius          float64      `json:"radius"`           // Акватория порта, м
+	AnchorageRadius float64      `json:"anchorage_radius"` // Рейдовая зона, м
+}
+
+// Размеры геозон портов
+const (
+	PortZoneRadius      = 5000.0  // Акватория порта, м
+	PortAnchorageRadius = 20000.0 // Рейдовая зона, м
+)
+
+// PortZonesFromPorts строит геозоны вокруг портов и мест убежища реестра.
+// Ориентиры справочника (мысы, острова) геозонами не становятся.
+func PortZonesFromPorts(ports []models.Port, radius, anchorageRadius float64) []PortZone {
+	zones := make([]PortZone, len(ports))
+	for i, p := range ports {
+		zones[i] = PortZone{Name: p.Name, Center: p.Point(), Radius: radius, AnchorageRadius: anchorageRadius}
+	}
+	return zones
+}
+
+// PortCall - стоянка судна в порту или на рейде
+type PortCall struct {
+	MMSI      int32     `json:"mmsi"`
+	Name      string    `json:"name"`
+	Port      string    `json:"port"`
+	Kind      string    `json:"kind"`      // port_call или anchorage
+	Arrival   time.Time `json:"arrival"`   // Первая позиция стоянки
+	Departure time.Time `json:"departure"` // Последняя позиция стоянки
+	Duration  float64   `json:"duration"`  // Длительность, с
+	Open      bool      `json:"open"`      // Судно еще стоит
+}
+
+// Voyage - рейс между двумя стоянками
+type Voyage struct {
+	MMSI        int32     `json:"mmsi"`
+	Name        string    `json:"name"`
+	Origin      string    `json:"origin"`      // Порт отхода, пусто если неизвестен
+	Destination string    `json:"destination"` // Порт прихода, пусто если в пути
+	Departure   time.Time `json:"departure"`
+	Arrival     time.Time `json:"arrival,omitempty"`
+	Status      string    `json:"status"`    // underway или completed
+	Duration    float64   `json:"duration"`  // Время в пути, с
+	Distance    float64   `json:"distance"`  // Пройдено по треку, м
+	AvgSpeed    float64   `json:"avg_speed"` // Средняя скорость, узлы
+	MaxSpeed    float64   `json:"max_speed"` // Максимальная SOG, узлы
+	Points      int       `json:"points"`    // Позиций в рейсе
+}
+
+// VoyageConfig - параметры выделения стоянок
+type VoyageConfig struct {
+	StopSpeed   float64       // Ниже этой SOG судно считается стоящим, узлы
+	DepartSpeed float64       // Выше этой SOG стоянка завершается даже в зоне порта, узлы
+	MinStay     time.Duration // Более короткие остановки не считаются заходом
+	MaxStored   int           // Сколько завершенных стоянок и рейсов хранить
+}
+
+// DefaultVoyageConfig - параметры по умолчанию
+func DefaultVoyageConfig() VoyageConfig {
+	return VoyageConfig{
+		StopSpeed:   1.0,
+		DepartSpeed: 3.0,
+		MinStay:     30 * time.Minute,
+		MaxStored:   5000,
+	}
+}
+
+// voyageState - состояние разбора трека одного судна
+type voyageState struct {
+	lastFix models.Ship
+	stay    *PortCall // Текущая стоянка (возможно, еще не подтвержденная)
+	voyage  *Voyage   // Текущий рейс
+}
+
+// VoyageTracker - выделение заходов в порт и рейсов из потока позиций
+type VoyageTracker struct {
+	cfg VoyageConfig
+	geo *GeoUtils
+
+	mu      sync.RWMutex
+	ports   []PortZone
+	states  map[int32]*voyageState
+	calls   []PortCall
+	voyages []Voyage
+}
+
+// NewVoyageTracker создает трекер и подписывает его на обновления позиций
+func NewVoyageTracker(store *ShipStore, ports []PortZone, cfg VoyageConfig) *VoyageTracker {
+	t := &VoyageTracker{
+		ports:  ports,
+		cfg:    cfg,
+		geo:    &GeoUtils{},
+		states: make(map[int32]*voyageState),
+	}
+	store.OnUpdate(t.Observe)
+	return t
+}
+
+// SetPorts заменяет геозоны портов
+func (t *VoyageTracker) SetPorts(ports []PortZone) {
+	t.mu.Lock()
+	t.ports = ports
+	t.mu.Unlock()
+}
+
+// zoneAt возвращает ближайшую геозону порта, в рейдовой зоне которой находится точка
+func (t *VoyageTracker) zoneAt(p models.Point) (*PortZone, float64) {
+	var nearest *PortZone
+	best := 0.0
+	for i := range t.ports {
+		zone := &t.ports[i]
+		d := t.geo.Distance(p, zone.Center)
+		if d <= zone.AnchorageRadius && (nearest == nil || d < best) {
+			nearest, best = zone, d
+		}
+	}
+	return nearest, best
+}
+
+// Observe обрабатывает новую позицию судна
+func (t *VoyageTracker) Observe(ship models.Ship) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.states[ship.MMSI]
+	if !ok {
+		state = &voyageState{}
+		t.states[ship.MMSI] = state
+	}
+
+	position := models.Point{Lat: ship.Latitude, Lon: ship.Longitude}
+	zone, distance := t.zoneAt(position)
+	stationary := ship.NavStatus == navStatusAtAnchor || ship.NavStatus == navStatusMoored ||
+		(ship.HasMotion() && ship.SOG <= t.cfg.StopSpeed)
+
+	// Стоянка продолжается, пока судно в зоне того же порта и не набрало ход.
+	// Порог отхода выше порога остановки, чтобы рыскание на якоре не дробило стоянку.
+	if state.stay != nil {
+		departing := !stationary && ship.HasMotion() && ship.SOG > t.cfg.DepartSpeed
+		if zone != nil && zone.Name == state.stay.Port && !departing {
+			// Пока стоянка не подтверждена, перемещения в зоне порта идут в рейс
+			t.advanceVoyage(state, ship)
+			t.extendStay(state, ship, stationary && distance <= zone.Radius)
+			state.lastFix = ship
+			return
+		}
+		t.closeStay(state, ship)
+	}
+
+	if zone != nil && stationary {
+		state.stay = &PortCall{
+			MMSI:      ship.MMSI,
+			Name:      ship.Name,
+			Port:      zone.Name,
+			Kind:      StayAnchorage,
+			Arrival:   ship.Timestamp,
+			Departure: ship.Timestamp,
+			Open:      true,
+		}
+		t.extendStay(state, ship, distance <= zone.Radius)
+	}
+
+	t.advanceVoyage(state, ship)
+	state.lastFix = ship
+}
+
+// extendStay продлевает стоянку; подтвержденная стоянка завершает текущий рейс
+func (t *VoyageTracker) extendStay(state *voyageState, ship models.Ship, berthed bool) {
+	stay := state.stay
+	stay.Departure = ship.Timestamp
+	stay.Duration = stay.Departure.Sub(stay.Arrival).Seconds()
+	if berthed {
+		stay.Kind = StayPortCall
+	}
+
+	if state.voyage != nil && stay.Departure.Sub(stay.Arrival) >= t.cfg.MinStay {
+		voyage := state.voyage
+		voyage.Destination = stay.Port
+		voyage.Arrival = stay.Arrival
+		voyage.Status = VoyageCompleted
+		voyage.Duration = voyage.Arrival.Sub(voyage.Departure).Seconds()
+		voyage.AvgSpeed = averageSpeed(voyage.Distance, voyage.Duration)
+		t.voyages = appendCapped(t.voyages, *voyage, t.cfg.MaxStored)
+		state.voyage = nil
+	}
+}
+
+// closeStay завершает стоянку при выходе из зоны порта и открывает новый рейс
+func (t *VoyageTracker) closeStay(state *voyageState, ship models.Ship) {
+	stay := *state.stay
+	state.stay = nil
+
+	if stay.Departure.Sub(stay.Arrival) < t.cfg.MinStay {
+		return // Короткая остановка - рейс продолжается
+	}
+
+	stay.Open = false
+	t.calls = appendCapped(t.calls, stay, t.cfg.MaxStored)
+
+	state.voyage = &Voyage{
+		MMSI:      ship.MMSI,
+		Name:      ship.Name,
+		Origin:    stay.Port,
+		Departure: stay.Departure,
+		Status:    VoyageUnderway,
+	}
+}
+
+// advanceVoyage учитывает пройденный отрезок в текущем рейсе
+func (t *VoyageTracker) advanceVoyage(state *voyageState, ship models.Ship) {
+	if state.voyage == nil {
+		if state.stay != nil {
+			return
+		}
+		// Судно впервые замечено в море - порт отхода неизвестен
+		state.voyage = &Voyage{
+			MMSI:      ship.MMSI,
+			Name:      ship.Name,
+			Departure: ship.Timestamp,
+			Status:    VoyageUnderway,
+		}
+	}
+
+	voyage := state.voyage
+	if !state.lastFix.Timestamp.IsZero() {
+		voyage.Distance += t.geo.Distance(
+			models.Point{Lat: state.lastFix.Latitude, Lon: state.lastFix.Longitude},
+			models.Point{Lat: ship.Latitude, Lon: ship.Longitude},
+		)
+	}
+	voyage.Points++
+	if ship.HasMotion() && ship.SOG > voyage.MaxSpeed {
+		voyage.MaxSpeed = ship.SOG
+	}
+	voyage.Duration = ship.Timestamp.Sub(voyage.Departure).Seconds()
+	voyage.AvgSpeed = averageSpeed(voyage.Distance, voyage.Duration)
+}
+
+// averageSpeed - средняя скорость в узлах
+func averageSpeed(distance, seconds float64) float64 {
+	if seconds <= 0 {
+		return 0
+	}
+	return distance / seconds / knotsToMS
+}
+
+// appendCapped добавляет элемент, отбрасывая самые старые сверх limit
+func appendCapped[T any](items []T, item T, limit int) []T {
+	items = append(items, item)
+	if len(items) > limit {
+		items = append(items[:0:0], items[len(items)-limit:]...)
+	}
+	return items
+}
+
+// VoyageFilter - отбор рейсов и стоянок; пустые поля не ограничивают
+type VoyageFilter struct {
+	MMSI   int32
+	Port   string // Порт стоянки, отхода или назначения
+	Status string
+}
+
+// PortCalls возвращает завершенные и текущие стоянки, новые первыми
+func (t *VoyageTracker) PortCalls(filter VoyageFilter) []PortCall {
+	t.mu.RLock()
+	calls := append([]PortCall(nil), t.calls...)
+	for _, state := range t.states {
+		if state.stay != nil && state.stay.Departure.Sub(state.stay.Arrival) >= t.cfg.MinStay {
+			calls = append(calls, *state.stay)
+		}
+	}
+	t.mu.RUnlock()
+
+	result := make([]PortCall, 0, len(calls))
+	for _, call := range calls {
+		if filter.MMSI != 0 && call.MMSI != filter.MMSI {
+			continue
+		}
+		if filter.Port != "" && !strings.EqualFold(call.Port, filter.Port) {
+			continue
+		}
+		result = append(result, call)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Arrival.After(result[j].Arrival) })
+	return result
+}
+
+// Voyages возвращает завершенные и текущие рейсы, новые первыми
+func (t *VoyageTracker) Voyages(filter VoyageFilter) []Voyage {
+	t.mu.RLock()
+	voyages := append([]Voyage(nil), t.voyages...)
+	for _, state := range t.states {
+		if state.voyage != nil {
+			voyages = append(voyages, *state.voyage)
+		}
+	}
+	t.mu.RUnlock()
+
+	result := make([]Voyage, 0, len(voyages))
+	for _, voyage := range voyages {
+		if filter.MMSI != 0 && voyage.MMSI != filter.MMSI {
+			continue
+		}
+		if filter.Port != "" && !strings.EqualFold(voyage.Origin, filter.Port) &&
+			!strings.EqualFold(voyage.Destination, filter.Port) {
+			continue
+		}
+		if filter.Status != "" && voyage.Status != filter.Status {
+			continue
+		}
+		result = append(result, voyage)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Departure.After(result[j].Departure) })
+	return result
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+// voyageFix - позиция тестового трека: смещение по времени, координаты, SOG и статус
+type voyageFix struct {
+	at        time.Duration
+	lat, lon  float64
+	sog       float64
+	navStatus int32
+}
+
+// runVoyage прогоняет трек через трекер с портом Мурманск
+func runVoyage(fixes []voyageFix) *VoyageTracker {
+	ports := []models.Port{{ID: "murmansk", Name: "Murmansk", Kind: HavenPort, Lat: 68.97, Lon: 33.07}}
+	store := NewShipStore()
+	tracker := NewVoyageTracker(store, PortZonesFromPorts(ports, PortZoneRadius, PortAnchorageRadius), DefaultVoyageConfig())
+
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	for _, f := range fixes {
+		store.Update(models.Ship{
+			MMSI: 273000101, Name: "TEST", Latitude: f.lat, Longitude: f.lon,
+			SOG: f.sog, COG: 0, NavStatus: f.navStatus, Timestamp: start.Add(f.at),
+		})
+	}
+	return tracker
+}
+
+func TestPortZonesFromPortsSkipsLandmarks(t *testing.T) {
+	ports := []models.Port{
+		{ID: "murmansk", Name: "Murmansk", Kind: HavenPort, Lat: 68.97, Lon: 33.07},
+		{ID: "bugrino", Name: "Kolguev (Bugrino roads)", Kind: HavenRefuge, Lat: 68.78, Lon: 49.3},
+	}
+	zones := PortZonesFromPorts(ports, PortZoneRadius, PortAnchorageRadius)
+	if len(zones) != 2 || zones[0].Name != "Murmansk" || zones[1].Center.Lat != 68.78 {
+		t.Fatalf("zones = %+v", zones)
+	}
+
+	// Судно дрейфует у мыса Канин Нос: мыс есть только в справочнике мест
+	tracker := runVoyage([]voyageFix{
+		{0, 68.65, 43.26, 0.2, 0},
+		{2 * time.Hour, 68.65, 43.27, 0.2, 0},
+	})
+	if calls := tracker.PortCalls(VoyageFilter{}); len(calls) != 0 {
+		t.Errorf("drifting off a cape produced port calls %+v", calls)
+	}
+}
+
+func TestVoyageTracker(t *testing.T) {
+	tests := []struct {
+		name      string
+		fixes     []voyageFix
+		calls     int
+		departure time.Duration // Окончание первой стоянки
+		voyages   int
+		origin    string
+		distance  float64 // Пройдено в последнем рейсе, м; 0 - не проверяется
+	}{
+		{
+			name: "stay closes when speed rises inside the anchorage zone",
+			fixes: []voyageFix{
+				{0, 68.97, 33.07, 0, navStatusMoored},
+				{1 * time.Hour, 68.97, 33.07, 0, navStatusMoored},
+				{2 * time.Hour, 68.97, 33.07, 0, navStatusMoored},
+				{2*time.Hour + 10*time.Minute, 69.0, 33.1, 10, 0},
+				{2*time.Hour + 20*time.Minute, 69.03, 33.13, 10, 0},
+			},
+			calls:     1,
+			departure: 2 * time.Hour,
+			voyages:   1,
+			origin:    "Murmansk",
+		},
+		{
+			name: "swinging at anchor does not end the stay",
+			fixes: []voyageFix{
+				{0, 69.05, 33.2, 0.5, 0},
+				{30 * time.Minute, 69.05, 33.2, 2.0, 0},
+				{1 * time.Hour, 69.05, 33.2, 0.4, 0},
+				{90 * time.Minute, 69.05, 33.2, 0.3, 0},
+			},
+			calls:     1,
+			departure: 90 * time.Minute,
+		},
+		{
+			name: "short stop keeps the distance covered",
+			fixes: []voyageFix{
+				{0, 69.5, 34.0, 10, 0},
+				{1 * time.Hour, 69.3, 33.6, 10, 0},
+				{2 * time.Hour, 69.12, 33.3, 0.5, 0},
+				{2*time.Hour + 10*time.Minute, 69.1, 33.2, 0.8, 0},
+				{2*time.Hour + 20*time.Minute, 69.3, 33.6, 10, 0},
+			},
+			voyages: 1,
+			distance: sumDistance(
+				models.Point{Lat: 69.5, Lon: 34.0}, models.Point{Lat: 69.3, Lon: 33.6},
+				models.Point{Lat: 69.12, Lon: 33.3}, models.Point{Lat: 69.1, Lon: 33.2},
+				models.Point{Lat: 69.3, Lon: 33.6},
+			),
+		},
+	}
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := runVoyage(tt.fixes)
+
+			calls := tracker.PortCalls(VoyageFilter{})
+			if len(calls) != tt.calls {
+				t.Fatalf("got %d port calls %+v, want %d", len(calls), calls, tt.calls)
+			}
+			if tt.calls > 0 {
+				if first := calls[len(calls)-1]; !first.Departure.Equal(start.Add(tt.departure)) {
+					t.Errorf("stay ended at %v, want %v", first.Departure, start.Add(tt.departure))
+				}
+			}
+
+			voyages := tracker.Voyages(VoyageFilter{})
+			if len(voyages) != tt.voyages {
+				t.Fatalf("got %d voyages %+v, want %d", len(voyages), voyages, tt.voyages)
+			}
+			if tt.voyages == 0 {
+				return
+			}
+			last := voyages[0]
+			if last.Origin != tt.origin || last.Status != VoyageUnderway {
+				t.Errorf("voyage from %q is %s, want underway from %q", last.Origin, last.Status, tt.origin)
+			}
+			if tt.distance > 0 && math.Abs(last.Distance-tt.distance) > 1 {
+				t.Errorf("voyage distance %.0f m, want %.0f m", last.Distance, tt.distance)
+			}
+		})
+	}
+}
+
+// sumDistance - длина ломаной, м
+func sumDistance(points ...models.Point) float64 {
+	geo := &GeoUtils{}
+	total := 0.0
+	for i := 1; i < len(points); i++ {
+		total += geo.Distance(points[i-1], points[i])
+	}
+	return total
+}
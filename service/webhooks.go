@@ -0,0 +1,303 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==============================
+// ВЕБХУКИ
+// ==============================
+
+// Параметры доставки
+const (
+	webhookAttempts    = 3                // Попыток доставки одного события
+	webhookTimeout     = 10 * time.Second // Таймаут одного запроса
+	webhookConcurrency = 8                // Одновременных запросов
+	webhookQueueLimit  = 1000             // Событий в очереди одного вебхука; сверх - отбрасываются старые
+)
+
+// WebhookEvents - типы событий живого канала, на которые можно подписать вебхук.
+// Позиции AIS сюда не входят: их поток слишком плотный для доставки запросами.
+var WebhookEvents = []string{"geofence", "collision_alert", "route_deviation", "anomaly", "ice_warning"}
+
+var (
+	ErrWebhookNotFound = errors.New("webhook not found")
+	ErrInvalidWebhook  = errors.New("invalid webhook")
+)
+
+// Webhook - адрес, на который отправляются события живого канала
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`              // Типы событий из WebhookEvents: "geofence", "collision_alert", ...
+	Geofences []string  `json:"geofences,omitempty"` // Только события этих геозон; пусто - всех
+	Secret    string    `json:"secret,omitempty"`    // Ключ подписи HMAC-SHA256 тела запроса
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// matches сообщает, нужно ли отправлять событие на вебхук
+func (w *Webhook) matches(event LiveEvent) bool {
+	if !slices.Contains(w.Events, event.Type) {
+		return false
+	}
+	if fence, ok := event.Data.(GeofenceEvent); ok && len(w.Geofences) > 0 {
+		return slices.Contains(w.Geofences, fence.GeofenceID)
+	}
+	return true
+}
+
+// WebhookDispatcher - рассылка событий живого канала по вебхукам.
+// Тело запроса - событие {"type": ..., "data": ...}; при заданном секрете
+// подпись передается в заголовке X-Signature-256 как "sha256=<hex>".
+// Подходящие события ставятся в собственную очередь доставки, которая не теряет их
+// при медленных получателях; доставляют webhookConcurrency обработчиков. У каждого
+// вебхука в очереди не больше queueLimit событий: для недоступного получателя
+// отбрасываются самые старые.
+type WebhookDispatcher struct {
+	path   string
+	client *http.Client
+	stop   func() // Отписка от живого канала
+
+	mu    sync.RWMutex
+	hooks map[string]Webhook
+
+	qmu        sync.Mutex
+	queued     *sync.Cond
+	queue      []webhookDelivery
+	pending    map[string]int // ID вебхука -> событий в очереди
+	queueLimit int
+	closed     bool
+}
+
+// webhookDelivery - событие, ожидающее отправки на вебхук
+type webhookDelivery struct {
+	hook Webhook
+	body []byte
+}
+
+// NewWebhookDispatcher загружает вебхуки из path и начинает рассылку событий hub
+func NewWebhookDispatcher(hub *LiveHub, path string) (*WebhookDispatcher, error) {
+	var hooks []Webhook
+	if err := loadJSON(path, &hooks); err != nil {
+		return nil, fmt.Errorf("load webhooks: %w", err)
+	}
+
+	d := &WebhookDispatcher{
+		path:       path,
+		client:     &http.Client{Timeout: webhookTimeout},
+		hooks:      make(map[string]Webhook),
+		pending:    make(map[string]int),
+		queueLimit: webhookQueueLimit,
+	}
+	for _, h := range hooks {
+		d.hooks[h.ID] = h
+	}
+
+	d.queued = sync.NewCond(&d.qmu)
+	for i := 0; i < webhookConcurrency; i++ {
+		go d.work()
+	}
+	d.stop = hub.Listen(d.enqueue)
+	return d, nil
+}
+
+// Close отписывается от живого канала; уже поставленные в очередь события доставляются
+func (d *WebhookDispatcher) Close() {
+	d.stop()
+	d.qmu.Lock()
+	d.closed = true
+	d.qmu.Unlock()
+	d.queued.Broadcast()
+}
+
+// enqueue ставит событие в очередь для подходящих вебхуков; вызывается при публикации
+// и не блокируется доставкой. События без подписанных вебхуков (например, позиции) отбрасываются сразу.
+func (d *WebhookDispatcher) enqueue(event LiveEvent) {
+	d.mu.RLock()
+	var targets []Webhook
+	for _, h := range d.hooks {
+		if h.matches(event) {
+			targets = append(targets, h)
+		}
+	}
+	d.mu.RUnlock()
+
+	if len(targets) == 0 {
+		return
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Println("Webhook marshal error:", err)
+		return
+	}
+
+	d.qmu.Lock()
+	for _, h := range targets {
+		if d.pending[h.ID] >= d.queueLimit {
+			// Получатель не успевает: отбрасываем самое старое событие этого вебхука
+			i := slices.IndexFunc(d.queue, func(q webhookDelivery) bool { return q.hook.ID == h.ID })
+			d.queue = slices.Delete(d.queue, i, i+1)
+			d.pending[h.ID]--
+			log.Printf("Webhook %s queue is full (%d events), dropping the oldest", h.ID, d.queueLimit)
+		}
+		d.queue = append(d.queue, webhookDelivery{hook: h, body: body})
+		d.pending[h.ID]++
+	}
+	d.qmu.Unlock()
+	d.queued.Broadcast()
+}
+
+// work доставляет события из очереди, пока рассылка не закрыта и очередь не пуста
+func (d *WebhookDispatcher) work() {
+	for {
+		d.qmu.Lock()
+		for len(d.queue) == 0 && !d.closed {
+			d.queued.Wait()
+		}
+		if len(d.queue) == 0 {
+			d.qmu.Unlock()
+			return
+		}
+		next := d.queue[0]
+		d.queue[0] = webhookDelivery{}
+		d.queue = d.queue[1:]
+		if d.pending[next.hook.ID]--; d.pending[next.hook.ID] == 0 {
+			delete(d.pending, next.hook.ID)
+		}
+		d.qmu.Unlock()
+
+		if err := d.deliver(next.hook, next.body); err != nil {
+			log.Printf("Webhook %s delivery failed: %v", next.hook.ID, err)
+		}
+	}
+}
+
+// Pending возвращает число событий, ожидающих доставки
+func (d *WebhookDispatcher) Pending() int {
+	d.qmu.Lock()
+	defer d.qmu.Unlock()
+	return len(d.queue)
+}
+
+// deliver отправляет тело на вебхук с повторами при ошибках сети и ответах 5xx
+func (d *WebhookDispatcher) deliver(h Webhook, body []byte) error {
+	var lastErr error
+	for attempt := 0; attempt < webhookAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(1<<(attempt-1)) * time.Second)
+		}
+
+		req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if h.Secret != "" {
+			mac := hmac.New(sha256.New, []byte(h.Secret))
+			mac.Write(body)
+			req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		}
+
+		resp, err := d.client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("status %d", resp.StatusCode)
+		if resp.StatusCode < 500 {
+			return lastErr // Ошибка клиента - повтор не поможет
+		}
+	}
+	return lastErr
+}
+
+// List возвращает вебхуки без секретов
+func (d *WebhookDispatcher) List() []Webhook {
+	d.mu.RLock()
+	hooks := make([]Webhook, 0, len(d.hooks))
+	for _, h := range d.hooks {
+		h.Secret = ""
+		hooks = append(hooks, h)
+	}
+	d.mu.RUnlock()
+
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].CreatedAt.Before(hooks[j].CreatedAt) })
+	return hooks
+}
+
+// Create добавляет вебхук
+func (d *WebhookDispatcher) Create(h Webhook) (Webhook, error) {
+	u, err := url.Parse(h.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Webhook{}, fmt.Errorf("%w: url must be an absolute http(s) url", ErrInvalidWebhook)
+	}
+	if len(h.Events) == 0 {
+		return Webhook{}, fmt.Errorf("%w: at least one event type is required", ErrInvalidWebhook)
+	}
+	for _, event := range h.Events {
+		if !slices.Contains(WebhookEvents, event) {
+			return Webhook{}, fmt.Errorf("%w: unknown event type %q; known types: %s",
+				ErrInvalidWebhook, event, strings.Join(WebhookEvents, ", "))
+		}
+	}
+	id, err := newID()
+	if err != nil {
+		return Webhook{}, err
+	}
+	h.ID = id
+	h.CreatedAt = time.Now().UTC()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hooks[id] = h
+	if err := d.save(); err != nil {
+		delete(d.hooks, id)
+		return Webhook{}, err
+	}
+	h.Secret = ""
+	return h, nil
+}
+
+// Delete удаляет вебхук
+func (d *WebhookDispatcher) Delete(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	prev, ok := d.hooks[id]
+	if !ok {
+		return ErrWebhookNotFound
+	}
+	delete(d.hooks, id)
+	if err := d.save(); err != nil {
+		d.hooks[id] = prev
+		return err
+	}
+	return nil
+}
+
+// save записывает вебхуки на диск; вызывается под блокировкой
+func (d *WebhookDispatcher) save() error {
+	hooks := make([]Webhook, 0, len(d.hooks))
+	for _, h := range d.hooks {
+		hooks = append(hooks, h)
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].ID < hooks[j].ID })
+	return saveJSON(d.path, hooks)
+}
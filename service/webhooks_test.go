@@ -0,0 +1,171 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/s3nkyh/arcticeroute/models"
+)
+
+func TestWebhookDispatcherDeliversEveryMatchingEvent(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[int64]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond) // Медленный получатель
+		var event struct {
+			Type string        `json:"type"`
+			Data GeofenceEvent `json:"data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil || event.Type != "geofence" {
+			t.Errorf("unexpected delivery: %v %q", err, event.Type)
+		}
+		mu.Lock()
+		received[event.Data.ID] = true
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	hub := NewLiveHub()
+	d, err := NewWebhookDispatcher(hub, filepath.Join(t.TempDir(), "webhooks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if _, err := d.Create(Webhook{URL: server.URL, Events: []string{"geofence"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Поток позиций в тот же канал не должен вытеснять события геозон
+	const fences = 100
+	for i := 0; i < fences; i++ {
+		for j := 0; j < 50; j++ {
+			hub.Publish("position", models.Ship{MMSI: int32(j)})
+		}
+		hub.Publish("geofence", GeofenceEvent{ID: int64(i), GeofenceID: "g1", Type: "enter"})
+	}
+	if pending := d.Pending(); pending > fences {
+		t.Fatalf("%d deliveries queued, want at most %d: positions must be filtered out", pending, fences)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if n == fences {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivered %d of %d geofence events", n, fences)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebhookMatches(t *testing.T) {
+	fence := LiveEvent{Type: "geofence", Data: GeofenceEvent{GeofenceID: "g1"}}
+	tests := []struct {
+		name  string
+		hook  Webhook
+		event LiveEvent
+		want  bool
+	}{
+		{"event type subscribed", Webhook{Events: []string{"geofence"}}, fence, true},
+		{"event type not subscribed", Webhook{Events: []string{"collision_alert"}}, fence, false},
+		{"geofence listed", Webhook{Events: []string{"geofence"}, Geofences: []string{"g0", "g1"}}, fence, true},
+		{"geofence not listed", Webhook{Events: []string{"geofence"}, Geofences: []string{"g2"}}, fence, false},
+		{"positions ignored", Webhook{Events: []string{"geofence"}}, LiveEvent{Type: "position", Data: models.Ship{}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hook.matches(tt.event); got != tt.want {
+				t.Errorf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookQueueDropsOldest(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	received := make(map[int64]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release // Получатель не отвечает, пока его не отпустят
+		var event struct {
+			Data GeofenceEvent `json:"data"`
+		}
+		json.NewDecoder(r.Body).Decode(&event)
+		mu.Lock()
+		received[event.Data.ID] = true
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	hub := NewLiveHub()
+	d, err := NewWebhookDispatcher(hub, filepath.Join(t.TempDir(), "webhooks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	d.queueLimit = 10
+	if _, err := d.Create(Webhook{URL: server.URL, Events: []string{"geofence"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	const events = 100
+	for i := 0; i < events; i++ {
+		hub.Publish("geofence", GeofenceEvent{ID: int64(i), GeofenceID: "g1", Type: "enter"})
+	}
+	if pending := d.Pending(); pending > d.queueLimit {
+		t.Errorf("%d deliveries queued, want at most %d", pending, d.queueLimit)
+	}
+	close(release)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for d.Pending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	for i := events - d.queueLimit; i < events; i++ {
+		if !received[int64(i)] {
+			t.Errorf("newest event %d was not delivered", i)
+		}
+	}
+	if len(received) > webhookConcurrency+d.queueLimit {
+		t.Errorf("delivered %d events, want at most %d", len(received), webhookConcurrency+d.queueLimit)
+	}
+}
+
+func TestWebhookCreateValidatesEvents(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []string
+		ok     bool
+	}{
+		{"known types", []string{"geofence", "collision_alert"}, true},
+		{"no types", nil, false},
+		{"unknown type", []string{"geofence", "geofence_enter"}, false},
+		{"positions", []string{"position"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewWebhookDispatcher(NewLiveHub(), filepath.Join(t.TempDir(), "webhooks.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.Close()
+			_, err = d.Create(Webhook{URL: "https://example.org/hook", Events: tt.events})
+			if tt.ok != (err == nil) || (err != nil && !errors.Is(err, ErrInvalidWebhook)) {
+				t.Errorf("Create(%v) = %v", tt.events, err)
+			}
+		})
+	}
+}
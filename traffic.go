@@ -0,0 +1,95 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/s3nkyh/arcticeroute/api"
+	"github.com/s3nkyh/arcticeroute/models"
+	"github.com/s3nkyh/arcticeroute/service"
+)
+
+// loadTrafficHistory прогоняет записи AIS через агрегат плотности движения
+func loadTrafficHistory(paths []string) {
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		src, err := api.OpenReplay(path, 0)
+		if err != nil {
+			log.Println("Traffic history error:", err)
+			continue
+		}
+
+		// Тип судна известен только из статических сообщений той же записи
+		shipTypes := make(map[int32]int32)
+		count := 0
+		err = api.Ingest(src, api.IngestHandler{
+			Position: func(ship models.Ship) bool {
+				ship.ShipType = shipTypes[ship.MMSI]
+				trafficDensity.Add(ship)
+				count++
+				return true
+			},
+			Static: func(static models.ShipStatic) bool {
+				if static.ShipType != 0 {
+					shipTypes[static.MMSI] = static.ShipType
+				}
+				return true
+			},
+		})
+		src.Close()
+
+		if err != nil {
+			log.Printf("Traffic history %s: %v", path, err)
+		}
+		log.Printf("Traffic history %s: %d positions", path, count)
+	}
+	diversionPlanner.RefreshTrafficWeights()
+}
+
+// refreshTrafficWeights пересчитывает веса ребер сети по накопленной плотности движения
+// раз в TRAFFIC_WEIGHT_MIN минут (по умолчанию 60)
+func refreshTrafficWeights() {
+	interval := time.Duration(envFloat("TRAFFIC_WEIGHT_MIN", 60) * float64(time.Minute))
+	for {
+		time.Sleep(interval)
+		diversionPlanner.RefreshTrafficWeights()
+	}
+}
+
+func getHeatmap(c *gin.Context) {
+	q := service.TrafficQuery{
+		Window:   c.Query("window"),
+		Category: c.Query("category"),
+	}
+	if v := c.Query("level"); v != "" {
+		level, err := strconv.Atoi(v)
+		if err != nil || level < 0 || level > 30 {
+			c.JSON(400, gin.H{"error": "invalid level"})
+			return
+		}
+		q.Level = level
+	}
+
+	bbox, ok := queryBBox(c, nil)
+	if !ok {
+		return
+	}
+	q.BBox = bbox
+
+	if c.DefaultQuery("format", "geojson") == "cells" {
+		c.JSON(200, trafficDensity.Query(q))
+		return
+	}
+	c.JSON(200, trafficDensity.GeoJSON(q))
+}
+
+func getHeatmapWindows(c *gin.Context) {
+	c.JSON(200, trafficDensity.Windows())
+}
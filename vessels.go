@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/s3nkyh/arcticeroute/models"
+	"github.com/s3nkyh/arcticeroute/service"
+)
+
+func getVessels(c *gin.Context) {
+	if v := c.Query("imo"); v != "" {
+		imo, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "invalid imo"})
+			return
+		}
+		vessel, found := vesselRegistry.ByIMO(int32(imo))
+		if !found {
+			c.JSON(200, []models.VesselProfile{})
+			return
+		}
+		c.JSON(200, []models.VesselProfile{vessel.Profile()})
+		return
+	}
+
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			c.JSON(400, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = parsed
+	}
+	c.JSON(200, vesselRegistry.Search(c.Query("q"), limit))
+}
+
+func getVessel(c *gin.Context) {
+	mmsi, ok := paramMMSI(c)
+	if !ok {
+		return
+	}
+	vessel, found := vesselRegistry.Get(mmsi)
+	if !found {
+		c.JSON(404, gin.H{"error": "vessel not found"})
+		return
+	}
+	c.JSON(200, gin.H{"vessel": vessel, "profile": vessel.Profile()})
+}
+
+func updateVesselOverrides(c *gin.Context) {
+	mmsi, ok := paramMMSI(c)
+	if !ok {
+		return
+	}
+	var overrides models.VesselOverrides
+	if err := c.ShouldBindJSON(&overrides); err != nil {
+		c.JSON(400, gin.H{"error": "invalid overrides: " + err.Error()})
+		return
+	}
+
+	vessel, err := vesselRegistry.SetOverrides(mmsi, overrides)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidVessel) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"vessel": vessel, "profile": vessel.Profile()})
+}